@@ -0,0 +1,61 @@
+package imagehashgo
+
+import "fmt"
+
+// AlgorithmParam describes an integer parameter of an algorithm, named as
+// in Python imagehash
+type AlgorithmParam struct {
+	Name    string
+	Default int
+	// Min and Max are the accepted range, inclusive; Max 0 is unbounded
+	Min, Max int
+}
+
+// AlgorithmInfo describes a built-in algorithm, for frontends that offer
+// the choice of algorithm and parameters at run time
+type AlgorithmInfo struct {
+	Kind HashKind
+	// Name is a human-readable name, e.g. "Perceptual hash"
+	Name string
+	// Params lists the parameters Hash and NewHasher take, or that the
+	// algorithm function takes beyond them
+	Params []AlgorithmParam
+	// PythonFunc is the Python imagehash function giving the same bits
+	// with the default options, or "" when there is none
+	PythonFunc string
+}
+
+// hashSizeParam is the hash_size parameter every built-in algorithm takes
+var hashSizeParam = AlgorithmParam{Name: "hash_size", Default: 8, Min: 2, Max: MaxHashSize}
+
+// Algorithms describes the built-in algorithms, in the order of the
+// HashKind constants. The result is a fresh copy the caller may modify.
+func Algorithms() []AlgorithmInfo {
+	return []AlgorithmInfo{
+		{Kind: KindAverage, Name: "Average hash", Params: []AlgorithmParam{hashSizeParam}, PythonFunc: "average_hash"},
+		{Kind: KindPerceptual, Name: "Perceptual hash", Params: []AlgorithmParam{
+			hashSizeParam,
+			// PerceptualHash replaces factors below 1 with 4; the DCT input
+			// is hash_size*highfreq_factor pixels wide
+			{Name: "highfreq_factor", Default: 4, Min: 1},
+		}, PythonFunc: "phash"},
+		{Kind: KindDifference, Name: "Difference hash", Params: []AlgorithmParam{hashSizeParam}, PythonFunc: "dhash"},
+		{Kind: KindDifferenceVertical, Name: "Vertical difference hash", Params: []AlgorithmParam{hashSizeParam}, PythonFunc: "dhash_vertical"},
+	}
+}
+
+// Bits returns the number of bits the algorithm produces for hashSize, or
+// an error when hashSize is outside the range of its hash_size parameter.
+// WithAspectBuckets changes the shape of the average and difference
+// hashes, and so their bit count; Bits gives it without that option.
+func (a AlgorithmInfo) Bits(hashSize int) (int, error) {
+	for _, p := range a.Params {
+		if p.Name == hashSizeParam.Name {
+			if hashSize < p.Min || (p.Max != 0 && hashSize > p.Max) {
+				return 0, fmt.Errorf("%s hash size must be from %d to %d, got %d", a.Kind, p.Min, p.Max, hashSize)
+			}
+			return hashSize * hashSize, nil
+		}
+	}
+	return 0, fmt.Errorf("%s has no hash size", a.Kind)
+}
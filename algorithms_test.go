@@ -0,0 +1,62 @@
+package imagehashgo
+
+import (
+	"image"
+	"slices"
+	"testing"
+)
+
+func TestAlgorithms_Builtins(t *testing.T) {
+	algorithms := Algorithms()
+	var kinds []HashKind
+	img := image.NewGray(image.Rect(0, 0, 40, 30))
+	for _, a := range algorithms {
+		kinds = append(kinds, a.Kind)
+		if _, err := ParseHashKind(string(a.Kind)); err != nil || a.Name == "" {
+			t.Errorf("%+v: %v", a, err)
+		}
+		if len(a.Params) == 0 || a.Params[0].Name != "hash_size" {
+			t.Fatalf("%s: first parameter is not hash_size: %+v", a.Kind, a.Params)
+		}
+		size := a.Params[0]
+
+		// The declared range is what NewHasher accepts, and Bits what Hash
+		// produces
+		for _, hashSize := range []int{size.Min, size.Default, 13, size.Max} {
+			bits, err := a.Bits(hashSize)
+			if err != nil {
+				t.Fatalf("%s: Bits(%d) error = %v", a.Kind, hashSize, err)
+			}
+			h, err := Hash(img, a.Kind, hashSize)
+			if err != nil || h.rows*h.cols != bits {
+				t.Errorf("%s: Bits(%d) = %d, Hash gives %v (%v)", a.Kind, hashSize, bits, h, err)
+			}
+			if _, err := NewHasher(a.Kind, hashSize); err != nil {
+				t.Errorf("%s: NewHasher(%d) error = %v", a.Kind, hashSize, err)
+			}
+		}
+		for _, hashSize := range []int{size.Min - 1, size.Max + 1} {
+			if _, err := a.Bits(hashSize); err == nil {
+				t.Errorf("%s: Bits(%d) expected error", a.Kind, hashSize)
+			}
+			if _, err := NewHasher(a.Kind, hashSize); err == nil {
+				t.Errorf("%s: NewHasher(%d) expected error", a.Kind, hashSize)
+			}
+		}
+
+		if a.PythonFunc != "" {
+			if _, _, kind, err := pythonShape(a.PythonFunc, size.Default); err != nil || kind != a.Kind {
+				t.Errorf("%s: Python function %q gives kind %q, %v", a.Kind, a.PythonFunc, kind, err)
+			}
+		}
+	}
+	if want := []HashKind{KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical}; !slices.Equal(kinds, want) {
+		t.Errorf("Algorithms() kinds = %v, want %v", kinds, want)
+	}
+
+	// Every call returns a fresh copy
+	algorithms[0].Params[0].Default = 99
+	if Algorithms()[0].Params[0].Default != 8 {
+		t.Error("modifying the result changed the built-in description")
+	}
+}
@@ -0,0 +1,73 @@
+package imagehashgo
+
+import "image"
+
+// aspectBuckets are the grids of WithAspectBuckets, by increasing ratio:
+// an image whose long side is at least minRatio times its short side gets
+// stretch times more cells along the long side and stretch times fewer
+// along the short one. The ratios are the geometric midpoints between the
+// cell aspects of neighbouring buckets (1, 4 and 16), so every cell covers
+// a region between half and twice as long as it is wide.
+var aspectBuckets = []struct {
+	minRatio float64
+	stretch  int
+}{
+	{1, 1},
+	{2, 2},
+	{8, 4},
+}
+
+// AspectGrid returns the rows and columns of the hashSize*hashSize cells
+// WithAspectBuckets lays over a width x height image:
+//
+//	long/short ratio   grid for hashSize 8 (landscape; portrait transposed)
+//	below 2            8x8
+//	2 to 8             16 columns x 4 rows
+//	8 and above        32 columns x 2 rows
+//
+// A bucket is skipped when its stretch does not divide hashSize, so odd
+// sizes are always square, as are empty images.
+func AspectGrid(width, height, hashSize int) (rows, cols int) {
+	if width <= 0 || height <= 0 {
+		return hashSize, hashSize
+	}
+	long, short := max(width, height), min(width, height)
+	ratio := float64(long) / float64(short)
+
+	stretch := 1
+	for _, b := range aspectBuckets {
+		if ratio >= b.minRatio && hashSize%b.stretch == 0 {
+			stretch = b.stretch
+		}
+	}
+	if width >= height {
+		return hashSize / stretch, hashSize * stretch
+	}
+	return hashSize * stretch, hashSize / stretch
+}
+
+// WithAspectBuckets makes AverageHash and the DifferenceHash variants keep
+// the aspect ratio of panoramas and tall images: instead of squeezing the
+// image into a square grid, which leaves a 20000x900 panorama 8 cells to
+// describe its whole width, the same number of cells is laid out as
+// AspectGrid chooses, e.g. 32x2 for hashSize 8. The grid is recorded in the
+// hash shape, so hashes of different buckets are never compared (Distance
+// returns an error); store them by shape, as with AutoHash.
+//
+// Images with a ratio below 2 hash exactly as without the option. Hashes
+// of TriDifferenceHash have two columns per cell, as usual. PerceptualHash
+// ignores this option.
+func WithAspectBuckets() Option {
+	return func(o *options) {
+		o.aspect = true
+	}
+}
+
+// grid returns the rows and columns of the cells of a hashSize hash of img
+func (o options) grid(img image.Image, hashSize int) (rows, cols int) {
+	if !o.aspect {
+		return hashSize, hashSize
+	}
+	b := img.Bounds()
+	return AspectGrid(b.Dx(), b.Dy(), hashSize)
+}
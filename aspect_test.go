@@ -0,0 +1,188 @@
+package imagehashgo
+
+import (
+	"image"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// panorama returns a deterministic w x h skyline: a few cosines along the
+// width, whose phases depend on seed, over a vertical gradient
+func panorama(w, h int, seed uint64) *image.Gray {
+	rng := rand.New(rand.NewPCG(seed, 964))
+	type wave struct{ freq, phase, amp float64 }
+	waves := make([]wave, 4)
+	for i := range waves {
+		waves[i] = wave{float64(3 + 4*i), rng.Float64() * 2 * math.Pi, 40 / float64(i+1)}
+	}
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for x := range w {
+		u := float64(x) / float64(w)
+		skyline := 0.5
+		for _, wv := range waves {
+			skyline += wv.amp / 200 * math.Cos(2*math.Pi*wv.freq*u+wv.phase)
+		}
+		for y := range h {
+			v := float64(y) / float64(h)
+			p := 60 + 90*v + 25*math.Sin(5*math.Pi*u)
+			if v > skyline {
+				p = 200 - 60*v
+			}
+			img.Pix[y*img.Stride+x] = uint8(p)
+		}
+	}
+	return img
+}
+
+func TestAspectGrid(t *testing.T) {
+	tests := []struct {
+		w, h, size int
+		rows, cols int
+	}{
+		{100, 100, 8, 8, 8},
+		{199, 100, 8, 8, 8},
+		{200, 100, 8, 4, 16},
+		{100, 200, 8, 16, 4},
+		{799, 100, 8, 4, 16},
+		{800, 100, 8, 2, 32},
+		{20000, 900, 8, 2, 32},
+		{900, 20000, 16, 64, 4},
+		{20000, 900, 2, 1, 4},
+		{20000, 900, 6, 3, 12},
+		{20000, 900, 9, 9, 9},
+		{0, 900, 8, 8, 8},
+	}
+	for _, tt := range tests {
+		rows, cols := AspectGrid(tt.w, tt.h, tt.size)
+		if rows != tt.rows || cols != tt.cols {
+			t.Errorf("AspectGrid(%d, %d, %d) = %dx%d, want %dx%d", tt.w, tt.h, tt.size, rows, cols, tt.rows, tt.cols)
+		}
+	}
+}
+
+// aspectHashes are the hashes WithAspectBuckets changes
+var aspectHashes = map[string]func(image.Image, ...Option) *ImageHash{
+	"average":    func(img image.Image, opts ...Option) *ImageHash { return AverageHash(img, 8, opts...) },
+	"difference": func(img image.Image, opts ...Option) *ImageHash { return DifferenceHash(img, 8, opts...) },
+	"vertical":   func(img image.Image, opts ...Option) *ImageHash { return DifferenceHashVertical(img, 8, opts...) },
+	"tri":        func(img image.Image, opts ...Option) *ImageHash { return TriDifferenceHash(img, 8, 4, opts...) },
+}
+
+func TestWithAspectBuckets_Golden(t *testing.T) {
+	golden := map[string]struct {
+		rows, cols int
+		hex        string
+	}{
+		"average":    {2, 32, "0400900cfff7faff"},
+		"difference": {2, 32, "ca5f21f89a4f33b8"},
+		"vertical":   {2, 32, "fffffffff8fe4fe3"},
+		"tri":        {2, 64, "a09964aa5852aa95828165a200508201"},
+	}
+	img := panorama(4000, 180, 1)
+	for name, hash := range aspectHashes {
+		h := hash(img, WithAspectBuckets())
+		want := golden[name]
+		if h.rows != want.rows || h.cols != want.cols || h.ToString() != want.hex {
+			t.Errorf("%s = %dx%d %s, want %dx%d %s", name, h.rows, h.cols, h.ToString(), want.rows, want.cols, want.hex)
+		}
+	}
+}
+
+// TestWithAspectBuckets_Square checks that images below ratio 2 hash as
+// without the option
+func TestWithAspectBuckets_Square(t *testing.T) {
+	for _, img := range []image.Image{getBenchImage(), panorama(390, 200, 2), panorama(200, 390, 3)} {
+		for name, hash := range aspectHashes {
+			if got, want := hash(img, WithAspectBuckets()).ToString(), hash(img).ToString(); got != want {
+				t.Errorf("%s of %v: %s, want %s", name, img.Bounds(), got, want)
+			}
+		}
+	}
+}
+
+// TestWithAspectBuckets_CropJitter measures the distance between a panorama
+// and its 1-pixel crops, with and without buckets. resizeGray scales its
+// Lanczos kernel with the image, so a crop moves every sample by a small
+// fraction of a cell and neither grid explodes; the square dHash still
+// flips the most bits, as its 8 columns each compare samples 750 pixels
+// apart over a smooth skyline.
+func TestWithAspectBuckets_CropJitter(t *testing.T) {
+	const w, h = 6000, 300
+	img := panorama(w, h, 4)
+	crops := []image.Rectangle{
+		image.Rect(1, 0, w, h),
+		image.Rect(0, 0, w-1, h),
+		image.Rect(0, 1, w, h),
+		image.Rect(0, 0, w, h-1),
+		image.Rect(1, 1, w-1, h-1),
+	}
+	for name, hash := range aspectHashes {
+		for _, opts := range [][]Option{nil, {WithAspectBuckets()}} {
+			base := hash(img, opts...)
+			worst := 0
+			for _, r := range crops {
+				d, err := base.Distance(hash(img.SubImage(r), opts...))
+				if err != nil {
+					t.Fatal(err)
+				}
+				worst = max(worst, d)
+			}
+			t.Logf("%s %dx%d: worst 1-pixel crop distance %d", name, base.rows, base.cols, worst)
+			if opts != nil && worst > 3 {
+				t.Errorf("%s %dx%d: 1-pixel crop moved the hash by %d bits", name, base.rows, base.cols, worst)
+			}
+		}
+	}
+}
+
+// TestWithAspectBuckets_Detail checks what the square grid loses: a change
+// over 3% of the width of a panorama is within one of 8 columns, but
+// spans a whole one of 32.
+func TestWithAspectBuckets_Detail(t *testing.T) {
+	const w, h = 6000, 300
+	img := panorama(w, h, 5)
+	edited := image.NewGray(img.Rect)
+	copy(edited.Pix, img.Pix)
+	for y := range h {
+		for x := 3000; x < 3180; x++ {
+			edited.Pix[y*edited.Stride+x] = 255 - edited.Pix[y*edited.Stride+x]
+		}
+	}
+	square, err := AverageHash(img, 8).Distance(AverageHash(edited, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucketed, err := AverageHash(img, 8, WithAspectBuckets()).Distance(AverageHash(edited, 8, WithAspectBuckets()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("inverted 3%% strip: %d bits at 8x8, %d bits at 2x32", square, bucketed)
+	if bucketed <= square {
+		t.Errorf("2x32 grid saw %d bits of the edit, the 8x8 grid %d", bucketed, square)
+	}
+}
+
+func TestWithAspectBuckets_Buckets(t *testing.T) {
+	wide := DifferenceHash(panorama(4000, 180, 6), 8, WithAspectBuckets())
+	medium := DifferenceHash(panorama(1200, 300, 6), 8, WithAspectBuckets())
+	if medium.rows != 4 || medium.cols != 16 {
+		t.Fatalf("1200x300 shape %dx%d, want 4x16", medium.rows, medium.cols)
+	}
+	if _, err := wide.Distance(medium); err == nil {
+		t.Error("hashes of different buckets compared without error")
+	}
+
+	// The option is part of the Hasher fingerprint
+	plain, err := NewHasher(KindDifference, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aspect, err := NewHasher(KindDifference, 8, WithAspectBuckets())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain.Fingerprint() == aspect.Fingerprint() {
+		t.Error("WithAspectBuckets does not change the Hasher fingerprint")
+	}
+}
@@ -0,0 +1,70 @@
+package imagehashgo
+
+import (
+	"errors"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+var (
+	// AutoHashEntropyThreshold is the grayscale histogram entropy (in bits)
+	// below which AutoHash considers an image flat and uses AutoHashFlatSize
+	AutoHashEntropyThreshold = 3.0
+	// AutoHashDefaultSize is the hashSize AutoHash uses for detailed images
+	AutoHashDefaultSize = 8
+	// AutoHashFlatSize is the hashSize AutoHash uses for flat images
+	AutoHashFlatSize = 16
+)
+
+// autoHashSampleSize is the side of the thumbnail used to estimate entropy
+const autoHashSampleSize = 32
+
+// AutoHash computes a hash of the given kind, choosing hashSize from the
+// image content: flat images such as logos on a white background carry
+// little information in 64 bits, so they get AutoHashFlatSize instead of
+// AutoHashDefaultSize. The chosen size is recorded in the hash shape.
+//
+// Hashes of different sizes cannot be compared (Distance returns an error),
+// so callers storing AutoHash results must bucket them by shape.
+func AutoHash(img image.Image, kind HashKind) (*ImageHash, error) {
+	if img.Bounds().Empty() {
+		return nil, errors.New("cannot hash an empty image")
+	}
+	return Hash(img, kind, AutoHashSize(img))
+}
+
+// AutoHashSize returns the hashSize AutoHash chooses for img. To compare
+// two images, hash both with the larger of their sizes: a flat and a
+// detailed copy of the same content then stay comparable.
+func AutoHashSize(img image.Image) int {
+	if grayEntropy(img) < AutoHashEntropyThreshold {
+		return AutoHashFlatSize
+	}
+	return AutoHashDefaultSize
+}
+
+// grayEntropy returns the Shannon entropy of the grayscale histogram of a
+// small downsample of img
+func grayEntropy(img image.Image) float64 {
+	gray := ToGrayscaleFast(img)
+	sample := nrgbaGrayExtract(imaging.Resize(gray, autoHashSampleSize, autoHashSampleSize, imaging.Box))
+
+	var hist [256]int
+	for y := range autoHashSampleSize {
+		for _, p := range sample.Pix[y*sample.Stride : y*sample.Stride+autoHashSampleSize] {
+			hist[p]++
+		}
+	}
+
+	total := float64(autoHashSampleSize * autoHashSampleSize)
+	var entropy float64
+	for _, n := range hist {
+		if n > 0 {
+			p := float64(n) / total
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
@@ -0,0 +1,51 @@
+package imagehashgo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestAutoHash_ChoosesSizeByEntropy(t *testing.T) {
+	// A flat logo: black square on white
+	logo := image.NewGray(image.Rect(0, 0, 200, 200))
+	draw.Draw(logo, logo.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(logo, image.Rect(60, 60, 140, 140), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+
+	photo := getBenchImage()
+	if AutoHashSize(logo) != AutoHashFlatSize || AutoHashSize(photo) != AutoHashDefaultSize {
+		t.Errorf("AutoHashSize = %d, %d", AutoHashSize(logo), AutoHashSize(photo))
+	}
+
+	for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical} {
+		t.Run(string(kind), func(t *testing.T) {
+			flat, err := AutoHash(logo, kind)
+			if err != nil {
+				t.Fatalf("AutoHash(logo) error = %v", err)
+			}
+			if flat.rows != AutoHashFlatSize || len(flat.hash) != AutoHashFlatSize*AutoHashFlatSize {
+				t.Errorf("logo: got %dx%d, want %dx%d", flat.rows, flat.cols, AutoHashFlatSize, AutoHashFlatSize)
+			}
+
+			busy, err := AutoHash(photo, kind)
+			if err != nil {
+				t.Fatalf("AutoHash(photo) error = %v", err)
+			}
+			if busy.rows != AutoHashDefaultSize || len(busy.hash) != AutoHashDefaultSize*AutoHashDefaultSize {
+				t.Errorf("photo: got %dx%d, want %dx%d", busy.rows, busy.cols, AutoHashDefaultSize, AutoHashDefaultSize)
+			}
+			if busy.Kind() != kind {
+				t.Errorf("Kind() = %q, want %q", busy.Kind(), kind)
+			}
+
+			if _, err := flat.Distance(busy); err == nil {
+				t.Error("Distance() between different shapes expected error")
+			}
+		})
+	}
+
+	if _, err := AutoHash(photo, "whash"); err == nil {
+		t.Error("AutoHash() with unknown kind expected error")
+	}
+}
@@ -0,0 +1,90 @@
+package imagehashgo
+
+// Match is a candidate returned by NearestN
+type Match struct {
+	// Index of the candidate in the slice passed to NearestN
+	Index    int
+	Distance int
+}
+
+// NearestN returns the n candidates closest to query, ordered by distance
+// and then by index. Nil candidates and candidates whose shape differs from
+// query are skipped.
+func NearestN(query *ImageHash, candidates []*ImageHash, n int) []Match {
+	return NearestNInto(query, candidates, n, nil)
+}
+
+// NearestNInto is NearestN writing into buf, which is reused when its
+// capacity is at least n. The prior contents of buf are overwritten and the
+// returned slice shares its backing array, so keep buf for the next call.
+func NearestNInto(query *ImageHash, candidates []*ImageHash, n int, buf []Match) []Match {
+	n = max(min(n, len(candidates)), 0)
+	if cap(buf) < n {
+		buf = make([]Match, 0, n)
+	}
+	out := buf[:0]
+	for i, c := range candidates {
+		d, ok := hamming(query, c)
+		if !ok {
+			continue
+		}
+		if len(out) == n && (n == 0 || d >= out[n-1].Distance) {
+			continue
+		}
+		// Insert keeping out sorted; equal distances keep index order
+		if len(out) < n {
+			out = append(out, Match{})
+		}
+		j := len(out) - 1
+		for j > 0 && out[j-1].Distance > d {
+			out[j] = out[j-1]
+			j--
+		}
+		out[j] = Match{Index: i, Distance: d}
+	}
+	return out
+}
+
+// DistanceMatrix returns the pairwise distances of hashes as a row-major
+// len(hashes) x len(hashes) matrix. Pairs of different shapes, or with a
+// nil hash, are -1.
+func DistanceMatrix(hashes []*ImageHash) []int {
+	return DistanceMatrixInto(hashes, nil)
+}
+
+// DistanceMatrixInto is DistanceMatrix writing into buf, which is reused
+// when its capacity is at least len(hashes)^2. The prior contents of buf
+// are overwritten and the returned slice shares its backing array.
+func DistanceMatrixInto(hashes []*ImageHash, buf []int) []int {
+	n := len(hashes)
+	if cap(buf) < n*n {
+		buf = make([]int, n*n)
+	}
+	m := buf[:n*n]
+	for i := range n {
+		m[i*n+i] = 0
+		for j := i + 1; j < n; j++ {
+			d, ok := hamming(hashes[i], hashes[j])
+			if !ok {
+				d = -1
+			}
+			m[i*n+j], m[j*n+i] = d, d
+		}
+	}
+	return m
+}
+
+// hamming is Distance without building an error for mismatched shapes, so
+// the batch helpers do not allocate
+func hamming(a, b *ImageHash) (int, bool) {
+	if a == nil || b == nil || a.rows != b.rows || a.cols != b.cols || len(a.hash) != len(b.hash) {
+		return 0, false
+	}
+	dist := 0
+	for i := range a.hash {
+		if a.hash[i] != b.hash[i] {
+			dist++
+		}
+	}
+	return dist, true
+}
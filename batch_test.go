@@ -0,0 +1,112 @@
+package imagehashgo
+
+import (
+	"slices"
+	"testing"
+)
+
+func batchHashes() []*ImageHash {
+	return []*ImageHash{
+		uint64Hash(0x00, 8, 8),
+		uint64Hash(0xff, 8, 8),
+		uint64Hash(0x01, 8, 8),
+		uint64Hash(0x0f, 8, 8),
+		uint64Hash(0x03, 8, 8),
+		uint64Hash(0x1, 4, 4),
+	}
+}
+
+func TestNearestN(t *testing.T) {
+	hashes := batchHashes()
+	got := NearestN(uint64Hash(0, 8, 8), hashes, 3)
+	want := []Match{{0, 0}, {2, 1}, {4, 2}}
+	if !slices.Equal(got, want) {
+		t.Errorf("NearestN = %v, want %v", got, want)
+	}
+
+	// The 4x4 hash is skipped, so asking for everything returns 5 matches
+	if got := NearestN(hashes[0], hashes, 10); len(got) != 5 {
+		t.Errorf("NearestN(10) returned %d matches, want 5", len(got))
+	}
+}
+
+func TestNearestNInto(t *testing.T) {
+	hashes := batchHashes()
+	query := uint64Hash(0, 8, 8)
+
+	buf := make([]Match, 0, 3)
+	buf = append(buf, Match{Index: 99, Distance: 99})
+	got := NearestNInto(query, hashes, 3, buf)
+	if &got[0] != &buf[:1][0] {
+		t.Error("NearestNInto did not reuse a large enough buffer")
+	}
+	if !slices.Equal(got, NearestN(query, hashes, 3)) {
+		t.Errorf("NearestNInto = %v, want %v", got, NearestN(query, hashes, 3))
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = NearestNInto(query, hashes, 3, buf)
+	})
+	if allocs != 0 {
+		t.Errorf("NearestNInto allocated %v times with a large enough buffer", allocs)
+	}
+
+	small := make([]Match, 0, 1)
+	got = NearestNInto(query, hashes, 3, small)
+	if len(got) != 3 || cap(got) < 3 {
+		t.Fatalf("NearestNInto with a small buffer = %v", got)
+	}
+	if !slices.Equal(got, NearestN(query, hashes, 3)) {
+		t.Errorf("NearestNInto reallocated = %v, want %v", got, NearestN(query, hashes, 3))
+	}
+}
+
+func TestDistanceMatrix(t *testing.T) {
+	hashes := batchHashes()
+	n := len(hashes)
+	m := DistanceMatrix(hashes)
+	if len(m) != n*n {
+		t.Fatalf("len = %d, want %d", len(m), n*n)
+	}
+	for i := range n {
+		for j := range n {
+			want, err := hashes[i].Distance(hashes[j])
+			if err != nil {
+				want = -1
+			}
+			if m[i*n+j] != want {
+				t.Errorf("m[%d][%d] = %d, want %d", i, j, m[i*n+j], want)
+			}
+		}
+	}
+}
+
+func TestDistanceMatrixInto(t *testing.T) {
+	hashes := batchHashes()
+	n := len(hashes)
+	want := DistanceMatrix(hashes)
+
+	buf := make([]int, n*n+4)
+	for i := range buf {
+		buf[i] = 42
+	}
+	got := DistanceMatrixInto(hashes, buf)
+	if &got[0] != &buf[0] {
+		t.Error("DistanceMatrixInto did not reuse a large enough buffer")
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("DistanceMatrixInto = %v, want %v", got, want)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = DistanceMatrixInto(hashes, buf)
+	})
+	if allocs != 0 {
+		t.Errorf("DistanceMatrixInto allocated %v times with a large enough buffer", allocs)
+	}
+
+	got = DistanceMatrixInto(hashes, make([]int, 3))
+	if !slices.Equal(got, want) {
+		t.Errorf("DistanceMatrixInto reallocated = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/K0ng2/imagehash-go/internal/bitio"
+)
+
+// ToUint64 packs a 64-bit hash into an integer in the canonical MSB-first
+// order (see ImageHash)
+func (h *ImageHash) ToUint64() (uint64, error) {
+	if len(h.hash) != 64 {
+		return 0, fmt.Errorf("ToUint64 requires a 64-bit hash, got %d bits", len(h.hash))
+	}
+	return bitio.NewBitReader(packBits(h.hash)).ReadUint(64)
+}
+
+// ToUintN packs a hash of exactly bits bits (at most 64) into the low bits
+// of an integer in the canonical MSB-first order, e.g. ToUintN(16) for a
+// 4x4 hash
+func (h *ImageHash) ToUintN(bits int) (uint64, error) {
+	if bits < 1 || bits > 64 {
+		return 0, fmt.Errorf("ToUintN supports 1 to 64 bits, got %d", bits)
+	}
+	if len(h.hash) != bits {
+		return 0, fmt.Errorf("ToUintN(%d) requires a %d-bit hash, got %d bits", bits, bits, len(h.hash))
+	}
+	return bitio.NewBitReader(packBits(h.hash)).ReadUint(bits)
+}
+
+// ToUint64LSB packs a 64-bit hash LSB-first: bit 0 of the result is the
+// top-left cell
+func (h *ImageHash) ToUint64LSB() (uint64, error) {
+	if len(h.hash) != 64 {
+		return 0, fmt.Errorf("ToUint64LSB requires a 64-bit hash, got %d bits", len(h.hash))
+	}
+	return h.ReverseBitOrder().ToUint64()
+}
+
+// FromUint64 unpacks an integer produced by ToUint64 (or Python imagehash)
+// into a hash of the given shape, which must have from 1 to 64 cells
+func FromUint64(v uint64, rows, cols int) (*ImageHash, error) {
+	n, err := checkUint64Shape(rows, cols)
+	if err != nil {
+		return nil, err
+	}
+	var w bitio.BitWriter
+	w.WriteUint(v, n)
+	return &ImageHash{hash: unpackBits(w.Bytes(), n), rows: rows, cols: cols}, nil
+}
+
+// FromUint64LSB unpacks an LSB-first integer, where bit 0 is the top-left
+// cell, into a hash of the given shape, which must have from 1 to 64 cells
+func FromUint64LSB(v uint64, rows, cols int) (*ImageHash, error) {
+	n, err := checkUint64Shape(rows, cols)
+	if err != nil {
+		return nil, err
+	}
+	return FromUint64(bits.Reverse64(v)>>(64-n), rows, cols)
+}
+
+// ReverseBitOrder returns a copy of the hash with the cell order reversed,
+// converting between MSB-first and LSB-first conventions
+func (h *ImageHash) ReverseBitOrder() *ImageHash {
+	n := len(h.hash)
+	hash := make([]bool, n)
+	for i, b := range h.hash {
+		hash[n-1-i] = b
+	}
+	return &ImageHash{hash: hash, rows: h.rows, cols: h.cols, kind: h.kind}
+}
+
+// checkUint64Shape returns the number of cells of a shape that fits in a
+// uint64
+func checkUint64Shape(rows, cols int) (int, error) {
+	if rows <= 0 || cols <= 0 || rows > 64 || cols > 64 || rows*cols > 64 {
+		return 0, fmt.Errorf("shape (%d, %d) does not fit in a uint64", rows, cols)
+	}
+	return rows * cols, nil
+}
@@ -0,0 +1,112 @@
+package imagehashgo
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// bucketSeed seeds the bit positions BucketLabel samples. Changing it
+// changes every label.
+const bucketSeed = 0x9e3779b97f4a7c15
+
+// BucketLabel maps h to one of buckets coarse groups, from 0 to buckets-1,
+// by locality-sensitive bit sampling: the label is built from
+// k = bits.Len(buckets-1) bits of h at positions chosen by a fixed
+// SplitMix64 sequence seeded with 0x9e3779b97f4a7c15, the i-th sampled bit
+// being worth 1<<i, and reduced modulo buckets. The positions depend only on
+// the number of bits of h, so labels are stable across runs and versions,
+// identical hashes always share a label, and two hashes d bits apart out of
+// n share it with probability about (1-d/n)^k.
+//
+// The positions are sampled in the same order for every buckets, so with
+// buckets a power of two, doubling it splits every group in two:
+// BucketLabel(h, n) == BucketLabel(h, 2*n) % n. Other counts are allowed,
+// but their groups are uneven, as 1<<k values are folded into buckets. The
+// label keeps only k bits of h, too few to recover the hash.
+func BucketLabel(h *ImageHash, buckets int) (int, error) {
+	if h == nil {
+		return 0, errors.New("bucket label of a nil hash")
+	}
+	if buckets < 1 {
+		return 0, fmt.Errorf("bucket count must be at least 1, got %d", buckets)
+	}
+	k := bits.Len(uint(buckets - 1))
+	if k > len(h.hash) {
+		return 0, fmt.Errorf("%d buckets need %d bits, the hash has %d", buckets, k, len(h.hash))
+	}
+
+	label := 0
+	for i, pos := range bucketPositions(len(h.hash), k) {
+		if h.hash[pos] {
+			label |= 1 << i
+		}
+	}
+	return label % buckets, nil
+}
+
+// bucketPositions returns the first k positions of a Fisher-Yates shuffle
+// of 0..n-1 driven by SplitMix64 from bucketSeed. Only the first k steps of
+// the shuffle run, so the positions for k are a prefix of those for k+1.
+func bucketPositions(n, k int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	state := uint64(bucketSeed)
+	for i := range k {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		z ^= z >> 31
+		j := i + int(z%uint64(n-i))
+		perm[i], perm[j] = perm[j], perm[i]
+	}
+	return perm[:k]
+}
+
+// BucketingQuality reports how well BucketLabel keeps near-duplicates
+// together on a sample: the fraction of hashes whose nearest neighbour in
+// hashes (of the same shape, the first on ties) has the same label. Chance
+// is about 1/buckets; values near 1 mean near-duplicates co-bucket.
+// Hashes without a neighbour of their shape, nil hashes and hashes too
+// short for buckets are skipped; the result is 0 when none are left. The
+// cost is quadratic in len(hashes), so pass a sample.
+func BucketingQuality(hashes []*ImageHash, buckets int) float64 {
+	labels := make([]int, len(hashes))
+	for i, h := range hashes {
+		label, err := BucketLabel(h, buckets)
+		if err != nil {
+			label = -1
+		}
+		labels[i] = label
+	}
+
+	var counted, together int
+	for i, h := range hashes {
+		if labels[i] < 0 {
+			continue
+		}
+		nearest, best := -1, 0
+		for j, other := range hashes {
+			if j == i || labels[j] < 0 {
+				continue
+			}
+			if d, ok := hamming(h, other); ok && (nearest < 0 || d < best) {
+				nearest, best = j, d
+			}
+		}
+		if nearest < 0 {
+			continue
+		}
+		counted++
+		if labels[nearest] == labels[i] {
+			together++
+		}
+	}
+	if counted == 0 {
+		return 0
+	}
+	return float64(together) / float64(counted)
+}
@@ -0,0 +1,132 @@
+package imagehashgo
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// randomBucketHash returns a rows x cols hash of random bits
+func randomBucketHash(rng *rand.Rand, rows, cols int) *ImageHash {
+	bits := make([]bool, rows*cols)
+	for i := range bits {
+		bits[i] = rng.IntN(2) == 1
+	}
+	return NewImageHash(bits, rows, cols)
+}
+
+// perturbed returns h with flips random bits inverted
+func perturbed(rng *rand.Rand, h *ImageHash, flips int) *ImageHash {
+	bits := slices.Clone(h.hash)
+	for _, i := range rng.Perm(len(bits))[:flips] {
+		bits[i] = !bits[i]
+	}
+	return NewImageHash(bits, h.rows, h.cols)
+}
+
+// TestBucketLabel_Pinned pins labels: a failure here means the sampled
+// positions changed, which relabels every stored bucket
+func TestBucketLabel_Pinned(t *testing.T) {
+	h, err := HexToHash("ffd8e0c0c0e0f0f8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := bucketPositions(64, 6); !slices.Equal(got, []int{52, 38, 6, 10, 34, 58}) {
+		t.Errorf("positions = %v", got)
+	}
+	for _, tt := range []struct{ buckets, want int }{{1, 0}, {2, 0}, {16, 4}, {10, 4}, {64, 36}} {
+		got, err := BucketLabel(h, tt.buckets)
+		if err != nil || got != tt.want {
+			t.Errorf("BucketLabel(h, %d) = %d, %v; want %d", tt.buckets, got, err, tt.want)
+		}
+	}
+}
+
+func TestBucketLabel_Refines(t *testing.T) {
+	rng := rand.New(rand.NewPCG(969, 1))
+	for range 200 {
+		h := randomBucketHash(rng, 8, 8)
+		for n := 1; n <= 32; n *= 2 {
+			coarse, err := BucketLabel(h, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fine, err := BucketLabel(h, 2*n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fine%n != coarse {
+				t.Fatalf("BucketLabel(h, %d) = %d, BucketLabel(h, %d) = %d", n, coarse, 2*n, fine)
+			}
+		}
+		// Identical hashes, built separately, share a label
+		a, _ := BucketLabel(h, 16)
+		b, _ := BucketLabel(NewImageHash(slices.Clone(h.hash), 8, 8), 16)
+		if a != b {
+			t.Fatalf("identical hashes labelled %d and %d", a, b)
+		}
+	}
+}
+
+func TestBucketLabel_CoBucketing(t *testing.T) {
+	rng := rand.New(rand.NewPCG(969, 2))
+	const pairs = 2000
+	var near, random int
+	for range pairs {
+		h := randomBucketHash(rng, 8, 8)
+		a, _ := BucketLabel(h, 16)
+		b, _ := BucketLabel(perturbed(rng, h, 3), 16)
+		c, _ := BucketLabel(randomBucketHash(rng, 8, 8), 16)
+		if a == b {
+			near++
+		}
+		if a == c {
+			random++
+		}
+	}
+	// (1-3/64)^4 = 0.82 for 3 flipped bits, 1/16 by chance
+	t.Logf("co-bucketed: %d of %d perturbed pairs, %d of %d random pairs", near, pairs, random, pairs)
+	if near < pairs*3/4 || random > pairs/8 {
+		t.Errorf("perturbed pairs co-bucketed %d times, random pairs %d times", near, random)
+	}
+}
+
+func TestBucketingQuality(t *testing.T) {
+	rng := rand.New(rand.NewPCG(969, 3))
+	var clustered, scattered []*ImageHash
+	for range 100 {
+		h := randomBucketHash(rng, 8, 8)
+		clustered = append(clustered, h, perturbed(rng, h, 2))
+		scattered = append(scattered, randomBucketHash(rng, 8, 8), randomBucketHash(rng, 8, 8))
+	}
+	near, far := BucketingQuality(clustered, 16), BucketingQuality(scattered, 16)
+	t.Logf("quality: %.2f with near-duplicates, %.2f without", near, far)
+	if near < 0.8 || far > 0.25 {
+		t.Errorf("quality %.2f with near-duplicates, %.2f without", near, far)
+	}
+
+	// Hashes without a neighbour of their shape are skipped
+	h := randomBucketHash(rng, 8, 8)
+	mixed := []*ImageHash{h, nil, h, randomBucketHash(rng, 4, 4)}
+	if got := BucketingQuality(mixed, 16); got != 1 {
+		t.Errorf("BucketingQuality(mixed) = %v, want 1", got)
+	}
+	if got := BucketingQuality(nil, 16); got != 0 {
+		t.Errorf("BucketingQuality(nil) = %v, want 0", got)
+	}
+}
+
+func TestBucketLabel_Errors(t *testing.T) {
+	h := randomBucketHash(rand.New(rand.NewPCG(969, 4)), 2, 2)
+	for _, tt := range []struct {
+		h       *ImageHash
+		buckets int
+	}{{nil, 16}, {h, 0}, {h, -1}, {h, 17}} {
+		if _, err := BucketLabel(tt.h, tt.buckets); err == nil {
+			t.Errorf("BucketLabel(%v, %d) succeeded", tt.h, tt.buckets)
+		}
+	}
+	if got, err := BucketLabel(h, 16); err != nil || got < 0 || got >= 16 {
+		t.Errorf("BucketLabel(2x2, 16) = %d, %v", got, err)
+	}
+}
@@ -0,0 +1,164 @@
+package imagehashgo
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// budgetVersion is the first byte of a PackBudgeted encoding
+const budgetVersion = 1
+
+// budgetMinBits is the fewest bits PackBudgeted folds a hash down to, the
+// size of a micro-hash
+const budgetMinBits = 16
+
+// budgetKinds are the kinds PackBudgeted stores, by their code minus one
+// and in the order it prefers them among hashes of the same bit count
+var budgetKinds = []HashKind{KindDifference, KindPerceptual, KindAverage, KindDifferenceVertical}
+
+// Fold returns the hash folded to the given number of rows: the bottom
+// half of the rows is XORed onto the top half until rows remain, so rows
+// must be the row count halved zero or more times. Two hashes folded alike
+// are no farther apart than before folding, so near duplicates stay near;
+// compare a folded hash only with another hash folded to the same shape.
+func (h *ImageHash) Fold(rows int) (*ImageHash, error) {
+	r := h.rows
+	for r > rows && r%2 == 0 {
+		r /= 2
+	}
+	if rows < 1 || r != rows {
+		return nil, fmt.Errorf("cannot fold %d rows to %d", h.rows, rows)
+	}
+	bits := slices.Clone(h.hash)
+	for r = h.rows; r > rows; r /= 2 {
+		half := r / 2 * h.cols
+		for i := range half {
+			bits[i] = bits[i] != bits[half+i]
+		}
+	}
+	return &ImageHash{hash: bits[:rows*h.cols], rows: rows, cols: h.cols, kind: h.kind}, nil
+}
+
+// PackBudgeted packs as many of hashes as fit in budget bytes, for
+// metadata fields of a fixed size. Hashes are taken by bit count, smallest
+// first, and among equal counts dHash, pHash, aHash then vertical dHash;
+// with one 8x8 dHash, one 8x8 pHash and 16x16 hashes of the other kinds,
+// that is dHash 64, pHash 64, then the 256-bit hashes. A hash that does
+// not fit in the bytes left is folded (see Fold) down to as few as 16 bits
+// until it does, or else left out.
+//
+// The encoding is a version byte of 1, then for each hash a byte holding
+// the kind (1 dhash, 2 phash, 3 ahash, 4 dhash_v) in the high nibble and
+// the number of folds in the low nibble, a byte each for the rows and
+// columns stored, and the bits packed as in HashSnapshot.Bits. It returns
+// an error when no hash fits, or for a kind other than these four.
+func PackBudgeted(hashes map[HashKind]*ImageHash, budget int) ([]byte, error) {
+	var order []HashKind
+	for kind, h := range hashes {
+		if slices.Index(budgetKinds, kind) < 0 {
+			return nil, fmt.Errorf("cannot pack hash kind %q", kind)
+		}
+		if h == nil || len(h.hash) == 0 {
+			return nil, fmt.Errorf("%s hash is empty", kind)
+		}
+		if h.rows > 255 || h.cols > 255 {
+			return nil, fmt.Errorf("%s hash shape %dx%d does not fit the header", kind, h.rows, h.cols)
+		}
+		order = append(order, kind)
+	}
+	slices.SortFunc(order, func(a, b HashKind) int {
+		return cmp.Or(cmp.Compare(len(hashes[a].hash), len(hashes[b].hash)),
+			cmp.Compare(slices.Index(budgetKinds, a), slices.Index(budgetKinds, b)))
+	})
+
+	out := []byte{budgetVersion}
+	for _, kind := range order {
+		h, folds := hashes[kind], 0
+		for len(out)+3+(len(h.hash)+7)/8 > budget && h.rows%2 == 0 && len(h.hash)/2 >= budgetMinBits {
+			h, _ = h.Fold(h.rows / 2)
+			folds++
+		}
+		if len(out)+3+(len(h.hash)+7)/8 > budget {
+			continue
+		}
+		code := byte(slices.Index(budgetKinds, kind) + 1)
+		out = append(out, code<<4|byte(folds), byte(h.rows), byte(h.cols))
+		out = append(out, packBits(h.hash)...)
+	}
+	if len(out) == 1 {
+		return nil, fmt.Errorf("budget of %d bytes holds none of the %d hashes", budget, len(hashes))
+	}
+	return out, nil
+}
+
+// BudgetedHash is a hash decoded by UnpackBudgeted
+type BudgetedHash struct {
+	// Hash is the hash as stored, with Rows >> Folds rows
+	Hash *ImageHash
+	// Folds is the number of times PackBudgeted folded the hash
+	Folds int
+	// Rows is the row count of the hash before folding; the columns are
+	// unchanged
+	Rows int
+}
+
+// Distance returns the distance between the stored hash and h, a fresh
+// hash of the shape it had before folding, folded alike
+func (b BudgetedHash) Distance(h *ImageHash) (int, error) {
+	if h == nil || h.rows != b.Rows || h.cols != b.Hash.cols {
+		return 0, fmt.Errorf("hash shape does not match the %dx%d hash stored", b.Rows, b.Hash.cols)
+	}
+	folded, err := h.Fold(b.Hash.rows)
+	if err != nil {
+		return 0, err
+	}
+	return b.Hash.Distance(folded)
+}
+
+// UnpackBudgeted decodes the hashes PackBudgeted stored. A folded hash is
+// returned as stored, with fewer rows than the hash it was folded from,
+// along with the fold count and the original row count; compare it with
+// a fresh hash through BudgetedHash.Distance.
+func UnpackBudgeted(data []byte) (map[HashKind]BudgetedHash, error) {
+	if len(data) == 0 {
+		return nil, errors.New("budgeted hashes are empty")
+	}
+	if data[0] != budgetVersion {
+		return nil, fmt.Errorf("unknown budgeted hashes version %d", data[0])
+	}
+	hashes := make(map[HashKind]BudgetedHash)
+	for data = data[1:]; len(data) > 0; {
+		if len(data) < 3 {
+			return nil, errors.New("truncated budgeted hash header")
+		}
+		code, folds, rows, cols := int(data[0]>>4), int(data[0]&0xf), int(data[1]), int(data[2])
+		if code < 1 || code > len(budgetKinds) {
+			return nil, fmt.Errorf("unknown budgeted hash kind code %d", code)
+		}
+		kind := budgetKinds[code-1]
+		if _, dup := hashes[kind]; dup {
+			return nil, fmt.Errorf("%s hash stored twice", kind)
+		}
+		if rows == 0 || cols == 0 || rows<<folds > 255 {
+			return nil, fmt.Errorf("invalid %s hash shape %dx%d folded %d times", kind, rows, cols, folds)
+		}
+		n := rows * cols
+		data = data[3:]
+		if len(data) < (n+7)/8 {
+			return nil, fmt.Errorf("truncated %s hash", kind)
+		}
+		packed := data[:(n+7)/8]
+		if err := checkPacked(packed, n); err != nil {
+			return nil, fmt.Errorf("%s hash: %w", kind, err)
+		}
+		hashes[kind] = BudgetedHash{
+			Hash:  &ImageHash{hash: unpackBits(packed, n), rows: rows, cols: cols, kind: kind},
+			Folds: folds,
+			Rows:  rows << folds,
+		}
+		data = data[len(packed):]
+	}
+	return hashes, nil
+}
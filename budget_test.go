@@ -0,0 +1,129 @@
+package imagehashgo
+
+import (
+	"slices"
+	"testing"
+)
+
+func budgetHashes(t *testing.T) map[HashKind]*ImageHash {
+	t.Helper()
+	img := getBenchImage()
+	hashes := make(map[HashKind]*ImageHash)
+	for kind, size := range map[HashKind]int{KindDifference: 8, KindPerceptual: 8, KindAverage: 16, KindDifferenceVertical: 32} {
+		h, err := Hash(img, kind, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[kind] = h
+	}
+	return hashes
+}
+
+func TestPackBudgeted_RoundTrip(t *testing.T) {
+	hashes := budgetHashes(t)
+	tests := []struct {
+		budget int
+		// rows of each hash stored, its columns being those of the original
+		rows map[HashKind]int
+		size int
+	}{
+		{16, map[HashKind]int{KindDifference: 8}, 12},
+		// The 32x32 vertical dHash cannot fold below a row of 32 bits
+		{64, map[HashKind]int{KindDifference: 8, KindPerceptual: 8, KindAverage: 16}, 58},
+		{128, map[HashKind]int{KindDifference: 8, KindPerceptual: 8, KindAverage: 16, KindDifferenceVertical: 16}, 125},
+		// Folded to 16 bits to fit
+		{6, map[HashKind]int{KindDifference: 2}, 6},
+	}
+	for _, tt := range tests {
+		data, err := PackBudgeted(hashes, tt.budget)
+		if err != nil {
+			t.Fatalf("budget %d: %v", tt.budget, err)
+		}
+		if len(data) != tt.size {
+			t.Errorf("budget %d: packed %d bytes, want %d", tt.budget, len(data), tt.size)
+		}
+		got, err := UnpackBudgeted(data)
+		if err != nil {
+			t.Fatalf("budget %d: %v", tt.budget, err)
+		}
+		if len(got) != len(tt.rows) {
+			t.Errorf("budget %d: unpacked %d hashes, want %d", tt.budget, len(got), len(tt.rows))
+		}
+		for kind, rows := range tt.rows {
+			want, err := hashes[kind].Fold(rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := got[kind]
+			h := b.Hash
+			if h == nil || h.Kind() != kind || h.rows != rows || h.cols != want.cols || !slices.Equal(h.hash, want.hash) {
+				t.Errorf("budget %d: %s unpacked as %v, want %s folded to %d rows", tt.budget, kind, h, kind, rows)
+				continue
+			}
+			if b.Rows != hashes[kind].rows || b.Rows != rows<<b.Folds {
+				t.Errorf("budget %d: %s folded %d times from %d rows, want from %d", tt.budget, kind, b.Folds, b.Rows, hashes[kind].rows)
+			}
+			if d, err := b.Distance(hashes[kind]); err != nil || d != 0 {
+				t.Errorf("budget %d: %s distance to the original = %d, %v", tt.budget, kind, d, err)
+			}
+		}
+	}
+
+	data, _ := PackBudgeted(hashes, 6)
+	got, _ := UnpackBudgeted(data)
+	folded, _ := hashes[KindPerceptual].Fold(4)
+	if _, err := got[KindDifference].Distance(folded); err == nil {
+		t.Error("distance to a hash of another shape expected error")
+	}
+
+	if _, err := PackBudgeted(hashes, 5); err == nil {
+		t.Error("budget below the smallest folded hash expected error")
+	}
+	if _, err := PackBudgeted(map[HashKind]*ImageHash{"whash": hashes[KindAverage]}, 128); err == nil {
+		t.Error("unknown kind expected error")
+	}
+}
+
+func TestFold(t *testing.T) {
+	h := NewImageHash([]bool{
+		true, false, true, false,
+		true, true, false, false,
+		false, false, true, true,
+		true, true, true, true,
+	}, 4, 4)
+	two, err := h.Fold(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []bool{true, false, false, true, false, false, true, true}; !slices.Equal(two.hash, want) {
+		t.Errorf("Fold(2) = %v, want %v", two.hash, want)
+	}
+	one, _ := h.Fold(1)
+	again, _ := two.Fold(1)
+	if !slices.Equal(one.hash, again.hash) || one.rows != 1 || one.cols != 4 {
+		t.Errorf("Fold(1) = %v, folding twice = %v", one.hash, again.hash)
+	}
+	for _, rows := range []int{0, 3, 8} {
+		if _, err := h.Fold(rows); err == nil {
+			t.Errorf("Fold(%d) expected error", rows)
+		}
+	}
+}
+
+func TestUnpackBudgeted_Errors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":            nil,
+		"version":          {2},
+		"short header":     {1, 0x10, 1},
+		"unknown kind":     {1, 0x50, 1, 8, 0},
+		"zero rows":        {1, 0x10, 0, 8},
+		"truncated bits":   {1, 0x10, 2, 8, 0},
+		"padding":          {1, 0x10, 1, 4, 0x01},
+		"twice":            {1, 0x10, 1, 8, 0, 0x10, 1, 8, 0},
+		"folded too large": {1, 0x19, 1, 8, 0},
+	} {
+		if _, err := UnpackBudgeted(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
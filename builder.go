@@ -0,0 +1,56 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"slices"
+)
+
+// BuildHash thresholds values into a rows x cols hash: a cell is set when
+// its value is above threshold(values), e.g. Median as PerceptualHash does.
+// Together with BuildHashFromBits it is the supported way for other
+// packages to implement hash algorithms; the result has no Kind. The shape
+// must be valid for BuildHashFromBits, with len(values) cells.
+func BuildHash(values []float64, rows, cols int, threshold func([]float64) float64) (*ImageHash, error) {
+	if err := checkShape(len(values), rows, cols); err != nil {
+		return nil, err
+	}
+	return thresholdHash(values, rows, cols, threshold), nil
+}
+
+// thresholdHash is BuildHash for the built-in algorithms, whose shapes are
+// valid by construction
+func thresholdHash(values []float64, rows, cols int, threshold func([]float64) float64) *ImageHash {
+	t := threshold(values)
+	bits := make([]bool, len(values))
+	for i, v := range values {
+		bits[i] = v > t
+	}
+	return &ImageHash{hash: bits, rows: rows, cols: cols}
+}
+
+// BuildHashFromBits returns a rows x cols hash of bits, row-major. Rows and
+// cols must be positive with at most MaxHashBits cells, and len(bits) must
+// be rows*cols. The bits are copied; the result has no Kind.
+func BuildHashFromBits(bits []bool, rows, cols int) (*ImageHash, error) {
+	if err := checkShape(len(bits), rows, cols); err != nil {
+		return nil, err
+	}
+	return &ImageHash{hash: slices.Clone(bits), rows: rows, cols: cols}, nil
+}
+
+// checkShape validates n cells against a rows x cols shape
+func checkShape(n, rows, cols int) error {
+	if rows <= 0 || cols <= 0 || rows > MaxHashBits || cols > MaxHashBits || rows*cols > MaxHashBits {
+		return fmt.Errorf("invalid hash shape: (%d, %d)", rows, cols)
+	}
+	if n != rows*cols {
+		return fmt.Errorf("%d cells do not fit shape (%d, %d)", n, rows, cols)
+	}
+	return nil
+}
+
+// builtHash finishes a built-in algorithm, taking ownership of bits, which
+// are neither validated nor copied
+func builtHash(bits []bool, rows, cols int, kind HashKind) *ImageHash {
+	return &ImageHash{hash: bits, rows: rows, cols: cols, kind: kind}
+}
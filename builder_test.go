@@ -0,0 +1,102 @@
+package imagehashgo
+
+import (
+	"image"
+	"testing"
+)
+
+func TestBuildHash(t *testing.T) {
+	values := []float64{5, 1, 9, 3, 7, 2}
+	mean := func(v []float64) float64 {
+		var sum float64
+		for _, x := range v {
+			sum += x
+		}
+		return sum / float64(len(v))
+	}
+
+	h, err := BuildHash(values, 2, 3, mean)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, cols := h.Shape(); rows != 2 || cols != 3 {
+		t.Errorf("shape = (%d, %d), want (2, 3)", rows, cols)
+	}
+	want := []bool{true, false, true, false, true, false}
+	for i, b := range want {
+		if h.hash[i] != b {
+			t.Errorf("bit %d = %v, want %v", i, h.hash[i], b)
+		}
+	}
+	if h.Kind() != "" {
+		t.Errorf("kind = %q, want empty", h.Kind())
+	}
+}
+
+func TestBuildHashErrors(t *testing.T) {
+	if _, err := BuildHash(make([]float64, 10), 3, 3, Median); err == nil {
+		t.Error("expected an error for values not matching the shape")
+	}
+	if _, err := BuildHash(nil, 0, 0, Median); err == nil {
+		t.Error("expected an error for an empty shape")
+	}
+}
+
+// A third-party algorithm only needs the exported API: this one thresholds
+// the DCT coefficients of PerceptualHash's input at the median, which must
+// give the built-in pHash
+func TestBuildHashExtensionPoint(t *testing.T) {
+	img := getBenchImage()
+	var resized *image.Gray
+	want := PerceptualHash(img, 8, 4, WithCaptureIntermediate(&resized))
+
+	matrix := make([][]float64, 32)
+	for y := range matrix {
+		matrix[y] = make([]float64, 32)
+		for x := range matrix[y] {
+			matrix[y][x] = float64(resized.GrayAt(x, y).Y)
+		}
+	}
+	dct := DCT2D(matrix)
+	values := make([]float64, 0, 64)
+	for y := range 8 {
+		values = append(values, dct[y][:8]...)
+	}
+
+	got, err := BuildHash(values, 8, 8, Median)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ToString() != want.ToString() {
+		t.Errorf("BuildHash = %s, PerceptualHash = %s", got.ToString(), want.ToString())
+	}
+}
+
+func TestBuildHashFromBits(t *testing.T) {
+	bits := []bool{true, false, false, true}
+	h, err := BuildHashFromBits(bits, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits[0] = false
+	if !h.hash[0] {
+		t.Error("BuildHashFromBits shares the caller's slice")
+	}
+	if h.ToString() != "9" {
+		t.Errorf("hash = %s, want 9", h.ToString())
+	}
+
+	invalid := []struct {
+		n, rows, cols int
+	}{
+		{4, 0, 4},
+		{4, 2, -2},
+		{5, 2, 2},
+		{MaxHashBits + 1, 1, MaxHashBits + 1},
+	}
+	for _, tt := range invalid {
+		if _, err := BuildHashFromBits(make([]bool, tt.n), tt.rows, tt.cols); err == nil {
+			t.Errorf("BuildHashFromBits(%d bits, %d, %d) succeeded", tt.n, tt.rows, tt.cols)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package imagehashgo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+)
+
+// CalibrationVersion identifies the embedded calibration tables. It
+// changes whenever gen/calibration (go generate) produces different
+// tables, so results can be traced to the curves that gave them.
+const CalibrationVersion = "synthetic-1"
+
+// calibrationEmbedded holds the calibration tables of the four built-in
+// algorithms, written by gen/calibration (go generate)
+//
+//go:embed calibration.json
+var calibrationEmbedded []byte
+
+// CalibrationPoint is the probability that two images are the same
+// content when their hashes are Distance bits apart
+type CalibrationPoint struct {
+	Distance    int     `json:"distance"`
+	Probability float64 `json:"probability"`
+}
+
+// CalibrationTable maps hash distances to match probabilities for one
+// algorithm, measured on hashes of Bits bits. Points are sorted by
+// distance; MatchProbability interpolates between them.
+type CalibrationTable struct {
+	// Version names the corpus and revision the table was measured on
+	Version string             `json:"version"`
+	Bits    int                `json:"bits"`
+	Points  []CalibrationPoint `json:"points"`
+}
+
+// validate checks that t is a usable table: distances strictly increasing
+// within [0, Bits], probabilities within [0, 1] and non-increasing
+func (t CalibrationTable) validate() error {
+	if t.Bits < 1 {
+		return fmt.Errorf("calibration table has %d bits", t.Bits)
+	}
+	if len(t.Points) == 0 {
+		return errors.New("calibration table has no points")
+	}
+	for i, p := range t.Points {
+		if p.Distance < 0 || p.Distance > t.Bits {
+			return fmt.Errorf("calibration point %d: distance %d outside [0, %d]", i, p.Distance, t.Bits)
+		}
+		if !(p.Probability >= 0 && p.Probability <= 1) {
+			return fmt.Errorf("calibration point %d: probability %g outside [0, 1]", i, p.Probability)
+		}
+		if i > 0 && p.Distance <= t.Points[i-1].Distance {
+			return fmt.Errorf("calibration point %d: distance %d not above %d", i, p.Distance, t.Points[i-1].Distance)
+		}
+		if i > 0 && p.Probability > t.Points[i-1].Probability {
+			return fmt.Errorf("calibration point %d: probability rises from %g to %g", i, t.Points[i-1].Probability, p.Probability)
+		}
+	}
+	return nil
+}
+
+// at returns the probability at distance d, in bits of the table,
+// interpolating linearly between points and clamping at the ends
+func (t CalibrationTable) at(d float64) float64 {
+	pts := t.Points
+	if d <= float64(pts[0].Distance) {
+		return pts[0].Probability
+	}
+	for i := 1; i < len(pts); i++ {
+		if d <= float64(pts[i].Distance) {
+			lo, hi := pts[i-1], pts[i]
+			f := (d - float64(lo.Distance)) / float64(hi.Distance-lo.Distance)
+			return lo.Probability + f*(hi.Probability-lo.Probability)
+		}
+	}
+	return pts[len(pts)-1].Probability
+}
+
+var (
+	calibrationMu       sync.RWMutex
+	calibrationOverride = map[HashKind]CalibrationTable{}
+)
+
+// calibrationDefaults parses the embedded tables once
+var calibrationDefaults = sync.OnceValue(func() map[HashKind]CalibrationTable {
+	var tables map[HashKind]CalibrationTable
+	if err := json.Unmarshal(calibrationEmbedded, &tables); err != nil {
+		panic("imagehashgo: invalid embedded calibration: " + err.Error())
+	}
+	for kind, t := range tables {
+		if err := t.validate(); err != nil {
+			panic(fmt.Sprintf("imagehashgo: invalid embedded calibration of %s: %v", kind, err))
+		}
+	}
+	return tables
+})
+
+// CalibrationFor returns the table MatchProbability uses for kind: the one
+// set with SetCalibration, else the embedded one. ok is false when kind has
+// neither.
+func CalibrationFor(kind HashKind) (table CalibrationTable, ok bool) {
+	calibrationMu.RLock()
+	table, ok = calibrationOverride[kind]
+	calibrationMu.RUnlock()
+	if !ok {
+		table, ok = calibrationDefaults()[kind]
+	}
+	table.Points = slices.Clone(table.Points)
+	return table, ok
+}
+
+// SetCalibration replaces the table MatchProbability uses for kind, for
+// callers who calibrated on their own images with Calibrate. The table must
+// have distances strictly increasing within [0, Bits] and probabilities
+// within [0, 1] that do not increase with distance. A zero CalibrationTable
+// restores the embedded table. It is safe to call concurrently with
+// MatchProbability.
+func SetCalibration(kind HashKind, table CalibrationTable) error {
+	if kind == "" {
+		return errors.New("calibration needs a hash kind")
+	}
+	calibrationMu.Lock()
+	defer calibrationMu.Unlock()
+	if table.Bits == 0 && table.Points == nil && table.Version == "" {
+		delete(calibrationOverride, kind)
+		return nil
+	}
+	if err := table.validate(); err != nil {
+		return err
+	}
+	table.Points = slices.Clone(table.Points)
+	calibrationOverride[kind] = table
+	return nil
+}
+
+// MatchProbability returns the probability that two images whose kind
+// hashes of bits bits are distance apart show the same content, read off
+// the calibration table of kind (see CalibrationFor) as if same-content
+// and unrelated pairs were equally common. Distances are compared as
+// fractions of the hash length, so a table measured on 64-bit hashes also
+// serves 256-bit ones; between table points the probability is
+// interpolated linearly, and beyond the ends it is clamped to the first or
+// last point. It never increases with distance.
+//
+// The embedded tables (CalibrationVersion) were measured by gen/calibration
+// on a synthetic corpus of photo-like scenes, positives being JPEG
+// recompressions, rescales, small crops, brightness shifts and blurs of an
+// original and negatives pairs of different scenes; they are a starting
+// point, not a guarantee for any particular collection. MatchProbability
+// returns NaN when kind has no table or bits is not positive.
+func MatchProbability(kind HashKind, bits int, distance int) float64 {
+	table, ok := CalibrationFor(kind)
+	if !ok || bits < 1 {
+		return math.NaN()
+	}
+	return table.at(float64(distance) * float64(table.Bits) / float64(bits))
+}
+
+// MaxMeaningfulDistance returns the distance between kind hashes of bits
+// bits beyond which MatchProbability no longer falls: from there on, pairs
+// are as likely to match as unrelated images. It returns -1 when kind has
+// no table or bits is not positive.
+func MaxMeaningfulDistance(kind HashKind, bits int) int {
+	table, ok := CalibrationFor(kind)
+	if !ok || bits < 1 {
+		return -1
+	}
+	floor := table.Points[len(table.Points)-1].Probability
+	for _, p := range table.Points {
+		if p.Probability <= floor {
+			return int(math.Ceil(float64(p.Distance) * float64(bits) / float64(table.Bits)))
+		}
+	}
+	return bits
+}
+
+// Calibrate builds a CalibrationTable from labelled distances between
+// hashes of bits bits: same holds the distances of pairs known to show the
+// same content, different those of unrelated pairs. At every distance
+// observed, the probability is the share of same-content pairs, with both
+// lists weighted as if they were equally long; the probabilities are then
+// made non-increasing by pooling adjacent violators, so noise in sparse
+// distances cannot make a larger distance look more alike. Runs of equal
+// probability keep only their first and last distance. Version is left for
+// the caller to set.
+func Calibrate(same, different []int, bits int) (CalibrationTable, error) {
+	if bits < 1 {
+		return CalibrationTable{}, fmt.Errorf("calibration needs a positive bit count, got %d", bits)
+	}
+	if len(same) == 0 || len(different) == 0 {
+		return CalibrationTable{}, errors.New("calibration needs both same-content and different pairs")
+	}
+	sameAt := make([]float64, bits+1)
+	diffAt := make([]float64, bits+1)
+	for _, list := range []struct {
+		dists []int
+		into  []float64
+	}{{same, sameAt}, {different, diffAt}} {
+		w := 1 / float64(len(list.dists))
+		for _, d := range list.dists {
+			if d < 0 || d > bits {
+				return CalibrationTable{}, fmt.Errorf("distance %d outside [0, %d]", d, bits)
+			}
+			list.into[d] += w
+		}
+	}
+
+	// Pool adjacent violators, and equal neighbours so that flat runs are
+	// one block: each block keeps its weighted mean
+	type block struct {
+		first, last int
+		same, total float64
+	}
+	var blocks []block
+	for d := range bits + 1 {
+		if sameAt[d]+diffAt[d] == 0 {
+			continue
+		}
+		blocks = append(blocks, block{d, d, sameAt[d], sameAt[d] + diffAt[d]})
+		for n := len(blocks); n > 1 && blocks[n-1].same/blocks[n-1].total >= blocks[n-2].same/blocks[n-2].total; n-- {
+			prev, cur := blocks[n-2], blocks[n-1]
+			blocks = append(blocks[:n-2], block{prev.first, cur.last, prev.same + cur.same, prev.total + cur.total})
+		}
+	}
+
+	// A block is flat, so its ends are enough for the interpolation
+	table := CalibrationTable{Bits: bits}
+	for _, b := range blocks {
+		p := b.same / b.total
+		table.Points = append(table.Points, CalibrationPoint{Distance: b.first, Probability: p})
+		if b.last != b.first {
+			table.Points = append(table.Points, CalibrationPoint{Distance: b.last, Probability: p})
+		}
+	}
+	return table, nil
+}
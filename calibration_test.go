@@ -0,0 +1,164 @@
+package imagehashgo
+
+import (
+	"math"
+	"testing"
+)
+
+var calibratedKinds = []HashKind{KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical}
+
+func TestCalibrationFor_Embedded(t *testing.T) {
+	for _, kind := range calibratedKinds {
+		table, ok := CalibrationFor(kind)
+		if !ok {
+			t.Fatalf("%s has no embedded table", kind)
+		}
+		if table.Version != CalibrationVersion || table.Bits != 64 {
+			t.Errorf("%s: version %q, %d bits; want %q, 64", kind, table.Version, table.Bits, CalibrationVersion)
+		}
+		if err := table.validate(); err != nil {
+			t.Errorf("%s: %v", kind, err)
+		}
+		// Identical hashes match, opposite ones do not
+		if p := MatchProbability(kind, 64, 0); p < 0.99 {
+			t.Errorf("%s: MatchProbability at 0 = %g", kind, p)
+		}
+		if p := MatchProbability(kind, 64, 64); p > 0.01 {
+			t.Errorf("%s: MatchProbability at 64 = %g", kind, p)
+		}
+	}
+	if _, ok := CalibrationFor("custom"); ok {
+		t.Error("CalibrationFor(custom) found a table")
+	}
+}
+
+func TestMatchProbability_Monotonic(t *testing.T) {
+	for _, kind := range calibratedKinds {
+		for _, bits := range []int{16, 64, 65, 256} {
+			prev := math.Inf(1)
+			for d := range bits + 1 {
+				p := MatchProbability(kind, bits, d)
+				if p < 0 || p > 1 || p > prev {
+					t.Fatalf("%s, %d bits: MatchProbability at %d = %g after %g", kind, bits, d, p, prev)
+				}
+				prev = p
+			}
+		}
+		// The same fraction of the hash gives the same probability
+		if a, b := MatchProbability(kind, 64, 10), MatchProbability(kind, 256, 40); math.Abs(a-b) > 1e-12 {
+			t.Errorf("%s: 10/64 gives %g, 40/256 gives %g", kind, a, b)
+		}
+	}
+
+	if p := MatchProbability("custom", 64, 3); !math.IsNaN(p) {
+		t.Errorf("unknown kind: %g, want NaN", p)
+	}
+	if p := MatchProbability(KindAverage, 0, 3); !math.IsNaN(p) {
+		t.Errorf("zero bits: %g, want NaN", p)
+	}
+}
+
+func TestSetCalibration(t *testing.T) {
+	t.Cleanup(func() { SetCalibration(KindAverage, CalibrationTable{}) })
+	embedded, _ := CalibrationFor(KindAverage)
+
+	table := CalibrationTable{Version: "mine", Bits: 10, Points: []CalibrationPoint{{2, 0.8}, {4, 0.4}, {6, 0.4}}}
+	if err := SetCalibration(KindAverage, table); err != nil {
+		t.Fatal(err)
+	}
+	table.Points[0].Probability = 0 // the table was copied
+	for d, want := range map[int]float64{0: 0.8, 2: 0.8, 3: 0.6, 5: 0.4, 10: 0.4, 20: 0.4} {
+		if got := MatchProbability(KindAverage, 10, d); math.Abs(got-want) > 1e-12 {
+			t.Errorf("MatchProbability(%d) = %g, want %g", d, got, want)
+		}
+	}
+	if got, _ := CalibrationFor(KindAverage); got.Version != "mine" {
+		t.Errorf("CalibrationFor() version %q, want mine", got.Version)
+	}
+	if got, _ := CalibrationFor(KindPerceptual); got.Version != CalibrationVersion {
+		t.Error("override leaked to another kind")
+	}
+
+	if err := SetCalibration(KindAverage, CalibrationTable{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := CalibrationFor(KindAverage); got.Version != embedded.Version || len(got.Points) != len(embedded.Points) {
+		t.Error("zero table did not restore the embedded one")
+	}
+
+	for name, bad := range map[string]CalibrationTable{
+		"no bits":       {Points: []CalibrationPoint{{0, 1}}},
+		"no points":     {Bits: 8},
+		"rising":        {Bits: 8, Points: []CalibrationPoint{{0, 0.5}, {4, 0.6}}},
+		"repeated":      {Bits: 8, Points: []CalibrationPoint{{2, 1}, {2, 0.5}}},
+		"beyond bits":   {Bits: 8, Points: []CalibrationPoint{{9, 0}}},
+		"above one":     {Bits: 8, Points: []CalibrationPoint{{0, 1.5}}},
+		"not a number":  {Bits: 8, Points: []CalibrationPoint{{0, math.NaN()}}},
+		"negative dist": {Bits: 8, Points: []CalibrationPoint{{-1, 1}}},
+	} {
+		if err := SetCalibration(KindAverage, bad); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if err := SetCalibration("", table); err == nil {
+		t.Error("empty kind expected error")
+	}
+}
+
+func TestCalibrate(t *testing.T) {
+	// Distance 5 has more same-content pairs than 4 by chance, which the
+	// pooling irons out
+	same := []int{0, 1, 1, 2, 5, 5, 5}
+	different := []int{4, 4, 5, 6, 7, 7}
+	table, err := Calibrate(same, different, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.validate(); err != nil {
+		t.Fatalf("Calibrate() = %+v: %v", table, err)
+	}
+	want := []CalibrationPoint{{0, 1}, {2, 1}, {4, 3.0 / 7 / (3.0/7 + 3.0/6)}, {5, 3.0 / 7 / (3.0/7 + 3.0/6)}, {6, 0}, {7, 0}}
+	if len(table.Points) != len(want) {
+		t.Fatalf("Calibrate() points = %v, want %v", table.Points, want)
+	}
+	for i, p := range table.Points {
+		if p.Distance != want[i].Distance || math.Abs(p.Probability-want[i].Probability) > 1e-12 {
+			t.Errorf("point %d = %v, want %v", i, p, want[i])
+		}
+	}
+
+	for name, args := range map[string]struct {
+		same, different []int
+		bits            int
+	}{
+		"no bits":      {same, different, 0},
+		"no same":      {nil, different, 8},
+		"no different": {same, nil, 8},
+		"beyond bits":  {same, []int{9}, 8},
+	} {
+		if _, err := Calibrate(args.same, args.different, args.bits); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestMaxMeaningfulDistance(t *testing.T) {
+	t.Cleanup(func() { SetCalibration(KindAverage, CalibrationTable{}) })
+	table := CalibrationTable{Bits: 16, Points: []CalibrationPoint{{0, 1}, {3, 0.5}, {5, 0.1}, {16, 0.1}}}
+	if err := SetCalibration(KindAverage, table); err != nil {
+		t.Fatal(err)
+	}
+	for bits, want := range map[int]int{16: 5, 64: 20, 8: 3} {
+		if got := MaxMeaningfulDistance(KindAverage, bits); got != want {
+			t.Errorf("MaxMeaningfulDistance(%d) = %d, want %d", bits, got, want)
+		}
+	}
+	for _, kind := range calibratedKinds[1:] {
+		if d := MaxMeaningfulDistance(kind, 64); d < 1 || d > 64 || MatchProbability(kind, 64, d) > 0.01 {
+			t.Errorf("%s: MaxMeaningfulDistance = %d", kind, d)
+		}
+	}
+	if got := MaxMeaningfulDistance("custom", 64); got != -1 {
+		t.Errorf("unknown kind: %d, want -1", got)
+	}
+}
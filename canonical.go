@@ -0,0 +1,132 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Orientation is one of the 8 rotations and flips of a square grid (the
+// dihedral group D4). Rotations are counter-clockwise, like imaging.Rotate90.
+type Orientation uint8
+
+const (
+	OrientIdentity Orientation = iota
+	OrientRotate90
+	OrientRotate180
+	OrientRotate270
+	OrientFlipH
+	OrientFlipV
+	OrientTranspose
+	OrientTransverse
+)
+
+var orientationNames = [...]string{
+	"identity", "rotate90", "rotate180", "rotate270",
+	"fliph", "flipv", "transpose", "transverse",
+}
+
+// String returns the lower-case name of the orientation
+func (o Orientation) String() string {
+	if int(o) < len(orientationNames) {
+		return orientationNames[o]
+	}
+	return fmt.Sprintf("Orientation(%d)", o)
+}
+
+// Inverse returns the orientation that undoes o
+func (o Orientation) Inverse() Orientation {
+	switch o {
+	case OrientRotate90:
+		return OrientRotate270
+	case OrientRotate270:
+		return OrientRotate90
+	}
+	return o
+}
+
+// Apply returns img transformed by o
+func (o Orientation) Apply(img image.Image) image.Image {
+	switch o {
+	case OrientRotate90:
+		return imaging.Rotate90(img)
+	case OrientRotate180:
+		return imaging.Rotate180(img)
+	case OrientRotate270:
+		return imaging.Rotate270(img)
+	case OrientFlipH:
+		return imaging.FlipH(img)
+	case OrientFlipV:
+		return imaging.FlipV(img)
+	case OrientTranspose:
+		return imaging.Transpose(img)
+	case OrientTransverse:
+		return imaging.Transverse(img)
+	}
+	return img
+}
+
+// source returns the cell of an n x n grid that o moves to (x, y)
+func (o Orientation) source(x, y, n int) (int, int) {
+	switch o {
+	case OrientRotate90:
+		return n - 1 - y, x
+	case OrientRotate180:
+		return n - 1 - x, n - 1 - y
+	case OrientRotate270:
+		return y, n - 1 - x
+	case OrientFlipH:
+		return n - 1 - x, y
+	case OrientFlipV:
+		return x, n - 1 - y
+	case OrientTranspose:
+		return y, x
+	case OrientTransverse:
+		return n - 1 - y, n - 1 - x
+	}
+	return x, y
+}
+
+// CanonicalOrientationHash returns an orientation-invariant Average Hash:
+// the lexicographically smallest (as a hex string) of the aHashes of the 8
+// rotations and flips of img, and the orientation that produced it, so that
+// AverageHash(o.Apply(img), hashSize) is the returned hash. Images that are
+// rotations or flips of each other get the same canonical hash.
+//
+// The mean is the same in every orientation, so the orientations are
+// enumerated on the hashSize x hashSize cells rather than on the image.
+func CanonicalOrientationHash(img image.Image, hashSize int) (*ImageHash, Orientation) {
+	base := AverageHash(img, hashSize)
+
+	best, bestOrient := base, OrientIdentity
+	for o := OrientRotate90; o <= OrientTransverse; o++ {
+		if h := o.permute(base); lessBits(h.hash, best.hash) {
+			best, bestOrient = h, o
+		}
+	}
+	return best, bestOrient
+}
+
+// permute returns the square hash h with its cells moved by o
+func (o Orientation) permute(h *ImageHash) *ImageHash {
+	n := h.rows
+	hash := make([]bool, len(h.hash))
+	for y := range n {
+		for x := range n {
+			sx, sy := o.source(x, y, n)
+			hash[y*n+x] = h.hash[sy*n+sx]
+		}
+	}
+	return &ImageHash{hash: hash, rows: n, cols: n, kind: h.kind}
+}
+
+// lessBits reports whether a sorts before b, with false before true
+func lessBits(a, b []bool) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return b[i]
+		}
+	}
+	return false
+}
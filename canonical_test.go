@@ -0,0 +1,70 @@
+package imagehashgo
+
+import (
+	"image"
+	"math/rand/v2"
+	"testing"
+)
+
+// cellImage is a random 8x8 grayscale image, already at the aHash size so
+// that rotating it commutes exactly with the (skipped) resize
+func cellImage() *image.Gray {
+	r := rand.New(rand.NewPCG(7, 11))
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(r.IntN(256))
+	}
+	return img
+}
+
+func TestCanonicalOrientationHash_Collapses(t *testing.T) {
+	img := cellImage()
+	want, _ := CanonicalOrientationHash(img, 8)
+
+	for o := OrientIdentity; o <= OrientTransverse; o++ {
+		t.Run(o.String(), func(t *testing.T) {
+			oriented := o.Apply(img)
+
+			// The cell permutation must agree with the image transform
+			if got, direct := o.permute(AverageHash(img, 8)), AverageHash(oriented, 8); got.ToString() != direct.ToString() {
+				t.Fatalf("cell permutation %s, image transform %s", got.ToString(), direct.ToString())
+			}
+
+			got, chosen := CanonicalOrientationHash(oriented, 8)
+			if got.ToString() != want.ToString() {
+				t.Errorf("canonical hash = %s, want %s", got.ToString(), want.ToString())
+			}
+			if roundTrip := AverageHash(chosen.Apply(oriented), 8); roundTrip.ToString() != got.ToString() {
+				t.Errorf("AverageHash(%s.Apply(img)) = %s, want %s", chosen, roundTrip.ToString(), got.ToString())
+			}
+			if back := o.Inverse().Apply(oriented); !sameGray(ToGrayscaleFast(back), img) {
+				t.Errorf("%s.Inverse() does not undo %s", o, o)
+			}
+		})
+	}
+}
+
+func TestCanonicalOrientationHash_Photo(t *testing.T) {
+	img := getBenchImage()
+	want, _ := CanonicalOrientationHash(img, 8)
+	for o := OrientRotate90; o <= OrientTransverse; o++ {
+		got, _ := CanonicalOrientationHash(o.Apply(img), 8)
+		if d, _ := got.Distance(want); d > 2 {
+			t.Errorf("%s: canonical hash distance %d, want <= 2", o, d)
+		}
+	}
+}
+
+func sameGray(a, b *image.Gray) bool {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return false
+	}
+	for y := range a.Bounds().Dy() {
+		for x := range a.Bounds().Dx() {
+			if a.GrayAt(a.Rect.Min.X+x, a.Rect.Min.Y+y) != b.GrayAt(b.Rect.Min.X+x, b.Rect.Min.Y+y) {
+				return false
+			}
+		}
+	}
+	return true
+}
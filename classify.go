@@ -0,0 +1,157 @@
+package imagehashgo
+
+import (
+	"image"
+	"slices"
+)
+
+// ImageClass is a coarse kind of image content, from ClassifyImage
+type ImageClass string
+
+const (
+	// ClassPhoto is continuous-tone content: photographs, renders, scans
+	// of pictures
+	ClassPhoto ImageClass = "photo"
+	// ClassScreenshot is flat panels of a few colors with sharp detail:
+	// user interfaces, slides, memes with a caption bar
+	ClassScreenshot ImageClass = "screenshot"
+	// ClassLineArt is strokes of one ink on a plain ground: drawings,
+	// diagrams, scanned text
+	ClassLineArt ImageClass = "lineart"
+	// ClassFlat is solid or near-solid content, see Quality.LowInformation
+	ClassFlat ImageClass = "flat"
+)
+
+// Thresholds used by ClassifyImage
+const (
+	// classLineArtInk is the share of the pixels off the ground that have
+	// the second most common gray level, from which they are one ink
+	classLineArtInk = 0.75
+	// classLineArtColors is the largest number of ColorSig bins of line art
+	classLineArtColors = 4
+	// classScreenshotPanels is the share of the pixels off the ground in
+	// the next seven most common gray levels, from which they are flat
+	// panels; on photos, even on a plain background, they spread out
+	classScreenshotPanels = 0.6
+	// classColorShare is the share of the pixels, in 255ths, from which a
+	// ColorSig bin counts as a color of the image
+	classColorShare = 2
+)
+
+// classStats are the statistics ClassifyImage decides on. The ground is
+// the most common gray level.
+type classStats struct {
+	// variance of the grayscale pixels
+	variance float64
+	// ink and panels are the shares of the pixels off the ground in the
+	// second most common gray level, and in the second to eighth
+	ink, panels float64
+	// colors is the number of ColorSig bins holding at least
+	// classColorShare 255ths of the pixels
+	colors int
+}
+
+// ClassifyImage labels img as a photo, screenshot, line art or flat
+// image from statistics of one grayscale conversion: the variance and the
+// most common levels of the gray pixels, and the number of colors of the
+// ColorSig counted during the conversion. Taking the most common level as
+// the ground, the rules are, in order:
+//
+//   - variance below LowInformationVariance: ClassFlat
+//   - at least 75% of the other pixels in one level, and at most 4 colors:
+//     ClassLineArt
+//   - at least 60% of the other pixels in seven levels: ClassScreenshot
+//   - otherwise ClassPhoto
+//
+// The class is a heuristic for choosing algorithms, see
+// RecommendedAlgorithms; borderline images, such as heavily compressed
+// screenshots, may fall either way.
+func ClassifyImage(img image.Image) ImageClass {
+	return measureClass(options{}.grayscaleWithSig(img)).class()
+}
+
+// RecommendedAlgorithms returns the algorithms that match images of class
+// best, most suitable first; WithAutoAlgorithm uses the first:
+//
+//	photo       phash, dhash     robust to recompression and color edits
+//	screenshot  dhash, dhash_v   sharp panel edges; phash sees mostly text
+//	lineart     dhash_v, dhash   strokes, which blur into ahash's mean
+//	flat        ahash            the others are decided by noise
+//
+// An unknown class gets the photo defaults. The result is a fresh copy.
+func RecommendedAlgorithms(class ImageClass) []HashKind {
+	switch class {
+	case ClassScreenshot:
+		return []HashKind{KindDifference, KindDifferenceVertical}
+	case ClassLineArt:
+		return []HashKind{KindDifferenceVertical, KindDifference}
+	case ClassFlat:
+		return []HashKind{KindAverage}
+	}
+	return []HashKind{KindPerceptual, KindDifference}
+}
+
+// WithAutoAlgorithm makes Hash and Hasher.Hash replace the kind they are
+// given with the first of RecommendedAlgorithms for the ClassifyImage
+// class of the (preprocessed) image; the image is converted to grayscale
+// once for both. The kind chosen is the Kind of the hash. Hashes of
+// different kinds may have the same shape, so compare Kind before
+// Distance. The algorithm functions ignore this option.
+func WithAutoAlgorithm() Option {
+	return func(o *options) {
+		o.autoAlgorithm = true
+	}
+}
+
+// measureClass computes the classStats of gray, whose ColorSig is sig
+func measureClass(gray *image.Gray, sig ColorSig) classStats {
+	var s classStats
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w == 0 || h == 0 {
+		return s
+	}
+
+	var levels [256]int
+	var sum, sumSq uint64
+	for y := range h {
+		for _, p := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			levels[p]++
+			sum += uint64(p)
+			sumSq += uint64(p) * uint64(p)
+		}
+	}
+	n := float64(w * h)
+	mean := float64(sum) / n
+	s.variance = max(float64(sumSq)/n-mean*mean, 0)
+
+	counts := levels[:]
+	slices.SortFunc(counts, func(a, b int) int { return b - a })
+	if rest := float64(w*h - counts[0]); rest > 0 {
+		s.ink = float64(counts[1]) / rest
+		panels := 0
+		for _, c := range counts[1:8] {
+			panels += c
+		}
+		s.panels = float64(panels) / rest
+	}
+
+	for _, share := range sig {
+		if share >= classColorShare {
+			s.colors++
+		}
+	}
+	return s
+}
+
+// class applies the rules of ClassifyImage
+func (s classStats) class() ImageClass {
+	switch {
+	case s.variance < LowInformationVariance:
+		return ClassFlat
+	case s.ink >= classLineArtInk && s.colors <= classLineArtColors:
+		return ClassLineArt
+	case s.panels >= classScreenshotPanels:
+		return ClassScreenshot
+	}
+	return ClassPhoto
+}
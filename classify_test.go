@@ -0,0 +1,109 @@
+package imagehashgo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/K0ng2/imagehash-go/internal/benchdata"
+)
+
+func TestClassifyImage(t *testing.T) {
+	for name, want := range map[string]ImageClass{
+		"photo.jpg":       ClassPhoto,
+		"gradient.png":    ClassPhoto,
+		"lineart.png":     ClassLineArt,
+		"text.png":        ClassLineArt,
+		"transparent.png": ClassLineArt,
+		"palette.gif":     ClassScreenshot,
+	} {
+		file, err := os.Open(filepath.Join("testdata", "golden", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ClassifyImage(img); got != want {
+			t.Errorf("%s: ClassifyImage = %s, want %s", name, got, want)
+		}
+	}
+
+	solid := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(solid, solid.Rect, &image.Uniform{color.NRGBA{40, 120, 200, 255}}, image.Point{}, draw.Src)
+	for name, c := range map[string]struct {
+		img  image.Image
+		want ImageClass
+	}{
+		// A photo on a plain white background is still a photo
+		"bench":      {getBenchImage(), ClassPhoto},
+		"wave":       {waveImage(999), ClassPhoto},
+		"noise":      {noiseImage(80, 60, 999), ClassPhoto},
+		"text":       {textPage(999), ClassLineArt},
+		"screenshot": {benchdata.Corpus()[2].Image, ClassScreenshot},
+		"solid":      {solid, ClassFlat},
+		"empty":      {image.NewGray(image.Rect(0, 0, 0, 0)), ClassFlat},
+	} {
+		if got := ClassifyImage(c.img); got != c.want {
+			t.Errorf("%s: ClassifyImage = %s, want %s", name, got, c.want)
+		}
+	}
+}
+
+func TestRecommendedAlgorithms(t *testing.T) {
+	for _, class := range []ImageClass{ClassPhoto, ClassScreenshot, ClassLineArt, ClassFlat, "meme"} {
+		kinds := RecommendedAlgorithms(class)
+		if len(kinds) == 0 {
+			t.Fatalf("%s: no algorithms", class)
+		}
+		for _, kind := range kinds {
+			if _, err := ParseHashKind(string(kind)); err != nil {
+				t.Errorf("%s: %v", class, err)
+			}
+		}
+		kinds[0] = "modified"
+		if RecommendedAlgorithms(class)[0] == "modified" {
+			t.Errorf("%s: RecommendedAlgorithms shares its result", class)
+		}
+	}
+	if got := RecommendedAlgorithms("meme")[0]; got != RecommendedAlgorithms(ClassPhoto)[0] {
+		t.Errorf("unknown class gets %s, want the photo defaults", got)
+	}
+}
+
+// The hasher records which algorithm ran, and hashes as that algorithm
+// does without the option
+func TestWithAutoAlgorithm(t *testing.T) {
+	h, err := NewHasher(KindAverage, 8, WithAutoAlgorithm())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Fingerprint() != "ahash/8/auto" {
+		t.Errorf("Fingerprint() = %q", h.Fingerprint())
+	}
+	for name, img := range map[string]image.Image{
+		"bench":      getBenchImage(),
+		"text":       textPage(999),
+		"screenshot": benchdata.Corpus()[2].Image,
+	} {
+		got, err := h.Hash(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind := RecommendedAlgorithms(ClassifyImage(img))[0]
+		if got.Kind() != kind {
+			t.Errorf("%s: Kind() = %s, want %s", name, got.Kind(), kind)
+		}
+		if want, _ := Hash(img, kind, 8); got.ToString() != want.ToString() {
+			t.Errorf("%s: hash %s, %s without the option %s", name, got.ToString(), kind, want.ToString())
+		}
+	}
+	if got, _ := h.Hash(getBenchImage()); got.Kind() == h.Kind() {
+		t.Errorf("the photo was hashed with the Hasher's %s", h.Kind())
+	}
+}
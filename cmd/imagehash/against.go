@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// imageExts are the file extensions the directory walks hash
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// runAgainst classifies every image under the given directories as a match
+// of an image under --baseline or as new, without changing the baseline.
+// With --copy-new-to the new images are copied there, see copyNew.
+func runAgainst(args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("against", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	var hf hashFlags
+	hf.register(fset)
+	baseline := fset.String("baseline", "", "directory of already known images (required)")
+	threshold := fset.Int("threshold", 4, "maximum distance to a baseline image for a match")
+	copyTo := fset.String("copy-new-to", "", "copy images without a match into this directory")
+
+	roots, err := parseInterspersed(fset, args)
+	if err != nil {
+		return exitUsage
+	}
+	if err := hf.setup(stderr); err != nil {
+		fmt.Fprintf(stderr, "imagehash against: %v\n", err)
+		return exitUsage
+	}
+	if *baseline == "" || len(roots) == 0 {
+		fmt.Fprintln(stderr, "usage: imagehash against --baseline DIR [flags] DIR...")
+		return exitUsage
+	}
+
+	baseNames, baseHashes, err := hf.hashTree(*baseline, nil, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash against: %v\n", err)
+		return exitFailure
+	}
+
+	status := exitOK
+	for _, root := range roots {
+		names, hashes, err := hf.hashTree(root, nil, stderr)
+		if err != nil {
+			fmt.Fprintf(stderr, "imagehash against: %v\n", err)
+			return exitFailure
+		}
+		for i, h := range hashes {
+			best, bestDist := -1, 0
+			for j, b := range baseHashes {
+				if d, err := h.Distance(b); err == nil && d <= *threshold && (best < 0 || d < bestDist) {
+					best, bestDist = j, d
+				}
+			}
+			if best >= 0 {
+				fmt.Fprintf(stdout, "match\t%s\t%s\t%d\n", names[i], baseNames[best], bestDist)
+				continue
+			}
+			fmt.Fprintf(stdout, "new\t%s\n", names[i])
+			if *copyTo != "" {
+				if err := copyNew(names[i], root, *copyTo); err != nil {
+					fmt.Fprintf(stderr, "imagehash against: %v\n", err)
+					status = exitFailure
+				}
+			}
+		}
+	}
+	return status
+}
+
+// isImageFile reports whether path has one of imageExts
+func isImageFile(path string) bool {
+	return imageExts[strings.ToLower(filepath.Ext(path))]
+}
+
+// hashTree hashes every image file under root in lexical order, or only
+// those the plan selects when it is not nil. Files that fail to hash are
+// reported to stderr and skipped.
+func (f *hashFlags) hashTree(root string, plan *imagehashgo.TriagePlan, stderr io.Writer) ([]string, []*imagehashgo.ImageHash, error) {
+	var names []string
+	var hashes []*imagehashgo.ImageHash
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isImageFile(path) || (plan != nil && !plan.Selected(path)) {
+			return nil
+		}
+		h, err := f.hashFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "imagehash: %v\n", err)
+			return nil
+		}
+		names = append(names, path)
+		hashes = append(hashes, h)
+		return nil
+	})
+	return names, hashes, err
+}
+
+// copyNew copies src, a file under root, to the same relative path under
+// dir, creating the directories on the way. A file already there, e.g. from
+// another root, is kept and the copy gets a numeric suffix: a.jpg, a-1.jpg,
+// a-2.jpg, ...
+func copyNew(src, root, dir string) error {
+	rel, err := filepath.Rel(root, src)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	ext := filepath.Ext(dst)
+	stem := strings.TrimSuffix(dst, ext)
+	for n := 1; ; n++ {
+		err := copyFile(src, dst)
+		if !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		dst = stem + "-" + strconv.Itoa(n) + ext
+	}
+}
+
+// copyFile copies src to dst, refusing to overwrite an existing file. A
+// failed copy removes dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pattern draws a 96x96 image whose brightness is f(x, y)
+func pattern(f func(x, y float64) float64) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 96, 96))
+	for y := range 96 {
+		for x := range 96 {
+			img.SetGray(x, y, color.Gray{Y: uint8(127 + 127*f(float64(x)/96, float64(y)/96))})
+		}
+	}
+	return img
+}
+
+func writeImage(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if filepath.Ext(path) == ".jpg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 70})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAgainst_Classifies(t *testing.T) {
+	t.Chdir(t.TempDir())
+	waves := pattern(func(x, y float64) float64 { return math.Sin(9*x) * math.Cos(5*y) })
+	writeImage(t, "baseline/waves.png", waves)
+	writeImage(t, "baseline/diagonal.png", pattern(func(x, y float64) float64 { return math.Sin(12 * (x + y)) }))
+	writeImage(t, "baseline/rings.png", pattern(func(x, y float64) float64 { return math.Cos(30 * math.Hypot(x-0.5, y-0.5)) }))
+
+	writeImage(t, "card/DSC001.jpg", waves)
+	writeImage(t, "card/DSC002.png", pattern(func(x, y float64) float64 { return math.Sin(20 * x * y) }))
+	writeImage(t, "card/DSC003.png", pattern(func(x, y float64) float64 { return math.Cos(7*x) * math.Sin(17*y) }))
+	if err := os.WriteFile("card/notes.txt", []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("new", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCommand("against", "--baseline", "baseline", "card", "--copy-new-to", "new")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), stdout)
+	}
+	if f := strings.Split(lines[0], "\t"); len(f) != 4 || f[0] != "match" || f[1] != filepath.Join("card", "DSC001.jpg") || f[2] != filepath.Join("baseline", "waves.png") {
+		t.Errorf("line 0 = %q, want DSC001.jpg to match waves.png", lines[0])
+	}
+	for i, name := range []string{"DSC002.png", "DSC003.png"} {
+		if want := "new\t" + filepath.Join("card", name); lines[i+1] != want {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i+1], want)
+		}
+		if _, err := os.Stat(filepath.Join("new", name)); err != nil {
+			t.Errorf("%s was not copied: %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir("baseline"); len(entries) != 3 {
+		t.Errorf("baseline has %d files, want it unchanged", len(entries))
+	}
+	if entries, _ := os.ReadDir("new"); len(entries) != 2 {
+		t.Errorf("new has %d files, want 2", len(entries))
+	}
+}
+
+func TestAgainst_CopyNames(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeImage(t, "baseline/rings.png", pattern(func(x, y float64) float64 { return math.Cos(30 * math.Hypot(x-0.5, y-0.5)) }))
+	// Equal names in different directories and roots
+	writeImage(t, "card1/100/DSC001.png", pattern(func(x, y float64) float64 { return math.Sin(20 * x * y) }))
+	writeImage(t, "card1/101/DSC001.png", pattern(func(x, y float64) float64 { return math.Cos(7*x) * math.Sin(17*y) }))
+	writeImage(t, "card2/100/DSC001.png", pattern(func(x, y float64) float64 { return math.Sin(12 * (x + y)) }))
+
+	if _, stderr, code := runCommand("against", "--baseline", "baseline", "card1", "card2", "--copy-new-to", "new"); code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	for _, pair := range [][2]string{
+		{"card1/100/DSC001.png", "new/100/DSC001.png"},
+		{"card1/101/DSC001.png", "new/101/DSC001.png"},
+		{"card2/100/DSC001.png", "new/100/DSC001-1.png"},
+	} {
+		src, _ := os.ReadFile(pair[0])
+		if dst, err := os.ReadFile(pair[1]); err != nil || !bytes.Equal(src, dst) {
+			t.Errorf("%s is not a copy of %s: %v", pair[1], pair[0], err)
+		}
+	}
+}
+
+func TestCopyFile_RemovesDstOnError(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// Reading a directory fails after the destination is created
+	if err := os.Mkdir("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyFile("dir", "dst"); err == nil {
+		t.Fatal("copyFile of a directory expected error")
+	}
+	if _, err := os.Stat("dst"); !os.IsNotExist(err) {
+		t.Errorf("dst left behind after a failed copy: %v", err)
+	}
+}
+
+func TestAgainst_Usage(t *testing.T) {
+	if _, _, code := runCommand("against", "card"); code != exitUsage {
+		t.Errorf("missing --baseline exit code = %d, want %d", code, exitUsage)
+	}
+}
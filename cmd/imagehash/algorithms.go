@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// allKinds are the algorithms of --algo all
+var allKinds = func() []imagehashgo.HashKind {
+	var kinds []imagehashgo.HashKind
+	for _, a := range imagehashgo.Algorithms() {
+		kinds = append(kinds, a.Kind)
+	}
+	return kinds
+}()
+
+// wantsAlgoHelp reports whether args hold --algo help, which lists the
+// algorithms instead of running the command. Flags end at "--".
+func wantsAlgoHelp(args []string) bool {
+	for i, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "algo" {
+			continue
+		}
+		if hasValue {
+			return value == "help"
+		}
+		return i+1 < len(args) && args[i+1] == "help"
+	}
+	return false
+}
+
+// printAlgorithms renders imagehashgo.Algorithms as a table
+func printAlgorithms(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ALGO\tNAME\tBITS\tPARAMETERS\tPYTHON")
+	for _, a := range imagehashgo.Algorithms() {
+		var params []string
+		bits := ""
+		for _, p := range a.Params {
+			limit := fmt.Sprintf("%d-%d", p.Min, p.Max)
+			if p.Max == 0 {
+				limit = fmt.Sprintf(">=%d", p.Min)
+			}
+			params = append(params, fmt.Sprintf("%s=%d (%s)", p.Name, p.Default, limit))
+			if p.Name == "hash_size" {
+				n, _ := a.Bits(p.Default)
+				bits = fmt.Sprintf("%d (hash_size^2)", n)
+			}
+		}
+		python := a.PythonFunc
+		if python == "" {
+			python = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", a.Kind, a.Name, bits, strings.Join(params, ", "), python)
+	}
+	tw.Flush()
+}
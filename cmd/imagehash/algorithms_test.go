@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAlgoHelp(t *testing.T) {
+	for _, args := range [][]string{
+		{"cross", "--algo", "help"},
+		{"dedupe", "-algo=help", "a.png"},
+		{"bench", "a.png", "--algo", "help"},
+	} {
+		stdout, _, code := runCommand(args...)
+		if code != exitOK {
+			t.Fatalf("%v exit code = %d", args, code)
+		}
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		if len(lines) != 1+len(allKinds) || !strings.HasPrefix(lines[0], "ALGO") {
+			t.Fatalf("%v: unexpected table:\n%s", args, stdout)
+		}
+		if !strings.Contains(stdout, "highfreq_factor=4 (>=1)") || !strings.Contains(stdout, "dhash_vertical") {
+			t.Errorf("%v: table lacks parameters or Python names:\n%s", args, stdout)
+		}
+	}
+
+	// A file named help, or one after "--", is not a request for help
+	for _, args := range [][]string{{"--algo", "dhash", "help"}, {"--", "--algo", "help"}, {"--algorithm", "help"}} {
+		if wantsAlgoHelp(args) {
+			t.Errorf("wantsAlgoHelp(%q) = true", args)
+		}
+	}
+}
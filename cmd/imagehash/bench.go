@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+type benchResult struct {
+	File          string  `json:"file"`
+	GrayscalePath string  `json:"grayscale_path"`
+	Algo          string  `json:"algo"`
+	Size          int     `json:"size"`
+	Iterations    int     `json:"iterations"`
+	MedianNs      int64   `json:"median_ns"`
+	P95Ns         int64   `json:"p95_ns"`
+	OpsPerSec     float64 `json:"ops_per_sec"`
+	AllocsPerOp   uint64  `json:"allocs_per_op"`
+}
+
+// runBench decodes every file once and times each algorithm and size on it
+func runBench(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	algo := fs.String("algo", "all", "comma-separated hash algorithms, or all (help lists them)")
+	sizes := fs.String("sizes", "8", "comma-separated hash sizes")
+	iterations := fs.Int("iterations", 100, "timed iterations per combination, after one warmup")
+	format := fs.String("format", "text", "output format: text or json")
+	verbose := fs.Bool("verbose", false, "wrap --format json output in an object with the effective configuration")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	kinds, err := parseKinds(*algo)
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash bench: %v\n", err)
+		return exitUsage
+	}
+	sizeList, err := parseSizes(*sizes)
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash bench: %v\n", err)
+		return exitUsage
+	}
+	if *iterations < 1 || (*format != "text" && *format != "json") || len(paths) == 0 {
+		fmt.Fprintln(stderr, "usage: imagehash bench [--algo all] [--sizes 8,16] [--iterations N] [--format text|json] FILE...")
+		return exitUsage
+	}
+
+	var results []benchResult
+	for _, path := range paths {
+		img, err := decodeFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "imagehash bench: %v\n", err)
+			return exitFailure
+		}
+		for _, kind := range kinds {
+			for _, size := range sizeList {
+				r := benchOne(img, kind, size, *iterations)
+				r.File = path
+				results = append(results, r)
+			}
+		}
+	}
+
+	if *format == "json" {
+		var out any = results
+		if *verbose {
+			out = struct {
+				Config  map[string]string `json:"config"`
+				Results []benchResult     `json:"results"`
+			}{effectiveConfig(fs), results}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(stderr, "imagehash bench: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tPATH\tALGO\tSIZE\tMEDIAN\tP95\tOPS/S\tALLOCS/OP")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%v\t%v\t%.0f\t%d\n", r.File, r.GrayscalePath, r.Algo, r.Size,
+			time.Duration(r.MedianNs), time.Duration(r.P95Ns), r.OpsPerSec, r.AllocsPerOp)
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(stderr, "imagehash bench: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// benchOne times n hashes of img after one warmup run
+func benchOne(img image.Image, kind imagehashgo.HashKind, size, n int) benchResult {
+	hash := func() {
+		// kind comes from ParseHashKind, so Hash cannot fail
+		_, _ = imagehashgo.Hash(img, kind, size)
+	}
+	hash()
+
+	var before, after runtime.MemStats
+	durations := make([]time.Duration, n)
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := range durations {
+		t := time.Now()
+		hash()
+		durations[i] = time.Since(t)
+	}
+	total := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	slices.Sort(durations)
+	return benchResult{
+		GrayscalePath: imagehashgo.GrayscalePath(img),
+		Algo:          string(kind),
+		Size:          size,
+		Iterations:    n,
+		MedianNs:      int64(durations[n/2]),
+		P95Ns:         int64(durations[min(n*95/100, n-1)]),
+		OpsPerSec:     float64(n) / total.Seconds(),
+		AllocsPerOp:   (after.Mallocs - before.Mallocs) / uint64(n),
+	}
+}
+
+func parseKinds(s string) ([]imagehashgo.HashKind, error) {
+	if s == "all" {
+		return allKinds, nil
+	}
+	var kinds []imagehashgo.HashKind
+	for name := range strings.SplitSeq(s, ",") {
+		kind, err := imagehashgo.ParseHashKind(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for field := range strings.SplitSeq(s, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || size < 2 {
+			return nil, fmt.Errorf("invalid hash size %q", field)
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
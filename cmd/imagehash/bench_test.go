@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBench_JSON(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, stderr, code := runCommand("bench", "--format", "json", "--iterations", "3", "--sizes", "8,16", "--algo", "ahash,phash", "a.png")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+
+	var results []map[string]any
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout, err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 2 algorithms x 2 sizes", len(results))
+	}
+	keys := []string{"file", "grayscale_path", "algo", "size", "iterations", "median_ns", "p95_ns", "ops_per_sec", "allocs_per_op"}
+	for _, r := range results {
+		if len(r) != len(keys) {
+			t.Errorf("result has keys %v, want %v", r, keys)
+		}
+		for _, k := range keys {
+			if _, ok := r[k]; !ok {
+				t.Errorf("result is missing %q: %v", k, r)
+			}
+		}
+		if r["file"] != "a.png" || r["iterations"] != 3.0 || r["grayscale_path"] != "gray" {
+			t.Errorf("unexpected result %v", r)
+		}
+		if r["median_ns"].(float64) <= 0 || r["p95_ns"].(float64) < r["median_ns"].(float64) {
+			t.Errorf("inconsistent latencies %v", r)
+		}
+	}
+}
+
+func TestBench_Text(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, _, code := runCommand("bench", "--iterations", "3", "a.png")
+	if code != exitOK {
+		t.Fatalf("exit code = %d", code)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 1+len(allKinds) || !strings.HasPrefix(lines[0], "FILE") {
+		t.Errorf("unexpected table:\n%s", stdout)
+	}
+	for _, args := range [][]string{{"bench", "--sizes", "x", "a.png"}, {"bench", "--algo", "whash", "a.png"}, {"bench"}} {
+		if _, _, code := runCommand(args...); code != exitUsage {
+			t.Errorf("%v exit code = %d, want %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompare_Builtin(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, stderr, code := runCommand("compare", "a.png", "b.png")
+	if code != exitOK || stdout != "match\tphash 0<=6 ok, dhash 0<=6 ok\n" {
+		t.Errorf("a.png b.png: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+	stdout, _, code = runCommand("compare", "--profile", "loose", "a.png", "c.png")
+	if code != exitFailure || !strings.HasPrefix(stdout, "different\tphash ") {
+		t.Errorf("a.png c.png: exit %d, stdout %q", code, stdout)
+	}
+
+	stdout, _, code = runCommand("compare", "a.png", "b.png", "--format", "json")
+	var out struct {
+		Profile string `json:"profile"`
+		Match   bool   `json:"match"`
+		Leaves  []struct {
+			Algo string `json:"algo"`
+		} `json:"algorithms"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil || code != exitOK {
+		t.Fatalf("json: exit %d, %v: %s", code, err, stdout)
+	}
+	if out.Profile != "strict" || !out.Match || len(out.Leaves) != 2 || out.Leaves[0].Algo != "phash" {
+		t.Errorf("json output %+v", out)
+	}
+}
+
+func TestCompare_ProfileFile(t *testing.T) {
+	writeTestImages(t)
+	rules := "combine: any\nalgorithms:\n  - algo: ahash\n    size: 4\n    threshold: 16\n"
+	if err := os.WriteFile("rules.yaml", []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Every distance of a 4x4 hash is within 16
+	stdout, stderr, code := runCommand("compare", "a.png", "c.png", "--profile", "rules.yaml")
+	if code != exitOK || !strings.HasPrefix(stdout, "match\tahash ") {
+		t.Errorf("exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	if err := os.WriteFile("bad.yaml", []byte("algorithms:\n  - algo: whash\n    threshold: 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCommand("compare", "a.png", "c.png", "--profile", "bad.yaml")
+	if code != exitUsage || !strings.Contains(stderr, "bad.yaml: line 2: unknown hash kind") {
+		t.Errorf("bad profile: exit %d, stderr %q", code, stderr)
+	}
+
+	for _, args := range [][]string{{"compare", "a.png"}, {"compare", "a.png", "b.png", "--format", "xml"}} {
+		if _, _, code := runCommand(args...); code != exitUsage {
+			t.Errorf("%v: exit %d, want %d", args, code, exitUsage)
+		}
+	}
+	if _, _, code := runCommand("compare", "a.png", "broken.png"); code != exitFailure {
+		t.Errorf("broken file: exit %d, want %d", code, exitFailure)
+	}
+}
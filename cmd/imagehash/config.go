@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// envPrefix starts the environment variables read as flag defaults
+const envPrefix = "IMAGEHASH_"
+
+// config holds flag defaults from the config file or the environment.
+// Top-level keys are the flags shared by the hashing commands; keys of a
+// section apply only to the command of the same name.
+type config struct {
+	global   map[string]string
+	sections map[string]map[string]string
+}
+
+// defaultConfigPath returns ~/.config/imagehash/config.toml, or the
+// equivalent under $XDG_CONFIG_HOME
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "imagehash", "config.toml")
+}
+
+// applyConfig sets every flag of fset not given on the command line from
+// the environment or, failing that, from the config file. A missing
+// default config file is not an error; unknown keys are reported on fset's
+// output and otherwise ignored.
+func applyConfig(fset *flag.FlagSet, path string) error {
+	explicit := make(map[string]bool)
+	fset.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	required := path != ""
+	if !required {
+		path = defaultConfigPath()
+	}
+	file := config{global: map[string]string{}, sections: map[string]map[string]string{}}
+	if path != "" {
+		var err error
+		file, err = loadConfig(path)
+		if errors.Is(err, fs.ErrNotExist) && !required {
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	env := envConfig(os.Environ())
+
+	for _, c := range []config{file, env} {
+		warnUnknown(fset, c)
+	}
+
+	// Later sources override earlier ones: file, then environment, each
+	// global before section
+	values := make(map[string]string)
+	for _, c := range []config{file, env} {
+		for k, v := range c.global {
+			values[k] = v
+		}
+		for k, v := range c.sections[fset.Name()] {
+			values[k] = v
+		}
+	}
+	for k, v := range values {
+		if explicit[k] || fset.Lookup(k) == nil {
+			continue
+		}
+		if err := fset.Set(k, v); err != nil {
+			return fmt.Errorf("config %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// warnUnknown reports keys that no flag will read: top-level keys that are
+// not shared hashing flags, sections that are not commands, and section
+// keys the command does not define
+func warnUnknown(fset *flag.FlagSet, c config) {
+	shared := flag.NewFlagSet("", flag.ContinueOnError)
+	var hf hashFlags
+	hf.register(shared)
+	for k := range c.global {
+		if shared.Lookup(k) == nil && fset.Lookup(k) == nil {
+			fmt.Fprintf(fset.Output(), "imagehash %s: warning: unknown config key %q\n", fset.Name(), k)
+		}
+	}
+	for name, section := range c.sections {
+		if !isCommand(name) {
+			fmt.Fprintf(fset.Output(), "imagehash %s: warning: unknown config section %q\n", fset.Name(), name)
+			continue
+		}
+		if name != fset.Name() {
+			continue
+		}
+		for k := range section {
+			if fset.Lookup(k) == nil {
+				fmt.Fprintf(fset.Output(), "imagehash %s: warning: unknown config key %q in [%s]\n", fset.Name(), k, name)
+			}
+		}
+	}
+}
+
+func isCommand(name string) bool {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// envConfig reads IMAGEHASH_FLAG_NAME as the flag flag-name and
+// IMAGEHASH_COMMAND_FLAG_NAME as flag-name of command
+func envConfig(environ []string) config {
+	c := config{global: map[string]string{}, sections: map[string]map[string]string{}}
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(k, envPrefix) || k == envPrefix+"CONFIG" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(k, envPrefix))
+		if name, rest, ok := strings.Cut(key, "_"); ok && isCommand(name) {
+			if c.sections[name] == nil {
+				c.sections[name] = map[string]string{}
+			}
+			c.sections[name][configKey(rest)] = v
+			continue
+		}
+		c.global[configKey(key)] = v
+	}
+	return c
+}
+
+// configKey maps a TOML or environment key to a flag name
+func configKey(k string) string {
+	return strings.ReplaceAll(k, "_", "-")
+}
+
+// loadConfig reads the subset of TOML needed for flag defaults: comments,
+// [section] headers and key = value lines with string, integer, float or
+// boolean values
+func loadConfig(path string) (config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return config{}, err
+	}
+	defer f.Close()
+	c, err := parseConfig(f)
+	if err != nil {
+		return config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+func parseConfig(r io.Reader) (config, error) {
+	c := config{global: map[string]string{}, sections: map[string]map[string]string{}}
+	current := c.global
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return config{}, fmt.Errorf("line %d: malformed section header", n)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if c.sections[name] == nil {
+				c.sections[name] = map[string]string{}
+			}
+			current = c.sections[name]
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" {
+			return config{}, fmt.Errorf("line %d: expected key = value", n)
+		}
+		value, err := parseValue(v)
+		if err != nil {
+			return config{}, fmt.Errorf("line %d: %w", n, err)
+		}
+		current[configKey(k)] = value
+	}
+	return c, sc.Err()
+}
+
+// stripComment removes a # comment that is not inside a string
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quote != 0 && ch == '\\' && quote == '"':
+			i++
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '"' || ch == '\''):
+			quote = ch
+		case quote == 0 && ch == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseValue converts a TOML value to the string form flag.Value.Set takes
+func parseValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		s, err := strconv.Unquote(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", v)
+		}
+		return s, nil
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("invalid string %s", v)
+		}
+		return v[1 : len(v)-1], nil
+	case v == "true" || v == "false":
+		return v, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(v, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("unsupported value %s", v)
+	}
+	return strings.ReplaceAll(v, "_", ""), nil
+}
+
+// effectiveConfig returns the value of every flag of fset after the
+// config file, environment and command line are applied
+func effectiveConfig(fset *flag.FlagSet) map[string]string {
+	m := make(map[string]string)
+	fset.VisitAll(func(f *flag.Flag) { m[f.Name] = f.Value.String() })
+	return m
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain keeps the user's config file and environment out of the tests
+func TestMain(m *testing.M) {
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); strings.HasPrefix(k, envPrefix) {
+			os.Unsetenv(k)
+		}
+	}
+	dir, err := os.MkdirTemp("", "imagehash-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verboseConfig runs cross with --format json --verbose and returns the
+// effective configuration
+func verboseConfig(t *testing.T, args ...string) (map[string]string, string) {
+	t.Helper()
+	args = append([]string{"cross", "--format", "json", "--verbose", "a.png", "b.png"}, args...)
+	stdout, stderr, code := runCommand(args...)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	var out struct {
+		Config map[string]string `json:"config"`
+		Pairs  []crossPair       `json:"pairs"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		t.Fatalf("%v in %s", err, stdout)
+	}
+	return out.Config, stderr
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	writeTestImages(t)
+	writeConfig(t, "config.toml", `
+# defaults for every command
+algo = "ahash"
+size = 16
+skip_low_information = true
+
+[cross]
+threshold = 3 # only close pairs
+`)
+	t.Setenv("IMAGEHASH_ALGO", "phash")
+	t.Setenv("IMAGEHASH_SIZE", "12")
+
+	cfg, _ := verboseConfig(t, "--config", "config.toml", "--algo", "dhash")
+	want := map[string]string{
+		"algo":                 "dhash", // flag over env and file
+		"size":                 "12",    // env over file
+		"skip-low-information": "true",  // file over default
+		"threshold":            "3",     // file section over default
+		"strict":               "false", // default
+	}
+	for k, v := range want {
+		if cfg[k] != v {
+			t.Errorf("%s = %q, want %q", k, cfg[k], v)
+		}
+	}
+
+	// A command section in the environment overrides the file section
+	t.Setenv("IMAGEHASH_CROSS_THRESHOLD", "5")
+	if cfg, _ := verboseConfig(t, "--config", "config.toml"); cfg["threshold"] != "5" || cfg["algo"] != "phash" {
+		t.Errorf("threshold = %q, algo = %q, want 5 and phash", cfg["threshold"], cfg["algo"])
+	}
+}
+
+func TestConfigDefaultPath(t *testing.T) {
+	writeTestImages(t)
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	writeConfig(t, filepath.Join(home, "imagehash", "config.toml"), "size = 4\n")
+
+	if cfg, _ := verboseConfig(t); cfg["size"] != "4" {
+		t.Errorf("size = %q, want 4 from the default config file", cfg["size"])
+	}
+}
+
+func TestConfigUnknownKeysWarn(t *testing.T) {
+	writeTestImages(t)
+	writeConfig(t, "config.toml", "colour = \"blue\"\n[cross]\nfoo = 1\n[nope]\nx = 1\n")
+	t.Setenv("IMAGEHASH_BOGUS", "1")
+
+	_, stderr := verboseConfig(t, "--config", "config.toml")
+	for _, want := range []string{`unknown config key "colour"`, `unknown config key "foo" in [cross]`, `unknown config section "nope"`, `unknown config key "bogus"`} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr does not warn %s:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	writeTestImages(t)
+	writeConfig(t, "bad.toml", "size = [1, 2]\n")
+	writeConfig(t, "badvalue.toml", "size = \"big\"\n")
+
+	for _, args := range [][]string{
+		{"cross", "--config", "missing.toml", "a.png"},
+		{"cross", "--config", "bad.toml", "a.png"},
+		{"cross", "--config", "badvalue.toml", "a.png"},
+	} {
+		if _, stderr, code := runCommand(args...); code != exitUsage {
+			t.Errorf("%v: exit code = %d, want %d (stderr: %s)", args, code, exitUsage, stderr)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := parseConfig(strings.NewReader(`
+a = "x # not a comment" # a comment
+b = 'literal\n'
+c = 1_000
+d = false
+[dedupe]
+report_path = "out.html"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "x # not a comment", "b": `literal\n`, "c": "1000", "d": "false"}
+	for k, v := range want {
+		if c.global[k] != v {
+			t.Errorf("%s = %q, want %q", k, c.global[k], v)
+		}
+	}
+	if c.sections["dedupe"]["report-path"] != "out.html" {
+		t.Errorf("sections = %v", c.sections)
+	}
+
+	if _, err := parseConfig(strings.NewReader("[open\n")); err == nil {
+		t.Error("expected an error for a malformed section header")
+	}
+	if _, err := parseConfig(strings.NewReader("novalue\n")); err == nil {
+		t.Error("expected an error for a line without =")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+type crossPair struct {
+	A        string `json:"a"`
+	B        string `json:"b"`
+	Distance int    `json:"distance"`
+}
+
+// runCross hashes every file once and prints the pairwise distance table,
+// or only the pairs within --threshold
+func runCross(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("cross", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var hf hashFlags
+	hf.register(fs)
+	format := fs.String("format", "text", "output format: text or json")
+	threshold := fs.Int("threshold", -1, "only print pairs at or under this distance")
+	strict := fs.Bool("strict", false, "fail if any file cannot be hashed")
+	verbose := fs.Bool("verbose", false, "wrap --format json output in an object with the effective configuration")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "imagehash cross: unknown format %q\n", *format)
+		return exitUsage
+	}
+	if err := hf.setup(stderr); err != nil {
+		fmt.Fprintf(stderr, "imagehash cross: %v\n", err)
+		return exitUsage
+	}
+
+	var names []string
+	var hashes []*imagehashgo.ImageHash
+	for _, path := range paths {
+		h, err := hf.hashFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "imagehash cross: %v\n", err)
+			if *strict {
+				return exitFailure
+			}
+			continue
+		}
+		names = append(names, path)
+		hashes = append(hashes, h)
+	}
+
+	dist := make([][]int, len(hashes))
+	var pairs []crossPair
+	for i := range hashes {
+		dist[i] = make([]int, len(hashes))
+		for j := range hashes {
+			// All hashes share algorithm and size, so shapes always match
+			dist[i][j], _ = hashes[i].Distance(hashes[j])
+			if j > i && (*threshold < 0 || dist[i][j] <= *threshold) {
+				pairs = append(pairs, crossPair{names[i], names[j], dist[i][j]})
+			}
+		}
+	}
+
+	if *format == "json" {
+		if pairs == nil {
+			pairs = []crossPair{}
+		}
+		var out any = pairs
+		if *verbose {
+			out = struct {
+				Config map[string]string `json:"config"`
+				Pairs  []crossPair       `json:"pairs"`
+			}{effectiveConfig(fs), pairs}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(stderr, "imagehash cross: %v\n", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if *threshold >= 0 {
+		for _, p := range pairs {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t\n", p.A, p.B, p.Distance)
+		}
+	} else {
+		fmt.Fprint(tw, "\t")
+		for _, name := range names {
+			fmt.Fprintf(tw, "%s\t", name)
+		}
+		fmt.Fprintln(tw)
+		for i, name := range names {
+			fmt.Fprintf(tw, "%s\t", name)
+			for j := range names {
+				fmt.Fprintf(tw, "%d\t", dist[i][j])
+			}
+			fmt.Fprintln(tw)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintf(stderr, "imagehash cross: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+// writeTestImages creates a.png and b.png (the same gradient), c.png (the
+// inverted gradient) and broken.png in a temporary working directory
+func writeTestImages(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	gradient := func(invert bool) image.Image {
+		img := image.NewGray(image.Rect(0, 0, 64, 64))
+		for y := range 64 {
+			for x := range 64 {
+				v := uint8((x*x + y*3) % 256)
+				if invert {
+					v = 255 - v
+				}
+				img.SetGray(x, y, color.Gray{Y: v})
+			}
+		}
+		return img
+	}
+	for name, img := range map[string]image.Image{"a.png": gradient(false), "b.png": gradient(false), "c.png": gradient(true)} {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile("broken.png", []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runCommand(args ...string) (stdout, stderr string, code int) {
+	var out, errOut bytes.Buffer
+	code = run(args, &out, &errOut)
+	return out.String(), errOut.String(), code
+}
+
+func TestCross_Table(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, stderr, code := runCommand("cross", "a.png", "b.png", "broken.png", "c.png", "--algo", "dhash")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "broken.png") {
+		t.Errorf("stderr should report broken.png, got %q", stderr)
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want header + 3 rows:\n%s", len(lines), stdout)
+	}
+	if got := strings.Fields(lines[0]); strings.Join(got, " ") != "a.png b.png c.png" {
+		t.Errorf("header = %q", lines[0])
+	}
+	for i, name := range []string{"a.png", "b.png", "c.png"} {
+		fields := strings.Fields(lines[i+1])
+		if len(fields) != 4 || fields[0] != name || fields[i+1] != "0" {
+			t.Errorf("row %d = %q", i, lines[i+1])
+		}
+	}
+	if fields := strings.Fields(lines[1]); fields[2] != "0" || fields[3] == "0" {
+		t.Errorf("a.png row = %q, want 0 to b.png and non-zero to c.png", lines[1])
+	}
+}
+
+func TestCross_Threshold(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, _, code := runCommand("cross", "--threshold", "2", "a.png", "b.png", "c.png")
+	if code != exitOK {
+		t.Fatalf("exit code = %d", code)
+	}
+	if fields := strings.Fields(stdout); strings.Join(fields, " ") != "a.png b.png 0" {
+		t.Errorf("threshold output = %q, want only a.png b.png 0", stdout)
+	}
+
+	stdout, _, code = runCommand("cross", "--format", "json", "--threshold", "2", "a.png", "b.png", "c.png")
+	if code != exitOK {
+		t.Fatalf("exit code = %d", code)
+	}
+	var pairs []crossPair
+	if err := json.Unmarshal([]byte(stdout), &pairs); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout, err)
+	}
+	if len(pairs) != 1 || pairs[0] != (crossPair{"a.png", "b.png", 0}) {
+		t.Errorf("pairs = %+v", pairs)
+	}
+}
+
+func TestCross_JSONAllPairs(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, _, _ := runCommand("cross", "--format", "json", "a.png", "b.png", "c.png")
+	var pairs []crossPair
+	if err := json.Unmarshal([]byte(stdout), &pairs); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout, err)
+	}
+	if len(pairs) != 3 {
+		t.Errorf("got %d pairs, want 3", len(pairs))
+	}
+}
+
+func TestCross_Strict(t *testing.T) {
+	writeTestImages(t)
+
+	if _, _, code := runCommand("cross", "--strict", "a.png", "broken.png"); code != exitFailure {
+		t.Errorf("strict exit code = %d, want %d", code, exitFailure)
+	}
+	if _, _, code := runCommand("cross", "a.png", "broken.png"); code != exitOK {
+		t.Errorf("non-strict exit code = %d, want %d", code, exitOK)
+	}
+	if _, _, code := runCommand("cross", "--algo", "nope", "a.png"); code != exitUsage {
+		t.Errorf("bad algo exit code = %d, want %d", code, exitUsage)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+	"github.com/K0ng2/imagehash-go/report"
+)
+
+// runDedupe groups files whose hashes are within --threshold of each other,
+// directly or through other files of the group, and suggests which file of
+// each group to keep: the one with the most pixels, then the largest file.
+// With --triage the arguments are directories, and only the files a
+// TriageScan selects are hashed; exact copies reuse the hash of the first.
+// --link-groups writes the groups as directories of links for review in a
+// file browser, see linkGroups.
+func runDedupe(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dedupe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var hf hashFlags
+	hf.register(fs)
+	threshold := fs.Int("threshold", 4, "maximum distance between duplicates")
+	reportPath := fs.String("report", "", "also write an HTML report with thumbnails to this file")
+	maxThumbs := fs.Int("max-thumbnails", 8, "thumbnails embedded per group in the report")
+	triage := fs.Bool("triage", false, "treat the arguments as directories and only hash sampled files, files of similar size and one of each exact copy group")
+	linkDir := fs.String("link-groups", "", "create a directory per group in this directory, with symlinks to the members named by distance to the keeper")
+	copyLinks := fs.Bool("copy", false, "with --link-groups, copy the files instead of linking them")
+	force := fs.Bool("force", false, "with --link-groups, replace the group directories of a previous run")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if err := hf.setup(stderr); err != nil {
+		fmt.Fprintf(stderr, "imagehash dedupe: %v\n", err)
+		return exitUsage
+	}
+	if *maxThumbs < 1 {
+		fmt.Fprintf(stderr, "imagehash dedupe: --max-thumbnails must be at least 1, got %d\n", *maxThumbs)
+		return exitUsage
+	}
+
+	if *reportPath != "" {
+		hf.thumbs = make(map[string]image.Image)
+	}
+
+	var names []string
+	var hashes []*imagehashgo.ImageHash
+	if *triage {
+		for _, root := range paths {
+			n, h, err := hf.hashTriaged(root, stderr)
+			if err != nil {
+				fmt.Fprintf(stderr, "imagehash dedupe: %v\n", err)
+				return exitFailure
+			}
+			names = append(names, n...)
+			hashes = append(hashes, h...)
+		}
+	} else {
+		for _, path := range paths {
+			h, err := hf.hashFile(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "imagehash dedupe: %v\n", err)
+				continue
+			}
+			names = append(names, path)
+			hashes = append(hashes, h)
+		}
+	}
+
+	var groups []report.Group
+	for _, members := range groupWithin(hashes, *threshold) {
+		g := report.Group{}
+		for _, i := range members {
+			g.Images = append(g.Images, describeFile(names[i]))
+		}
+		for a := range members {
+			for b := a + 1; b < len(members); b++ {
+				d, _ := hashes[members[a]].Distance(hashes[members[b]])
+				g.Pairs = append(g.Pairs, report.Pair{A: a, B: b, Distance: d})
+			}
+		}
+		g.Keeper = suggestKeeper(g.Images)
+		groups = append(groups, g)
+	}
+
+	for n, g := range groups {
+		fmt.Fprintf(stdout, "group %d\n", n+1)
+		for i, img := range g.Images {
+			mark := " "
+			if i == g.Keeper {
+				mark = "*"
+			}
+			fmt.Fprintf(stdout, "%s %s\n", mark, img.Path)
+		}
+	}
+
+	if *reportPath != "" {
+		if err := writeReport(*reportPath, groups, hf.thumbs, *maxThumbs); err != nil {
+			fmt.Fprintf(stderr, "imagehash dedupe: %v\n", err)
+			return exitFailure
+		}
+	}
+	if *linkDir != "" {
+		if err := linkGroups(*linkDir, groups, linkOptions{copy: *copyLinks, force: *force, inputs: names}, stderr); err != nil {
+			fmt.Fprintf(stderr, "imagehash dedupe: %v\n", err)
+			return exitFailure
+		}
+	}
+	return exitOK
+}
+
+// hashTriaged hashes the image files under root that a TriageScan selects,
+// and gives the other files of each exact copy group the hash of the first
+func (f *hashFlags) hashTriaged(root string, stderr io.Writer) ([]string, []*imagehashgo.ImageHash, error) {
+	plan, err := imagehashgo.TriageScan(context.Background(), root, imagehashgo.TriageOptions{Match: isImageFile})
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(stderr, "imagehash dedupe: %s: hashing %d of %d files, %d exact copy groups\n", root, len(plan.Hash), plan.Files, len(plan.Exact))
+
+	names, hashes, err := f.hashTree(root, &plan, stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+	for _, group := range plan.Exact {
+		if i, ok := index[group[0]]; ok {
+			for _, path := range group[1:] {
+				names = append(names, path)
+				hashes = append(hashes, hashes[i])
+				if thumb, ok := f.thumbs[group[0]]; ok {
+					f.thumbs[path] = thumb
+				}
+			}
+		}
+	}
+	return names, hashes, nil
+}
+
+// groupWithin returns the connected components of at least two hashes,
+// linking hashes at most maxDist apart, each in input order
+func groupWithin(hashes []*imagehashgo.ImageHash, maxDist int) [][]int {
+	parent := make([]int, len(hashes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if d, err := hashes[i].Distance(hashes[j]); err == nil && d <= maxDist {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for i := range hashes {
+		r := find(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], i)
+	}
+	var groups [][]int
+	for _, r := range roots {
+		if len(members[r]) > 1 {
+			groups = append(groups, members[r])
+		}
+	}
+	return groups
+}
+
+// describeFile reads the size and dimensions of a file that hashed
+// successfully; errors leave the fields zero
+func describeFile(path string) report.Image {
+	img := report.Image{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		img.Size = info.Size()
+	}
+	if f, err := os.Open(path); err == nil {
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			img.Width, img.Height = cfg.Width, cfg.Height
+		}
+		f.Close()
+	}
+	return img
+}
+
+// suggestKeeper picks the image with the most pixels, then the largest
+// file, then the first
+func suggestKeeper(images []report.Image) int {
+	best := 0
+	for i, img := range images {
+		b := images[best]
+		if px, bpx := img.Width*img.Height, b.Width*b.Height; px > bpx || px == bpx && img.Size > b.Size {
+			best = i
+		}
+	}
+	return best
+}
+
+// reportThumbnailSize is the thumbnail size of the HTML report
+const reportThumbnailSize = 128
+
+// reportThumbnailed returns the indexes of the images of g that the report
+// embeds a thumbnail of: the keeper, then the others in order up to a total
+// of maxThumbs
+func reportThumbnailed(g report.Group, maxThumbs int) []int {
+	embed := []int{g.Keeper}
+	for i := range g.Images {
+		if len(embed) >= maxThumbs {
+			break
+		}
+		if i != g.Keeper {
+			embed = append(embed, i)
+		}
+	}
+	return embed
+}
+
+// writeReport writes the HTML report with the thumbnails made while
+// hashing, for the keeper and up to maxThumbs-1 others per group. No file
+// is decoded again; images without a thumbnail are listed without one.
+func writeReport(path string, groups []report.Group, thumbs map[string]image.Image, maxThumbs int) error {
+	for _, g := range groups {
+		for _, i := range reportThumbnailed(g, maxThumbs) {
+			img := &g.Images[i]
+			img.Thumbnail = thumbs[img.Path]
+		}
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.Render(out, groups, report.Options{ThumbnailSize: reportThumbnailSize, MaxThumbnails: maxThumbs}); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/K0ng2/imagehash-go/report"
+)
+
+func TestDedupe_Groups(t *testing.T) {
+	writeTestImages(t)
+
+	stdout, stderr, code := runCommand("dedupe", "c.png", "a.png", "broken.png", "b.png", "--report", "out.html")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if want := "group 1\n* a.png\n  b.png\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+
+	html, err := os.ReadFile("out.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(html)
+	if n := strings.Count(page, `<section class="group">`); n != 1 {
+		t.Errorf("report has %d groups, want 1", n)
+	}
+	if !strings.Contains(page, "<strong>keep</strong> a.png<br>64x64") || strings.Contains(page, "c.png") {
+		t.Errorf("unexpected report:\n%s", page)
+	}
+	if n := strings.Count(page, "data:image/jpeg;base64,"); n != 2 {
+		t.Errorf("report has %d thumbnails, want 2", n)
+	}
+}
+
+func TestWriteReport_Thumbnails(t *testing.T) {
+	t.Chdir(t.TempDir())
+	thumb := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	thumbs := map[string]image.Image{"a.png": thumb, "b.png": thumb}
+	group := func() []report.Group {
+		return []report.Group{{Images: []report.Image{{Path: "gone.png"}, {Path: "a.png"}, {Path: "b.png"}}, Keeper: 1}}
+	}
+
+	for _, tt := range []struct {
+		maxThumbs, want int
+	}{
+		// The keeper and gone.png, which has no thumbnail; b.png is left out
+		{2, 1},
+		{3, 2},
+	} {
+		if err := writeReport("out.html", group(), thumbs, tt.maxThumbs); err != nil {
+			t.Fatal(err)
+		}
+		html, err := os.ReadFile("out.html")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(html), "data:image/jpeg;base64,"); n != tt.want {
+			t.Errorf("max %d: report has %d thumbnails, want %d", tt.maxThumbs, n, tt.want)
+		}
+	}
+}
+
+func TestSuggestKeeper(t *testing.T) {
+	images := []report.Image{
+		{Path: "small", Width: 10, Height: 10, Size: 900},
+		{Path: "large", Width: 20, Height: 20, Size: 100},
+		{Path: "large-heavier", Width: 20, Height: 20, Size: 200},
+		{Path: "large-again", Width: 20, Height: 20, Size: 200},
+	}
+	if got := suggestKeeper(images); got != 2 {
+		t.Errorf("suggestKeeper() = %s, want large-heavier", images[got].Path)
+	}
+}
+
+func TestDedupe_SkipLowInformation(t *testing.T) {
+	writeTestImages(t)
+	for _, name := range []string{"solid1.png", "solid2.png"} {
+		img := image.NewGray(image.Rect(0, 0, 64, 64))
+		for i := range img.Pix {
+			img.Pix[i] = 90
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Solid frames share the all-zero hash and group together
+	stdout, _, _ := runCommand("dedupe", "solid1.png", "solid2.png", "a.png", "b.png")
+	if !strings.Contains(stdout, "solid1.png") {
+		t.Errorf("solid frames not grouped without the flag:\n%s", stdout)
+	}
+
+	stdout, stderr, code := runCommand("dedupe", "--skip-low-information", "solid1.png", "solid2.png", "a.png", "b.png")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if want := "group 1\n* a.png\n  b.png\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	if !strings.Contains(stderr, "solid1.png: low information image") {
+		t.Errorf("skipped files not reported, stderr: %s", stderr)
+	}
+}
+
+func TestDedupe_Triage(t *testing.T) {
+	writeTestImages(t)
+	if err := os.Mkdir("copies", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("a.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("copies", "a.png"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCommand("dedupe", "--triage", ".", "--report", "out.html", "--max-thumbnails", "3")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	// a.png, b.png and copies/a.png are byte-identical: only a.png is hashed
+	if !strings.Contains(stderr, "hashing 2 of 5 files, 1 exact copy groups") {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if want := "group 1\n* a.png\n  b.png\n  copies/a.png\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	// The copies share the thumbnail of the file hashed for them
+	html, err := os.ReadFile("out.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(html), "data:image/jpeg;base64,"); n != 3 {
+		t.Errorf("report has %d thumbnails, want 3", n)
+	}
+}
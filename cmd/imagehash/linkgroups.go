@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/K0ng2/imagehash-go/report"
+)
+
+// errPrivilegeNotHeld is Windows' ERROR_PRIVILEGE_NOT_HELD, returned by
+// os.Symlink without Developer Mode or administrator rights
+const errPrivilegeNotHeld = syscall.Errno(1314)
+
+// symlink is os.Symlink, replaced by tests
+var symlink = os.Symlink
+
+// linkOptions configures linkGroups
+type linkOptions struct {
+	// copy copies the files instead of linking them
+	copy bool
+	// force replaces the group directories of a previous run
+	force bool
+	// inputs are the files the run read, which --force must not remove
+	inputs []string
+}
+
+// linkGroups writes a directory per group under dir, for reviewing the
+// groups in a file browser. Group directories are numbered and named after
+// the keeper; they hold a relative symlink (or copy) of every member,
+// prefixed with its distance to the keeper, e.g. 00_keep_IMG_1234.jpg and
+// 03_IMG_1234_copy.jpg. When symlinks are not supported, as on exFAT or on
+// Windows without the privilege, the remaining members are copied after
+// a warning.
+func linkGroups(dir string, groups []report.Group, opts linkOptions, stderr io.Writer) error {
+	if err := prepareLinkDir(dir, opts.force, opts.inputs); err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	groupWidth := max(3, len(strconv.Itoa(len(groups))))
+	distWidth := 2
+	for _, g := range groups {
+		for _, p := range g.Pairs {
+			distWidth = max(distWidth, len(strconv.Itoa(p.Distance)))
+		}
+	}
+
+	copying := opts.copy
+	for n, g := range groups {
+		keeper := g.Images[g.Keeper].Path
+		groupDir := filepath.Join(absDir, fmt.Sprintf("%0*d_%s", groupWidth, n+1, stem(keeper)))
+		if err := os.Mkdir(groupDir, 0o755); err != nil {
+			return err
+		}
+
+		dist := keeperDistances(g)
+		used := make(map[string]bool)
+		for _, i := range membersByDistance(g, dist) {
+			src, err := filepath.Abs(g.Images[i].Path)
+			if err != nil {
+				return err
+			}
+			prefix := fmt.Sprintf("%0*d_", distWidth, dist[i])
+			if i == g.Keeper {
+				prefix += "keep_"
+			}
+			dst := filepath.Join(groupDir, uniqueName(prefix+filepath.Base(src), used))
+
+			if !copying {
+				target, err := filepath.Rel(groupDir, src)
+				if err != nil {
+					return err
+				}
+				err = symlink(target, dst)
+				if err == nil {
+					continue
+				}
+				if !symlinkUnsupported(err) {
+					return err
+				}
+				fmt.Fprintf(stderr, "imagehash dedupe: symlinks are not supported in %s (%v), copying files instead\n", dir, err)
+				copying = true
+			}
+			if err := copyFile(src, dst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prepareLinkDir creates dir, or checks that it is empty. With force the
+// group directories of a previous run are removed instead, and other
+// entries are kept; force is refused when dir holds any of the inputs.
+func prepareLinkDir(dir string, force bool, inputs []string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return os.MkdirAll(dir, 0o755)
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if !force {
+		return fmt.Errorf("%s is not empty; use --force to replace its contents", dir)
+	}
+
+	realDir := realPath(dir)
+	for _, in := range inputs {
+		if rel, err := filepath.Rel(realDir, realPath(in)); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s is inside %s; not replacing its contents", in, dir)
+		}
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if !isGroupDir(path, e) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	// groupDirName and memberName match the names linkGroups creates
+	groupDirName = regexp.MustCompile(`^\d{3,}_`)
+	memberName   = regexp.MustCompile(`^\d{2,}_`)
+)
+
+// isGroupDir reports whether the entry e at path is a group directory as
+// linkGroups creates them: a numbered directory holding only symlinks and
+// files with a distance prefix
+func isGroupDir(path string, e fs.DirEntry) bool {
+	if !e.IsDir() || !groupDirName.MatchString(e.Name()) {
+		return false
+	}
+	members, err := os.ReadDir(path)
+	if err != nil {
+		return false
+	}
+	for _, m := range members {
+		if !m.Type().IsRegular() && m.Type()&fs.ModeSymlink == 0 || !memberName.MatchString(m.Name()) {
+			return false
+		}
+	}
+	return true
+}
+
+// realPath returns the absolute path of path with symlinks resolved, or as
+// much of that as succeeds
+func realPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return path
+}
+
+// symlinkUnsupported reports whether a symlink error means that the file
+// system or the user cannot create symlinks at all, rather than that this
+// one failed
+func symlinkUnsupported(err error) bool {
+	return errors.Is(err, errors.ErrUnsupported) || errors.Is(err, fs.ErrPermission) || errors.Is(err, errPrivilegeNotHeld)
+}
+
+// keeperDistances returns the distance of every member of g to its keeper
+func keeperDistances(g report.Group) []int {
+	dist := make([]int, len(g.Images))
+	for _, p := range g.Pairs {
+		switch g.Keeper {
+		case p.A:
+			dist[p.B] = p.Distance
+		case p.B:
+			dist[p.A] = p.Distance
+		}
+	}
+	return dist
+}
+
+// membersByDistance returns the member indices of g with the keeper first,
+// then by distance to the keeper, then in group order
+func membersByDistance(g report.Group, dist []int) []int {
+	order := make([]int, len(g.Images))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		if (a == g.Keeper) != (b == g.Keeper) {
+			if a == g.Keeper {
+				return -1
+			}
+			return 1
+		}
+		return dist[a] - dist[b]
+	})
+	return order
+}
+
+// uniqueName returns name, or name with a "_2", "_3"... suffix before its
+// extension when used already has it, and records the result in used
+func uniqueName(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	unique := name
+	for n := 2; used[strings.ToLower(unique)]; n++ {
+		unique = fmt.Sprintf("%s_%d%s", base, n, ext)
+	}
+	// Case-insensitive file systems (exFAT, NTFS, APFS) would collide too
+	used[strings.ToLower(unique)] = true
+	return unique
+}
+
+// stem returns the file name of path without its extension
+func stem(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
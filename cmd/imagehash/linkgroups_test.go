@@ -0,0 +1,245 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/K0ng2/imagehash-go/report"
+)
+
+// writeLinkTree writes two groups of duplicates, with two members named
+// IMG_1234.jpg in the first, and an unrelated image
+func writeLinkTree(t *testing.T) []string {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	waves := pattern(func(x, y float64) float64 { return math.Sin(9*x) * math.Cos(5*y) })
+	diagonal := pattern(func(x, y float64) float64 { return math.Sin(12 * (x + y)) })
+	files := []struct {
+		path string
+		img  func(x, y float64) float64
+	}{
+		{"shoot/IMG_1234.jpg", nil},
+		{"shoot/IMG_1234.png", nil},
+		{"backup/IMG_1234.jpg", nil},
+		{"shoot/IMG_2000.png", nil},
+		{"backup/IMG_2000.png", nil},
+		{"shoot/rings.png", func(x, y float64) float64 { return math.Cos(30 * math.Hypot(x-0.5, y-0.5)) }},
+	}
+	var paths []string
+	for _, f := range files {
+		switch {
+		case f.img != nil:
+			writeImage(t, f.path, pattern(f.img))
+		case strings.Contains(f.path, "1234"):
+			writeImage(t, f.path, waves)
+		default:
+			writeImage(t, f.path, diagonal)
+		}
+		paths = append(paths, f.path)
+	}
+	return paths
+}
+
+// readFarm lists the group directories of dir and their entries
+func readFarm(t *testing.T, dir string) map[string][]string {
+	t.Helper()
+	farm := make(map[string][]string)
+	groups, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		entries, err := os.ReadDir(filepath.Join(dir, g.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			farm[g.Name()] = append(farm[g.Name()], e.Name())
+		}
+	}
+	return farm
+}
+
+var distancePrefix = regexp.MustCompile(`^(\d\d)_(.*)$`)
+
+func TestDedupe_LinkGroups(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need a privilege on Windows; see TestLinkGroups_Fallback")
+	}
+	paths := writeLinkTree(t)
+
+	args := append([]string{"dedupe", "--threshold", "8", "--link-groups", "review"}, paths...)
+	stdout, stderr, code := runCommand(args...)
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if strings.Count(stdout, "group ") != 2 {
+		t.Fatalf("want 2 groups, stdout:\n%s", stdout)
+	}
+
+	farm := readFarm(t, "review")
+	first, second := farm["001_IMG_1234"], farm["002_IMG_2000"]
+	if len(farm) != 2 || len(first) != 3 || len(second) != 2 {
+		t.Fatalf("farm = %v", farm)
+	}
+	// The PNG has the same pixels and the larger file, so it is the keeper
+	if !slices.Contains(first, "00_keep_IMG_1234.png") || !slices.Equal(second, []string{"00_IMG_2000.png", "00_keep_IMG_2000.png"}) {
+		t.Errorf("farm = %v", farm)
+	}
+	var names []string
+	for _, entry := range first {
+		if strings.Contains(entry, "_keep_") {
+			continue
+		}
+		m := distancePrefix.FindStringSubmatch(entry)
+		if m == nil {
+			t.Fatalf("%s has no distance prefix", entry)
+		}
+		if d, _ := strconv.Atoi(m[1]); d > 8 {
+			t.Errorf("%s: distance above the threshold", entry)
+		}
+		names = append(names, m[2])
+	}
+	// The two IMG_1234.jpg do not overwrite each other
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"IMG_1234.jpg", "IMG_1234_2.jpg"}) {
+		t.Errorf("members of group 1 = %v", first)
+	}
+
+	// Every entry is a relative symlink to a member
+	for group, entries := range farm {
+		for _, entry := range entries {
+			link := filepath.Join("review", group, entry)
+			target, err := os.Readlink(link)
+			if err != nil {
+				t.Fatalf("%s is not a symlink: %v", link, err)
+			}
+			if filepath.IsAbs(target) {
+				t.Errorf("%s points to absolute path %s", link, target)
+			}
+			if _, err := os.Stat(link); err != nil {
+				t.Errorf("%s is dangling: %v", link, err)
+			}
+		}
+	}
+	if target, _ := os.Readlink(filepath.Join("review", "001_IMG_1234", "00_keep_IMG_1234.png")); target != filepath.Join("..", "..", "shoot", "IMG_1234.png") {
+		t.Errorf("keeper link points to %s", target)
+	}
+
+	// An existing farm is not replaced without --force
+	if err := os.WriteFile(filepath.Join("review", "notes.txt"), []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCommand(args...)
+	if code != exitFailure || !strings.Contains(stderr, "--force") {
+		t.Errorf("second run: exit code = %d, stderr: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join("review", "notes.txt")); err != nil {
+		t.Errorf("second run clobbered the directory: %v", err)
+	}
+
+	// --force replaces the group directories only
+	_, stderr, code = runCommand(append(args, "--force", "--copy")...)
+	if code != exitOK {
+		t.Fatalf("--force: exit code = %d, stderr: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join("review", "notes.txt")); err != nil {
+		t.Errorf("--force removed a file it did not create: %v", err)
+	}
+	info, err := os.Lstat(filepath.Join("review", "002_IMG_2000", "00_keep_IMG_2000.png"))
+	if err != nil || !info.Mode().IsRegular() {
+		t.Errorf("--copy did not copy the keeper: %v, %v", info, err)
+	}
+}
+
+func TestLinkGroups_ForceKeepsInputs(t *testing.T) {
+	paths := writeLinkTree(t)
+
+	// The inputs are under the link directory
+	args := append([]string{"dedupe", "--threshold", "8", "--link-groups", ".", "--force"}, paths...)
+	_, stderr, code := runCommand(args...)
+	if code != exitFailure || !strings.Contains(stderr, "not replacing") {
+		t.Errorf("link directory holding the inputs: exit code = %d, stderr: %s", code, stderr)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s removed: %v", path, err)
+		}
+	}
+
+	// Directories that only look like group directories are kept
+	for _, path := range []string{"review/001_mine/photo.jpg", "review/002_mine/00_a.jpg", "review/002_mine/sub/00_b.jpg"} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := prepareLinkDir("review", true, paths); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("review/001_mine/photo.jpg"); err != nil {
+		t.Errorf("unprefixed member removed: %v", err)
+	}
+	if _, err := os.Stat("review/002_mine/sub/00_b.jpg"); err != nil {
+		t.Errorf("directory with a subdirectory removed: %v", err)
+	}
+}
+
+func TestLinkGroups_Fallback(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := symlink
+	t.Cleanup(func() { symlink = saved })
+	var calls int
+	symlink = func(oldname, newname string) error {
+		calls++
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errPrivilegeNotHeld}
+	}
+
+	groups := []report.Group{{
+		Images: []report.Image{{Path: "a.jpg"}, {Path: "b.jpg"}},
+		Pairs:  []report.Pair{{A: 0, B: 1, Distance: 3}},
+		Keeper: 1,
+	}}
+	var stderr strings.Builder
+	if err := linkGroups("out", groups, linkOptions{}, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || strings.Count(stderr.String(), "copying files instead") != 1 {
+		t.Errorf("symlink called %d times, stderr: %s", calls, stderr.String())
+	}
+	for name, want := range map[string]string{"00_keep_b.jpg": "b.jpg", "03_a.jpg": "a.jpg"} {
+		data, err := os.ReadFile(filepath.Join("out", "001_b", name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want a copy of %s", name, data, err, want)
+		}
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	used := make(map[string]bool)
+	var got []string
+	for _, name := range []string{"00_x.jpg", "00_x.jpg", "00_X.JPG", "00_x.jpg", "00_x_2.jpg"} {
+		got = append(got, uniqueName(name, used))
+	}
+	want := []string{"00_x.jpg", "00_x_2.jpg", "00_X_3.JPG", "00_x_4.jpg", "00_x_2_2.jpg"}
+	if !slices.Equal(got, want) {
+		t.Errorf("uniqueName() = %v, want %v", got, want)
+	}
+	if s := stem("dir/IMG_1.tar.gz"); s != "IMG_1.tar" {
+		t.Errorf("stem() = %s", s)
+	}
+}
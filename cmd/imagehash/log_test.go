@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// captureHandler records every log record it receives
+type captureHandler struct {
+	mu      sync.Mutex
+	level   slog.Level
+	records []slog.Record
+}
+
+func (h *captureHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.level }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler      { return h }
+
+// events returns the messages and attribute keys of the captured records
+func (h *captureHandler) events() []string {
+	var events []string
+	for _, r := range h.records {
+		keys := []string{r.Level.String(), r.Message}
+		r.Attrs(func(a slog.Attr) bool {
+			keys = append(keys, a.Key)
+			return true
+		})
+		events = append(events, strings.Join(keys, " "))
+	}
+	return events
+}
+
+func TestHashFile_Logging(t *testing.T) {
+	writeTestImages(t)
+
+	for _, tt := range []struct {
+		level slog.Level
+		want  []string
+	}{
+		{slog.LevelInfo, []string{
+			"INFO hash finish path kind size duration",
+			"INFO hash failed path class err",
+			"INFO hash failed path class err",
+		}},
+		{slog.LevelDebug, []string{
+			"DEBUG hash start path",
+			"INFO hash finish path kind size duration",
+			"DEBUG hash value path hash variance marginal",
+			"DEBUG hash start path",
+			"INFO hash failed path class err",
+			"DEBUG hash start path",
+			"INFO hash failed path class err",
+		}},
+	} {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			h := &captureHandler{level: tt.level}
+			hf := hashFlags{algo: "dhash", size: 8, logger: slog.New(h)}
+			for _, path := range []string{"a.png", "broken.png", "missing.png"} {
+				_, _ = hf.hashFile(path)
+			}
+
+			got := h.events()
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			var classes []string
+			for _, r := range h.records {
+				r.Attrs(func(a slog.Attr) bool {
+					if a.Key == "class" {
+						classes = append(classes, a.Value.String())
+					}
+					if a.Key == "hash" && r.Level > slog.LevelDebug {
+						t.Errorf("hash logged at %s", r.Level)
+					}
+					return true
+				})
+			}
+			if strings.Join(classes, ",") != "unknown_format,open" {
+				t.Errorf("error classes = %v, want [unknown_format open]", classes)
+			}
+		})
+	}
+}
+
+func TestCross_LogLevel(t *testing.T) {
+	writeTestImages(t)
+
+	_, stderr, code := runCommand("cross", "--log-level", "info", "a.png", "b.png")
+	if code != exitOK {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "msg=\"hash finish\" path=a.png") || strings.Contains(stderr, "hash=") {
+		t.Errorf("unexpected log output:\n%s", stderr)
+	}
+	if _, _, code := runCommand("cross", "--log-level", "trace", "a.png"); code != exitUsage {
+		t.Errorf("unknown log level exit code = %d, want %d", code, exitUsage)
+	}
+}
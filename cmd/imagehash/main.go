@@ -0,0 +1,249 @@
+// Command imagehash computes and compares perceptual image hashes.
+//
+// Usage:
+//
+//	imagehash <command> [flags] FILE...
+//
+// Commands:
+//
+//	against classify images as matches of a baseline directory or new
+//	bench   time every algorithm and size on the given files
+//	compare apply a match profile to two files
+//	cross   print the pairwise distances between all files
+//	dedupe  group near-duplicate files and suggest which to keep
+//	repro   record or rerun a bundle reproducing one image's hash
+//	selftest check this build's DCT and hashes against expected outputs
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// Exit codes
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands []command
+
+// The table is filled in init because the commands read the config file,
+// which looks up command names in it
+func init() {
+	commands = []command{
+		{"against", "classify images as matches of a baseline directory or new", runAgainst},
+		{"bench", "time every algorithm and size on the given files", runBench},
+		{"compare", "apply a match profile to two files", runCompare},
+		{"cross", "print the pairwise distances between all files", runCross},
+		{"dedupe", "group near-duplicate files and suggest which to keep", runDedupe},
+		{"repro", "record or rerun a bundle reproducing one image's hash", runRepro},
+		{"selftest", "check this build's DCT and hashes against expected outputs", runSelfTest},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			if wantsAlgoHelp(args[1:]) {
+				printAlgorithms(stdout)
+				return exitOK
+			}
+			return cmd.run(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "imagehash: unknown command %q\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: imagehash <command> [flags] FILE...")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// the positional arguments, returning the positional arguments. Flags not
+// given are then taken from the environment and the config file, see
+// applyConfig; --config names the file.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	configPath := fs.String("config", "", "config file with flag defaults (default ~/.config/imagehash/config.toml)")
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if err := applyConfig(fs, *configPath); err != nil {
+		fmt.Fprintf(fs.Output(), "imagehash %s: %v\n", fs.Name(), err)
+		return nil, err
+	}
+	return positional, nil
+}
+
+// hashFlags holds the flags shared by every command that hashes images
+type hashFlags struct {
+	algo       string
+	size       int
+	logLevel   string
+	skipLowInf bool
+
+	// logger receives per-file events; nil disables logging
+	logger *slog.Logger
+	// thumbs, when not nil, receives a report thumbnail of every file
+	// hashed, made from the same decode as the hash
+	thumbs map[string]image.Image
+}
+
+func (f *hashFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.algo, "algo", string(imagehashgo.KindDifference), "hash algorithm: ahash, phash, dhash or dhash_v (help lists them)")
+	fs.IntVar(&f.size, "size", 8, "hash size")
+	fs.StringVar(&f.logLevel, "log-level", "", "log per-file events to stderr: debug or info")
+	fs.BoolVar(&f.skipLowInf, "skip-low-information", false, "skip solid and near-solid images, whose hashes match each other regardless of content")
+}
+
+// setup validates the parsed flags and creates the logger
+func (f *hashFlags) setup(stderr io.Writer) error {
+	if _, err := imagehashgo.ParseHashKind(f.algo); err != nil {
+		return err
+	}
+	var level slog.Level
+	switch f.logLevel {
+	case "":
+		return nil
+	case "debug":
+		level = slog.LevelDebug
+	case "info":
+		level = slog.LevelInfo
+	default:
+		return fmt.Errorf("unknown log level %q", f.logLevel)
+	}
+	f.logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+	return nil
+}
+
+// errLowInformation rejects solid images under --skip-low-information
+var errLowInformation = errors.New("low information image")
+
+// hashFile hashes one file. With a logger it emits "hash start" and, on
+// success, "hash finish" with the duration at Info, the hash and image
+// quality only at Debug; failures are logged as "hash failed" with an
+// error class.
+func (f *hashFlags) hashFile(path string) (*imagehashgo.ImageHash, error) {
+	if f.logger == nil {
+		h, _, err := f.hashFileQuiet(path)
+		return h, err
+	}
+
+	f.logger.Debug("hash start", "path", path)
+	start := time.Now()
+	h, q, err := f.hashFileQuiet(path)
+	if err != nil {
+		f.logger.Info("hash failed", "path", path, "class", errorClass(err), "err", err)
+		return nil, err
+	}
+	f.logger.Info("hash finish", "path", path, "kind", f.algo, "size", f.size, "duration", time.Since(start))
+	f.logger.Debug("hash value", "path", path, "hash", h.ToString(), "variance", q.Variance, "marginal", q.Marginal)
+	return h, nil
+}
+
+func (f *hashFlags) hashFileQuiet(path string) (*imagehashgo.ImageHash, imagehashgo.Quality, error) {
+	kind, err := imagehashgo.ParseHashKind(f.algo)
+	if err != nil {
+		return nil, imagehashgo.Quality{}, err
+	}
+
+	img, err := decodeFile(path)
+	if err != nil {
+		return nil, imagehashgo.Quality{}, err
+	}
+	var h *imagehashgo.ImageHash
+	var q imagehashgo.Quality
+	if f.wantQuality() {
+		h, q, err = imagehashgo.HashWithQuality(img, kind, f.size)
+		if err == nil && f.skipLowInf && q.LowInformation() {
+			return nil, q, fmt.Errorf("%s: %w", path, errLowInformation)
+		}
+	} else {
+		h, err = imagehashgo.Hash(img, kind, f.size)
+	}
+	if err != nil {
+		return nil, q, err
+	}
+	if f.thumbs != nil {
+		f.thumbs[path] = imagehashgo.Thumbnail(img, reportThumbnailSize)
+	}
+	return h, q, nil
+}
+
+// wantQuality reports whether the image quality is read: by
+// --skip-low-information, or by the "hash value" event at --log-level debug
+func (f *hashFlags) wantQuality() bool {
+	return f.skipLowInf || f.logger != nil && f.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// decodeFile opens and decodes an image file
+func decodeFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// errorClass buckets a hashFile error for logging
+func errorClass(err error) string {
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, errLowInformation):
+		return "low_information"
+	case errors.Is(err, image.ErrFormat):
+		return "unknown_format"
+	case errors.As(err, &pathErr):
+		return "open"
+	default:
+		return "decode"
+	}
+}
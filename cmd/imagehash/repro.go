@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// runRepro writes a repro bundle for one image (dump) or reruns one with
+// this binary (run). run exits 1 when the recorded hash is not reproduced.
+func runRepro(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "dump":
+			return runReproDump(args[1:], stdout, stderr)
+		case "run":
+			return runReproRun(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintln(stderr, "usage: imagehash repro dump [--algo A] [--size N] [--hash HEX] [--out FILE] IMAGE")
+	fmt.Fprintln(stderr, "       imagehash repro run BUNDLE")
+	return exitUsage
+}
+
+func runReproDump(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("repro", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var hf hashFlags
+	hf.register(fs)
+	reported := fs.String("hash", "", "the hash to reproduce, as reported (default: the hash this binary computes)")
+	out := fs.String("out", "", "write the bundle to this file instead of stdout")
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(paths) != 1 {
+		fmt.Fprintln(stderr, "usage: imagehash repro dump [--algo A] [--size N] [--hash HEX] [--out FILE] IMAGE")
+		return exitUsage
+	}
+	if err := hf.setup(stderr); err != nil {
+		fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+		return exitUsage
+	}
+
+	img, err := decodeFile(paths[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+		return exitFailure
+	}
+	var h *imagehashgo.ImageHash
+	if *reported != "" {
+		// The kind and size come from the flags, as for a hash the user
+		// computed with them
+		h, err = imagehashgo.ParsePythonHash(*reported, hf.algo, hf.size)
+	} else {
+		h, err = imagehashgo.Hash(img, imagehashgo.HashKind(hf.algo), hf.size)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+		return exitUsage
+	}
+
+	w := stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+			return exitFailure
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := imagehashgo.DumpRepro(w, img, imagehashgo.ResolvedOptions{}, h); err != nil {
+		fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runReproRun(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "usage: imagehash repro run BUNDLE")
+		return exitUsage
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+		return exitFailure
+	}
+	defer f.Close()
+	res, err := imagehashgo.RunRepro(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "imagehash repro: %v\n", err)
+		return exitFailure
+	}
+
+	fmt.Fprintf(stdout, "recorded by %s on %s with %s\n", res.Version, res.GOARCH, res.GoVersion)
+	if res.Reproduced {
+		fmt.Fprintf(stdout, "reproduced: %s\n", res.Got.ToString())
+		return exitOK
+	}
+	fmt.Fprintf(stdout, "diverged at %s: %s\n", res.Stage, res.Detail)
+	return exitFailure
+}
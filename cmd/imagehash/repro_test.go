@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRepro_DumpRun(t *testing.T) {
+	writeTestImages(t)
+
+	if _, stderr, code := runCommand("repro", "dump", "--algo", "phash", "--out", "a.json", "a.png"); code != exitOK {
+		t.Fatalf("dump: exit %d, stderr %q", code, stderr)
+	}
+	stdout, stderr, code := runCommand("repro", "run", "a.json")
+	if code != exitOK || !strings.Contains(stdout, "reproduced: ") {
+		t.Errorf("run: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	// A reported hash this build does not compute
+	if _, stderr, code := runCommand("repro", "dump", "--hash", "0000000000000000", "--out", "b.json", "a.png"); code != exitOK {
+		t.Fatalf("dump --hash: exit %d, stderr %q", code, stderr)
+	}
+	stdout, _, code = runCommand("repro", "run", "b.json")
+	if code != exitFailure || !strings.Contains(stdout, "diverged at hash") {
+		t.Errorf("run reported: exit %d, stdout %q", code, stdout)
+	}
+
+	for _, args := range [][]string{{"repro"}, {"repro", "replay"}, {"repro", "run"}, {"repro", "dump"}, {"repro", "dump", "--hash", "xyz", "a.png"}} {
+		if _, _, code := runCommand(args...); code != exitUsage {
+			t.Errorf("%v: exit %d, want %d", args, code, exitUsage)
+		}
+	}
+	if _, _, code := runCommand("repro", "run", "missing.json"); code != exitFailure {
+		t.Errorf("missing bundle: exit %d", code)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// selfTest is imagehashgo.SelfTest, replaced by tests to simulate a
+// deviating build
+var selfTest = imagehashgo.SelfTest
+
+// runSelfTest checks the DCT kernels, grayscale paths and hash algorithms
+// of this binary against the embedded expected outputs, printing one line
+// per deviating stage and exiting non-zero on any deviation
+func runSelfTest(args []string, stdout, stderr io.Writer) int {
+	fset := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	fset.SetOutput(stderr)
+	if err := fset.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fset.NArg() != 0 {
+		fmt.Fprintln(stderr, "usage: imagehash selftest")
+		return exitUsage
+	}
+
+	if err := selfTest(); err != nil {
+		for line := range strings.Lines(err.Error()) {
+			fmt.Fprintf(stderr, "imagehash %s", line)
+		}
+		fmt.Fprintln(stderr)
+		return exitFailure
+	}
+	fmt.Fprintln(stdout, "selftest: ok")
+	return exitOK
+}
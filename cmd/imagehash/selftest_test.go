@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+func TestSelfTestCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"selftest"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit code %d, stderr %q", code, stderr.String())
+	}
+	if got := stdout.String(); got != "selftest: ok\n" {
+		t.Errorf("stdout = %q", got)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"selftest", "extra"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("selftest with an argument: exit code %d, want %d", code, exitUsage)
+	}
+}
+
+func TestSelfTestCommand_Deviation(t *testing.T) {
+	defer func() { selfTest = imagehashgo.SelfTest }()
+	selfTest = func() error {
+		return errors.Join(
+			&imagehashgo.SelfTestError{Stage: "dct32", Detail: "value 5 is 1, want 2"},
+			&imagehashgo.SelfTestError{Stage: "hash/phash/8/4", Detail: "got 00, want 01"},
+		)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"selftest"}, &stdout, &stderr); code != exitFailure {
+		t.Fatalf("exit code %d, want %d", code, exitFailure)
+	}
+	want := "imagehash selftest: stage dct32: value 5 is 1, want 2\nimagehash selftest: stage hash/phash/8/4: got 00, want 01\n"
+	if stdout.Len() != 0 || stderr.String() != want {
+		t.Errorf("stdout %q, stderr %q, want %q", stdout.String(), stderr.String(), want)
+	}
+}
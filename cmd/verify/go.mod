@@ -1,4 +1,4 @@
-module verify
+module github.com/K0ng2/imagehash-go/cmd/verify
 
 go 1.25.0
 
@@ -12,3 +12,6 @@ require (
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
 	golang.org/x/image v0.36.0 // indirect
 )
+
+// Verify the working tree rather than the published release
+replace github.com/K0ng2/imagehash-go => ../..
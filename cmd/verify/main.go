@@ -1,51 +1,68 @@
+// Command verify cross-checks the hashes of imagehash-go against
+// goimagehash on an image and prints the paritycheck report:
+//
+//	verify [--format text|json] [IMAGE]
+//
+// IMAGE defaults to image.png. The exit status is 1 when a check fails.
+// verify.py prints the Python imagehash values for the same image.
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"image"
+	_ "image/gif"
+	_ "image/jpeg"
 	_ "image/png"
 	"os"
 
-	imagehashgo "github.com/K0ng2/imagehash-go"
-	"github.com/corona10/goimagehash"
+	"github.com/K0ng2/imagehash-go/cmd/verify/paritycheck"
 )
 
 func main() {
-	file, err := os.Open("image.png")
-	if err != nil {
-		panic(err)
+	format := flag.String("format", "text", "output format: text or json")
+	flag.Parse()
+	if flag.NArg() > 1 || (*format != "text" && *format != "json") {
+		fmt.Fprintln(os.Stderr, "usage: verify [--format text|json] [IMAGE]")
+		os.Exit(2)
+	}
+	path := "image.png"
+	if flag.NArg() == 1 {
+		path = flag.Arg(0)
 	}
-	defer file.Close()
 
-	img, _, err := image.Decode(file)
+	img, err := decode(path)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+		os.Exit(2)
 	}
 
-	ahash := imagehashgo.AverageHash(img, 8)
-	phash := imagehashgo.PerceptualHash(img, 8, 4)
-	dhash := imagehashgo.DifferenceHash(img, 8)
-	dhashV := imagehashgo.DifferenceHashVertical(img, 8)
-
-	ahash1, err := goimagehash.AverageHash(img)
-	if err != nil {
-		panic(err)
+	report := paritycheck.Run(img)
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+			os.Exit(2)
+		}
+	} else {
+		fmt.Print(report)
+	}
+	if !report.Pass {
+		os.Exit(1)
 	}
-	phash1, err := goimagehash.ExtPerceptionHash(img, 8, 8)
+}
+
+func decode(path string) (image.Image, error) {
+	file, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	dhash1, err := goimagehash.DifferenceHash(img)
+	defer file.Close()
+	img, _, err := image.Decode(file)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
-
-	fmt.Printf("ahash: %s\n", ahash.ToString())
-	fmt.Printf("phash: %s\n", phash.ToString())
-	fmt.Printf("dhash: %s\n", dhash.ToString())
-	fmt.Printf("dhash_v: %s\n", dhashV.ToString())
-
-	fmt.Printf("ahash1: %s\n", ahash1.ToString())
-	fmt.Printf("phash1: %s\n", phash1.ToString())
-	fmt.Printf("dhash1: %s\n", dhash1.ToString())
+	return img, nil
 }
@@ -0,0 +1,185 @@
+// Package paritycheck cross-checks the hashes of imagehash-go against
+// github.com/corona10/goimagehash for the algorithms both libraries
+// implement the same way, so that downstream test suites can assert that
+// the two stay consistent.
+//
+// The libraries resize with different filters (Lanczos here, bilinear in
+// goimagehash), so their hashes are close rather than identical; each
+// check passes when the bit distance is within a tolerance.
+package paritycheck
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+	"github.com/corona10/goimagehash"
+)
+
+// Algorithms compared by Run. pHash is computed over a size² x size²
+// resize, as goimagehash does, i.e. with a high-frequency factor of size.
+const (
+	AHash = "ahash"
+	DHash = "dhash"
+	PHash = "phash"
+)
+
+// Sizes are the hash sizes compared by Run
+var Sizes = []int{8, 16}
+
+// DefaultTolerances are the largest accepted distances, as a fraction of
+// the hash bits, for each algorithm. Photographs stay well within them:
+// about 1% of the aHash bits, 20% of the dHash bits, whose neighbour
+// comparisons are most sensitive to the resize filter, and none of the
+// pHash bits differ. Flat, gradient and tiny images fail the pHash check,
+// since their DCT coefficients are close to zero and the median threshold
+// decides on noise, and transparent images may fail dHash, as goimagehash
+// does not un-premultiply alpha.
+var DefaultTolerances = map[string]float64{
+	AHash: 0.1,
+	DHash: 0.25,
+	PHash: 0.15,
+}
+
+// Option configures Run
+type Option func(*options)
+
+type options struct {
+	tolerances map[string]float64
+}
+
+// WithTolerance sets the largest accepted distance of algorithm, as a
+// fraction of the hash bits
+func WithTolerance(algorithm string, fraction float64) Option {
+	return func(o *options) {
+		o.tolerances[algorithm] = fraction
+	}
+}
+
+// Check is the comparison of one algorithm at one hash size
+type Check struct {
+	Algorithm string `json:"algorithm"`
+	HashSize  int    `json:"hash_size"`
+	Bits      int    `json:"bits"`
+	// Ours and Theirs are the imagehash-go and goimagehash hashes, both
+	// formatted as imagehash-go hex
+	Ours   string `json:"imagehash_go"`
+	Theirs string `json:"goimagehash"`
+	// Distance is the Hamming distance between the two, and Tolerance the
+	// largest distance accepted
+	Distance  int    `json:"distance"`
+	Tolerance int    `json:"tolerance"`
+	Pass      bool   `json:"pass"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ParityReport is the result of Run
+type ParityReport struct {
+	Checks []Check `json:"checks"`
+	// Pass is true when every check passed
+	Pass bool `json:"pass"`
+}
+
+// Run hashes img with both libraries for every algorithm and size and
+// compares the results
+func Run(img image.Image, opts ...Option) ParityReport {
+	o := options{tolerances: make(map[string]float64)}
+	for algo, tol := range DefaultTolerances {
+		o.tolerances[algo] = tol
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	report := ParityReport{Pass: true}
+	for _, algo := range []string{AHash, DHash, PHash} {
+		for _, size := range Sizes {
+			c := check(img, algo, size, o.tolerances[algo])
+			report.Pass = report.Pass && c.Pass
+			report.Checks = append(report.Checks, c)
+		}
+	}
+	return report
+}
+
+// String renders the report as one line per check and a verdict
+func (r ParityReport) String() string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		verdict := "ok"
+		if !c.Pass {
+			verdict = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s/%d: %d/%d bits differ (tolerance %d) %s\n", c.Algorithm, c.HashSize, c.Distance, c.Bits, c.Tolerance, verdict)
+		if c.Error != "" {
+			fmt.Fprintf(&b, "  error: %s\n", c.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "  imagehash-go: %s\n  goimagehash:  %s\n", c.Ours, c.Theirs)
+	}
+	if r.Pass {
+		b.WriteString("PASS\n")
+	} else {
+		b.WriteString("FAIL\n")
+	}
+	return b.String()
+}
+
+func check(img image.Image, algo string, size int, tolerance float64) Check {
+	c := Check{Algorithm: algo, HashSize: size, Bits: size * size, Tolerance: int(tolerance * float64(size*size))}
+	ours, theirs, err := hashBoth(img, algo, size)
+	if err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.Ours, c.Theirs = ours.ToString(), theirs.ToString()
+	if c.Distance, err = ours.Distance(theirs); err != nil {
+		c.Error = err.Error()
+		return c
+	}
+	c.Pass = c.Distance <= c.Tolerance
+	return c
+}
+
+// hashBoth computes the hashes of both libraries, converting the
+// goimagehash one to an imagehash-go hash
+func hashBoth(img image.Image, algo string, size int) (ours, theirs *imagehashgo.ImageHash, err error) {
+	var words []uint64
+	switch algo {
+	case AHash:
+		ours = imagehashgo.AverageHash(img, size)
+		var h *goimagehash.ExtImageHash
+		if h, err = goimagehash.ExtAverageHash(img, size, size); err == nil {
+			words = h.GetHash()
+		}
+	case DHash:
+		ours = imagehashgo.DifferenceHash(img, size)
+		var h *goimagehash.ExtImageHash
+		if h, err = goimagehash.ExtDifferenceHash(img, size, size); err == nil {
+			words = h.GetHash()
+		}
+	case PHash:
+		ours = imagehashgo.PerceptualHash(img, size, size)
+		var h *goimagehash.ExtImageHash
+		if h, err = goimagehash.ExtPerceptionHash(img, size, size); err == nil {
+			words = h.GetHash()
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown algorithm %q", algo)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return ours, fromWords(words, size), nil
+}
+
+// fromWords unpacks goimagehash's MSB-first words, which use the same
+// row-major bit order as imagehash-go
+func fromWords(words []uint64, size int) *imagehashgo.ImageHash {
+	bits := make([]bool, size*size)
+	for i := range bits {
+		bits[i] = words[i/64]>>(63-i%64)&1 == 1
+	}
+	return imagehashgo.NewImageHash(bits, size, size)
+}
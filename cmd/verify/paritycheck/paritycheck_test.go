@@ -0,0 +1,115 @@
+package paritycheck
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// photoLike is a generated stand-in for a photograph: overlapping
+// discs of different colors on a textured background
+func photoLike() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 160, 120))
+	discs := []struct {
+		x, y, r float64
+		c       color.RGBA
+	}{
+		{40, 40, 30, color.RGBA{200, 40, 40, 255}},
+		{110, 70, 40, color.RGBA{30, 160, 60, 255}},
+		{70, 95, 20, color.RGBA{240, 220, 80, 255}},
+		{130, 20, 15, color.RGBA{20, 30, 120, 255}},
+	}
+	for y := range 120 {
+		for x := range 160 {
+			fx, fy := float64(x), float64(y)
+			v := uint8(100 + 50*math.Sin(fx/9)*math.Cos(fy/7))
+			c := color.RGBA{v, v, v / 2, 255}
+			for _, d := range discs {
+				if math.Hypot(fx-d.x, fy-d.y) < d.r {
+					c = d.c
+				}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestRun(t *testing.T) {
+	report := Run(photoLike())
+
+	var want []Check
+	for _, algo := range []string{AHash, DHash, PHash} {
+		for _, size := range Sizes {
+			want = append(want, Check{Algorithm: algo, HashSize: size, Bits: size * size})
+		}
+	}
+	if len(report.Checks) != len(want) {
+		t.Fatalf("got %d checks, want %d", len(report.Checks), len(want))
+	}
+	for i, c := range report.Checks {
+		if c.Algorithm != want[i].Algorithm || c.HashSize != want[i].HashSize || c.Bits != want[i].Bits {
+			t.Errorf("check %d is %s/%d with %d bits, want %s/%d with %d", i, c.Algorithm, c.HashSize, c.Bits, want[i].Algorithm, want[i].HashSize, want[i].Bits)
+		}
+		if c.Error != "" {
+			t.Errorf("%s/%d: %s", c.Algorithm, c.HashSize, c.Error)
+		}
+		if len(c.Ours) != c.Bits/4 || len(c.Theirs) != c.Bits/4 {
+			t.Errorf("%s/%d: hashes %q and %q, want %d hex digits", c.Algorithm, c.HashSize, c.Ours, c.Theirs, c.Bits/4)
+		}
+		if wantTol := int(DefaultTolerances[c.Algorithm] * float64(c.Bits)); c.Tolerance != wantTol {
+			t.Errorf("%s/%d: tolerance %d, want %d", c.Algorithm, c.HashSize, c.Tolerance, wantTol)
+		}
+		if c.Pass != (c.Distance <= c.Tolerance) {
+			t.Errorf("%s/%d: pass %v with distance %d and tolerance %d", c.Algorithm, c.HashSize, c.Pass, c.Distance, c.Tolerance)
+		}
+	}
+	if !report.Pass {
+		t.Errorf("smooth image fails parity:\n%s", report)
+	}
+}
+
+func TestRunTolerances(t *testing.T) {
+	img := photoLike()
+	strict := Run(img, WithTolerance(DHash, 0))
+	for _, c := range strict.Checks {
+		if c.Algorithm == DHash && (c.Tolerance != 0 || c.Pass != (c.Distance == 0)) {
+			t.Errorf("dhash/%d: tolerance %d, pass %v with distance %d", c.HashSize, c.Tolerance, c.Pass, c.Distance)
+		}
+	}
+
+	// The verdict is the conjunction of the checks
+	for _, r := range []ParityReport{strict, Run(img, WithTolerance(AHash, 1), WithTolerance(DHash, 1), WithTolerance(PHash, 1))} {
+		pass := true
+		for _, c := range r.Checks {
+			pass = pass && c.Pass
+		}
+		if r.Pass != pass {
+			t.Errorf("report pass %v, checks %v", r.Pass, pass)
+		}
+	}
+}
+
+func TestParityReportJSON(t *testing.T) {
+	data, err := json.Marshal(Run(photoLike()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Pass   *bool            `json:"pass"`
+		Checks []map[string]any `json:"checks"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Pass == nil || len(out.Checks) == 0 {
+		t.Fatalf("unexpected JSON: %s", data)
+	}
+	for _, key := range []string{"algorithm", "hash_size", "bits", "imagehash_go", "goimagehash", "distance", "tolerance", "pass"} {
+		if _, ok := out.Checks[0][key]; !ok {
+			t.Errorf("check has no %q field: %s", key, data)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package imagehashgo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/disintegration/imaging"
+)
+
+// maxICCProfile bounds the size of an embedded ICC profile, so a corrupt
+// length cannot make ExtractICCProfile allocate without limit
+const maxICCProfile = 16 << 20
+
+// ColorProfiled is implemented by images that carry the ICC profile they
+// were encoded in. NormalizeColorProfile uses it to convert them to sRGB.
+type ColorProfiled interface {
+	ICCProfile() []byte
+}
+
+// ProfiledImage attaches an ICC profile, e.g. from ExtractICCProfile, to a
+// decoded image
+type ProfiledImage struct {
+	image.Image
+	// ICC is the raw profile; nil means untagged, taken as sRGB
+	ICC []byte
+}
+
+// ICCProfile implements the ColorProfiled interface
+func (p ProfiledImage) ICCProfile() []byte {
+	return p.ICC
+}
+
+// ColorProfileInfo is what InspectColorProfile found in an ICC profile
+type ColorProfileInfo struct {
+	// Description is the profile's own description, if it has one
+	Description string
+	// Space is "sRGB" or "Display P3" when the primaries are those, "RGB
+	// matrix" for other matrix and tone curve RGB profiles, and "" for
+	// profiles NormalizeColor passes through
+	Space string
+	// Note says why a profile is passed through; empty when it is converted
+	// or is already sRGB
+	Note string
+}
+
+// iccProfile is the part of a matrix and tone curve RGB profile the
+// conversion needs
+type iccProfile struct {
+	info ColorProfileInfo
+	// colorants are the XYZ (D50) of the red, green and blue primaries
+	colorants [3][3]float64
+	// trc decodes each channel, from 0-1, to linear light
+	trc [3]func(float64) float64
+}
+
+// D50-adapted primaries of sRGB and Display P3, as in their ICC profiles
+var (
+	srgbColorants = [3][3]float64{{0.4361, 0.2225, 0.0139}, {0.3851, 0.7169, 0.0971}, {0.1431, 0.0606, 0.7141}}
+	p3Colorants   = [3][3]float64{{0.5151, 0.2412, -0.0011}, {0.2920, 0.6922, 0.0419}, {0.1572, 0.0666, 0.7841}}
+)
+
+// InspectColorProfile reports what an ICC profile is and whether
+// NormalizeColor converts it. Profiles it cannot convert, such as CMYK,
+// LUT-based or Lab profiles, get a Note; a blob that is not an ICC profile
+// or whose tags run past its end is an error.
+func InspectColorProfile(icc []byte) (ColorProfileInfo, error) {
+	p, err := parseICC(icc)
+	return p.info, err
+}
+
+// parseICC reads the matrix and tone curves of icc. A profile of a kind
+// it cannot convert comes back with a Note and no error.
+func parseICC(icc []byte) (iccProfile, error) {
+	var p iccProfile
+	if len(icc) < 132 || string(icc[36:40]) != "acsp" {
+		return p, errors.New("not an ICC profile")
+	}
+	if size := binary.BigEndian.Uint32(icc); size >= 132 && uint64(size) <= uint64(len(icc)) {
+		icc = icc[:size]
+	} else {
+		return p, fmt.Errorf("ICC profile declares %d bytes but has %d", size, len(icc))
+	}
+	count := binary.BigEndian.Uint32(icc[128:])
+	if uint64(count) > uint64(len(icc)-132)/12 {
+		return p, fmt.Errorf("ICC tag table of %d entries runs past the profile", count)
+	}
+	tags := make(map[string][]byte, count)
+	for i := range int(count) {
+		entry := icc[132+12*i:]
+		offset, size := binary.BigEndian.Uint32(entry[4:]), binary.BigEndian.Uint32(entry[8:])
+		if uint64(offset)+uint64(size) > uint64(len(icc)) {
+			return p, fmt.Errorf("ICC tag %q runs past the profile", entry[:4])
+		}
+		tags[string(entry[:4])] = icc[offset : offset+size]
+	}
+	p.info.Description = iccText(tags["desc"])
+
+	if space := string(icc[16:20]); space != "RGB " {
+		p.info.Note = fmt.Sprintf("%s profile, only RGB profiles are converted", strings.TrimSpace(space))
+		return p, nil
+	}
+	if string(icc[20:24]) != "XYZ " {
+		p.info.Note = "Lab connection space needs a full color management engine"
+		return p, nil
+	}
+	for i, ch := range "rgb" {
+		xyz, ok := tags[string(ch)+"XYZ"]
+		if !ok || len(xyz) < 20 || string(xyz[:4]) != "XYZ " {
+			p.info.Note = "no matrix and tone curve tags, e.g. a LUT-based profile"
+			return p, nil
+		}
+		for k := range 3 {
+			p.colorants[i][k] = s15Fixed16(xyz[8+4*k:])
+		}
+		if p.trc[i], ok = iccCurve(tags[string(ch)+"TRC"]); !ok {
+			p.info.Note = "unsupported tone curve"
+			return p, nil
+		}
+	}
+
+	switch {
+	case colorantsNear(p.colorants, srgbColorants):
+		p.info.Space = "sRGB"
+	case colorantsNear(p.colorants, p3Colorants):
+		p.info.Space = "Display P3"
+	default:
+		p.info.Space = "RGB matrix"
+	}
+	return p, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// colorantsNear compares primaries within the spread of published profiles
+func colorantsNear(a, b [3][3]float64) bool {
+	for i := range 3 {
+		for k := range 3 {
+			if math.Abs(a[i][k]-b[i][k]) > 0.005 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// iccText decodes a v2 desc or v4 mluc tag, taking the first record
+func iccText(tag []byte) string {
+	switch {
+	case len(tag) >= 12 && string(tag[:4]) == "desc":
+		n := binary.BigEndian.Uint32(tag[8:])
+		if uint64(n) > uint64(len(tag)-12) {
+			return ""
+		}
+		return strings.TrimRight(string(tag[12:12+n]), "\x00")
+	case len(tag) >= 28 && string(tag[:4]) == "mluc":
+		n, offset := binary.BigEndian.Uint32(tag[20:]), binary.BigEndian.Uint32(tag[24:])
+		if uint64(offset)+uint64(n) > uint64(len(tag)) {
+			return ""
+		}
+		units := make([]uint16, n/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(tag[int(offset)+2*i:])
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	}
+	return ""
+}
+
+// iccCurve returns the decoding function of a curv or para tone curve
+func iccCurve(tag []byte) (func(float64) float64, bool) {
+	if len(tag) < 12 {
+		return nil, false
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:]))
+		if n > (len(tag)-12)/2 {
+			return nil, false
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, true
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, gamma) }, true
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+		}
+		return func(x float64) float64 {
+			pos := x * float64(n-1)
+			i := int(pos)
+			if i >= n-1 {
+				return table[n-1]
+			}
+			return table[i] + (pos-float64(i))*(table[i+1]-table[i])
+		}, true
+	case "para":
+		kind := binary.BigEndian.Uint16(tag[8:])
+		params := []int{1, 3, 4, 5, 7}
+		if kind > 4 || len(tag) < 12+4*params[kind] {
+			return nil, false
+		}
+		var v [7]float64
+		for i := range params[kind] {
+			v[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := v[0], v[1], v[2], v[3], v[4], v[5], v[6]
+		if kind == 1 || kind == 2 {
+			if a == 0 {
+				return nil, false
+			}
+			// Types 1 and 2 switch where the power's base reaches 0
+			d = -b / a
+			if kind == 2 {
+				e, f = c, c
+			}
+			c = 0
+		}
+		if kind == 0 {
+			a, d = 1, math.Inf(-1)
+		}
+		return func(x float64) float64 {
+			if x >= d {
+				return math.Pow(max(a*x+b, 0), g) + e
+			}
+			return c*x + f
+		}, true
+	}
+	return nil, false
+}
+
+// NormalizeColor converts img from the color space of the ICC profile icc
+// to sRGB, so that hashes of a color-managed export and of a naive decode
+// of it agree. Matrix and tone curve RGB profiles are converted, which
+// covers sRGB and Display P3: each channel is decoded with its tone curve,
+// the linear color is mapped through the profile's primaries and sRGB's,
+// and the result is encoded with the sRGB curve, in 8 bits. This
+// approximates a color management engine without rendering intents or
+// gamut mapping; colors outside sRGB are clipped.
+//
+// img is returned unchanged when icc is empty or already sRGB, and when
+// InspectColorProfile has a Note for it, so that unknown profiles do not
+// stop hashing. The result is an *image.NRGBA with the bounds moved to
+// the origin, as imaging returns them, and with alpha kept.
+func NormalizeColor(img image.Image, icc []byte) (image.Image, error) {
+	out, _, err := normalizeColorProfile(img, icc)
+	return out, err
+}
+
+// normalizeColorProfile is NormalizeColor also reporting whether img was
+// converted
+func normalizeColorProfile(img image.Image, icc []byte) (image.Image, bool, error) {
+	if len(icc) == 0 {
+		return img, false, nil
+	}
+	p, err := parseICC(icc)
+	if err != nil {
+		return nil, false, err
+	}
+	if p.info.Note != "" {
+		return img, false, nil
+	}
+
+	var decode [3][256]float64
+	isSRGB := p.info.Space == "sRGB"
+	for c := range 3 {
+		for i := range 256 {
+			decode[c][i] = p.trc[c](float64(i) / 255)
+			isSRGB = isSRGB && math.Abs(decode[c][i]-srgbDecode(float64(i)/255)) < 0.002
+		}
+	}
+	if isSRGB {
+		return img, false, nil
+	}
+
+	// Linear source to D50 XYZ through the profile's primaries, then to
+	// linear sRGB through the inverse of sRGB's
+	var toSRGB [3][3]float64
+	inv := invert3(transpose3(srgbColorants))
+	src := transpose3(p.colorants)
+	for i := range 3 {
+		for j := range 3 {
+			for k := range 3 {
+				toSRGB[i][j] += inv[i][k] * src[k][j]
+			}
+		}
+	}
+	var encode [4096]uint8
+	for i := range encode {
+		encode[i] = uint8(math.Round(255 * srgbEncode(float64(i)/4095)))
+	}
+
+	out := imaging.Clone(img)
+	for y := range out.Rect.Dy() {
+		row := out.Pix[y*out.Stride : y*out.Stride+4*out.Rect.Dx()]
+		for x := 0; x < len(row); x += 4 {
+			r, g, b := decode[0][row[x]], decode[1][row[x+1]], decode[2][row[x+2]]
+			for c := range 3 {
+				v := toSRGB[c][0]*r + toSRGB[c][1]*g + toSRGB[c][2]*b
+				row[x+c] = encode[int(min(max(v, 0), 1)*4095+0.5)]
+			}
+		}
+	}
+	return out, true, nil
+}
+
+func srgbDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func transpose3(m [3][3]float64) (t [3][3]float64) {
+	for i := range 3 {
+		for j := range 3 {
+			t[i][j] = m[j][i]
+		}
+	}
+	return t
+}
+
+// invert3 inverts a 3x3 matrix by its adjugate; the sRGB primaries are far
+// from singular
+func invert3(m [3][3]float64) (inv [3][3]float64) {
+	for i := range 3 {
+		for j := range 3 {
+			// Cofactor of m[j][i], with the cyclic indices giving the sign
+			a, b := (j+1)%3, (j+2)%3
+			c, d := (i+1)%3, (i+2)%3
+			inv[i][j] = m[a][c]*m[b][d] - m[a][d]*m[b][c]
+		}
+	}
+	det := m[0][0]*inv[0][0] + m[0][1]*inv[1][0] + m[0][2]*inv[2][0]
+	for i := range 3 {
+		for j := range 3 {
+			inv[i][j] /= det
+		}
+	}
+	return inv
+}
+
+type normalizeColor struct{}
+
+// NormalizeColorProfile converts images implementing ColorProfiled, such as
+// ProfiledImage, to sRGB with NormalizeColor. Other images, and images
+// with profiles NormalizeColor does not convert, are passed through. An
+// OrientedImage around a ColorProfiled image keeps its orientation, so
+// this step may run before or after AutoOrient.
+func NormalizeColorProfile() PreprocessStep {
+	return normalizeColor{}
+}
+
+func (normalizeColor) Apply(img image.Image) (image.Image, error) {
+	if o, ok := img.(OrientedImage); ok {
+		inner, err := normalizeColor{}.Apply(o.Image)
+		if err != nil {
+			return nil, err
+		}
+		return OrientedImage{Image: inner, Exif: o.Exif}, nil
+	}
+	p, ok := img.(ColorProfiled)
+	if !ok {
+		return img, nil
+	}
+	inner := img
+	if pi, ok := img.(ProfiledImage); ok {
+		inner = pi.Image
+	}
+	out, converted, err := normalizeColorProfile(inner, p.ICCProfile())
+	if err != nil {
+		return nil, err
+	}
+	if !converted {
+		// Passed through: keep the profile for inspection
+		return img, nil
+	}
+	return out, nil
+}
+
+func (normalizeColor) String() string { return "normalizecolor" }
+
+// pngSignature starts every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// ExtractICCProfile returns the ICC profile embedded in a JPEG (APP2
+// ICC_PROFILE segments, reassembled in order) or PNG (iCCP chunk) read
+// from r, or nil when the image has none. It reads only up to the image
+// data, so decode the image from a separate reader over the same bytes.
+func ExtractICCProfile(r io.Reader) ([]byte, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(pngSignature))
+	switch {
+	case bytes.HasPrefix(magic, []byte{0xff, 0xd8}):
+		br.Discard(2)
+		return jpegICCProfile(br)
+	case string(magic) == pngSignature:
+		br.Discard(len(pngSignature))
+		return pngICCProfile(br)
+	}
+	return nil, errors.New("ICC profiles can be extracted from JPEG and PNG only")
+}
+
+// jpegICCProfile reads the segments after SOI up to the first scan
+func jpegICCProfile(r *bufio.Reader) ([]byte, error) {
+	chunks := make(map[byte][]byte)
+	var count byte
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, fmt.Errorf("reading JPEG marker: %w", err)
+		}
+		if marker[0] != 0xff {
+			return nil, fmt.Errorf("invalid JPEG marker %#02x%02x", marker[0], marker[1])
+		}
+		m := marker[1]
+		for m == 0xff {
+			// Fill bytes may pad any marker
+			var err error
+			if m, err = r.ReadByte(); err != nil {
+				return nil, fmt.Errorf("reading JPEG marker: %w", err)
+			}
+		}
+		if m == 0xda || m == 0xd9 {
+			break
+		}
+		if m == 0x01 || (m >= 0xd0 && m <= 0xd7) {
+			continue
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return nil, fmt.Errorf("reading JPEG segment: %w", err)
+		}
+		n := int(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return nil, fmt.Errorf("JPEG segment %#02x has invalid length", m)
+		}
+		if m != 0xe2 {
+			if _, err := r.Discard(n); err != nil {
+				return nil, fmt.Errorf("reading JPEG segment: %w", err)
+			}
+			continue
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return nil, fmt.Errorf("reading JPEG segment: %w", err)
+		}
+		payload, ok := bytes.CutPrefix(seg, []byte("ICC_PROFILE\x00"))
+		if !ok || len(payload) < 2 {
+			continue
+		}
+		if payload[0] == 0 || payload[0] > payload[1] || (count != 0 && payload[1] != count) {
+			return nil, fmt.Errorf("ICC profile chunk %d of %d is invalid", payload[0], payload[1])
+		}
+		count = payload[1]
+		chunks[payload[0]] = payload[2:]
+	}
+
+	if count == 0 {
+		return nil, nil
+	}
+	var icc []byte
+	for seq := byte(1); seq <= count && seq != 0; seq++ {
+		chunk, ok := chunks[seq]
+		if !ok {
+			return nil, fmt.Errorf("ICC profile chunk %d of %d is missing", seq, count)
+		}
+		icc = append(icc, chunk...)
+	}
+	return icc, nil
+}
+
+// pngICCProfile reads the chunks after the signature up to the image data
+func pngICCProfile(r *bufio.Reader) ([]byte, error) {
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("reading PNG chunk: %w", err)
+		}
+		n := binary.BigEndian.Uint32(header[:])
+		switch string(header[4:]) {
+		case "IDAT", "IEND":
+			return nil, nil
+		case "iCCP":
+			if n > maxICCProfile {
+				return nil, fmt.Errorf("PNG iCCP chunk of %d bytes is too large", n)
+			}
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("reading PNG iCCP chunk: %w", err)
+			}
+			// A profile name, a NUL, the compression method (0, zlib) and
+			// the compressed profile
+			_, compressed, ok := bytes.Cut(data, []byte{0})
+			if !ok || len(compressed) < 1 || compressed[0] != 0 {
+				return nil, errors.New("PNG iCCP chunk is invalid")
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(compressed[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("PNG iCCP chunk: %w", err)
+			}
+			icc, err := io.ReadAll(io.LimitReader(zr, maxICCProfile))
+			if err != nil {
+				return nil, fmt.Errorf("PNG iCCP chunk: %w", err)
+			}
+			return icc, nil
+		}
+		if _, err := r.Discard(int(n) + 4); err != nil {
+			return nil, fmt.Errorf("reading PNG chunk: %w", err)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// testICCProfile builds a minimal v4 matrix and tone curve RGB profile:
+// the given D50 primaries, and the sRGB curve as a type 3 para curve
+func testICCProfile(desc string, colorants [3][3]float64) []byte {
+	s15 := func(v float64) []byte { return binary.BigEndian.AppendUint32(nil, uint32(int32(math.Round(v*65536)))) }
+	var tags []struct {
+		sig  string
+		data []byte
+	}
+	add := func(sig string, data []byte) {
+		tags = append(tags, struct {
+			sig  string
+			data []byte
+		}{sig, data})
+	}
+	text := []byte("desc\x00\x00\x00\x00")
+	text = binary.BigEndian.AppendUint32(text, uint32(len(desc)+1))
+	add("desc", append(append(text, desc...), 0))
+	for i, ch := range "rgb" {
+		xyz := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range colorants[i] {
+			xyz = append(xyz, s15(v)...)
+		}
+		add(string(ch)+"XYZ", xyz)
+	}
+	curve := []byte("para\x00\x00\x00\x00\x00\x03\x00\x00")
+	for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		curve = append(curve, s15(v)...)
+	}
+	for _, ch := range "rgb" {
+		add(string(ch)+"TRC", curve)
+	}
+
+	header := make([]byte, 128)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB XYZ ")
+	copy(header[36:], "acsp")
+	table := binary.BigEndian.AppendUint32(nil, uint32(len(tags)))
+	offset := 128 + 4 + 12*len(tags)
+	var data []byte
+	for _, tag := range tags {
+		table = append(table, tag.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(offset+len(data)))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+		data = append(data, tag.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	icc := append(append(header, table...), data...)
+	binary.BigEndian.PutUint32(icc, uint32(len(icc)))
+	return icc
+}
+
+// colorScene is a saturated test scene: hue and lightness gradients with
+// a few solid patches
+func colorScene() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 240, 160))
+	for y := range 160 {
+		for x := range 240 {
+			c := color.NRGBA{uint8(x * 255 / 239), uint8(y * 255 / 159), uint8(255 - (x+y)*255/398), 255}
+			switch {
+			case x > 30 && x < 90 && y > 20 && y < 70:
+				c = color.NRGBA{230, 20, 30, 255}
+			case x > 140 && x < 220 && y > 90 && y < 140:
+				c = color.NRGBA{20, 200, 60, 255}
+			case (x-180)*(x-180)+(y-40)*(y-40) < 600:
+				c = color.NRGBA{30, 40, 220, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// toDisplayP3 re-encodes sRGB pixels as Display P3 with the CSS Color 4
+// linear sRGB to P3 matrix, independent of the profile math under test
+func toDisplayP3(src *image.NRGBA) *image.NRGBA {
+	m := [3][3]float64{
+		{0.8224621, 0.1775380, 0},
+		{0.0331941, 0.9668058, 0},
+		{0.0170827, 0.0723974, 0.9105199},
+	}
+	dst := image.NewNRGBA(src.Rect)
+	for i := 0; i < len(src.Pix); i += 4 {
+		lin := [3]float64{srgbDecode(float64(src.Pix[i]) / 255), srgbDecode(float64(src.Pix[i+1]) / 255), srgbDecode(float64(src.Pix[i+2]) / 255)}
+		for c := range 3 {
+			v := m[c][0]*lin[0] + m[c][1]*lin[1] + m[c][2]*lin[2]
+			dst.Pix[i+c] = uint8(math.Round(255 * srgbEncode(v)))
+		}
+		dst.Pix[i+3] = src.Pix[i+3]
+	}
+	return dst
+}
+
+// pngWithICC encodes img as a PNG with an iCCP chunk after IHDR
+func pngWithICC(t *testing.T, img image.Image, icc []byte) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(icc)
+	zw.Close()
+	chunk := append([]byte("iCCPtest\x00\x00"), z.Bytes()...)
+	out := append([]byte(nil), buf.Bytes()[:33]...) // signature and IHDR
+	out = binary.BigEndian.AppendUint32(out, uint32(len(chunk)-4))
+	out = append(out, chunk...)
+	out = binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(chunk))
+	return append(out, buf.Bytes()[33:]...)
+}
+
+// jpegWithICC encodes img as a JPEG with the profile split over APP2
+// segments of at most chunk bytes, written in the given order
+func jpegWithICC(t *testing.T, img image.Image, icc []byte, chunk int, order []int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	var parts [][]byte
+	for len(icc) > 0 {
+		n := min(chunk, len(icc))
+		parts = append(parts, icc[:n])
+		icc = icc[n:]
+	}
+	out := []byte{0xff, 0xd8}
+	for _, i := range order {
+		seg := append([]byte("ICC_PROFILE\x00"), byte(i+1), byte(len(parts)))
+		seg = append(seg, parts[i]...)
+		out = append(out, 0xff, 0xe2)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(seg)+2))
+		out = append(out, seg...)
+	}
+	return append(out, buf.Bytes()[2:]...)
+}
+
+func TestNormalizeColor_DisplayP3(t *testing.T) {
+	scene := colorScene()
+	p3 := testICCProfile("Display P3", p3Colorants)
+	info, err := InspectColorProfile(p3)
+	if err != nil || info.Space != "Display P3" || info.Description != "Display P3" || info.Note != "" {
+		t.Fatalf("InspectColorProfile() = %+v, %v", info, err)
+	}
+
+	file := pngWithICC(t, toDisplayP3(scene), p3)
+	icc, err := ExtractICCProfile(bytes.NewReader(file))
+	if err != nil || !bytes.Equal(icc, p3) {
+		t.Fatalf("ExtractICCProfile() = %d bytes, %v; want the P3 profile", len(icc), err)
+	}
+	decoded, err := png.Decode(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	normalized, err := NormalizeColor(decoded, icc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Against the CSS Color 4 P3 to sRGB matrix on the same 8-bit P3
+	// pixels; the scene itself is not recovered exactly, as 8-bit P3 loses
+	// dark reds next to bright greens
+	m := [3][3]float64{
+		{1.2249401, -0.2249404, 0},
+		{-0.0420569, 1.0420571, 0},
+		{-0.0196376, -0.0786361, 1.0982735},
+	}
+	got := normalized.(*image.NRGBA)
+	src := imaging.Clone(decoded)
+	worst := 0
+	for i := 0; i < len(got.Pix); i += 4 {
+		lin := [3]float64{srgbDecode(float64(src.Pix[i]) / 255), srgbDecode(float64(src.Pix[i+1]) / 255), srgbDecode(float64(src.Pix[i+2]) / 255)}
+		for c := range 3 {
+			v := min(max(m[c][0]*lin[0]+m[c][1]*lin[1]+m[c][2]*lin[2], 0), 1)
+			want := int(math.Round(255 * srgbEncode(v)))
+			worst = max(worst, want-int(got.Pix[i+c]), int(got.Pix[i+c])-want)
+		}
+	}
+	if worst > 1 {
+		t.Errorf("normalized pixels differ from the reference conversion by up to %d", worst)
+	}
+
+	for _, kind := range rawKinds {
+		want, _ := Hash(scene, kind, 8)
+		naive, _ := Hash(decoded, kind, 8)
+		h, _ := Hash(ProfiledImage{Image: decoded, ICC: icc}, kind, 8, WithPreprocess(NewPreprocess(NormalizeColorProfile())))
+		dNaive, _ := naive.Distance(want)
+		d, _ := h.Distance(want)
+		t.Logf("%s: naive decode %d bits from sRGB, normalized %d", kind, dNaive, d)
+		if d > 1 {
+			t.Errorf("%s: normalized hash is %d bits from the sRGB hash", kind, d)
+		}
+	}
+}
+
+func TestNormalizeColor_PassThrough(t *testing.T) {
+	scene := colorScene()
+	srgb := testICCProfile("sRGB IEC61966-2.1", srgbColorants)
+	if info, err := InspectColorProfile(srgb); err != nil || info.Space != "sRGB" {
+		t.Errorf("InspectColorProfile(sRGB) = %+v, %v", info, err)
+	}
+
+	cmyk := testICCProfile("Coated FOGRA39", srgbColorants)
+	copy(cmyk[16:], "CMYK")
+	lut := testICCProfile("LUT", srgbColorants)
+	copy(lut[132+12:], "AXYZ") // rename rXYZ
+	for name, icc := range map[string][]byte{"none": nil, "sRGB": srgb, "CMYK": cmyk, "LUT": lut} {
+		out, err := NormalizeColor(scene, icc)
+		if err != nil || out != image.Image(scene) {
+			t.Errorf("%s: NormalizeColor() = %T, %v; want the image unchanged", name, out, err)
+		}
+	}
+	if info, _ := InspectColorProfile(cmyk); info.Note == "" || info.Space != "" {
+		t.Errorf("CMYK profile: %+v, want a note", info)
+	}
+	if info, _ := InspectColorProfile(lut); !strings.Contains(info.Note, "LUT") {
+		t.Errorf("LUT profile: %+v, want a note", info)
+	}
+
+	// The step keeps the profile of images it passes through, and the
+	// orientation of images it converts
+	step := NormalizeColorProfile()
+	if out, _ := step.Apply(ProfiledImage{Image: scene, ICC: cmyk}); !isProfiled(out) {
+		t.Error("passed-through image lost its profile")
+	}
+	out, err := step.Apply(OrientedImage{Image: ProfiledImage{Image: toDisplayP3(scene), ICC: testICCProfile("P3", p3Colorants)}, Exif: 6})
+	if o, ok := out.(OrientedImage); err != nil || !ok || o.Exif != 6 {
+		t.Errorf("Apply() = %T, %v; want an OrientedImage", out, err)
+	}
+	if p, err := parsePreprocess(NewPreprocess(step).String()); err != nil || p.String() != "normalizecolor" {
+		t.Errorf("parsePreprocess(normalizecolor) = %v, %v", p, err)
+	}
+}
+
+func TestInspectColorProfile_Corrupt(t *testing.T) {
+	p3 := testICCProfile("Display P3", p3Colorants)
+	tagPastEnd := bytes.Clone(p3)
+	binary.BigEndian.PutUint32(tagPastEnd[132+4:], uint32(len(p3)))
+	tableTooLong := bytes.Clone(p3)
+	binary.BigEndian.PutUint32(tableTooLong[128:], 1000)
+	for name, icc := range map[string][]byte{
+		"short":          p3[:100],
+		"no signature":   append(make([]byte, 40), p3[40:]...),
+		"truncated":      p3[:len(p3)-8],
+		"tag past end":   tagPastEnd,
+		"table too long": tableTooLong,
+	} {
+		if _, err := InspectColorProfile(icc); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if _, err := NormalizeColor(colorScene(), icc); err == nil {
+			t.Errorf("%s: NormalizeColor expected error", name)
+		}
+	}
+}
+
+func TestExtractICCProfile(t *testing.T) {
+	scene := colorScene()
+	icc := testICCProfile("Display P3", p3Colorants)
+
+	// Chunks may arrive in any order
+	for _, order := range [][]int{{0, 1, 2}, {2, 0, 1}} {
+		got, err := ExtractICCProfile(bytes.NewReader(jpegWithICC(t, scene, icc, len(icc)/3+1, order)))
+		if err != nil || !bytes.Equal(got, icc) {
+			t.Errorf("JPEG chunks %v: %d bytes, %v", order, len(got), err)
+		}
+	}
+	if _, err := ExtractICCProfile(bytes.NewReader(jpegWithICC(t, scene, icc, len(icc)/3+1, []int{0, 2}))); err == nil {
+		t.Error("missing JPEG chunk expected error")
+	}
+
+	var plain bytes.Buffer
+	png.Encode(&plain, scene)
+	if got, err := ExtractICCProfile(&plain); got != nil || err != nil {
+		t.Errorf("untagged PNG: %d bytes, %v", len(got), err)
+	}
+	plain.Reset()
+	jpeg.Encode(&plain, scene, nil)
+	if got, err := ExtractICCProfile(&plain); got != nil || err != nil {
+		t.Errorf("untagged JPEG: %d bytes, %v", len(got), err)
+	}
+	if _, err := ExtractICCProfile(strings.NewReader("GIF89a")); err == nil {
+		t.Error("GIF expected error")
+	}
+	if _, err := ExtractICCProfile(bytes.NewReader(pngWithICC(t, scene, icc)[:60])); err == nil {
+		t.Error("truncated PNG expected error")
+	}
+}
+
+func isProfiled(img image.Image) bool {
+	_, ok := img.(ColorProfiled)
+	return ok
+}
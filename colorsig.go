@@ -0,0 +1,125 @@
+package imagehashgo
+
+import (
+	"encoding/hex"
+	"image"
+)
+
+// colorSigBins is the number of ColorSig bins: 2 bits of red, 2 of green
+// and 1 of blue, to which the eye is least sensitive
+const colorSigBins = 32
+
+// ColorSig is a coarse color histogram of an image: each byte is the share
+// of the pixels, in 255ths, whose un-premultiplied color falls into one of
+// 32 RGB bins. It is a cheap pre-filter for structural hashes, which do not
+// see color: images with distant signatures are not duplicates even when
+// their grayscale hashes match.
+type ColorSig [colorSigBins]uint8
+
+// Distance returns the L1 distance between the two histograms, normalized
+// to [0, 1]: 0 for identical color distributions, 1 for disjoint ones
+func (s ColorSig) Distance(other ColorSig) float64 {
+	d := 0
+	for i := range s {
+		diff := int(s[i]) - int(other[i])
+		d += max(diff, -diff)
+	}
+	return min(float64(d)/(2*255), 1)
+}
+
+// String returns the signature as 64 hex digits
+func (s ColorSig) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// ColorSignature computes the ColorSig of img
+func ColorSignature(img image.Image, opts ...Option) ColorSig {
+	_, sig := newOptions(opts).grayscaleWithSig(img)
+	return sig
+}
+
+// HashWithColorSignature is Hash also returning the ColorSig of the image.
+// Both come from a single pass over the pixels: the signature is counted
+// while the image is converted to grayscale.
+func HashWithColorSignature(img image.Image, kind HashKind, hashSize int, opts ...Option) (*ImageHash, ColorSig, error) {
+	o := newOptions(opts)
+	if o.preprocess != nil {
+		var err error
+		if img, err = o.preprocess.Apply(img); err != nil {
+			return nil, ColorSig{}, err
+		}
+		// Already applied, do not let Hash run it again
+		opts = append(opts[:len(opts):len(opts)], WithPreprocess(nil))
+	}
+
+	gray, sig := o.grayscaleWithSig(img)
+	// Hash takes *image.Gray as is, so the image is not converted again
+	h, err := Hash(gray, kind, hashSize, opts...)
+	if err != nil {
+		return nil, ColorSig{}, err
+	}
+	return h, sig, nil
+}
+
+// grayscaleWithSig converts img to grayscale, without the quantization of
+// o.grayscale, and computes its ColorSig
+func (o options) grayscaleWithSig(img image.Image) (*image.Gray, ColorSig) {
+	threshold := ParallelGrayscaleThreshold
+	if o.parallelThresholdSet {
+		threshold = o.parallelThreshold
+	}
+	var hist colorHist
+	o.logGrayscale(img, threshold)
+	gray := convertGrayscale(img, threshold, &hist, nil)
+	return gray, hist.sig()
+}
+
+// colorHist counts pixels per ColorSig bin
+type colorHist [colorSigBins]uint64
+
+// gray is rgbaToGray that also counts the pixel's bin when h is not nil
+func (h *colorHist) gray(r, g, b, a uint32) uint8 {
+	if a > 0 && a < 0xffff {
+		r = (r * 0xffff) / a
+		g = (g * 0xffff) / a
+		b = (b * 0xffff) / a
+	}
+	r8, g8, b8 := r>>8, g>>8, b>>8
+	if h != nil {
+		h[r8>>6<<3|g8>>6<<1|b8>>7]++
+	}
+	return uint8((r8*299 + g8*587 + b8*114 + 500) / 1000)
+}
+
+func (h *colorHist) add(other *colorHist) {
+	for i, n := range other {
+		h[i] += n
+	}
+}
+
+// sig scales the counts to 255ths of the total
+func (h *colorHist) sig() ColorSig {
+	var total uint64
+	for _, n := range h {
+		total += n
+	}
+	var s ColorSig
+	if total == 0 {
+		return s
+	}
+	for i, n := range h {
+		s[i] = uint8((n*255 + total/2) / total)
+	}
+	return s
+}
+
+// processGrayHist counts the bins of an image that is already grayscale
+func processGrayHist(gray *image.Gray, hist *colorHist) {
+	w := gray.Rect.Dx()
+	for y := range gray.Rect.Dy() {
+		for _, p := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			v := uint32(p) * 0x101
+			hist.gray(v, v, v, 0xffff)
+		}
+	}
+}
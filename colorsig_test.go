@@ -0,0 +1,122 @@
+package imagehashgo
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestColorSignature_Fixtures(t *testing.T) {
+	// One fixture per grayscale path: YCbCr, Paletted (rgba64), NRGBA,
+	// Gray and RGBA
+	want := map[string]string{
+		"photo.jpg":       "000000000300000001000000010000000f0002000001000128001001000400aa",
+		"palette.gif":     "4000004000000000000000000000000000000000000000000000000000004040",
+		"transparent.png": "9000000000000000000000000000000000000000000000006f00000000000000",
+		"checker.png":     "8000000000000000000000000000000000000000000000000000000000000080",
+		"gradient.png":    "001100110011000c001100110011000c001100110011000c001100110011000c",
+	}
+	for name, sig := range want {
+		f, err := os.Open(filepath.Join("testdata", "golden", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := ColorSignature(img).String(); got != sig {
+			t.Errorf("%s: ColorSignature = %s, want %s", name, got, sig)
+		}
+	}
+}
+
+func TestHashWithColorSignature(t *testing.T) {
+	inputs := grayscaleInputs(97, 61)
+	inputs["gray"] = ToGrayscale(inputs["RGBA"])
+	inputs["grayrow"] = newSlowImage(40, 30, -1)
+	for name, img := range inputs {
+		want := ColorSignature(genericImage{img}, WithParallelGrayscaleThreshold(math.MaxInt))
+		for _, threshold := range []int{0, math.MaxInt} {
+			opt := WithParallelGrayscaleThreshold(threshold)
+			for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifference} {
+				h, sig, err := HashWithColorSignature(img, kind, 8, opt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if sig != want {
+					t.Errorf("%s threshold %d: signature %s, want %s from At", name, threshold, sig, want)
+				}
+				plain, _ := Hash(img, kind, 8, opt)
+				if h.ToString() != plain.ToString() {
+					t.Errorf("%s %s: hash %s, want %s", name, kind, h.ToString(), plain.ToString())
+				}
+			}
+		}
+	}
+
+	if _, _, err := HashWithColorSignature(inputs["RGBA"], "whash", 8); err == nil {
+		t.Error("unknown kind should fail")
+	}
+}
+
+func TestColorSig_Distance(t *testing.T) {
+	solid := func(c color.Color) ColorSig {
+		img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+		for y := range 8 {
+			for x := range 8 {
+				img.Set(x, y, c)
+			}
+		}
+		return ColorSignature(img)
+	}
+	red, blue := solid(color.RGBA{255, 0, 0, 255}), solid(color.RGBA{0, 0, 255, 255})
+	if d := red.Distance(red); d != 0 {
+		t.Errorf("identical signatures: distance %v, want 0", d)
+	}
+	if d := red.Distance(blue); d != 1 {
+		t.Errorf("disjoint signatures: distance %v, want 1", d)
+	}
+
+	// Half red, half blue is halfway between the two
+	half := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := range 8 {
+		for x := range 8 {
+			half.Set(x, y, color.RGBA{uint8(255 * (y % 2)), 0, uint8(255 * (1 - y%2)), 255})
+		}
+	}
+	mixed := ColorSignature(half)
+	if d1, d2 := mixed.Distance(red), red.Distance(mixed); d1 != d2 || math.Abs(d1-0.5) > 0.01 {
+		t.Errorf("half red: distances %v and %v, want 0.5 both ways", d1, d2)
+	}
+}
+
+// BenchmarkColorSignature compares the pixel pass of each grayscale path
+// alone, fused with the signature, and followed by a separate signature
+// pass (as calling Hash and ColorSignature does)
+func BenchmarkColorSignature(b *testing.B) {
+	for name, img := range grayscaleInputs(512, 512) {
+		b.Run(name+"/plain", func(b *testing.B) {
+			for b.Loop() {
+				toGrayscaleFast(img, math.MaxInt)
+			}
+		})
+		b.Run(name+"/fused", func(b *testing.B) {
+			for b.Loop() {
+				var hist colorHist
+				convertGrayscale(img, math.MaxInt, &hist, nil)
+			}
+		})
+		b.Run(name+"/separate", func(b *testing.B) {
+			for b.Loop() {
+				var hist colorHist
+				toGrayscaleFast(img, math.MaxInt)
+				convertGrayscale(img, math.MaxInt, &hist, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package imagehashgo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CombinedHash is the horizontal and vertical dHash of one image, either of
+// which may be missing: a collection that stored only horizontal dHashes
+// can add the vertical half for the originals it still has, and compare
+// old and new entries on the halves both have. Nil marks a missing half.
+type CombinedHash struct {
+	Horizontal *ImageHash
+	Vertical   *ImageHash
+}
+
+// CombineAvailable returns the combined hash of a horizontal dHash h and
+// a vertical dHash v, either of which may be nil. It returns an error when
+// both are nil, when a half is of another kind (hashes without a kind, as
+// parsed from hex, are accepted), or when the halves differ in shape.
+func CombineAvailable(h, v *ImageHash) (CombinedHash, error) {
+	if h == nil && v == nil {
+		return CombinedHash{}, errors.New("combined hash needs at least one half")
+	}
+	if h != nil && h.kind != "" && h.kind != KindDifference {
+		return CombinedHash{}, fmt.Errorf("horizontal half must be a %s hash, got %s", KindDifference, h.kind)
+	}
+	if v != nil && v.kind != "" && v.kind != KindDifferenceVertical {
+		return CombinedHash{}, fmt.Errorf("vertical half must be a %s hash, got %s", KindDifferenceVertical, v.kind)
+	}
+	if h != nil && v != nil && (h.rows != v.rows || h.cols != v.cols) {
+		return CombinedHash{}, fmt.Errorf("halves must be of the same shape: (%d, %d) vs (%d, %d)", h.rows, h.cols, v.rows, v.cols)
+	}
+	return CombinedHash{Horizontal: h, Vertical: v}, nil
+}
+
+// Hash returns the halves stacked into one hash, the horizontal rows above
+// the vertical ones, and its validity mask: the bits of a missing half are
+// false in both. It returns nil, nil when both halves are missing.
+func (c CombinedHash) Hash() (hash *ImageHash, valid []bool) {
+	half := c.Horizontal
+	if half == nil {
+		half = c.Vertical
+	}
+	if half == nil {
+		return nil, nil
+	}
+	n := len(half.hash)
+	bits := make([]bool, 2*n)
+	valid = make([]bool, 2*n)
+	for i, h := range []*ImageHash{c.Horizontal, c.Vertical} {
+		if h != nil {
+			copy(bits[i*n:], h.hash)
+			for j := range n {
+				valid[i*n+j] = true
+			}
+		}
+	}
+	return &ImageHash{hash: bits, rows: 2 * half.rows, cols: half.cols}, valid
+}
+
+// Distance returns the Hamming distance over the bits valid in both hashes
+// divided by their number, and that number. Entries with one half and
+// entries with both then rank on one scale. It returns an error when the
+// hashes have no half in common or differ in shape.
+func (c CombinedHash) Distance(other CombinedHash) (normalized float64, overlap int, err error) {
+	a, validA := c.Hash()
+	b, validB := other.Hash()
+	if a == nil || b == nil {
+		return 0, 0, errors.New("combined hash has no half")
+	}
+	if a.rows != b.rows || a.cols != b.cols {
+		return 0, 0, fmt.Errorf("combined hashes must be of the same shape: (%d, %d) vs (%d, %d)", a.rows, a.cols, b.rows, b.cols)
+	}
+	ignore := make([]bool, len(validA))
+	for i := range ignore {
+		ignore[i] = !validA[i] || !validB[i]
+		if !ignore[i] {
+			overlap++
+		}
+	}
+	if overlap == 0 {
+		return 0, 0, errors.New("combined hashes have no half in common")
+	}
+	dist, err := a.MaskedDistance(b, ignore)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(dist) / float64(overlap), overlap, nil
+}
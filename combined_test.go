@@ -0,0 +1,113 @@
+package imagehashgo
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+func TestCombinedHash_MixedRanking(t *testing.T) {
+	photo, copy, _, _ := profilePairs(t)
+	images := []image.Image{copy}
+	for _, name := range []string{"checker.png", "gradient.png", "lineart.png", "noise.png", "text.png", "tall.png"} {
+		img, err := imaging.Open(filepath.Join("testdata", "golden", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		images = append(images, img)
+	}
+	halves := func(img image.Image) (h, v *ImageHash) {
+		return DifferenceHash(img, 8), DifferenceHashVertical(img, 8)
+	}
+
+	// Every combination of available halves: the copy of the photo ranks
+	// first whenever the query and the entries share a half
+	present := []struct {
+		name   string
+		h, v   bool
+		common int
+	}{{"horizontal", true, false, 1}, {"vertical", false, true, 2}, {"both", true, true, 3}}
+	qh, qv := halves(photo)
+	for _, q := range present {
+		query, err := CombineAvailable(pick(q.h, qh), pick(q.v, qv))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range present {
+			if q.common&e.common == 0 {
+				continue
+			}
+			// Entries with the halves of e alternate with full ones, the
+			// copy being of either sort
+			for shift := range 2 {
+				best, bestDist := -1, 2.0
+				for i, img := range images {
+					h, v := halves(img)
+					full := (i+shift)%2 == 1
+					entry, err := CombineAvailable(pick(e.h || full, h), pick(e.v || full, v))
+					if err != nil {
+						t.Fatal(err)
+					}
+					d, _, err := query.Distance(entry)
+					if err != nil {
+						t.Fatalf("query %s, entry %s: %v", q.name, e.name, err)
+					}
+					if d < bestDist {
+						best, bestDist = i, d
+					}
+				}
+				if best != 0 {
+					t.Errorf("query %s, entries %s/%d: image %d ranks first at %.3f, not the copy", q.name, e.name, shift, best, bestDist)
+				}
+			}
+		}
+	}
+
+	horizontal, _ := CombineAvailable(qh, nil)
+	vertical, _ := CombineAvailable(nil, qv)
+	if _, _, err := horizontal.Distance(vertical); err == nil {
+		t.Error("hashes without a common half expected error")
+	}
+}
+
+// pick returns h when ok and nil otherwise
+func pick(ok bool, h *ImageHash) *ImageHash {
+	if ok {
+		return h
+	}
+	return nil
+}
+
+func TestCombineAvailable(t *testing.T) {
+	img := getBenchImage()
+	h, v := DifferenceHash(img, 8), DifferenceHashVertical(img, 8)
+	c, err := CombineAvailable(h, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, valid := c.Hash()
+	if hash.rows != 16 || hash.cols != 8 || !valid[63] || valid[64] {
+		t.Errorf("horizontal only: %dx%d, valid %v", hash.rows, hash.cols, valid)
+	}
+	if d, overlap, err := c.Distance(CombinedHash{Horizontal: h, Vertical: v}); err != nil || d != 0 || overlap != 64 {
+		t.Errorf("Distance to the full hash = %v, %d, %v", d, overlap, err)
+	}
+
+	for name, halves := range map[string][2]*ImageHash{
+		"none":        {nil, nil},
+		"wrong kind":  {AverageHash(img, 8), nil},
+		"swapped":     {v, h},
+		"shape":       {h, DifferenceHashVertical(img, 16)},
+		"vertical ok": {nil, v},
+	} {
+		_, err := CombineAvailable(halves[0], halves[1])
+		if (err == nil) != (name == "vertical ok") {
+			t.Errorf("%s: error = %v", name, err)
+		}
+	}
+	if _, _, err := c.Distance(CombinedHash{Horizontal: DifferenceHash(img, 16)}); err == nil {
+		t.Error("shape mismatch expected error")
+	}
+}
@@ -0,0 +1,144 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/K0ng2/imagehash-go/internal/conformance"
+)
+
+// Every fast path in the package must produce the same result as the
+// generic image.Image code path on all legal image layouts. New fast paths
+// should be added to conformanceAlgorithms and pass this suite.
+
+// genericImage hides the concrete type of an image so that only the
+// generic At-based code paths are used
+type genericImage struct {
+	image.Image
+}
+
+// conformanceImages returns the table of legal but unusual image layouts
+func conformanceImages() map[string]image.Image {
+	images := make(map[string]image.Image)
+	for _, l := range conformance.Layouts() {
+		images[l.Name] = l.Image
+	}
+	return images
+}
+
+// conformanceAlgorithms are the hash functions checked against the generic
+// path, keyed by name
+var conformanceAlgorithms = map[string]func(image.Image) *ImageHash{
+	"AverageHash":            func(img image.Image) *ImageHash { return AverageHash(img, 8) },
+	"AverageHash/integer":    func(img image.Image) *ImageHash { return AverageHash(img, 8, WithIntegerPipeline()) },
+	"DifferenceHash":         func(img image.Image) *ImageHash { return DifferenceHash(img, 8) },
+	"DifferenceHashVertical": func(img image.Image) *ImageHash { return DifferenceHashVertical(img, 8) },
+	"PerceptualHash":         func(img image.Image) *ImageHash { return PerceptualHash(img, 8, 4) },
+	"PerceptualHash/16":      func(img image.Image) *ImageHash { return PerceptualHash(img, 16, 4) },
+	"PerceptualHash/quant":   func(img image.Image) *ImageHash { return PerceptualHash(img, 8, 4, WithDecoderTolerantQuantization(2)) },
+	"AverageHash/parallel":   func(img image.Image) *ImageHash { return AverageHash(img, 8, WithParallelGrayscaleThreshold(0)) },
+	"AverageHash/ignore": func(img image.Image) *ImageHash {
+		return AverageHash(img, 8, WithIgnoreRegion(image.Rect(0, 80, 100, 100)))
+	},
+	"DifferenceHash/integer": func(img image.Image) *ImageHash { return DifferenceHash(img, 8, WithIntegerPipeline()) },
+	"PerceptualHash/ignore": func(img image.Image) *ImageHash {
+		return PerceptualHash(img, 8, 4, WithIgnoreRegion(image.Rect(0, 80, 100, 100)))
+	},
+	"CanonicalOrientation": func(img image.Image) *ImageHash {
+		h, _ := CanonicalOrientationHash(img, 8)
+		return h
+	},
+}
+
+// grayPixels returns the pixels of gray row by row, without stride padding
+func grayPixels(gray *image.Gray) []byte {
+	b := gray.Bounds()
+	var pix []byte
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		pix = append(pix, gray.Pix[gray.PixOffset(b.Min.X, y):gray.PixOffset(b.Max.X, y)]...)
+	}
+	return pix
+}
+
+func TestConformance_Grayscale(t *testing.T) {
+	// Force real fan-out even on single-CPU machines
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for name, img := range conformanceImages() {
+		t.Run(name, func(t *testing.T) {
+			want := toGrayscaleFast(genericImage{img}, math.MaxInt)
+			if want.Bounds() != img.Bounds() {
+				t.Fatalf("reference bounds %v, want %v", want.Bounds(), img.Bounds())
+			}
+			for path, got := range map[string]*image.Gray{
+				"ToGrayscale":      ToGrayscale(img),
+				"ToGrayscaleFast":  ToGrayscaleFast(img),
+				"serial":           toGrayscaleFast(img, math.MaxInt),
+				"parallel":         toGrayscaleFast(img, 0),
+				"ToGrayscale/wrap": ToGrayscale(genericImage{img}),
+				"parallel/generic": toGrayscaleFast(genericImage{img}, 0),
+			} {
+				if got.Bounds() != want.Bounds() || !bytes.Equal(grayPixels(got), grayPixels(want)) {
+					t.Errorf("%s differs from the generic path", path)
+				}
+			}
+		})
+	}
+}
+
+func TestConformance_Hashes(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for name, img := range conformanceImages() {
+		for algo, hash := range conformanceAlgorithms {
+			t.Run(fmt.Sprintf("%s/%s", name, algo), func(t *testing.T) {
+				if got, want := hash(img).ToString(), hash(genericImage{img}).ToString(); got != want {
+					t.Errorf("hash %s, generic path %s", got, want)
+				}
+			})
+		}
+	}
+}
+
+// FastAverageHash deliberately differs from the generic path (it reads
+// only luma), so its layouts are checked against a compact copy of the same
+// type instead
+func TestConformance_FastAverageHash(t *testing.T) {
+	for name, img := range conformanceImages() {
+		t.Run(name, func(t *testing.T) {
+			var compact image.Image
+			switch src := img.(type) {
+			case *image.YCbCr:
+				c := image.NewYCbCr(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()), src.SubsampleRatio)
+				for y := range src.Rect.Dy() {
+					for x := range src.Rect.Dx() {
+						c.Y[c.YOffset(x, y)] = src.Y[src.YOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)]
+					}
+				}
+				compact = c
+			case *image.Gray:
+				c := image.NewGray(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()))
+				draw.Draw(c, c.Bounds(), src, src.Rect.Min, draw.Src)
+				compact = c
+			case *image.RGBA:
+				c := image.NewRGBA(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()))
+				draw.Draw(c, c.Bounds(), src, src.Rect.Min, draw.Src)
+				compact = c
+			case *image.NRGBA:
+				c := image.NewNRGBA(image.Rect(0, 0, src.Rect.Dx(), src.Rect.Dy()))
+				draw.Draw(c, c.Bounds(), src, src.Rect.Min, draw.Src)
+				compact = c
+			default:
+				compact = genericImage{img}
+			}
+			if got, want := FastAverageHash(img).ToString(), FastAverageHash(compact).ToString(); got != want {
+				t.Errorf("FastAverageHash %s, compact copy %s", got, want)
+			}
+		})
+	}
+}
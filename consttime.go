@@ -0,0 +1,48 @@
+package imagehashgo
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"math/bits"
+)
+
+// ConstantTimeMatch reports whether the Hamming distance between a and b is
+// at most maxDist, taking time that depends only on the hash shape.
+//
+// Every bit of both hashes is visited, the distance is accumulated without
+// data-dependent branches and the final comparison uses crypto/subtle, so
+// neither the distance nor how early it exceeds maxDist leaks through
+// timing. A shape mismatch or a negative maxDist returns immediately, since
+// neither depends on hash contents. Like crypto/subtle, this relies on the
+// compiler emitting branch-free code for bool conversions, which holds for
+// the gc toolchain on common architectures but is not a language guarantee.
+func ConstantTimeMatch(a, b *ImageHash, maxDist int) (bool, error) {
+	if a.rows != b.rows || a.cols != b.cols || len(a.hash) != len(b.hash) {
+		return false, fmt.Errorf("ImageHashes must be of the same shape: (%d, %d) vs (%d, %d)", a.rows, a.cols, b.rows, b.cols)
+	}
+	if maxDist < 0 {
+		return false, nil
+	}
+
+	var dist int
+	for i := 0; i < len(a.hash); i += 64 {
+		var wa, wb uint64
+		for j, n := 0, min(64, len(a.hash)-i); j < n; j++ {
+			wa |= boolBit(a.hash[i+j]) << uint(j)
+			wb |= boolBit(b.hash[i+j]) << uint(j)
+		}
+		dist += bits.OnesCount64(wa ^ wb)
+	}
+	// ConstantTimeLessOrEq requires operands below 2^31
+	return subtle.ConstantTimeLessOrEq(dist, min(maxDist, len(a.hash))) == 1, nil
+}
+
+// boolBit converts b to 0 or 1; the gc compiler lowers this to a SETcc-style
+// instruction rather than a branch
+func boolBit(b bool) uint64 {
+	var v uint64
+	if b {
+		v = 1
+	}
+	return v
+}
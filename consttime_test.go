@@ -0,0 +1,34 @@
+package imagehashgo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestConstantTimeMatch_AgreesWithDistance(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{2, 8, 9, 16} {
+		for range 50 {
+			a := &ImageHash{hash: make([]bool, size*size), rows: size, cols: size}
+			b := &ImageHash{hash: make([]bool, size*size), rows: size, cols: size}
+			for i := range a.hash {
+				a.hash[i] = r.Intn(2) == 1
+				b.hash[i] = r.Intn(2) == 1
+			}
+			dist, _ := a.Distance(b)
+			maxDist := r.Intn(size*size+2) - 1
+
+			got, err := ConstantTimeMatch(a, b, maxDist)
+			if err != nil {
+				t.Fatalf("ConstantTimeMatch() error = %v", err)
+			}
+			if want := dist <= maxDist; got != want {
+				t.Errorf("size %d: ConstantTimeMatch(maxDist=%d) = %v, distance %d", size, maxDist, got, dist)
+			}
+		}
+	}
+
+	if _, err := ConstantTimeMatch(AverageHash(getBenchImage(), 8), AverageHash(getBenchImage(), 9), 10); err == nil {
+		t.Error("ConstantTimeMatch() with different shapes expected error")
+	}
+}
@@ -0,0 +1,67 @@
+package imagehashgo
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// ContentIDLength is the length of the strings ContentID returns
+const ContentIDLength = 26
+
+// contentIDEncoding is lower-case unpadded RFC 4648 base32, which is safe
+// in file names, URLs and case-insensitive keys
+var contentIDEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// contentIDDomain separates ContentID digests from other SHA-256 digests
+// of the same bytes; changing it changes every ID
+const contentIDDomain = "imagehash-go/contentid/v1"
+
+// ContentID returns a stable opaque key for the hash computed by kind: the
+// first 16 bytes of the SHA-256 of kind, the shape and the bits packed as
+// in HashSnapshot.Bits, as ContentIDLength characters of lower-case base32.
+// kind is taken as an argument because parsed hashes have no Kind; pass
+// h.Kind() when it is known.
+//
+// The same kind, shape and bits give the same ID in every version of the
+// package, and hashes that differ in any of them get different IDs. Equal
+// IDs mean identical perceptual hashes and parameters, never that the
+// images are visually identical; use Distance for similarity.
+func (h *ImageHash) ContentID(kind HashKind) string {
+	d := sha256.New()
+	var n [4]byte
+	for _, field := range [][]byte{[]byte(contentIDDomain), []byte(kind)} {
+		binary.BigEndian.PutUint32(n[:], uint32(len(field)))
+		d.Write(n[:])
+		d.Write(field)
+	}
+	binary.BigEndian.PutUint32(n[:], uint32(h.rows))
+	d.Write(n[:])
+	binary.BigEndian.PutUint32(n[:], uint32(h.cols))
+	d.Write(n[:])
+	d.Write(packBits(h.hash))
+
+	return contentIDEncoding.EncodeToString(d.Sum(nil)[:16])
+}
+
+// ValidateContentID checks that id has the format of ContentID. It cannot
+// tell whether any hash has that ID.
+func ValidateContentID(id string) error {
+	if len(id) != ContentIDLength {
+		return fmt.Errorf("content ID has %d characters, want %d", len(id), ContentIDLength)
+	}
+	if strings.ToLower(id) != id {
+		return fmt.Errorf("content ID %q is not lower-case", id)
+	}
+	raw, err := contentIDEncoding.DecodeString(id)
+	if err != nil {
+		return fmt.Errorf("content ID %q: %w", id, err)
+	}
+	// 26 characters carry 130 bits, the last 2 of which must be zero
+	if contentIDEncoding.EncodeToString(raw) != id {
+		return fmt.Errorf("content ID %q has non-zero padding bits", id)
+	}
+	return nil
+}
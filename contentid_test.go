@@ -0,0 +1,77 @@
+package imagehashgo
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestContentID_Pinned pins example IDs: a failure here means every stored
+// ContentID changed, which needs a new contentIDDomain version instead
+func TestContentID_Pinned(t *testing.T) {
+	square, err := HexToHash("ffd8e0c0c0e0f0f8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wide, err := HexToHashShape("ffd8e0c0c0e0f0f8", 4, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		h    *ImageHash
+		kind HashKind
+		want string
+	}{
+		{"ahash", square, KindAverage, "nnrtunlrwrvjbgiokr25qw4xia"},
+		{"dhash", square, KindDifference, "6xmfqnfi5b2ndvi7jq2o6au3xa"},
+		{"no kind", square, "", "noqneusqzov6w7dzfkf7mqkomi"},
+		{"4x16", wide, KindAverage, "4h7wbqdz7kqma6prpzp2xs2e64"},
+	}
+	for _, tt := range tests {
+		got := tt.h.ContentID(tt.kind)
+		if got != tt.want {
+			t.Errorf("%s: ContentID = %s, want %s", tt.name, got, tt.want)
+		}
+		if err := ValidateContentID(got); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestContentID_Distinct(t *testing.T) {
+	h := AverageHash(getBenchImage(), 8)
+	if h.ContentID(KindAverage) != AverageHash(getBenchImage(), 8).ContentID(KindAverage) {
+		t.Error("ContentID is not deterministic")
+	}
+
+	// Flipping one bit, the kind or the shape changes the ID
+	flipped := slices.Clone(h.hash)
+	flipped[63] = !flipped[63]
+	ids := map[string]string{
+		h.ContentID(KindAverage):                           "original",
+		NewImageHash(flipped, 8, 8).ContentID(KindAverage): "one bit flipped",
+		h.ContentID(KindPerceptual):                        "other kind",
+		NewImageHash(h.hash, 4, 16).ContentID(KindAverage): "other shape",
+	}
+	if len(ids) != 4 {
+		t.Errorf("IDs collide: %v", ids)
+	}
+}
+
+func TestValidateContentID(t *testing.T) {
+	tests := map[string]string{
+		"short":        "nnrtunlrwrvjbgiokr25qw4xi",
+		"long":         "nnrtunlrwrvjbgiokr25qw4xiaa",
+		"upper-case":   strings.ToUpper("nnrtunlrwrvjbgiokr25qw4xia"),
+		"not base32":   "nnrtunlrwrvjbgiokr25qw4x1a",
+		"padding bits": "nnrtunlrwrvjbgiokr25qw4xib",
+		"empty":        "",
+	}
+	for name, id := range tests {
+		if err := ValidateContentID(id); err == nil {
+			t.Errorf("%s: ValidateContentID(%q) = nil", name, id)
+		}
+	}
+}
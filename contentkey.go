@@ -0,0 +1,125 @@
+package imagehashgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// ContentKeyBytes is how much of the start and of the end of a file
+// ContentKey reads
+const ContentKeyBytes = 64 << 10
+
+// ContentKey returns a 64-bit fingerprint of the content of a file of the
+// given size, read from r: the XXH64 of its first and last ContentKeyBytes,
+// or of the whole file when it is not longer than twice that, seeded with
+// the size. It reads at most 128 KiB without decoding, so it keys a cache
+// of hashes by content where modification times cannot be trusted, as on
+// many network filesystems.
+//
+// Edits that keep the size and leave the start and end alone go unnoticed,
+// and distinct files collide with probability 2^-64. It is not a
+// cryptographic digest: check that a cached hash has the expected kind and
+// shape before using it, and rehash when it does not. It fails when r holds
+// fewer than size bytes.
+func ContentKey(r io.ReaderAt, size int64) (uint64, error) {
+	if size < 0 {
+		return 0, fmt.Errorf("negative file size %d", size)
+	}
+	var buf []byte
+	if size <= 2*ContentKeyBytes {
+		buf = make([]byte, size)
+		if err := readFull(r, buf, 0); err != nil {
+			return 0, err
+		}
+	} else {
+		buf = make([]byte, 2*ContentKeyBytes)
+		if err := readFull(r, buf[:ContentKeyBytes], 0); err != nil {
+			return 0, err
+		}
+		if err := readFull(r, buf[ContentKeyBytes:], size-ContentKeyBytes); err != nil {
+			return 0, err
+		}
+	}
+	return xxh64(buf, uint64(size)), nil
+}
+
+// readFull fills buf from r at off; io.EOF with a full buffer is success
+func readFull(r io.ReaderAt, buf []byte, off int64) error {
+	n, err := r.ReadAt(buf, off)
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("reading %d bytes at offset %d: %w", len(buf), off, err)
+}
+
+// The primes of XXH64
+const (
+	xxhPrime1 uint64 = 0x9E3779B185EBCA87
+	xxhPrime2 uint64 = 0xC2B2AE3D27D4EB4F
+	xxhPrime3 uint64 = 0x165667B19E3779F9
+	xxhPrime4 uint64 = 0x85EBCA77C2B2AE63
+	xxhPrime5 uint64 = 0x27D4EB2F165667C5
+)
+
+// xxh64 is the XXH64 hash of b with the given seed
+func xxh64(b []byte, seed uint64) uint64 {
+	n := uint64(len(b))
+	var h uint64
+	if len(b) >= 32 {
+		v1 := seed + xxhPrime1 + xxhPrime2
+		v2 := seed + xxhPrime2
+		v3 := seed
+		v4 := seed - xxhPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxhRound(v1, binary.LittleEndian.Uint64(b[0:]))
+			v2 = xxhRound(v2, binary.LittleEndian.Uint64(b[8:]))
+			v3 = xxhRound(v3, binary.LittleEndian.Uint64(b[16:]))
+			v4 = xxhRound(v4, binary.LittleEndian.Uint64(b[24:]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxhMerge(h, v1)
+		h = xxhMerge(h, v2)
+		h = xxhMerge(h, v3)
+		h = xxhMerge(h, v4)
+	} else {
+		h = seed + xxhPrime5
+	}
+	h += n
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxhRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime1
+		h = bits.RotateLeft64(h, 23)*xxhPrime2 + xxhPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxhPrime5
+		h = bits.RotateLeft64(h, 11) * xxhPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxhPrime2
+	h ^= h >> 29
+	h *= xxhPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	return bits.RotateLeft64(acc, 31) * xxhPrime1
+}
+
+func xxhMerge(h, v uint64) uint64 {
+	h ^= xxhRound(0, v)
+	return h*xxhPrime1 + xxhPrime4
+}
@@ -0,0 +1,100 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"math/rand"
+	"testing"
+
+	"github.com/K0ng2/imagehash-go/internal/benchdata"
+)
+
+func TestXXH64(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xEF46DB3751D8E999},
+		{"abc", 0x44BC2CF5AD770999},
+		{"Nobody inspects the spammish repetition", 0xFBCEA83C8A378BF1},
+	} {
+		if got := xxh64([]byte(c.in), 0); got != c.want {
+			t.Errorf("xxh64(%q) = %#x, want %#x", c.in, got, c.want)
+		}
+	}
+}
+
+func TestContentKey(t *testing.T) {
+	r := rand.New(rand.NewSource(1001))
+	key := func(data []byte) uint64 {
+		t.Helper()
+		k, err := ContentKey(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	for _, size := range []int{0, 1, 100, 2*ContentKeyBytes - 1, 2 * ContentKeyBytes, 2*ContentKeyBytes + 1, 1 << 20} {
+		data := make([]byte, size)
+		r.Read(data)
+		k := key(data)
+		if k != key(bytes.Clone(data)) {
+			t.Errorf("size %d: key is not deterministic", size)
+		}
+		// Files up to 128 KiB are read whole, so any edit changes the key
+		for _, at := range []int{0, size / 2, size - 1} {
+			if size == 0 || size > 2*ContentKeyBytes && at == size/2 {
+				continue
+			}
+			edited := bytes.Clone(data)
+			edited[at] ^= 1
+			if key(edited) == k {
+				t.Errorf("size %d: editing byte %d keeps the key", size, at)
+			}
+		}
+		if size > 2*ContentKeyBytes {
+			// The middle of a large file is not read
+			edited := bytes.Clone(data)
+			edited[size/2] ^= 1
+			if key(edited) != k {
+				t.Errorf("size %d: editing the middle changes the key", size)
+			}
+		}
+	}
+
+	// The size is part of the key: a file of zeros and its extension differ
+	if key(make([]byte, 10)) == key(make([]byte, 11)) {
+		t.Error("zero files of different sizes have the same key")
+	}
+
+	short := make([]byte, 1000)
+	for _, size := range []int64{1001, 3 * ContentKeyBytes} {
+		if _, err := ContentKey(bytes.NewReader(short), size); err == nil {
+			t.Errorf("size %d of a 1000 byte reader: expected error", size)
+		}
+	}
+	if _, err := ContentKey(bytes.NewReader(short), -1); err == nil {
+		t.Error("negative size expected error")
+	}
+}
+
+// BenchmarkContentKey compares the key of a large JPEG with decoding it
+func BenchmarkContentKey(b *testing.B) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, benchdata.Corpus()[0].Image, nil); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+	b.Run("key", func(b *testing.B) {
+		for b.Loop() {
+			ContentKey(bytes.NewReader(data), int64(len(data)))
+		}
+	})
+	b.Run("decode", func(b *testing.B) {
+		for b.Loop() {
+			image.Decode(bytes.NewReader(data))
+		}
+	})
+}
@@ -0,0 +1,438 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"image"
+	"image/color"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The tables below are the source of truth for two promises of the API:
+// which exported types are safe for concurrent use, and which exported
+// functions panic instead of returning an error. TestAPIContractDocs
+// checks the doc comments against them, so a new exported type or panic
+// fails the tests until it is classified here and documented.
+
+// concurrency is the documented concurrency promise of an exported type
+type concurrency int
+
+const (
+	// plainData types are values, interfaces and options, which make no
+	// promise and must not mention concurrent use
+	plainData concurrency = iota
+	// concurrentSafe docs must say "safe for concurrent use"
+	concurrentSafe
+	// concurrentUnsafe docs must say "not safe for concurrent use"
+	concurrentUnsafe
+)
+
+var typeContracts = map[string]concurrency{
+	"Ensemble":        concurrentSafe,
+	"Hasher":          concurrentSafe,
+	"MatchProfile":    concurrentSafe,
+	"ImageHash":       concurrentSafe,
+	"PHashPrecomp":    concurrentSafe,
+	"Preprocess":      concurrentSafe,
+	"ResolvedOptions": concurrentSafe,
+	"SlidingDedup":    concurrentSafe,
+
+	"HashReader": concurrentUnsafe,
+	"HashWriter": concurrentUnsafe,
+
+	"AdaptiveStep":        plainData,
+	"AlgorithmInfo":       plainData,
+	"AlgorithmParam":      plainData,
+	"BudgetedHash":        plainData,
+	"CalibrationPoint":    plainData,
+	"CalibrationTable":    plainData,
+	"ColorProfileInfo":    plainData,
+	"ColorProfiled":       plainData,
+	"ColorSig":            plainData,
+	"CombinedHash":        plainData,
+	"EnsembleExplanation": plainData,
+	"EnsembleHashes":      plainData,
+	"ExifOriented":        plainData,
+	"Explanation":         plainData,
+	"GrayRowReader":       plainData,
+	"HashKind":            plainData,
+	"HashSnapshot":        plainData,
+	"ImageClass":          plainData,
+	"LeafVerdict":         plainData,
+	"Match":               plainData,
+	"MedianStrategy":      plainData,
+	"Option":              plainData,
+	"Orientation":         plainData,
+	"OrientedImage":       plainData,
+	"PairResult":          plainData,
+	"PixelOrder":          plainData,
+	"PreprocessStep":      plainData,
+	"ProfiledImage":       plainData,
+	"Quality":             plainData,
+	"ReproResult":         plainData,
+	"Rule":                plainData,
+	"SelfTestError":       plainData,
+	"ShapeStats":          plainData,
+	"Stats":               plainData,
+	"StreamShape":         plainData,
+	"TriageOptions":       plainData,
+	"TriagePlan":          plainData,
+}
+
+// panicContracts lists the exported functions whose docs say "panics",
+// each with a call that must panic. They are programmer errors (a buffer
+// that no valid caller passes) on functions without an error result; the
+// error-returning entry points check the same inputs.
+var panicContracts = map[string]func(img image.Image){
+	"DCT2DFast64": func(image.Image) {
+		in := make([]float64, 32*32)
+		DCT2DFast64(&in)
+	},
+	"DCT2DFast32": func(image.Image) {
+		in := make([]float64, 32*32)
+		DCT2DFast32(&in, 33)
+	},
+	"DCT2DFast16": func(image.Image) {
+		in := make([]float64, 16*16)
+		DCT2DFast16(&in, 17)
+	},
+}
+
+// apiDocs returns the doc comments of the exported types and of the
+// exported functions and methods ("Type.Method") of the package
+func apiDocs(t *testing.T) (types, funcs map[string]string) {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	types, funcs = make(map[string]string), make(map[string]string)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if !ts.Name.IsExported() {
+						continue
+					}
+					doc := ts.Doc.Text()
+					if doc == "" {
+						doc = decl.Doc.Text()
+					}
+					types[ts.Name.Name] = doc
+				}
+			case *ast.FuncDecl:
+				if !decl.Name.IsExported() {
+					continue
+				}
+				name := decl.Name.Name
+				if decl.Recv != nil {
+					typ := decl.Recv.List[0].Type
+					if star, ok := typ.(*ast.StarExpr); ok {
+						typ = star.X
+					}
+					ident, ok := typ.(*ast.Ident)
+					if !ok || !ident.IsExported() {
+						continue
+					}
+					name = ident.Name + "." + name
+				}
+				funcs[name] = decl.Doc.Text()
+			}
+		}
+	}
+	return types, funcs
+}
+
+// docConcurrency returns the promise a doc comment makes, with doc
+// comments joined onto one line first so that wrapping does not matter
+func docConcurrency(doc string) concurrency {
+	doc = strings.Join(strings.Fields(doc), " ")
+	switch {
+	case strings.Contains(doc, "not safe for concurrent use"):
+		return concurrentUnsafe
+	case strings.Contains(doc, "safe for concurrent use"):
+		return concurrentSafe
+	default:
+		return plainData
+	}
+}
+
+func TestAPIContractDocs(t *testing.T) {
+	types, funcs := apiDocs(t)
+
+	for name, doc := range types {
+		want, ok := typeContracts[name]
+		if !ok {
+			t.Errorf("exported type %s is not in typeContracts; decide whether it is safe for concurrent use", name)
+			continue
+		}
+		if got := docConcurrency(doc); got != want {
+			t.Errorf("doc of %s makes concurrency promise %d, typeContracts says %d", name, got, want)
+		}
+	}
+	for name := range typeContracts {
+		if _, ok := types[name]; !ok {
+			t.Errorf("typeContracts lists %s, which is not an exported type", name)
+		}
+	}
+
+	for name, doc := range funcs {
+		_, want := panicContracts[name]
+		if got := strings.Contains(doc, "panics"); got != want {
+			t.Errorf("doc of %s mentions panics: %v, panicContracts lists it: %v", name, got, want)
+		}
+	}
+	for name := range panicContracts {
+		if _, ok := funcs[name]; !ok {
+			t.Errorf("panicContracts lists %s, which is not an exported function", name)
+		}
+	}
+}
+
+func TestAPIContractPanics(t *testing.T) {
+	img := noiseImage(40, 30, 959)
+	for name, call := range panicContracts {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			call(img)
+		})
+	}
+}
+
+// TestAPIContractErrors covers inputs that used to panic on exported paths:
+// they now return an error or a documented neutral result
+func TestAPIContractErrors(t *testing.T) {
+	img := noiseImage(40, 30, 959)
+	big := MaxHashSize + 1
+	errorCases := map[string]func() error{
+		"Hash": func() error {
+			_, err := Hash(img, KindPerceptual, big)
+			return err
+		},
+		"HashWithQuality": func() error {
+			_, _, err := HashWithQuality(img, KindAverage, big)
+			return err
+		},
+		"HashWithColorSignature": func() error {
+			_, _, err := HashWithColorSignature(img, KindDifference, big)
+			return err
+		},
+		"ShiftTolerantDistance": func() error {
+			_, _, err := ShiftTolerantDistance(img, img, KindAverage, big, 1)
+			return err
+		},
+		"NewHasher": func() error {
+			_, err := NewHasher(KindAverage, big)
+			return err
+		},
+	}
+	for name, call := range errorCases {
+		if err := call(); err == nil {
+			t.Errorf("%s with hash size %d returned no error", name, big)
+		}
+	}
+	if _, err := Hash(img, KindAverage, MaxHashSize); err != nil {
+		t.Errorf("Hash with MaxHashSize: %v", err)
+	}
+	// The algorithm functions have no cap, as before the audit
+	if h := AverageHash(img, big); h.rows != big || h.cols != big {
+		t.Errorf("AverageHash with hash size %d: shape (%d, %d)", big, h.rows, h.cols)
+	}
+
+	// Empty images hash like black ones, as with WithIntegerPipeline
+	empty := image.NewRGBA(image.Rect(0, 0, 0, 5))
+	for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical} {
+		h, err := Hash(empty, kind, 8)
+		if err != nil {
+			t.Errorf("%s of an empty image: %v", kind, err)
+			continue
+		}
+		if want, _ := Hash(image.NewGray(image.Rect(0, 0, 8, 8)), kind, 8); h.ToString() != want.ToString() {
+			t.Errorf("%s of an empty image = %s, want %s", kind, h.ToString(), want.ToString())
+		}
+	}
+	TextPerceptualHash(empty, 8)
+	TriDifferenceHash(empty, 8, 4)
+
+	// Nil and out-of-range arguments
+	if mask := RegionMask(image.Rect(0, 0, 50, 50), -1, 8); mask != nil {
+		t.Errorf("RegionMask with negative rows = %v, want nil", mask)
+	}
+	var nilPipeline *Preprocess
+	if out, err := nilPipeline.Apply(img); err != nil || out != img || nilPipeline.String() != "" {
+		t.Errorf("nil Preprocess: Apply = %v, %v; String = %q", out, err, nilPipeline.String())
+	}
+	if p := NewPreprocess(nil, Composite(nil), nil); p.String() != NewPreprocess(Composite(color.White)).String() {
+		t.Errorf("NewPreprocess(nil, Composite(nil), nil) = %q", p)
+	}
+	h := AverageHash(img, 8)
+	if got := NearestN(h, []*ImageHash{nil, h}, 2); !slices.Equal(got, []Match{{Index: 1}}) {
+		t.Errorf("NearestN with a nil candidate = %v", got)
+	}
+	if got := DistanceMatrix([]*ImageHash{h, nil}); !slices.Equal(got, []int{0, -1, -1, 0}) {
+		t.Errorf("DistanceMatrix with a nil hash = %v", got)
+	}
+	if got := CorpusStats([]*ImageHash{nil, h, h}, 10, 1); len(got.Shapes) != 1 || got.Shapes[0].Hashes != 2 {
+		t.Errorf("CorpusStats with a nil hash = %+v", got)
+	}
+	d := NewSlidingDedup(time.Minute, 4, KindAverage)
+	d.Add(1, nil, time.Now())
+	if _, found := d.Check(nil, time.Now()); found || d.Len() != 0 {
+		t.Error("SlidingDedup indexed a nil hash")
+	}
+}
+
+// TestAPIContractConcurrent calls the package from many goroutines on
+// shared inputs and checks every result against a sequential run. Run it
+// with -race to check the promises of typeContracts and the pools and
+// lazily built tables behind the hash functions.
+func TestAPIContractConcurrent(t *testing.T) {
+	photo := getBenchImage()
+	gray := waveImage(959)
+	hasher, err := NewHasher(KindPerceptual, 8, WithPreprocess(NewPreprocess(Composite(color.White), Equalize())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hashers []*Hasher
+	for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifference} {
+		h, err := NewHasher(kind, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashers = append(hashers, h)
+	}
+	ensemble, err := NewEnsemble(Or(Leaf(KindDifference, 4), And(Leaf(KindPerceptual, 10), Leaf(KindAverage, 12))), hashers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := []*ImageHash{AverageHash(photo, 8), AverageHash(gray, 8), DifferenceHash(gray, 8)}
+	precomp, err := PerceptualPrecompute(photo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedup := NewSlidingDedup(time.Hour, 6, KindAverage)
+	start := time.Unix(0, 0)
+
+	calls := map[string]func() string{
+		"AverageHash":    func() string { return AverageHash(photo, 8).ToString() },
+		"DifferenceHash": func() string { return DifferenceHash(gray, 16).ToString() },
+		"DifferenceHashVertical": func() string {
+			return DifferenceHashVertical(photo, 8, WithIntegerPipeline()).ToString()
+		},
+		"TriDifferenceHash": func() string { return TriDifferenceHash(gray, 8, 4).ToString() },
+		"PerceptualHash/4":  func() string { return PerceptualHash(gray, 4, 4).ToString() },
+		"PerceptualHash/8":  func() string { return PerceptualHash(photo, 8, 4).ToString() },
+		"PerceptualHash/16": func() string { return PerceptualHash(gray, 16, 4).ToString() },
+		"PerceptualHash/12": func() string { return PerceptualHash(gray, 12, 4).ToString() },
+		"TextPerceptualHash": func() string {
+			return TextPerceptualHash(gray, 8).ToString()
+		},
+		"FastAverageHash": func() string { return FastAverageHash(photo).ToString() },
+		"CanonicalOrientationHash": func() string {
+			h, o := CanonicalOrientationHash(gray, 8)
+			return fmt.Sprint(h.ToString(), o)
+		},
+		"AutoHash": func() string {
+			h, err := AutoHash(photo, KindDifference)
+			return fmt.Sprint(h.ToString(), err)
+		},
+		"ToGrayscale": func() string {
+			g := ToGrayscale(photo)
+			return fmt.Sprint(g.Pix[len(g.Pix)/2], g.Pix[len(g.Pix)-1])
+		},
+		"HashWithColorSignature": func() string {
+			h, sig, err := HashWithColorSignature(photo, KindAverage, 8, WithParallelGrayscaleThreshold(1))
+			return fmt.Sprint(h.ToString(), sig, err)
+		},
+		"HashWithQuality": func() string {
+			h, q, err := HashWithQuality(gray, KindPerceptual, 8)
+			return fmt.Sprint(h.ToString(), q, err)
+		},
+		"Hasher.Hash": func() string {
+			h, err := hasher.Hash(photo)
+			return fmt.Sprint(h.ToString(), err)
+		},
+		"PHashPrecomp": func() string {
+			return precomp.Hash8().ToString() + precomp.Hash16().ToString()
+		},
+		"Ensemble": func() string {
+			a, err := ensemble.Hash(photo)
+			if err != nil {
+				return err.Error()
+			}
+			b, err := ensemble.Hash(gray)
+			if err != nil {
+				return err.Error()
+			}
+			match, why := ensemble.Match(a, b)
+			return fmt.Sprint(a, b, match, why)
+		},
+		"NearestN": func() string {
+			return fmt.Sprint(NearestN(shared[0], shared, 2), DistanceMatrix(shared))
+		},
+		"Explain": func() string {
+			e, err := Explain(shared[0], shared[1])
+			return fmt.Sprint(e.String(), err)
+		},
+		"Median": func() string {
+			v, _ := GrayVector(gray, 8)
+			return fmt.Sprint(Median(v))
+		},
+		"SlidingDedup": func() string {
+			// Entries never expire within the test, and every goroutine adds
+			// the same ids, so the answer does not depend on the interleaving
+			dedup.Add(1, shared[1], start)
+			id, found := dedup.Check(shared[1], start)
+			return fmt.Sprint(id, found)
+		},
+	}
+
+	want := make(map[string]string, len(calls))
+	for name, call := range calls {
+		want[name] = call()
+	}
+
+	const goroutines = 8
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Go(func() {
+			// Visit the calls in a different order in every goroutine
+			names := make([]string, 0, len(calls))
+			for name := range calls {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			for i := range names {
+				name := names[(i+g*3)%len(names)]
+				if got := calls[name](); got != want[name] {
+					t.Errorf("%s concurrently = %s, sequentially %s", name, got, want[name])
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
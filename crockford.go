@@ -0,0 +1,150 @@
+package imagehashgo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// crockfordAlphabet is Crockford's base32 alphabet: the digits and the
+// upper-case letters without I, L, O and U
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// crockfordValue maps an input character to its 5-bit value, or -1.
+// Decoding is case-insensitive, and I and L read as 1 and O as 0, the
+// characters they are mistaken for.
+var crockfordValue = func() (v [256]int8) {
+	for i := range v {
+		v[i] = -1
+	}
+	for i, c := range crockfordAlphabet {
+		v[c] = int8(i)
+		v[c|0x20] = int8(i) // lower case; a no-op for digits
+	}
+	for _, c := range "IiLl" {
+		v[c] = 1
+	}
+	for _, c := range "Oo" {
+		v[c] = 0
+	}
+	return v
+}()
+
+// ToCrockfordBase32 returns the hash in Crockford's base32, without
+// padding: (rows*cols+4)/5 upper-case characters, each 5 bits MSB-first in
+// the same order as ToString, the last padded with zero bits. The result
+// has no characters that are unsafe in file names or that differ only by
+// case, so it fits file names and QR codes; FromCrockfordBase32 reverses
+// it given the shape.
+func (h *ImageHash) ToCrockfordBase32() string {
+	out := make([]byte, (len(h.hash)+4)/5)
+	for i := range out {
+		v := 0
+		for j := range 5 {
+			v <<= 1
+			if k := 5*i + j; k < len(h.hash) && h.hash[k] {
+				v |= 1
+			}
+		}
+		out[i] = crockfordAlphabet[v]
+	}
+	return string(out)
+}
+
+// FromCrockfordBase32 is the inverse of ToCrockfordBase32 for a rows x
+// cols hash. Decoding is case-insensitive and, as Crockford specifies,
+// reads I and L as 1 and O as 0; U, hyphens and other characters are
+// rejected, as are a wrong length and non-zero padding bits, so every
+// hash has exactly one encoding up to case and those substitutions. The
+// result has no Kind.
+func FromCrockfordBase32(s string, rows, cols int) (*ImageHash, error) {
+	if err := checkShape(rows*cols, rows, cols); err != nil {
+		return nil, err
+	}
+	n := rows * cols
+	if want := (n + 4) / 5; len(s) != want {
+		return nil, fmt.Errorf("base32 hash %q has %d characters, shape (%d, %d) needs %d", s, len(s), rows, cols, want)
+	}
+	bits := make([]bool, 5*len(s))
+	for i := range len(s) {
+		v := crockfordValue[s[i]]
+		if v < 0 {
+			return nil, fmt.Errorf("base32 hash %q has invalid character %q", s, s[i])
+		}
+		for j := range 5 {
+			bits[5*i+j] = v&(0x10>>j) != 0
+		}
+	}
+	if slices.Contains(bits[n:], true) {
+		return nil, fmt.Errorf("base32 hash %q has non-zero padding bits", s)
+	}
+	return &ImageHash{hash: bits[:n], rows: rows, cols: cols}, nil
+}
+
+// compactKinds are the kind letters of the compact ID
+var compactKinds = []struct {
+	letter byte
+	kind   HashKind
+}{
+	{'a', KindAverage},
+	{'p', KindPerceptual},
+	{'d', KindDifference},
+	{'v', KindDifferenceVertical},
+}
+
+// ToCompactID returns a file-name-safe ID carrying the kind, size and bits
+// of a square hash: a kind letter (a for ahash, p for phash, d for dhash,
+// v for dhash_v), the hash size in decimal and the ToCrockfordBase32 of the
+// bits, e.g. "d8" followed by 13 characters for an 8x8 dHash. It fails for
+// hashes without a known kind and for non-square hashes.
+func (h *ImageHash) ToCompactID() (string, error) {
+	if h.rows != h.cols {
+		return "", fmt.Errorf("compact ID needs a square hash, got (%d, %d)", h.rows, h.cols)
+	}
+	for _, k := range compactKinds {
+		if k.kind == h.kind {
+			return string(k.letter) + strconv.Itoa(h.rows) + h.ToCrockfordBase32(), nil
+		}
+	}
+	return "", fmt.Errorf("compact ID needs a known hash kind, got %q", h.kind)
+}
+
+// ParseCompactID parses an ID made by ToCompactID, case-insensitively.
+// The size digits run into the base32, which may start with a digit too;
+// they are told apart by length, as only one split gives a base32 part of
+// the length the size needs.
+func ParseCompactID(s string) (*ImageHash, error) {
+	if s == "" {
+		return nil, errors.New("empty compact ID")
+	}
+	var kind HashKind
+	for _, k := range compactKinds {
+		if k.letter == s[0]|0x20 {
+			kind = k.kind
+		}
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("compact ID %q has unknown kind letter %q", s, s[0])
+	}
+	rest := s[1:]
+	for digits := 1; digits <= len(strconv.Itoa(MaxHashSize)) && digits < len(rest); digits++ {
+		if c := rest[digits-1]; c < '0' || c > '9' || rest[0] == '0' {
+			break
+		}
+		size, _ := strconv.Atoi(rest[:digits])
+		if size > MaxHashSize {
+			break
+		}
+		if len(rest)-digits != (size*size+4)/5 {
+			continue
+		}
+		h, err := FromCrockfordBase32(rest[digits:], size, size)
+		if err != nil {
+			return nil, fmt.Errorf("compact ID %q: %w", s, err)
+		}
+		h.kind = kind
+		return h, nil
+	}
+	return nil, fmt.Errorf("compact ID %q has no valid size", s)
+}
@@ -0,0 +1,147 @@
+package imagehashgo
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestCrockfordBase32_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, shape := range [][2]int{{1, 1}, {1, 5}, {2, 3}, {4, 4}, {8, 8}, {2, 64}, {9, 9}, {16, 16}, {1, MaxHashBits}} {
+		for range 20 {
+			h := lawRandomHash(r, shape[0], shape[1])
+			s := h.ToCrockfordBase32()
+			if want := (shape[0]*shape[1] + 4) / 5; len(s) != want {
+				t.Fatalf("%v: %d characters, want %d", shape, len(s), want)
+			}
+			for _, in := range []string{s, strings.ToLower(s)} {
+				got, err := FromCrockfordBase32(in, shape[0], shape[1])
+				if err != nil {
+					t.Fatalf("%v: FromCrockfordBase32(%q) error = %v", shape, in, err)
+				}
+				if d, err := got.Distance(h); err != nil || d != 0 {
+					t.Errorf("%v: round trip of %q differs by %d bits", shape, in, d)
+				}
+			}
+		}
+	}
+
+	h, err := HexToHash("ffd8e0c0c0e0f0f8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := h.ToCrockfordBase32(); got != "ZZCE1G60W3RFG" {
+		t.Errorf("ToCrockfordBase32() = %s, want ZZCE1G60W3RFG", got)
+	}
+}
+
+func TestFromCrockfordBase32_Aliases(t *testing.T) {
+	// I and L read as 1, O as 0, in either case
+	want, err := FromCrockfordBase32("10", 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"IO", "Lo", "io", "lO", "1o"} {
+		got, err := FromCrockfordBase32(s, 2, 5)
+		if err != nil {
+			t.Errorf("FromCrockfordBase32(%q) error = %v", s, err)
+			continue
+		}
+		if d, _ := got.Distance(want); d != 0 {
+			t.Errorf("FromCrockfordBase32(%q) = %s, want %s", s, got.ToCrockfordBase32(), "10")
+		}
+	}
+
+	for _, s := range []string{"U0", "u0", "1-", "1=", "1 ", "é"} {
+		if _, err := FromCrockfordBase32(s, 2, 5); err == nil {
+			t.Errorf("FromCrockfordBase32(%q) expected error", s)
+		}
+	}
+	// 8 bits need 2 characters, the last 2 bits of which are padding
+	if _, err := FromCrockfordBase32("ZZ", 2, 4); err == nil {
+		t.Error("non-zero padding bits expected error")
+	}
+	if _, err := FromCrockfordBase32("ZW", 2, 4); err != nil {
+		t.Errorf("zero padding bits: %v", err)
+	}
+	if _, err := FromCrockfordBase32("ZW0", 2, 4); err == nil {
+		t.Error("wrong length expected error")
+	}
+	if _, err := FromCrockfordBase32("", 0, 4); err == nil {
+		t.Error("invalid shape expected error")
+	}
+}
+
+// Encodings must stay distinct on case-insensitive file systems
+func TestCrockfordBase32_NoCaseCollisions(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	seen := make(map[string]string)
+	for range 5000 {
+		h := lawNear(r, lawRandomHash(r, 4, 4))
+		s := h.ToCrockfordBase32()
+		if s != strings.ToUpper(s) {
+			t.Fatalf("encoding %q is not upper case", s)
+		}
+		folded := strings.ToLower(s)
+		if prev, ok := seen[folded]; ok && prev != h.ToString() {
+			t.Fatalf("hashes %s and %s both encode to %q ignoring case", prev, h.ToString(), folded)
+		}
+		seen[folded] = h.ToString()
+	}
+	if len(seen) < 4000 {
+		t.Errorf("only %d distinct encodings", len(seen))
+	}
+}
+
+func TestCompactID(t *testing.T) {
+	h, err := HexToHash("ffd8e0c0c0e0f0f8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.kind = KindDifference
+	id, err := h.ToCompactID()
+	if err != nil || id != "d8ZZCE1G60W3RFG" {
+		t.Fatalf("ToCompactID() = %q, %v; want d8ZZCE1G60W3RFG", id, err)
+	}
+	for _, in := range []string{id, strings.ToLower(id), "D8ZZCE1G60W3RFG"} {
+		got, err := ParseCompactID(in)
+		if err != nil {
+			t.Fatalf("ParseCompactID(%q) error = %v", in, err)
+		}
+		if got.Kind() != KindDifference || got.ToString() != "ffd8e0c0c0e0f0f8" {
+			t.Errorf("ParseCompactID(%q) = %s %s", in, got.Kind(), got.ToString())
+		}
+	}
+
+	// The base32 of a 1x1 hash is one character, which may be a digit
+	for size, wantLen := range map[int]int{1: 1, 2: 1, 3: 2, 10: 20, 12: 29, 100: 2000, MaxHashSize: 3277} {
+		for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifferenceVertical} {
+			h := &ImageHash{hash: make([]bool, size*size), rows: size, cols: size, kind: kind}
+			h.hash[len(h.hash)-1] = true
+			id, err := h.ToCompactID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(h.ToCrockfordBase32()) != wantLen {
+				t.Errorf("size %d: %d base32 characters, want %d", size, len(h.ToCrockfordBase32()), wantLen)
+			}
+			got, err := ParseCompactID(id)
+			if err != nil || got.rows != size || got.Kind() != kind || got.ToString() != h.ToString() {
+				t.Errorf("ParseCompactID(%.20q) = %v, %v", id, got, err)
+			}
+		}
+	}
+
+	if _, err := (&ImageHash{hash: make([]bool, 16), rows: 4, cols: 4}).ToCompactID(); err == nil {
+		t.Error("ToCompactID() without a kind expected error")
+	}
+	if _, err := (&ImageHash{hash: make([]bool, 16), rows: 2, cols: 8, kind: KindDifference}).ToCompactID(); err == nil {
+		t.Error("ToCompactID() of a non-square hash expected error")
+	}
+	for _, s := range []string{"", "x8ZZCE1G60W3RFG", "d", "d8", "d08ZZCE1G60W3RFG", "d+8ZZCE1G60W3RFG", "d9ZZCE1G60W3RFG", "d8ZZCE1G60W3RFU", "d8ZZCE1G60W3RFH"} {
+		if _, err := ParseCompactID(s); err == nil {
+			t.Errorf("ParseCompactID(%q) expected error", s)
+		}
+	}
+}
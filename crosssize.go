@@ -0,0 +1,58 @@
+package imagehashgo
+
+import "fmt"
+
+// CrossSizeDistance compares hashes of different sizes, e.g. legacy 8x8
+// hashes with newly computed 16x16 ones. When the shapes differ by integer
+// factors, each block of the larger hash is majority-pooled into one bit of
+// the smaller shape (a block exactly half set pools to 0), and the Hamming
+// distance is returned normalized by the smaller hash's bit count. Hashes
+// of the same shape are compared directly.
+//
+// PerceptualHash bits are DCT coefficients rather than image areas, so
+// they do not pool: when the larger hash has KindPerceptual, its top-left
+// block of the smaller shape, which holds the same frequencies as the
+// native smaller hash, is compared instead.
+//
+// The result is approximate: the reduced bits are not the bits the
+// algorithm computes natively at the smaller size. On generated images the
+// mean error of the normalized distance is about 0.05 for near-duplicates
+// (TestCrossSizeDistanceError); pooling pHash bits would give about 0.35.
+func CrossSizeDistance(a, b *ImageHash) (normalized float64, err error) {
+	if len(a.hash) < len(b.hash) {
+		a, b = b, a
+	}
+	if b.rows <= 0 || b.cols <= 0 || a.rows%b.rows != 0 || a.cols%b.cols != 0 {
+		return 0, fmt.Errorf("shapes (%d, %d) and (%d, %d) do not differ by integer factors", a.rows, a.cols, b.rows, b.cols)
+	}
+
+	fy, fx := a.rows/b.rows, a.cols/b.cols
+	dist := 0
+	if a.kind == KindPerceptual {
+		for y := range b.rows {
+			for x := range b.cols {
+				if a.hash[y*a.cols+x] != b.hash[y*b.cols+x] {
+					dist++
+				}
+			}
+		}
+		return float64(dist) / float64(len(b.hash)), nil
+	}
+	for y := range b.rows {
+		for x := range b.cols {
+			set := 0
+			for by := range fy {
+				row := a.hash[(y*fy+by)*a.cols:]
+				for bx := range fx {
+					if row[x*fx+bx] {
+						set++
+					}
+				}
+			}
+			if (2*set > fy*fx) != b.hash[y*b.cols+x] {
+				dist++
+			}
+		}
+	}
+	return float64(dist) / float64(len(b.hash)), nil
+}
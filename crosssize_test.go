@@ -0,0 +1,130 @@
+package imagehashgo
+
+import (
+	"image"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// waveImage returns a smooth 96x96 image made of a few random cosine
+// waves, with photo-like low-frequency content
+func waveImage(seed uint64) *image.Gray {
+	rng := rand.New(rand.NewPCG(seed, 1))
+	type wave struct{ fx, fy, phase, amp float64 }
+	waves := make([]wave, 4)
+	for i := range waves {
+		waves[i] = wave{rng.Float64() * 4, rng.Float64() * 4, rng.Float64() * 2 * math.Pi, 20 + rng.Float64()*30}
+	}
+	img := image.NewGray(image.Rect(0, 0, 96, 96))
+	for y := range 96 {
+		for x := range 96 {
+			v := 128.0
+			for _, w := range waves {
+				v += w.amp * math.Cos(2*math.Pi*(w.fx*float64(x)+w.fy*float64(y))/96+w.phase)
+			}
+			img.Pix[y*img.Stride+x] = uint8(min(max(v, 0), 255))
+		}
+	}
+	return img
+}
+
+func TestCrossSizeDistance(t *testing.T) {
+	// Every 2x2 block of big pools to the matching cell of small
+	small := uint64Hash(0b1001, 2, 2)
+	big := uint64Hash(0b1100_1000_0011_0001, 4, 4)
+	for _, pair := range [][2]*ImageHash{{big, small}, {small, big}} {
+		d, err := CrossSizeDistance(pair[0], pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != 0 {
+			t.Errorf("distance = %v, want 0", d)
+		}
+	}
+
+	// A block exactly half set pools to 0
+	half := uint64Hash(0b1100_0000_0000_0000, 4, 4)
+	if d, _ := CrossSizeDistance(half, uint64Hash(0, 2, 2)); d != 0 {
+		t.Errorf("half-set block: distance = %v, want 0", d)
+	}
+
+	// Non-square factors pool rectangular blocks
+	wide := uint64Hash(0b1110_0000, 2, 4)
+	if d, _ := CrossSizeDistance(wide, uint64Hash(0b11, 2, 1)); d != 0.5 {
+		t.Errorf("rectangular blocks: distance = %v, want 0.5", d)
+	}
+
+	if d, err := CrossSizeDistance(small, uint64Hash(0b0110, 2, 2)); err != nil || d != 1 {
+		t.Errorf("same shape: distance = %v, %v, want 1", d, err)
+	}
+	if _, err := CrossSizeDistance(uint64Hash(0, 8, 8), uint64Hash(0, 3, 3)); err == nil {
+		t.Error("expected an error for a non-integer ratio")
+	}
+	// pHash keeps the low-frequency top-left block instead of pooling
+	p4 := uint64Hash(0b1000_0100_0000_0000, 4, 4)
+	p4.kind = KindPerceptual
+	if d, _ := CrossSizeDistance(p4, uint64Hash(0b1001, 2, 2)); d != 0 {
+		t.Errorf("pHash: distance = %v, want 0", d)
+	}
+	if _, err := CrossSizeDistance(uint64Hash(0, 8, 2), uint64Hash(0, 2, 4)); err == nil {
+		t.Error("expected an error when neither shape contains the other")
+	}
+}
+
+// TestCrossSizeDistanceError measures how far the pooled 16x16 vs 8x8
+// distance is from the native 8x8 distance over generated images, for
+// near-duplicate pairs and for unrelated ones
+func TestCrossSizeDistanceError(t *testing.T) {
+	const n = 24
+	images := make([]image.Image, n)
+	for i := range images {
+		images[i] = waveImage(uint64(i / 2))
+		if i%2 == 1 {
+			// Every other image is a shifted near-duplicate of the previous
+			shifted := image.NewGray(images[i-1].Bounds())
+			translateGray(images[i-1].(*image.Gray), shifted, 2, 1)
+			images[i] = shifted
+		}
+	}
+
+	// Upper bounds on the mean absolute error of the normalized distance,
+	// for near-duplicates and unrelated pairs
+	bounds := map[HashKind][2]float64{
+		KindAverage:    {0.1, 0.1},
+		KindDifference: {0.1, 0.1},
+		KindPerceptual: {0.1, 0.1},
+	}
+	for kind, bound := range bounds {
+		var sum [2]float64
+		var pairs [2]int
+		for i := range n {
+			a16, _ := Hash(images[i], kind, 16)
+			a8, _ := Hash(images[i], kind, 8)
+			for j := range n {
+				if i == j {
+					continue
+				}
+				b8, _ := Hash(images[j], kind, 8)
+				native, _ := a8.Distance(b8)
+				pooled, err := CrossSizeDistance(a16, b8)
+				if err != nil {
+					t.Fatal(err)
+				}
+				k := 1
+				if i/2 == j/2 {
+					k = 0
+				}
+				sum[k] += math.Abs(pooled - float64(native)/64)
+				pairs[k]++
+			}
+		}
+		for k, name := range []string{"near-duplicates", "unrelated"} {
+			mae := sum[k] / float64(pairs[k])
+			t.Logf("%s %s: mean absolute error %.3f over %d pairs", kind, name, mae, pairs[k])
+			if mae > bound[k] {
+				t.Errorf("%s %s: mean absolute error %.3f above %.2f", kind, name, mae, bound[k])
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDCTTables(t *testing.T) {
+	dctTablesOnce.Do(initDCTTables)
+
+	tables := map[int][]float64{
+		64: dct64[:],
+		32: dct32[:],
+		16: dct16[:],
+		8:  dct8[:],
+		4:  dct4[:],
+		2:  dct2[:],
+	}
+	for n, table := range tables {
+		if len(table) != n/2 {
+			t.Errorf("dct%d has %d entries, want %d", n, len(table), n/2)
+		}
+		for i, got := range table {
+			// 2*cos(x) written as 2*sin(pi/2 - x) so the check does not
+			// repeat the generator expression
+			want := 2 * math.Sin(math.Pi/2-(float64(i)+0.5)*math.Pi/float64(n))
+			if math.Abs(got-want) > 1e-12 {
+				t.Errorf("dct%d[%d] = %v, want %v", n, i, got, want)
+			}
+		}
+	}
+}
+
+func TestForwardDCT32_MatchesDCT1D(t *testing.T) {
+	dctTablesOnce.Do(initDCTTables)
+
+	input := make([]float64, 32)
+	for i := range input {
+		input[i] = float64((i*37)%256) - 100
+	}
+	want := DCT1D(input)
+
+	got := append([]float64(nil), input...)
+	forwardDCT32(got)
+	for i := range got {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Errorf("coefficient %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// The pruned kernels skip butterflies, not operations within them, so the
+// outputs they compute must equal the full kernels' exactly
+func TestForwardDCTLow_Exact(t *testing.T) {
+	dctTablesOnce.Do(initDCTTables)
+	r := rand.New(rand.NewSource(985))
+	kernels := []struct {
+		name string
+		n    int
+		full func([]float64)
+		low  func([]float64, int)
+	}{
+		{"64", 64, forwardDCT64, func(in []float64, _ int) { forwardDCT64Low8(in) }},
+		{"32", 32, forwardDCT32, forwardDCT32Low},
+		{"16", 16, forwardDCT16, forwardDCT16Low},
+	}
+	for _, kernel := range kernels {
+		for range 200 {
+			input := make([]float64, kernel.n)
+			for i := range input {
+				input[i] = r.NormFloat64() * 100
+			}
+			want := append([]float64(nil), input...)
+			kernel.full(want)
+			for k := range kernel.n + 1 {
+				if kernel.n == 64 && k != 8 {
+					continue
+				}
+				got := append([]float64(nil), input...)
+				kernel.low(got, k)
+				for i := range k {
+					if got[i] != want[i] {
+						t.Fatalf("%s-point, k=%d: output %d = %v, want %v", kernel.name, k, i, got[i], want[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDCT2DLowFreq(t *testing.T) {
+	r := rand.New(rand.NewSource(959))
+	for _, tt := range []struct{ size, hashSize int }{{16, 4}, {16, 16}, {32, 8}, {32, 0}, {64, 8}, {64, 16}} {
+		in := make([]float64, tt.size*tt.size)
+		matrix := make([][]float64, tt.size)
+		for y := range matrix {
+			matrix[y] = make([]float64, tt.size)
+			for x := range matrix[y] {
+				in[y*tt.size+x] = float64(r.Intn(256))
+				matrix[y][x] = in[y*tt.size+x]
+			}
+		}
+		want := DCT2D(matrix)
+		got, err := DCT2DLowFreq(in, tt.size, tt.hashSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.hashSize*tt.hashSize {
+			t.Fatalf("%dx%d low %d: %d coefficients", tt.size, tt.size, tt.hashSize, len(got))
+		}
+		for i, v := range got {
+			if w := want[i/tt.hashSize][i%tt.hashSize]; math.Abs(v-w) > 1e-6*max(1, math.Abs(w)) {
+				t.Errorf("%dx%d low %d: coefficient %d = %g, DCT2D %g", tt.size, tt.size, tt.hashSize, i, v, w)
+				break
+			}
+		}
+	}
+
+	for _, tt := range []struct{ n, size, hashSize int }{
+		{24 * 24, 24, 8},
+		{32 * 32, 64, 8},
+		{16 * 16, 16, 17},
+		{32 * 32, 32, -1},
+	} {
+		if _, err := DCT2DLowFreq(make([]float64, tt.n), tt.size, tt.hashSize); err == nil {
+			t.Errorf("DCT2DLowFreq(%d values, %d, %d) expected error", tt.n, tt.size, tt.hashSize)
+		}
+	}
+}
+
+func BenchmarkDCT2DFast(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{32, 64} {
+		src := make([]float64, size*size)
+		for i := range src {
+			src[i] = float64(r.Intn(256))
+		}
+		in := make([]float64, size*size)
+		b.Run(fmt.Sprintf("%dx%d", size, size), func(b *testing.B) {
+			for b.Loop() {
+				copy(in, src)
+				DCT2DLowFreq(in, size, 8)
+			}
+		})
+	}
+}
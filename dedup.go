@@ -0,0 +1,152 @@
+package imagehashgo
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// SlidingDedup answers "is this a near-duplicate of anything added in the
+// last window" with memory bounded by the entries inside the window.
+//
+// Hashes are indexed with multi-index hashing: each hash is split into
+// maxDist+1 chunks, and by the pigeonhole principle any hash within maxDist
+// shares at least one identical chunk, so only entries sharing a chunk are
+// compared. Hashes with no more than maxDist bits are all compared.
+// Entries older than the window are evicted lazily on Add and Check. A
+// SlidingDedup is safe for concurrent use.
+type SlidingDedup struct {
+	window  time.Duration
+	maxDist int
+	kind    HashKind
+
+	mu sync.Mutex
+	// ring holds the live entries in insertion order starting at head
+	ring    []*dedupEntry
+	head    int
+	buckets map[string]map[*dedupEntry]struct{}
+}
+
+type dedupEntry struct {
+	id    uint64
+	hash  *ImageHash
+	added time.Time
+	keys  []string
+}
+
+// NewSlidingDedup creates a SlidingDedup that reports hashes within maxDist
+// of a hash added less than window ago. Only hashes whose Kind is kind are
+// indexed and checked; others are ignored, including hashes without a Kind
+// (e.g. parsed from hex) unless kind is "", so that hashes of different
+// algorithms are never compared.
+func NewSlidingDedup(window time.Duration, maxDist int, kind HashKind) *SlidingDedup {
+	return &SlidingDedup{
+		window:  window,
+		maxDist: maxDist,
+		kind:    kind,
+		buckets: make(map[string]map[*dedupEntry]struct{}),
+	}
+}
+
+// Add records h under id at time now. Times are expected to be
+// non-decreasing; entries are expired in insertion order. Nil and empty
+// hashes are ignored.
+func (d *SlidingDedup) Add(id uint64, h *ImageHash, now time.Time) {
+	if !d.accepts(h) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(now)
+
+	e := &dedupEntry{id: id, hash: h, added: now, keys: d.chunkKeys(h)}
+	for _, k := range e.keys {
+		bucket := d.buckets[k]
+		if bucket == nil {
+			bucket = make(map[*dedupEntry]struct{})
+			d.buckets[k] = bucket
+		}
+		bucket[e] = struct{}{}
+	}
+	d.ring = append(d.ring, e)
+}
+
+// Check reports the id of the closest live entry within maxDist of h
+func (d *SlidingDedup) Check(h *ImageHash, now time.Time) (dupOf uint64, found bool) {
+	if !d.accepts(h) {
+		return 0, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(now)
+
+	best := d.maxDist + 1
+	seen := make(map[*dedupEntry]struct{})
+	for _, k := range d.chunkKeys(h) {
+		for e := range d.buckets[k] {
+			if _, ok := seen[e]; ok {
+				continue
+			}
+			seen[e] = struct{}{}
+			if dist, err := h.Distance(e.hash); err == nil && dist < best {
+				best, dupOf, found = dist, e.id, true
+			}
+		}
+	}
+	return dupOf, found
+}
+
+// Len returns the number of entries currently held
+func (d *SlidingDedup) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.ring) - d.head
+}
+
+func (d *SlidingDedup) accepts(h *ImageHash) bool {
+	return d.maxDist >= 0 && h != nil && len(h.hash) > 0 && h.kind == d.kind
+}
+
+// expire drops every entry added at or before now-window. d.mu must be held.
+func (d *SlidingDedup) expire(now time.Time) {
+	cutoff := now.Add(-d.window)
+	for d.head < len(d.ring) && !d.ring[d.head].added.After(cutoff) {
+		e := d.ring[d.head]
+		for _, k := range e.keys {
+			delete(d.buckets[k], e)
+			if len(d.buckets[k]) == 0 {
+				delete(d.buckets, k)
+			}
+		}
+		d.ring[d.head] = nil
+		d.head++
+	}
+	// Compact once the expired prefix dominates so memory follows the window
+	if d.head > 0 && d.head >= len(d.ring)/2 {
+		d.ring = append(d.ring[:0], d.ring[d.head:]...)
+		d.head = 0
+	}
+}
+
+// chunkKeys splits h into maxDist+1 chunks and returns one bucket key per
+// chunk, tagged with the chunk index and hash length so hashes of different
+// sizes never share buckets. A hash of at most maxDist bits cannot be split
+// that far, and every hash of its length is within maxDist: it gets the
+// single key of its length, so they are all compared.
+func (d *SlidingDedup) chunkKeys(h *ImageHash) []string {
+	n := len(h.hash)
+	if d.maxDist >= n {
+		return []string{string(binary.AppendUvarint(nil, uint64(n)))}
+	}
+	m := d.maxDist + 1
+	keys := make([]string, m)
+	for i := range m {
+		start, end := i*n/m, (i+1)*n/m
+		key := binary.AppendUvarint(nil, uint64(n))
+		key = binary.AppendUvarint(key, uint64(i))
+		keys[i] = string(append(key, packBits(h.hash[start:end])...))
+	}
+	return keys
+}
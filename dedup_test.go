@@ -0,0 +1,134 @@
+package imagehashgo
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func randomHash(r *rand.Rand, size int) *ImageHash {
+	h := &ImageHash{hash: make([]bool, size*size), rows: size, cols: size, kind: KindDifference}
+	for i := range h.hash {
+		h.hash[i] = r.Intn(2) == 1
+	}
+	return h
+}
+
+// flipBits returns a copy of h with n distinct bits flipped
+func flipBits(r *rand.Rand, h *ImageHash, n int) *ImageHash {
+	c := &ImageHash{hash: append([]bool(nil), h.hash...), rows: h.rows, cols: h.cols, kind: h.kind}
+	for _, i := range r.Perm(len(c.hash))[:n] {
+		c.hash[i] = !c.hash[i]
+	}
+	return c
+}
+
+func TestSlidingDedup_Expiry(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := NewSlidingDedup(24*time.Hour, 4, KindDifference)
+
+	original := randomHash(r, 8)
+	d.Add(1, original, start)
+	d.Add(2, randomHash(r, 8), start.Add(time.Hour))
+
+	near := flipBits(r, original, 4)
+	if id, found := d.Check(near, start.Add(23*time.Hour)); !found || id != 1 {
+		t.Errorf("Check() inside window = %d, %v; want 1, true", id, found)
+	}
+	if _, found := d.Check(flipBits(r, original, 12), start.Add(23*time.Hour)); found {
+		t.Error("Check() reported a hash 12 bits away with maxDist 4")
+	}
+
+	if _, found := d.Check(near, start.Add(24*time.Hour)); found {
+		t.Error("Check() reported an expired duplicate")
+	}
+	if d.Len() != 1 {
+		t.Errorf("Len() = %d after expiring one entry, want 1", d.Len())
+	}
+	if _, found := d.Check(near, start.Add(48*time.Hour)); found || d.Len() != 0 {
+		t.Errorf("all entries should have expired, Len() = %d", d.Len())
+	}
+}
+
+func TestSlidingDedup_MatchesBruteForce(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	now := time.Now()
+	d := NewSlidingDedup(time.Hour, 6, KindDifference)
+
+	var stored []*ImageHash
+	for i := range 200 {
+		h := randomHash(r, 8)
+		if i%3 == 0 && len(stored) > 0 {
+			h = flipBits(r, stored[r.Intn(len(stored))], r.Intn(10))
+		}
+		stored = append(stored, h)
+		d.Add(uint64(i), h, now)
+	}
+
+	for range 200 {
+		q := flipBits(r, stored[r.Intn(len(stored))], r.Intn(10))
+		want := false
+		for _, h := range stored {
+			if dist, _ := q.Distance(h); dist <= 6 {
+				want = true
+			}
+		}
+		if _, found := d.Check(q, now); found != want {
+			t.Fatalf("Check() found = %v, brute force %v", found, want)
+		}
+	}
+
+	if _, found := d.Check(&ImageHash{hash: stored[0].hash, rows: 8, cols: 8, kind: KindAverage}, now); found {
+		t.Error("Check() matched a hash of another kind")
+	}
+	if _, found := d.Check(&ImageHash{hash: stored[0].hash, rows: 8, cols: 8}, now); found {
+		t.Error("Check() matched a hash without a kind")
+	}
+}
+
+// A maxDist of the whole hash or more matches every hash of the length,
+// including the complement, which shares no chunk of any split
+func TestSlidingDedup_MaxDistCoversHash(t *testing.T) {
+	now := time.Now()
+	a := &ImageHash{hash: []bool{true, false, true, true}, rows: 2, cols: 2, kind: KindAverage}
+	b := &ImageHash{hash: []bool{false, true, false, false}, rows: 2, cols: 2, kind: KindAverage}
+	for _, maxDist := range []int{4, 10} {
+		d := NewSlidingDedup(time.Hour, maxDist, KindAverage)
+		d.Add(1, a, now)
+		if id, found := d.Check(b, now); !found || id != 1 {
+			t.Errorf("maxDist %d: Check() = %d, %v; want 1, true", maxDist, id, found)
+		}
+	}
+	d := NewSlidingDedup(time.Hour, 3, KindAverage)
+	d.Add(1, a, now)
+	if _, found := d.Check(b, now); found {
+		t.Error("maxDist 3 matched a hash at distance 4")
+	}
+}
+
+func TestSlidingDedup_Concurrent(t *testing.T) {
+	d := NewSlidingDedup(time.Minute, 3, KindDifference)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(w)))
+			for i := range 200 {
+				now := start.Add(time.Duration(i) * time.Second)
+				h := randomHash(r, 8)
+				d.Check(h, now)
+				d.Add(uint64(w*1000+i), h, now)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := d.Len(); n == 0 || n > 8*200 {
+		t.Errorf("Len() = %d", n)
+	}
+}
@@ -0,0 +1,113 @@
+package imagehashgo
+
+import (
+	"image"
+	"math/rand/v2"
+	"testing"
+)
+
+// scannedPage renders a synthetic document scan: paper with a few text
+// blocks, sensor noise, a lamp falloff of up to lamp levels across the
+// page and an overall brightness offset
+func scannedPage(seed uint64, brightness, lamp int) *image.Gray {
+	const w, h = 420, 594
+	r := rand.New(rand.NewPCG(seed, 1))
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			v := 225 + brightness - lamp*x/w + r.IntN(5) - 2
+			// Text lines in the upper half, a figure block lower down
+			if (y/18)%2 == 1 && y < h/2 && x > 40 && x < w-40 && (x/9)%5 != 0 {
+				v -= 150
+			}
+			if y > 2*h/3 && y < 5*h/6 && x > w/4 && x < 3*w/4 {
+				v -= 90
+			}
+			img.Pix[y*img.Stride+x] = uint8(min(max(v, 0), 255))
+		}
+	}
+	return img
+}
+
+func TestDifferenceHashThresholded(t *testing.T) {
+	img := getBenchImage()
+	if got, want := DifferenceHashThresholded(img, 8, 0).ToString(), DifferenceHash(img, 8).ToString(); got != want {
+		t.Errorf("minDelta 0 = %s, want DifferenceHash %s", got, want)
+	}
+
+	// Every bit set at a higher threshold is set at a lower one
+	low, high := DifferenceHashThresholded(img, 8, 2), DifferenceHashThresholded(img, 8, 20)
+	for i := range high.hash {
+		if high.hash[i] && !low.hash[i] {
+			t.Fatalf("bit %d set at minDelta 20 but not at 2", i)
+		}
+	}
+}
+
+func TestTriDifferenceHash_Shape(t *testing.T) {
+	img := getBenchImage()
+	h := TriDifferenceHash(img, 8, 3)
+	if h.rows != 8 || h.cols != 16 || len(h.hash) != 128 {
+		t.Fatalf("shape (%d, %d) with %d bits, want (8, 16) with 128", h.rows, h.cols, len(h.hash))
+	}
+
+	greater := DifferenceHashThresholded(img, 8, 3)
+	for y := range 8 {
+		for x := range 8 {
+			g, l := h.hash[y*16+2*x], h.hash[y*16+2*x+1]
+			if g && l {
+				t.Fatalf("cell (%d, %d) is both greater and less", x, y)
+			}
+			if g != greater.hash[y*8+x] {
+				t.Errorf("cell (%d, %d) greater bit %v, thresholded dHash %v", x, y, g, greater.hash[y*8+x])
+			}
+		}
+	}
+
+	back, err := FromSnapshot(h.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := h.Distance(back); err != nil || d != 0 {
+		t.Errorf("snapshot round trip distance %d, %v", d, err)
+	}
+}
+
+// TestDifferenceHash_BrightnessShiftedScans compares plain, thresholded and
+// three-state dHash on scans of one page under different lamp and
+// brightness conditions, against a different page
+func TestDifferenceHash_BrightnessShiftedScans(t *testing.T) {
+	original := scannedPage(1, 0, 0)
+	rescans := []*image.Gray{scannedPage(2, 12, 0), scannedPage(3, -10, 6), scannedPage(4, 20, 10)}
+	other := ToGrayscaleFast(getBenchImage())
+
+	const minDelta = 3
+	algos := []struct {
+		name string
+		hash func(image.Image) *ImageHash
+	}{
+		{"dhash", func(img image.Image) *ImageHash { return DifferenceHash(img, 8) }},
+		{"thresholded", func(img image.Image) *ImageHash { return DifferenceHashThresholded(img, 8, minDelta) }},
+		{"tri", func(img image.Image) *ImageHash { return TriDifferenceHash(img, 8, minDelta) }},
+	}
+
+	// Distances as a fraction of the hash length, as tri has twice the bits
+	same := make(map[string]float64)
+	for _, a := range algos {
+		ref := a.hash(original)
+		total := 0
+		for _, rescan := range rescans {
+			d, _ := ref.Distance(a.hash(rescan))
+			total += d
+		}
+		same[a.name] = float64(total) / float64(len(ref.hash)*len(rescans))
+		d, _ := ref.Distance(a.hash(other))
+		t.Logf("%-11s rescans %.3f of bits differ, unrelated image %.3f", a.name, same[a.name], float64(d)/float64(len(ref.hash)))
+	}
+
+	for _, name := range []string{"thresholded", "tri"} {
+		if same[name] >= same["dhash"] {
+			t.Errorf("%s (%.3f) should be more stable than dHash (%.3f) on rescans", name, same[name], same["dhash"])
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package imagehashgo
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"slices"
+	"strings"
+)
+
+// Rule is a boolean expression over per-algorithm distances, built with
+// Leaf, And and Or
+type Rule interface {
+	// evaluate appends one verdict per leaf to leaves, visiting every leaf
+	// so that the explanation is complete
+	evaluate(a, b EnsembleHashes, leaves *[]LeafVerdict) bool
+	// kinds appends the kinds referenced by the rule
+	kinds(dst []HashKind) []HashKind
+}
+
+type leafRule struct {
+	kind    HashKind
+	maxDist int
+}
+
+type andRule []Rule
+
+type orRule []Rule
+
+// Leaf matches when the distance between the two hashes of kind is at most
+// maxDist. A missing hash or a shape mismatch never matches.
+func Leaf(kind HashKind, maxDist int) Rule {
+	return leafRule{kind: kind, maxDist: maxDist}
+}
+
+// And matches when every rule matches
+func And(rules ...Rule) Rule {
+	return andRule(rules)
+}
+
+// Or matches when at least one rule matches
+func Or(rules ...Rule) Rule {
+	return orRule(rules)
+}
+
+func (r leafRule) evaluate(a, b EnsembleHashes, leaves *[]LeafVerdict) bool {
+	v := LeafVerdict{Kind: r.kind, MaxDist: r.maxDist, Distance: -1}
+	if ha, hb := a[r.kind], b[r.kind]; ha != nil && hb != nil {
+		if dist, err := ha.Distance(hb); err == nil {
+			v.Distance = dist
+			v.Match = dist <= r.maxDist
+		}
+	}
+	*leaves = append(*leaves, v)
+	return v.Match
+}
+
+func (r leafRule) kinds(dst []HashKind) []HashKind {
+	return append(dst, r.kind)
+}
+
+func (r andRule) evaluate(a, b EnsembleHashes, leaves *[]LeafVerdict) bool {
+	match := true
+	for _, sub := range r {
+		match = sub.evaluate(a, b, leaves) && match
+	}
+	return match
+}
+
+func (r andRule) kinds(dst []HashKind) []HashKind {
+	for _, sub := range r {
+		dst = sub.kinds(dst)
+	}
+	return dst
+}
+
+func (r orRule) evaluate(a, b EnsembleHashes, leaves *[]LeafVerdict) bool {
+	match := false
+	for _, sub := range r {
+		match = sub.evaluate(a, b, leaves) || match
+	}
+	return match
+}
+
+func (r orRule) kinds(dst []HashKind) []HashKind {
+	for _, sub := range r {
+		dst = sub.kinds(dst)
+	}
+	return dst
+}
+
+// LeafVerdict is the outcome of one Leaf of a Rule
+type LeafVerdict struct {
+	Kind    HashKind
+	MaxDist int
+	// Distance is -1 when either hash is missing or the shapes differ
+	Distance int
+	Match    bool
+}
+
+// EnsembleExplanation lists the verdict of every leaf of an ensemble rule,
+// in the order the leaves appear in the rule
+type EnsembleExplanation struct {
+	Leaves []LeafVerdict
+}
+
+// String renders the explanation as one "kind dist<=max ok" item per leaf
+func (e EnsembleExplanation) String() string {
+	parts := make([]string, len(e.Leaves))
+	for i, l := range e.Leaves {
+		verdict := "no"
+		if l.Match {
+			verdict = "ok"
+		}
+		parts[i] = fmt.Sprintf("%s %d<=%d %s", l.Kind, l.Distance, l.MaxDist, verdict)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// EnsembleHashes holds one hash per algorithm of an Ensemble
+type EnsembleHashes map[HashKind]*ImageHash
+
+// ensemblePrefix starts the string form of EnsembleHashes
+const ensemblePrefix = "ens:"
+
+// String returns a single storable string such as
+// "ens:ahash/8x8/ffefc3c3c3c3c3e7,dhash/8x8/12189e3333968e0c",
+// with kinds in sorted order. ParseEnsembleHashes reverses it.
+func (e EnsembleHashes) String() string {
+	kinds := make([]HashKind, 0, len(e))
+	for kind := range e {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+
+	parts := make([]string, len(kinds))
+	for i, kind := range kinds {
+		h := e[kind]
+		parts[i] = fmt.Sprintf("%s/%dx%d/%s", kind, h.rows, h.cols, h.ToString())
+	}
+	return ensemblePrefix + strings.Join(parts, ",")
+}
+
+// ParseEnsembleHashes parses the string form produced by EnsembleHashes.String
+func ParseEnsembleHashes(s string) (EnsembleHashes, error) {
+	body, ok := strings.CutPrefix(s, ensemblePrefix)
+	if !ok {
+		return nil, fmt.Errorf("ensemble hashes must start with %q", ensemblePrefix)
+	}
+
+	hashes := make(EnsembleHashes)
+	if body == "" {
+		return hashes, nil
+	}
+	for part := range strings.SplitSeq(body, ",") {
+		fields := strings.Split(part, "/")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid ensemble entry %q", part)
+		}
+		kind, err := ParseHashKind(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := hashes[kind]; dup {
+			return nil, fmt.Errorf("duplicate ensemble entry for %s", kind)
+		}
+		var rows, cols int
+		if _, err := fmt.Sscanf(fields[1], "%dx%d", &rows, &cols); err != nil || rows <= 0 || cols <= 0 || rows > MaxHashBits || cols > MaxHashBits || rows*cols > MaxHashBits {
+			return nil, fmt.Errorf("invalid ensemble shape %q", fields[1])
+		}
+		h, err := HexToHashShape(fields[2], rows, cols)
+		if err != nil {
+			return nil, err
+		}
+		h.kind = kind
+		hashes[kind] = h
+	}
+	return hashes, nil
+}
+
+// Ensemble combines several hash algorithms into a single match decision,
+// e.g. Or(Leaf(KindDifference, 4), And(Leaf(KindPerceptual, 10), Leaf(KindAverage, 12))).
+// An Ensemble is safe for concurrent use.
+type Ensemble struct {
+	rule    Rule
+	hashers []*Hasher
+}
+
+// NewEnsemble returns an Ensemble evaluating rule over the hashes computed
+// by hashers. Every kind referenced by the rule needs exactly one hasher.
+func NewEnsemble(rule Rule, hashers ...*Hasher) (*Ensemble, error) {
+	if rule == nil {
+		return nil, errors.New("ensemble rule is nil")
+	}
+
+	byKind := make(map[HashKind]bool)
+	for _, h := range hashers {
+		if byKind[h.kind] {
+			return nil, fmt.Errorf("more than one hasher for %s", h.kind)
+		}
+		byKind[h.kind] = true
+	}
+	for _, kind := range rule.kinds(nil) {
+		if !byKind[kind] {
+			return nil, fmt.Errorf("rule uses %s but no hasher computes it", kind)
+		}
+	}
+
+	return &Ensemble{rule: rule, hashers: slices.Clone(hashers)}, nil
+}
+
+// Hash computes every hash of the ensemble for img
+func (e *Ensemble) Hash(img image.Image) (EnsembleHashes, error) {
+	hashes := make(EnsembleHashes, len(e.hashers))
+	for _, h := range e.hashers {
+		hash, err := h.Hash(img)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.kind, err)
+		}
+		hashes[h.kind] = hash
+	}
+	return hashes, nil
+}
+
+// Match evaluates the ensemble rule on a pair of hash sets
+func (e *Ensemble) Match(a, b EnsembleHashes) (bool, EnsembleExplanation) {
+	var exp EnsembleExplanation
+	match := e.rule.evaluate(a, b, &exp.Leaves)
+	return match, exp
+}
@@ -0,0 +1,120 @@
+package imagehashgo
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func newTestEnsemble(t *testing.T) *Ensemble {
+	t.Helper()
+	var hashers []*Hasher
+	for _, kind := range []HashKind{KindDifference, KindPerceptual, KindAverage} {
+		h, err := NewHasher(kind, 8)
+		if err != nil {
+			t.Fatalf("NewHasher(%s) error = %v", kind, err)
+		}
+		hashers = append(hashers, h)
+	}
+	// dHash <= 4 OR (pHash <= 10 AND aHash <= 12)
+	rule := Or(Leaf(KindDifference, 4), And(Leaf(KindPerceptual, 10), Leaf(KindAverage, 12)))
+	e, err := NewEnsemble(rule, hashers...)
+	if err != nil {
+		t.Fatalf("NewEnsemble() error = %v", err)
+	}
+	return e
+}
+
+func TestEnsemble_Match(t *testing.T) {
+	e := newTestEnsemble(t)
+	base, err := e.Hash(getBenchImage())
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	r := rand.New(rand.NewSource(1))
+	variant := func(d, p, a int) EnsembleHashes {
+		return EnsembleHashes{
+			KindDifference: flipBits(r, base[KindDifference], d),
+			KindPerceptual: flipBits(r, base[KindPerceptual], p),
+			KindAverage:    flipBits(r, base[KindAverage], a),
+		}
+	}
+
+	tests := []struct {
+		name  string
+		other EnsembleHashes
+		want  bool
+	}{
+		{"identical", base, true},
+		{"dHash branch only", variant(3, 20, 20), true},
+		{"pHash and aHash branch only", variant(10, 8, 11), true},
+		{"aHash over threshold", variant(10, 8, 13), false},
+		{"pHash over threshold", variant(5, 11, 0), false},
+		{"missing dHash", EnsembleHashes{KindPerceptual: base[KindPerceptual], KindAverage: base[KindAverage]}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exp := e.Match(base, tt.other)
+			if got != tt.want {
+				t.Errorf("Match() = %v, want %v (%s)", got, tt.want, exp)
+			}
+			if len(exp.Leaves) != 3 {
+				t.Fatalf("explanation has %d leaves, want 3", len(exp.Leaves))
+			}
+			for _, l := range exp.Leaves {
+				if want, ok := tt.other[l.Kind]; ok {
+					dist, _ := base[l.Kind].Distance(want)
+					if l.Distance != dist || l.Match != (dist <= l.MaxDist) {
+						t.Errorf("leaf %+v, distance %d", l, dist)
+					}
+				} else if l.Distance != -1 || l.Match {
+					t.Errorf("missing hash leaf %+v", l)
+				}
+			}
+		})
+	}
+}
+
+func TestEnsembleHashes_StringRoundTrip(t *testing.T) {
+	e := newTestEnsemble(t)
+	hashes, err := e.Hash(getBenchImage())
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	hashes[KindDifferenceVertical] = &ImageHash{hash: []bool{true, false, true}, rows: 1, cols: 3}
+
+	s := hashes.String()
+	parsed, err := ParseEnsembleHashes(s)
+	if err != nil {
+		t.Fatalf("ParseEnsembleHashes(%q) error = %v", s, err)
+	}
+	if len(parsed) != len(hashes) {
+		t.Fatalf("parsed %d hashes, want %d", len(parsed), len(hashes))
+	}
+	for kind, h := range hashes {
+		if dist, err := parsed[kind].Distance(h); err != nil || dist != 0 {
+			t.Errorf("%s: Distance() = %d, %v", kind, dist, err)
+		}
+	}
+	if parsed.String() != s {
+		t.Errorf("String() not stable: %q vs %q", parsed.String(), s)
+	}
+
+	for _, bad := range []string{"ahash/8x8/00", "ens:ahash/8x8", "ens:nope/1x4/0", "ens:ahash/2x2/00", "ens:ahash/1x4/0,ahash/1x4/0"} {
+		if _, err := ParseEnsembleHashes(bad); err == nil {
+			t.Errorf("ParseEnsembleHashes(%q) expected error", bad)
+		}
+	}
+}
+
+func TestNewEnsemble_Validation(t *testing.T) {
+	d, _ := NewHasher(KindDifference, 8)
+	if _, err := NewEnsemble(Leaf(KindPerceptual, 4), d); err == nil {
+		t.Error("NewEnsemble() without a pHash hasher expected error")
+	}
+	if _, err := NewEnsemble(Leaf(KindDifference, 4), d, d); err == nil {
+		t.Error("NewEnsemble() with duplicate hashers expected error")
+	}
+	if _, err := NewHasher("whash", 8); err == nil {
+		t.Error("NewHasher() with unknown kind expected error")
+	}
+}
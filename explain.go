@@ -0,0 +1,219 @@
+package imagehashgo
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"image"
+	"slices"
+	"strings"
+)
+
+// Classifications reported by Explain
+const (
+	ClassIdentical  = "likely identical"
+	ClassCropBorder = "likely crop/border difference"
+	ClassDifferent  = "different content"
+)
+
+// Thresholds of the Explain classification rules
+const (
+	// explainIdentical is the largest normalized distance classified as
+	// likely identical
+	explainIdentical = 0.1
+	// explainConcentrated is the share of disagreements that must fall on
+	// the border ring, or in a single quadrant, to be classified as a crop
+	// or border difference
+	explainConcentrated = 0.75
+)
+
+// Explanation describes where two hashes agree, for reviewing a match
+type Explanation struct {
+	Distance int
+	// Normalized is Distance divided by the number of bits
+	Normalized float64
+	Rows, Cols int
+	// Disagree marks the cells whose bits differ, row-major
+	Disagree []bool
+	// Quadrants counts the disagreements in the top-left, top-right,
+	// bottom-left and bottom-right quadrants. With an odd number of rows
+	// (columns) the middle row (column) counts as bottom (right).
+	Quadrants [4]int
+	// Border counts the disagreements on the outermost ring of cells
+	Border int
+	// Classification is ClassIdentical, ClassCropBorder or ClassDifferent
+	Classification string
+}
+
+// Explain compares two hashes cell by cell and classifies the difference:
+//
+//   - likely identical: at most 10% of the bits differ
+//   - likely crop/border difference: otherwise, if at least 75% of the
+//     differing cells are on the border ring or in a single quadrant
+//   - different content: anything else
+//
+// The spatial rules are meaningful for AverageHash and the DifferenceHash
+// variants, whose cells map to image areas, but not for PerceptualHash.
+func Explain(a, b *ImageHash) (Explanation, error) {
+	dist, err := a.Distance(b)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	e := Explanation{
+		Distance:   dist,
+		Normalized: float64(dist) / float64(max(len(a.hash), 1)),
+		Rows:       a.rows,
+		Cols:       a.cols,
+		Disagree:   make([]bool, len(a.hash)),
+	}
+	for y := range a.rows {
+		for x := range a.cols {
+			i := y*a.cols + x
+			if a.hash[i] == b.hash[i] {
+				continue
+			}
+			e.Disagree[i] = true
+			q := 0
+			if 2*y >= a.rows-a.rows%2 {
+				q += 2
+			}
+			if 2*x >= a.cols-a.cols%2 {
+				q++
+			}
+			e.Quadrants[q]++
+			if x == 0 || y == 0 || x == a.cols-1 || y == a.rows-1 {
+				e.Border++
+			}
+		}
+	}
+
+	concentrated := float64(max(e.Border, e.Quadrants[0], e.Quadrants[1], e.Quadrants[2], e.Quadrants[3]))
+	switch {
+	case e.Normalized <= explainIdentical:
+		e.Classification = ClassIdentical
+	case concentrated >= explainConcentrated*float64(dist):
+		e.Classification = ClassCropBorder
+	default:
+		e.Classification = ClassDifferent
+	}
+	return e, nil
+}
+
+// grid renders Disagree as one string per row, '.' for agreement and 'x'
+// for disagreement
+func (e Explanation) grid() []string {
+	rows := make([]string, e.Rows)
+	for y := range e.Rows {
+		var sb strings.Builder
+		for x := range e.Cols {
+			if e.Disagree[y*e.Cols+x] {
+				sb.WriteByte('x')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		rows[y] = sb.String()
+	}
+	return rows
+}
+
+// String renders the explanation as text: a summary line followed by the
+// disagreement grid
+func (e Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: distance %d (%.1f%%), quadrants TL %d TR %d BL %d BR %d, border %d\n",
+		e.Classification, e.Distance, 100*e.Normalized,
+		e.Quadrants[0], e.Quadrants[1], e.Quadrants[2], e.Quadrants[3], e.Border)
+	for _, row := range e.grid() {
+		sb.WriteString(row)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// MarshalJSON encodes the explanation with the grid as strings of '.' and
+// 'x', one per row
+func (e Explanation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Distance       int      `json:"distance"`
+		Normalized     float64  `json:"normalized"`
+		Rows           int      `json:"rows"`
+		Cols           int      `json:"cols"`
+		Grid           []string `json:"grid"`
+		Quadrants      [4]int   `json:"quadrants"`
+		Border         int      `json:"border"`
+		Classification string   `json:"classification"`
+	}{e.Distance, e.Normalized, e.Rows, e.Cols, e.grid(), e.Quadrants, e.Border, e.Classification})
+}
+
+// DiffRegions locates the cells where a and b differ in an image with
+// bounds srcBounds. Differing cells are grouped into connected components,
+// cells touching at an edge or a corner belonging to the same one, and the
+// bounding box of every component is scaled to pixels: cell column x of
+// cols covers the pixels from floor(x*W/cols) to ceil((x+1)*W/cols) past
+// srcBounds.Min.X, and likewise for rows, so a component reaching the last
+// column or row ends exactly at srcBounds.Max and every region, even of a
+// single cell, has at least one pixel. The regions are sorted by area,
+// largest first, then top to bottom and left to right; identical hashes
+// give none.
+//
+// As with Explain, the regions are meaningful for AverageHash and the
+// DifferenceHash variants, whose cells map to image areas, but not for
+// PerceptualHash.
+func DiffRegions(a, b *ImageHash, srcBounds image.Rectangle) ([]image.Rectangle, error) {
+	if _, err := a.Distance(b); err != nil {
+		return nil, err
+	}
+	if srcBounds.Empty() {
+		return nil, fmt.Errorf("empty source bounds %v", srcBounds)
+	}
+
+	rows, cols := a.rows, a.cols
+	seen := make([]bool, len(a.hash))
+	var regions []image.Rectangle
+	var stack []int
+	for start := range a.hash {
+		if seen[start] || a.hash[start] == b.hash[start] {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		x0, y0, x1, y1 := cols, rows, -1, -1
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%cols, i/cols
+			x0, y0, x1, y1 = min(x0, x), min(y0, y), max(x1, x), max(y1, y)
+			for ny := max(y-1, 0); ny <= min(y+1, rows-1); ny++ {
+				for nx := max(x-1, 0); nx <= min(x+1, cols-1); nx++ {
+					if j := ny*cols + nx; !seen[j] && a.hash[j] != b.hash[j] {
+						seen[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		w, h := srcBounds.Dx(), srcBounds.Dy()
+		regions = append(regions, image.Rect(
+			srcBounds.Min.X+x0*w/cols, srcBounds.Min.Y+y0*h/rows,
+			srcBounds.Min.X+ceilDiv((x1+1)*w, cols), srcBounds.Min.Y+ceilDiv((y1+1)*h, rows),
+		))
+	}
+
+	slices.SortStableFunc(regions, func(r, s image.Rectangle) int {
+		if ar, as := r.Dx()*r.Dy(), s.Dx()*s.Dy(); ar != as {
+			return cmp.Compare(as, ar)
+		}
+		if r.Min.Y != s.Min.Y {
+			return cmp.Compare(r.Min.Y, s.Min.Y)
+		}
+		return cmp.Compare(r.Min.X, s.Min.X)
+	})
+	return regions, nil
+}
+
+// ceilDiv returns a/b rounded up, for non-negative a and positive b
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,171 @@
+package imagehashgo
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// flipCells returns a copy of h with the bits of the selected cells inverted
+func flipCells(h *ImageHash, pick func(x, y int) bool) *ImageHash {
+	out := &ImageHash{hash: append([]bool(nil), h.hash...), rows: h.rows, cols: h.cols, kind: h.kind}
+	for y := range h.rows {
+		for x := range h.cols {
+			if pick(x, y) {
+				out.hash[y*h.cols+x] = !out.hash[y*h.cols+x]
+			}
+		}
+	}
+	return out
+}
+
+func TestExplainClassification(t *testing.T) {
+	base := uint64Hash(0x0123456789abcdef, 8, 8)
+
+	tests := []struct {
+		name string
+		pick func(x, y int) bool
+		want string
+	}{
+		{"identical", func(x, y int) bool { return false }, ClassIdentical},
+		{"few bits", func(x, y int) bool { return x == 3 && y < 2 }, ClassIdentical},
+		{"border ring", func(x, y int) bool { return x == 0 || y == 0 || x == 7 || y == 7 }, ClassCropBorder},
+		{"one quadrant", func(x, y int) bool { return x >= 4 && y >= 4 }, ClassCropBorder},
+		{"checkerboard", func(x, y int) bool { return (x+y)%2 == 0 }, ClassDifferent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Explain(base, flipCells(base, tt.pick))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.Classification != tt.want {
+				t.Errorf("classification = %q, want %q\n%s", e.Classification, tt.want, e)
+			}
+			sum := e.Quadrants[0] + e.Quadrants[1] + e.Quadrants[2] + e.Quadrants[3]
+			if sum != e.Distance {
+				t.Errorf("quadrants sum to %d, distance %d", sum, e.Distance)
+			}
+		})
+	}
+}
+
+func TestExplainBorderOverdraw(t *testing.T) {
+	img := getBenchImage()
+	b := img.Bounds()
+	framed := image.NewRGBA(b)
+	draw.Draw(framed, b, img, b.Min, draw.Src)
+	// Paint a thick white frame, as a scanner or letterboxing would
+	inner := b.Inset(b.Dx() / 8)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !image.Pt(x, y).In(inner) {
+				framed.Set(x, y, color.White)
+			}
+		}
+	}
+
+	e, err := Explain(AverageHash(img, 8), AverageHash(framed, 8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Distance == 0 {
+		t.Fatal("frame did not change the hash")
+	}
+	if e.Classification == ClassDifferent {
+		t.Errorf("border overdraw classified as %q\n%s", e.Classification, e)
+	}
+}
+
+func TestExplainShapeMismatch(t *testing.T) {
+	if _, err := Explain(uint64Hash(1, 8, 8), uint64Hash(1, 4, 4)); err == nil {
+		t.Error("expected an error for different shapes")
+	}
+}
+
+func TestExplainRender(t *testing.T) {
+	base := uint64Hash(0, 4, 4)
+	e, err := Explain(base, flipCells(base, func(x, y int) bool { return y == 0 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := e.String()
+	if !strings.Contains(text, ClassCropBorder) || !strings.Contains(text, "xxxx\n....\n") {
+		t.Errorf("unexpected text rendering:\n%s", text)
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Distance       int      `json:"distance"`
+		Grid           []string `json:"grid"`
+		Quadrants      [4]int   `json:"quadrants"`
+		Classification string   `json:"classification"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Distance != 4 || got.Grid[0] != "xxxx" || got.Quadrants != [4]int{2, 2, 0, 0} || got.Classification != ClassCropBorder {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+}
+
+func TestDiffRegions(t *testing.T) {
+	// 4 rows of 6 cells over a 100x50 image at (10, 20)
+	base := &ImageHash{hash: make([]bool, 24), rows: 4, cols: 6}
+	src := image.Rect(10, 20, 110, 70)
+	cells := map[[2]int]bool{{0, 0}: true, {2, 1}: true, {3, 2}: true, {5, 3}: true}
+	other := flipCells(base, func(x, y int) bool { return cells[[2]int{x, y}] })
+
+	got, err := DiffRegions(base, other, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []image.Rectangle{
+		// (2, 1) and (3, 2) touch at a corner: columns 33..67, rows 12..38
+		image.Rect(43, 32, 77, 58),
+		// Single cells of 16.7x12.5 pixels, widened to whole pixels; ties
+		// in area are ordered top to bottom
+		image.Rect(10, 20, 27, 33),
+		// The last column and row end exactly at the image edge
+		image.Rect(93, 57, 110, 70),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("DiffRegions() = %v, want %v", got, want)
+	}
+
+	all := flipCells(base, func(x, y int) bool { return true })
+	if got, err := DiffRegions(base, all, src); err != nil || !slices.Equal(got, []image.Rectangle{src}) {
+		t.Errorf("all cells differ: DiffRegions() = %v, %v; want %v", got, err, src)
+	}
+	if got, err := DiffRegions(base, base, src); err != nil || len(got) != 0 {
+		t.Errorf("identical hashes: DiffRegions() = %v, %v", got, err)
+	}
+
+	// Images smaller than the grid still give a pixel per cell, inside the
+	// bounds
+	tiny := image.Rect(-2, 5, 1, 7)
+	for y := range 4 {
+		for x := range 6 {
+			one := flipCells(base, func(cx, cy int) bool { return cx == x && cy == y })
+			got, err := DiffRegions(base, one, tiny)
+			if err != nil || len(got) != 1 || got[0].Empty() || !got[0].In(tiny) {
+				t.Errorf("cell (%d, %d) of a 3x2 image: DiffRegions() = %v, %v", x, y, got, err)
+			}
+		}
+	}
+
+	if _, err := DiffRegions(base, &ImageHash{hash: make([]bool, 24), rows: 6, cols: 4}, src); err == nil {
+		t.Error("hashes of different shapes expected error")
+	}
+	if _, err := DiffRegions(base, other, image.Rect(5, 5, 5, 9)); err == nil {
+		t.Error("empty source bounds expected error")
+	}
+}
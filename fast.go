@@ -0,0 +1,107 @@
+package imagehashgo
+
+import "image"
+
+// fastSamplesPerCell is how many pixels FastAverageHash samples along each
+// side of a cell
+const fastSamplesPerCell = 16
+
+// FastAverageHash computes an 8x8 average hash tuned for throughput, e.g.
+// for thumbnail servers hashing every upload. Compared to AverageHash it
+// reads only the Y plane of YCbCr images, does not un-premultiply alpha,
+// and replaces the Lanczos resize with a box average over a grid of at most
+// 16x16 evenly spaced pixels per cell; the threshold is integer-only.
+//
+// The result is NOT bit-compatible with AverageHash (or Python imagehash):
+// only compare it with other FastAverageHash values. Its Kind is empty.
+func FastAverageHash(img image.Image) *ImageHash {
+	const size = 8
+	b := img.Bounds()
+	xs, xcells := fastSamples(b.Dx(), size)
+	ys, ycells := fastSamples(b.Dy(), size)
+
+	var sums, counts [size * size]uint32
+	add := func(cy int, x int, luma uint32) {
+		i := cy*size + xcells[x]
+		sums[i] += luma
+		counts[i]++
+	}
+
+	switch src := img.(type) {
+	case *image.YCbCr:
+		for j, y := range ys {
+			row := src.Y[y*src.YStride:]
+			for i, x := range xs {
+				add(ycells[j], i, uint32(row[x]))
+			}
+		}
+	case *image.Gray:
+		for j, y := range ys {
+			row := src.Pix[y*src.Stride:]
+			for i, x := range xs {
+				add(ycells[j], i, uint32(row[x]))
+			}
+		}
+	case *image.RGBA:
+		for j, y := range ys {
+			row := src.Pix[y*src.Stride:]
+			for i, x := range xs {
+				p := row[4*x : 4*x+3]
+				add(ycells[j], i, fastLuma(p[0], p[1], p[2]))
+			}
+		}
+	case *image.NRGBA:
+		for j, y := range ys {
+			row := src.Pix[y*src.Stride:]
+			for i, x := range xs {
+				p := row[4*x : 4*x+3]
+				add(ycells[j], i, fastLuma(p[0], p[1], p[2]))
+			}
+		}
+	default:
+		for j, y := range ys {
+			for i, x := range xs {
+				r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+				add(ycells[j], i, fastLuma(uint8(r>>8), uint8(g>>8), uint8(bl>>8)))
+			}
+		}
+	}
+
+	var means [size * size]uint32
+	var total uint32
+	for i := range means {
+		means[i] = sums[i] / max(counts[i], 1)
+		total += means[i]
+	}
+	hash := make([]bool, size*size)
+	for i, m := range means {
+		hash[i] = m*size*size > total
+	}
+	return builtHash(hash, size, size, "")
+}
+
+// fastSamples returns the sampled offsets along one axis of length n, and
+// the cell each falls in. Every pixel is sampled when
+// the axis is short, otherwise fastSamplesPerCell evenly spaced ones per
+// cell.
+func fastSamples(n, cells int) (coords, cellOf []int) {
+	samples := cells * fastSamplesPerCell
+	if n <= samples {
+		coords, cellOf = make([]int, n), make([]int, n)
+		for i := range n {
+			coords[i], cellOf[i] = i, i*cells/n
+		}
+		return coords, cellOf
+	}
+	coords, cellOf = make([]int, samples), make([]int, samples)
+	for i := range samples {
+		coords[i], cellOf[i] = (2*i+1)*n/(2*samples), i/fastSamplesPerCell
+	}
+	return coords, cellOf
+}
+
+// fastLuma approximates the Pillow L conversion with 8-bit fixed-point
+// weights (77, 150, 29)/256
+func fastLuma(r, g, b uint8) uint32 {
+	return (77*uint32(r) + 150*uint32(g) + 29*uint32(b)) >> 8
+}
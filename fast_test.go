@@ -0,0 +1,87 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+
+	"github.com/K0ng2/imagehash-go/internal/conformance"
+	"github.com/disintegration/imaging"
+)
+
+func TestFastAverageHash_ImageTypes(t *testing.T) {
+	gray := ToGrayscaleFast(getBenchImage())
+	want := FastAverageHash(gray).ToString()
+
+	rgba := image.NewRGBA(gray.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), gray, gray.Bounds().Min, draw.Src)
+	nrgba := image.NewNRGBA(gray.Bounds())
+	draw.Draw(nrgba, nrgba.Bounds(), gray, gray.Bounds().Min, draw.Src)
+	ycbcr := image.NewYCbCr(gray.Bounds(), image.YCbCrSubsampleRatio420)
+	copy(ycbcr.Y, gray.Pix)
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = 128, 128
+	}
+	paletted := image.NewPaletted(gray.Bounds(), color.Palette(conformance.GrayPalette()))
+	draw.Draw(paletted, paletted.Bounds(), gray, gray.Bounds().Min, draw.Src)
+
+	for name, img := range map[string]image.Image{"RGBA": rgba, "NRGBA": nrgba, "YCbCr": ycbcr, "generic": paletted} {
+		if got := FastAverageHash(img).ToString(); got != want {
+			t.Errorf("%s: FastAverageHash = %s, want %s as for *image.Gray", name, got, want)
+		}
+	}
+}
+
+func TestFastAverageHash_SubImage(t *testing.T) {
+	img := imaging.Clone(getBenchImage())
+	sub := img.SubImage(image.Rect(100, 50, 400, 300))
+	copied := imaging.Clone(sub)
+	if got, want := FastAverageHash(sub).ToString(), FastAverageHash(copied).ToString(); got != want {
+		t.Errorf("sub-image hash %s, want %s", got, want)
+	}
+
+	small := image.NewGray(image.Rect(3, 3, 8, 6))
+	if h := FastAverageHash(small); len(h.hash) != 64 {
+		t.Errorf("tiny image hash has %d bits", len(h.hash))
+	}
+}
+
+// TestFastAverageHash_Robustness compares how far common modifications move
+// FastAverageHash and AverageHash, to document the trade-off
+func TestFastAverageHash_Robustness(t *testing.T) {
+	img := getBenchImage()
+	b := img.Bounds()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	reencoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	brighter := imaging.AdjustBrightness(img, 5)
+	cropped := imaging.Crop(img, image.Rect(b.Dx()/50, b.Dy()/50, b.Dx(), b.Dy()))
+	scaled := imaging.Resize(img, b.Dx()/2, 0, imaging.Linear)
+
+	var standard, fast int
+	for _, modified := range []image.Image{reencoded, brighter, cropped, scaled} {
+		d, _ := AverageHash(img, 8).Distance(AverageHash(modified, 8))
+		f, _ := FastAverageHash(img).Distance(FastAverageHash(modified))
+		standard += d
+		fast += f
+	}
+	t.Logf("total distance over modifications: AverageHash %d, FastAverageHash %d", standard, fast)
+	if fast > standard+8 {
+		t.Errorf("FastAverageHash robustness %d is not within 8 bits of AverageHash %d", fast, standard)
+	}
+}
+
+func BenchmarkFastAverageHash(b *testing.B) {
+	benchCorpus(b, func(img image.Image) {
+		FastAverageHash(img)
+	})
+}
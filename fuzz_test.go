@@ -0,0 +1,83 @@
+package imagehashgo
+
+import (
+	"strings"
+	"testing"
+)
+
+// checkUsable fails if any operation on a parsed hash panics or disagrees
+// with the hash's own shape
+func checkUsable(t *testing.T, h *ImageHash) {
+	t.Helper()
+	if h.rows <= 0 || h.cols <= 0 || h.rows*h.cols != len(h.hash) || len(h.hash) > MaxHashBits {
+		t.Fatalf("inconsistent hash: shape (%d, %d) with %d bits", h.rows, h.cols, len(h.hash))
+	}
+	if d, err := h.Distance(h); err != nil || d != 0 {
+		t.Fatalf("Distance to itself = %d, %v", d, err)
+	}
+	if _, err := h.MaskedDistance(h, make([]bool, len(h.hash))); err != nil {
+		t.Fatalf("MaskedDistance: %v", err)
+	}
+	back, err := FromSnapshot(h.Snapshot())
+	if err != nil {
+		t.Fatalf("FromSnapshot(Snapshot()): %v", err)
+	}
+	if back.ToString() != h.ToString() {
+		t.Fatalf("snapshot round trip %s, want %s", back.ToString(), h.ToString())
+	}
+	if _, err := h.GobEncode(); err != nil {
+		t.Fatalf("GobEncode: %v", err)
+	}
+}
+
+func FuzzHexToHash(f *testing.F) {
+	for _, seed := range []string{"", "0", "f", "abc", "FFff00", "0x1f", " ab", "ab\n", "é", strings.Repeat("a", 16), strings.Repeat("7", MaxHashBits/4+1)} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		h, err := HexToHash(s)
+		if err != nil {
+			return
+		}
+		checkUsable(t, h)
+		if got := h.ToString(); got != strings.ToLower(s) {
+			t.Fatalf("ToString() = %q, want %q", got, strings.ToLower(s))
+		}
+	})
+}
+
+func FuzzParseEnsembleHashes(f *testing.F) {
+	for _, seed := range []string{"ens:", "ens:ahash/8x8/ffffffffffffffff", "ens:ahash/1x3/f", "ens:dhash/2x2/a,phash/1x4/0", "ens:ahash/3037000500x3037000500/0", "ens:ahash/8x8x/00"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		hashes, err := ParseEnsembleHashes(s)
+		if err != nil {
+			return
+		}
+		for _, h := range hashes {
+			checkUsable(t, h)
+		}
+		again, err := ParseEnsembleHashes(hashes.String())
+		if err != nil {
+			t.Fatalf("reparsing %q: %v", hashes.String(), err)
+		}
+		if again.String() != hashes.String() {
+			t.Fatalf("round trip %q, want %q", again.String(), hashes.String())
+		}
+	})
+}
+
+func FuzzFromSnapshot(f *testing.F) {
+	f.Add(8, 8, []byte{1, 2, 3, 4, 5, 6, 7, 8}, "ahash")
+	f.Add(1, 3, []byte{0xe0}, "")
+	f.Add(1, 3, []byte{0xf0}, "")
+	f.Add(1<<32, 1<<32, []byte{}, "dhash")
+	f.Fuzz(func(t *testing.T, rows, cols int, bits []byte, kind string) {
+		h, err := FromSnapshot(HashSnapshot{Rows: rows, Cols: cols, Bits: bits, Kind: kind})
+		if err != nil {
+			return
+		}
+		checkUsable(t, h)
+	})
+}
@@ -0,0 +1,351 @@
+// Command golden regenerates testdata/golden/golden.json, the expected hashes
+// of every corpus image for every algorithm and parameter combination.
+//
+// It is run through go generate from the repository root:
+//
+//	go generate ./...
+//
+// When a Python interpreter with the imagehash package is available, its
+// results are recorded in a separate column so parity drift is visible.
+// Without Python, previously recorded Python values are kept, unless
+// -require-python is given: then the run fails, so that a regeneration
+// meant to refresh the column cannot silently skip it.
+//
+// It also writes selftest.json, the stage outputs SelfTest embeds, from
+// SelfTestData of the current build.
+//
+// The -corpus flag rewrites the synthetic corpus images themselves; it is
+// only needed when the corpus changes.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+	"github.com/disintegration/imaging"
+)
+
+// Entry is one golden hash. The test in the root package reads the same
+// JSON layout.
+type Entry struct {
+	Image          string `json:"image"`
+	Kind           string `json:"kind"`
+	HashSize       int    `json:"hash_size"`
+	HighfreqFactor int    `json:"highfreq_factor,omitempty"`
+	Go             string `json:"go"`
+	Python         string `json:"python,omitempty"`
+}
+
+var (
+	kinds          = []imagehashgo.HashKind{imagehashgo.KindAverage, imagehashgo.KindPerceptual, imagehashgo.KindDifference, imagehashgo.KindDifferenceVertical}
+	hashSizes      = []int{4, 8, 16}
+	highfreqFactor = []int{4, 8}
+)
+
+func main() {
+	dir := flag.String("dir", "testdata/golden", "corpus directory")
+	python := flag.String("python", "python3", "Python interpreter with imagehash installed (empty to skip)")
+	requirePython := flag.Bool("require-python", false, "fail when Python imagehash is unavailable instead of keeping the recorded values")
+	corpus := flag.Bool("corpus", false, "rewrite the synthetic corpus images")
+	selftest := flag.String("selftest", "selftest.json", "expected SelfTest values to write (empty to skip)")
+	flag.Parse()
+
+	if *selftest != "" {
+		data, err := imagehashgo.SelfTestData()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*selftest, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *corpus {
+		if err := writeCorpus(*dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	entries, err := computeGo(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pyValues, err := computePython(*python, *dir, entries)
+	if err != nil && *requirePython {
+		log.Fatalf("golden: Python imagehash unavailable: %v", err)
+	}
+	if err != nil {
+		log.Printf("golden: Python imagehash unavailable, keeping recorded values: %v", err)
+		pyValues = readPrevious(filepath.Join(*dir, "golden.json"))
+	}
+	for i := range entries {
+		entries[i].Python = pyValues[key(entries[i])]
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "golden.json"), append(data, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func key(e Entry) string {
+	return fmt.Sprintf("%s/%s/%d/%d", e.Image, e.Kind, e.HashSize, e.HighfreqFactor)
+}
+
+// corpusImages lists the image files of the corpus in a stable order
+func corpusImages(dir string) ([]string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		switch strings.ToLower(filepath.Ext(f.Name())) {
+		case ".png", ".jpg", ".gif":
+			names = append(names, f.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+func computeGo(dir string) ([]Entry, error) {
+	names, err := corpusImages(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, name := range names {
+		img, err := imaging.Open(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range kinds {
+			for _, size := range hashSizes {
+				if kind != imagehashgo.KindPerceptual {
+					h, _ := imagehashgo.Hash(img, kind, size)
+					entries = append(entries, Entry{Image: name, Kind: string(kind), HashSize: size, Go: h.ToString()})
+					continue
+				}
+				for _, f := range highfreqFactor {
+					h := imagehashgo.PerceptualHash(img, size, f)
+					entries = append(entries, Entry{Image: name, Kind: string(kind), HashSize: size, HighfreqFactor: f, Go: h.ToString()})
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+// pythonScript reads JSON entries on stdin and prints "key value" lines
+const pythonScript = `
+import json, sys
+import imagehash
+from PIL import Image
+
+funcs = {
+    "ahash": lambda img, e: imagehash.average_hash(img, e["hash_size"]),
+    "phash": lambda img, e: imagehash.phash(img, e["hash_size"], e["highfreq_factor"]),
+    "dhash": lambda img, e: imagehash.dhash(img, e["hash_size"]),
+    "dhash_v": lambda img, e: imagehash.dhash_vertical(img, e["hash_size"]),
+}
+directory = sys.argv[1]
+for e in json.load(sys.stdin):
+    img = Image.open(directory + "/" + e["image"])
+    print(e["key"], funcs[e["kind"]](img, e))
+`
+
+func computePython(python, dir string, entries []Entry) (map[string]string, error) {
+	if python == "" {
+		return nil, fmt.Errorf("disabled")
+	}
+
+	type request struct {
+		Entry
+		Key string `json:"key"`
+	}
+	reqs := make([]request, len(entries))
+	for i, e := range entries {
+		reqs[i] = request{e, key(e)}
+	}
+	input, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(python, "-c", pythonScript, dir)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for line := range strings.Lines(string(out)) {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+func readPrevious(path string) map[string]string {
+	values := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return values
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return values
+	}
+	for _, e := range entries {
+		if e.Python != "" {
+			values[key(e)] = e.Python
+		}
+	}
+	return values
+}
+
+// writeCorpus writes the synthetic corpus: small images covering line art,
+// gradients, transparency, tiny and extreme-aspect sizes, noise, a palette
+// image and a downscaled photo derived from the repository's image.png
+func writeCorpus(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	lineArt := image.NewGray(image.Rect(0, 0, 96, 96))
+	draw.Draw(lineArt, lineArt.Bounds(), image.White, image.Point{}, draw.Src)
+	for i := 10; i < 86; i++ {
+		lineArt.SetGray(i, i, color.Gray{})
+		lineArt.SetGray(i, 95-i, color.Gray{})
+		lineArt.SetGray(i, 20, color.Gray{})
+		lineArt.SetGray(70, i, color.Gray{})
+	}
+
+	gradient := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := range 48 {
+		for x := range 64 {
+			gradient.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8(y * 5), uint8(255 - x*2), 255})
+		}
+	}
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			dx, dy := x-32, y-32
+			if dx*dx+dy*dy < 24*24 {
+				transparent.SetNRGBA(x, y, color.NRGBA{200, 30, 30, uint8(64 + x*3)})
+			}
+		}
+	}
+
+	tiny := image.NewRGBA(image.Rect(0, 0, 5, 4))
+	for i := range tiny.Pix {
+		tiny.Pix[i] = uint8(i * 13)
+	}
+	for i := 3; i < len(tiny.Pix); i += 4 {
+		tiny.Pix[i] = 255
+	}
+
+	noise := image.NewGray(image.Rect(0, 0, 48, 48))
+	r := rand.New(rand.NewSource(42))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(r.Intn(256))
+	}
+
+	checker := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			if (x/8+y/8)%2 == 0 {
+				checker.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	text := image.NewGray(image.Rect(0, 0, 120, 40))
+	draw.Draw(text, text.Bounds(), image.White, image.Point{}, draw.Src)
+	for word := range 6 {
+		x0 := 4 + word*19
+		for line := range 3 {
+			y0 := 5 + line*12
+			w := 8 + (word*7+line*3)%9
+			draw.Draw(text, image.Rect(x0, y0, x0+w, y0+6), image.Black, image.Point{}, draw.Src)
+		}
+	}
+
+	tall := image.NewGray(image.Rect(0, 0, 16, 200))
+	for y := range 200 {
+		for x := range 16 {
+			tall.SetGray(x, y, color.Gray{Y: uint8(y + x*3)})
+		}
+	}
+
+	palette := image.NewPaletted(image.Rect(0, 0, 64, 64), color.Palette{color.White, color.Black, color.RGBA{0, 90, 200, 255}, color.RGBA{240, 200, 0, 255}})
+	for y := range 64 {
+		for x := range 64 {
+			palette.SetColorIndex(x, y, uint8((x/16+y/21)%4))
+		}
+	}
+
+	pngs := map[string]image.Image{
+		"lineart.png":     lineArt,
+		"gradient.png":    gradient,
+		"transparent.png": transparent,
+		"tiny.png":        tiny,
+		"noise.png":       noise,
+		"checker.png":     checker,
+		"text.png":        text,
+		"tall.png":        tall,
+	}
+	for name, img := range pngs {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, palette, nil); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "palette.gif"), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	src, err := imaging.Open("image.png")
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	if err := jpeg.Encode(&buf, imaging.Resize(src, 128, 0, imaging.Lanczos), &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "photo.jpg"), buf.Bytes(), 0o644)
+}
@@ -0,0 +1,234 @@
+// Command moments regenerates moments/testdata/golden.json, the expected
+// color moment hashes of the corpus images and of their recompressed
+// copies.
+//
+// It is run through go generate from the repository root:
+//
+//	go generate ./...
+//
+// When a Python interpreter with OpenCV (cv2 with the contrib img_hash
+// module) is available, its ColorMomentHash results are recorded in a
+// separate column. Without it, previously recorded OpenCV values are kept.
+//
+// The -copies flag rewrites the recompressed copies themselves; it is only
+// needed when the corpus changes.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/K0ng2/imagehash-go/moments"
+)
+
+// Entry is one golden hash. The test in the moments package reads the same
+// JSON layout.
+type Entry struct {
+	// Image is the path of the image relative to the moments directory
+	Image string `json:"image"`
+	// Original is the image Image was recompressed from, if any
+	Original string    `json:"original,omitempty"`
+	Go       []float64 `json:"go"`
+	OpenCV   []float64 `json:"opencv,omitempty"`
+}
+
+// originals are the corpus images, relative to the moments directory. The
+// palette and transparent images are left out: their flat red and gray
+// areas have hues near 0, which JPEG noise flips to near 180, and the
+// resulting distances say more about the Hue channel than about the port.
+var originals = []string{
+	"../image.png",
+	"../testdata/golden/checker.png",
+	"../testdata/golden/gradient.png",
+	"../testdata/golden/lineart.png",
+	"../testdata/golden/noise.png",
+	"../testdata/golden/photo.jpg",
+	"../testdata/golden/text.png",
+}
+
+// copyQuality is the JPEG quality of the recompressed copies
+const copyQuality = 75
+
+func main() {
+	dir := flag.String("dir", ".", "moments package directory")
+	python := flag.String("python", "python3", "Python interpreter with OpenCV installed (empty to skip)")
+	copies := flag.Bool("copies", false, "rewrite the recompressed copies")
+	flag.Parse()
+
+	if *copies {
+		if err := writeCopies(*dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var entries []Entry
+	for _, orig := range originals {
+		entries = append(entries, Entry{Image: orig}, Entry{Image: copyPath(orig), Original: orig})
+	}
+	for i := range entries {
+		img, err := decode(filepath.Join(*dir, entries[i].Image))
+		if err != nil {
+			log.Fatal(err)
+		}
+		h, err := moments.ColorMomentHash(img)
+		if err != nil {
+			log.Fatalf("%s: %v", entries[i].Image, err)
+		}
+		entries[i].Go = h[:]
+	}
+
+	golden := filepath.Join(*dir, "testdata", "golden.json")
+	cvValues, err := computeOpenCV(*python, *dir, entries)
+	if err != nil {
+		log.Printf("moments: OpenCV unavailable, keeping recorded values: %v", err)
+		cvValues = readPrevious(golden)
+	}
+	for i := range entries {
+		entries[i].OpenCV = cvValues[entries[i].Image]
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(golden, append(data, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// copyPath returns where the recompressed copy of orig is stored
+func copyPath(orig string) string {
+	name := strings.TrimSuffix(filepath.Base(orig), filepath.Ext(orig))
+	return "testdata/recompressed/" + name + ".jpg"
+}
+
+func decode(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// writeCopies recompresses every original as a JPEG
+func writeCopies(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "testdata", "recompressed"), 0o755); err != nil {
+		return err
+	}
+	for _, orig := range originals {
+		img, err := decode(filepath.Join(dir, orig))
+		if err != nil {
+			return err
+		}
+		// Drop alpha first, as the hash does, rather than let the encoder
+		// composite onto black
+		opaque := image.NewNRGBA(img.Bounds())
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				c.A = 255
+				opaque.SetNRGBA(x, y, c)
+			}
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, opaque, &jpeg.Options{Quality: copyQuality}); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, copyPath(orig)), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pythonScript reads image paths on stdin and prints "path v0 ... v41"
+// lines. Images are decoded with Pillow, since older OpenCV builds cannot
+// read GIF, and passed to OpenCV in BGR order with alpha dropped.
+const pythonScript = `
+import json, os, sys
+import cv2
+import numpy as np
+from PIL import Image
+
+hasher = cv2.img_hash.ColorMomentHash_create()
+directory = sys.argv[1]
+for path in json.load(sys.stdin):
+    img = Image.open(os.path.join(directory, path)).convert("RGB")
+    bgr = np.ascontiguousarray(np.array(img)[:, :, ::-1])
+    print(path, " ".join(repr(float(v)) for v in hasher.compute(bgr).ravel()))
+`
+
+func computeOpenCV(python, dir string, entries []Entry) (map[string][]float64, error) {
+	if python == "" {
+		return nil, fmt.Errorf("disabled")
+	}
+
+	paths := make([]string, len(entries))
+	for i, e := range entries {
+		paths[i] = e.Image
+	}
+	input, err := json.Marshal(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(python, "-c", pythonScript, dir)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]float64)
+	for line := range strings.Lines(string(out)) {
+		fields := strings.Fields(line)
+		if len(fields) != moments.HashLen+1 {
+			return nil, fmt.Errorf("unexpected OpenCV output %q", line)
+		}
+		hash := make([]float64, moments.HashLen)
+		for i, f := range fields[1:] {
+			if hash[i], err = strconv.ParseFloat(f, 64); err != nil {
+				return nil, err
+			}
+		}
+		values[fields[0]] = hash
+	}
+	return values, nil
+}
+
+func readPrevious(path string) map[string][]float64 {
+	values := make(map[string][]float64)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return values
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return values
+	}
+	for _, e := range entries {
+		if len(e.OpenCV) > 0 {
+			values[e.Image] = e.OpenCV
+		}
+	}
+	return values
+}
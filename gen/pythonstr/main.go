@@ -0,0 +1,102 @@
+// Command pythonstr regenerates testdata/python/strings.json, the str()
+// of Python imagehash hashes of image.png for every algorithm
+// ParsePythonHash accepts and hash sizes 6 to 16, with the bits and shape
+// Python holds for each. TestParsePythonHash_Python parses every string
+// and checks that it gets the same bits and shape.
+//
+// It is run through go generate from the repository root:
+//
+//	go generate ./...
+//
+// It needs a Python interpreter with the imagehash package. Without one
+// the recorded fixture is kept, unless -require-python is given: then the
+// run fails.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Entry is one Python hash. The test in the root package reads the same
+// JSON layout.
+type Entry struct {
+	Algorithm string `json:"algorithm"`
+	HashSize  int    `json:"hash_size"`
+	Rows      int    `json:"rows"`
+	Cols      int    `json:"cols"`
+	// Str is str() of the hash
+	Str string `json:"str"`
+	// Bits is the hash array flattened in row-major order, as 0 and 1
+	Bits string `json:"bits"`
+}
+
+// pythonScript prints the entries for the image given as argument as JSON.
+// whash only takes powers of 2; colorhash takes binbits as its size.
+const pythonScript = `
+import json, sys
+import imagehash
+from PIL import Image
+
+img = Image.open(sys.argv[1])
+funcs = {
+    "average_hash": lambda n: imagehash.average_hash(img, n),
+    "phash": lambda n: imagehash.phash(img, n),
+    "phash_simple": lambda n: imagehash.phash_simple(img, n),
+    "dhash": lambda n: imagehash.dhash(img, n),
+    "dhash_vertical": lambda n: imagehash.dhash_vertical(img, n),
+    "whash": lambda n: imagehash.whash(img, n),
+    "colorhash": lambda n: imagehash.colorhash(img, n),
+}
+entries = []
+for name, f in funcs.items():
+    for n in range(6, 17):
+        if name == "whash" and n & (n - 1):
+            continue
+        h = f(n)
+        rows, cols = h.hash.shape
+        bits = "".join("1" if b else "0" for b in h.hash.flatten())
+        entries.append({"algorithm": name, "hash_size": n, "rows": rows, "cols": cols, "str": str(h), "bits": bits})
+json.dump(entries, sys.stdout)
+`
+
+func main() {
+	img := flag.String("image", "image.png", "image to hash")
+	out := flag.String("out", "testdata/python/strings.json", "fixture to write")
+	python := flag.String("python", "python3", "Python interpreter with imagehash installed")
+	requirePython := flag.Bool("require-python", false, "fail when Python imagehash is unavailable instead of keeping the recorded fixture")
+	flag.Parse()
+
+	cmd := exec.Command(*python, "-c", pythonScript, *img)
+	cmd.Stderr = os.Stderr
+	data, err := cmd.Output()
+	if err != nil {
+		if *requirePython {
+			log.Fatalf("pythonstr: Python imagehash unavailable: %v", err)
+		}
+		log.Printf("pythonstr: Python imagehash unavailable, keeping %s: %v", *out, err)
+		return
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
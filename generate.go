@@ -0,0 +1,5 @@
+package imagehashgo
+
+//go:generate go run ./gen/golden -dir testdata/golden
+//go:generate go run ./gen/calibration
+//go:generate go run ./gen/pythonstr
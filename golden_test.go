@@ -0,0 +1,103 @@
+package imagehashgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// goldenEntry mirrors the entries written by gen/golden
+type goldenEntry struct {
+	Image          string `json:"image"`
+	Kind           string `json:"kind"`
+	HashSize       int    `json:"hash_size"`
+	HighfreqFactor int    `json:"highfreq_factor"`
+	Go             string `json:"go"`
+	Python         string `json:"python"`
+}
+
+// pythonParityBits is the share of the bits, as a divisor, by which a hash
+// may differ from the recorded Python imagehash value: Pillow and imaging
+// resample slightly differently, which flips bits near the threshold
+const pythonParityBits = 8
+
+// TestGoldenCorpus recomputes every hash in testdata/golden/golden.json,
+// and checks the recorded Python imagehash values, where there are any,
+// within 1/pythonParityBits of the bits. Run `go generate` to refresh the
+// file after an intentional change, and `go run ./gen/golden
+// -require-python` with Python imagehash installed to refresh the Python
+// column.
+func TestGoldenCorpus(t *testing.T) {
+	dir := filepath.Join("testdata", "golden")
+	data, err := os.ReadFile(filepath.Join(dir, "golden.json"))
+	if err != nil {
+		t.Fatalf("reading golden.json: %v", err)
+	}
+	var entries []goldenEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("parsing golden.json: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("golden.json has no entries")
+	}
+
+	images := make(map[string]image.Image)
+	var pythonDrift, pythonValues int
+	for _, e := range entries {
+		img, ok := images[e.Image]
+		if !ok {
+			file, err := os.Open(filepath.Join(dir, e.Image))
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, _, err = image.Decode(file)
+			file.Close()
+			if err != nil {
+				t.Fatalf("decoding %s: %v", e.Image, err)
+			}
+			images[e.Image] = img
+		}
+
+		name := fmt.Sprintf("%s/%s/%d/%d", e.Image, e.Kind, e.HashSize, e.HighfreqFactor)
+		t.Run(name, func(t *testing.T) {
+			var h *ImageHash
+			if HashKind(e.Kind) == KindPerceptual {
+				h = PerceptualHash(img, e.HashSize, e.HighfreqFactor)
+			} else {
+				h, err = Hash(img, HashKind(e.Kind), e.HashSize)
+				if err != nil {
+					t.Fatalf("Hash() error = %v", err)
+				}
+			}
+			if got := h.ToString(); got != e.Go {
+				t.Errorf("got %s, want %s", got, e.Go)
+			}
+			if e.Python == "" {
+				return
+			}
+			py, err := HexToHashShape(e.Python, h.rows, h.cols)
+			if err != nil {
+				t.Fatalf("Python value: %v", err)
+			}
+			if d, _ := h.Distance(py); d > len(h.hash)/pythonParityBits {
+				t.Errorf("%d bits from Python imagehash %s, at most %d allowed", d, e.Python, len(h.hash)/pythonParityBits)
+			}
+		})
+		if e.Python != "" {
+			pythonValues++
+			if e.Python != e.Go {
+				pythonDrift++
+			}
+		}
+	}
+	if pythonValues == 0 {
+		t.Log("golden.json has no Python imagehash values; cross-implementation parity is unchecked")
+	} else if pythonDrift > 0 {
+		t.Logf("%d of %d Python imagehash values differ from the Go hashes", pythonDrift, pythonValues)
+	}
+}
@@ -18,7 +18,7 @@ func ToGrayscale(img image.Image) *image.Gray {
 	bounds := img.Bounds()
 	grayImg := image.NewGray(bounds)
 
-	numCPUs := runtime.NumCPU()
+	numCPUs := runtime.GOMAXPROCS(0)
 	if numCPUs > 1 && bounds.Dy() > numCPUs {
 		var wg sync.WaitGroup
 		rowsPerWorker := bounds.Dy() / numCPUs
@@ -59,10 +59,41 @@ func ToGrayscale(img image.Image) *image.Gray {
 	return grayImg
 }
 
+// ParallelGrayscaleThreshold is the pixel count above which ToGrayscaleFast
+// splits the conversion across GOMAXPROCS goroutines. Below it the
+// goroutine fan-out costs more than it saves.
+//
+// BenchmarkGrayscaleCrossover measures a fixed fan-out cost of roughly
+// 5-20µs against a serial cost of about 10ns per pixel, so two workers
+// break even near 64x64 and the break-even point grows with the worker
+// count; the default of 128x128 keeps small images serial on machines with
+// up to ~16 workers. Run the benchmark with -cpu to tune it for a specific
+// machine, and set it before hashing concurrently, or use
+// WithParallelGrayscaleThreshold for a single call.
+var ParallelGrayscaleThreshold = 128 * 128
+
 // ToGrayscaleFast is an optimized version with type-specific fast paths
-// For small images (< 64x64), it avoids goroutine overhead
+// For small images (<= ParallelGrayscaleThreshold pixels), it avoids
+// goroutine overhead
 func ToGrayscaleFast(img image.Image) *image.Gray {
+	return toGrayscaleFast(img, ParallelGrayscaleThreshold)
+}
+
+// toGrayscaleFast is ToGrayscaleFast with an explicit parallelism threshold
+func toGrayscaleFast(img image.Image, parallelThreshold int) *image.Gray {
+	return convertGrayscale(img, parallelThreshold, nil, nil)
+}
+
+// convertGrayscale is toGrayscaleFast also counting the ColorSig bins of
+// the pixels into hist when it is not nil. The row processors test hist
+// once per row, so the plain conversion keeps its inner loops. Once done is
+// closed the conversion stops at the next chunk of rows, leaving the rest
+// black.
+func convertGrayscale(img image.Image, parallelThreshold int, hist *colorHist, done <-chan struct{}) *image.Gray {
 	if gray, ok := img.(*image.Gray); ok {
+		if hist != nil {
+			processGrayHist(gray, hist)
+		}
 		return gray
 	}
 
@@ -72,58 +103,78 @@ func ToGrayscaleFast(img image.Image) *image.Gray {
 	grayImg := image.NewGray(bounds)
 
 	// For small images, avoid goroutine overhead
-	useParallel := width*height > 64*64 && runtime.NumCPU() > 1
+	useParallel := width*height > parallelThreshold && runtime.GOMAXPROCS(0) > 1
 
 	// Type-specific optimizations
+	var rows func(y0, y1 int, hist *colorHist)
 	switch typedImg := img.(type) {
 	case *image.YCbCr:
-		if useParallel {
-			processYCbCrParallel(typedImg, grayImg)
-		} else {
-			processYCbCr(typedImg, grayImg)
-		}
+		rows = func(y0, y1 int, hist *colorHist) { processYCbCrRows(typedImg, grayImg, y0, y1, hist) }
 	case *image.RGBA:
-		if useParallel {
-			processRGBAParallel(typedImg, grayImg)
+		// Opaque stops at the first translucent pixel, so mixed-alpha images
+		// pay little for the check and opaque ones skip the un-premultiply
+		if hist == nil && typedImg.Opaque() {
+			rows = func(y0, y1 int, _ *colorHist) {
+				processOpaqueRows(typedImg.Pix, typedImg.Stride, bounds, grayImg, y0, y1)
+			}
 		} else {
-			processRGBA(typedImg, grayImg)
+			rows = func(y0, y1 int, hist *colorHist) { processRGBARows(typedImg, grayImg, y0, y1, hist) }
 		}
 	case *image.NRGBA:
-		if useParallel {
-			processNRGBAParallel(typedImg, grayImg)
+		if hist == nil && typedImg.Opaque() {
+			rows = func(y0, y1 int, _ *colorHist) {
+				processOpaqueRows(typedImg.Pix, typedImg.Stride, bounds, grayImg, y0, y1)
+			}
 		} else {
-			processNRGBA(typedImg, grayImg)
+			rows = func(y0, y1 int, hist *colorHist) { processNRGBARows(typedImg, grayImg, y0, y1, hist) }
 		}
+	case GrayRowReader:
+		// Gray rows carry no color, so the signature needs At
+		if hist != nil || readGrayRows(typedImg, bounds, grayImg) != nil {
+			processGenericRows(img, grayImg, bounds.Min.Y, bounds.Max.Y, hist)
+		}
+		return grayImg
+	case image.RGBA64Image:
+		rows = func(y0, y1 int, hist *colorHist) { processRGBA64Rows(typedImg, grayImg, y0, y1, hist) }
 	default:
 		// Fallback to generic interface
-		if useParallel {
-			processGenericParallel(img, grayImg)
-		} else {
-			processGeneric(img, grayImg)
-		}
+		rows = func(y0, y1 int, hist *colorHist) { processGenericRows(img, grayImg, y0, y1, hist) }
 	}
 
+	if done != nil {
+		rows = cancelableRows(rows, done)
+	}
+	if useParallel {
+		inParallelHist(bounds, hist, rows)
+	} else {
+		rows(bounds.Min.Y, bounds.Max.Y, hist)
+	}
 	return grayImg
 }
 
-// Type-specific processors for YCbCr (common in JPEG)
-func processYCbCr(src *image.YCbCr, dst *image.Gray) {
-	bounds := src.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := src.YCbCrAt(x, y).RGBA()
-			dst.Pix[(y-bounds.Min.Y)*dst.Stride+(x-bounds.Min.X)] = rgbaToGray(r, g, b, a)
+// cancelRows is how many rows cancelableRows converts between checks
+const cancelRows = 32
+
+// cancelableRows runs rows in chunks of cancelRows rows, returning once
+// done is closed
+func cancelableRows(rows func(y0, y1 int, hist *colorHist), done <-chan struct{}) func(y0, y1 int, hist *colorHist) {
+	return func(y0, y1 int, hist *colorHist) {
+		for y := y0; y < y1; y += cancelRows {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			rows(y, min(y+cancelRows, y1), hist)
 		}
 	}
 }
 
-func processYCbCrParallel(src *image.YCbCr, dst *image.Gray) {
-	bounds := src.Bounds()
-	numCPUs := runtime.NumCPU()
-	rowsPerWorker := bounds.Dy() / numCPUs
-	if rowsPerWorker == 0 {
-		rowsPerWorker = 1
-	}
+// inParallel splits the rows of bounds into one band per GOMAXPROCS and
+// calls rows for each band concurrently
+func inParallel(bounds image.Rectangle, rows func(y0, y1 int)) {
+	numCPUs := runtime.GOMAXPROCS(0)
+	rowsPerWorker := max(bounds.Dy()/numCPUs, 1)
 
 	var wg sync.WaitGroup
 	for i := range numCPUs {
@@ -139,145 +190,200 @@ func processYCbCrParallel(src *image.YCbCr, dst *image.Gray) {
 		wg.Add(1)
 		go func(sY, eY int) {
 			defer wg.Done()
-			for y := sY; y < eY; y++ {
-				for x := bounds.Min.X; x < bounds.Max.X; x++ {
-					r, g, b, a := src.YCbCrAt(x, y).RGBA()
-					dst.Pix[(y-bounds.Min.Y)*dst.Stride+(x-bounds.Min.X)] = rgbaToGray(r, g, b, a)
-				}
-			}
+			rows(sY, eY)
 		}(startY, endY)
 	}
 	wg.Wait()
 }
 
-// Type-specific processors for RGBA
-func processRGBA(src *image.RGBA, dst *image.Gray) {
-	bounds := src.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			r, g, b, a := src.RGBAAt(x, y).RGBA()
-			dst.Pix[(y-bounds.Min.Y)*dst.Stride+(x-bounds.Min.X)] = rgbaToGray(r, g, b, a)
-		}
+// inParallelHist is inParallel for row processors that count into a
+// histogram: each band counts into its own and they are summed into hist
+func inParallelHist(bounds image.Rectangle, hist *colorHist, rows func(y0, y1 int, hist *colorHist)) {
+	if hist == nil {
+		inParallel(bounds, func(y0, y1 int) { rows(y0, y1, nil) })
+		return
 	}
+	var mu sync.Mutex
+	inParallel(bounds, func(y0, y1 int) {
+		var band colorHist
+		rows(y0, y1, &band)
+		mu.Lock()
+		hist.add(&band)
+		mu.Unlock()
+	})
 }
 
-func processRGBAParallel(src *image.RGBA, dst *image.Gray) {
-	bounds := src.Bounds()
-	numCPUs := runtime.NumCPU()
-	rowsPerWorker := bounds.Dy() / numCPUs
-	if rowsPerWorker == 0 {
-		rowsPerWorker = 1
+// chromaDivisors returns the horizontal and vertical chroma subsampling
+// factors of ratio, or ok false for a ratio this package does not know
+func chromaDivisors(ratio image.YCbCrSubsampleRatio) (dx, dy int, ok bool) {
+	switch ratio {
+	case image.YCbCrSubsampleRatio444:
+		return 1, 1, true
+	case image.YCbCrSubsampleRatio422:
+		return 2, 1, true
+	case image.YCbCrSubsampleRatio420:
+		return 2, 2, true
+	case image.YCbCrSubsampleRatio440:
+		return 1, 2, true
+	case image.YCbCrSubsampleRatio411:
+		return 4, 1, true
+	case image.YCbCrSubsampleRatio410:
+		return 4, 2, true
+	default:
+		return 0, 0, false
 	}
+}
 
-	var wg sync.WaitGroup
-	for i := range numCPUs {
-		startY := bounds.Min.Y + i*rowsPerWorker
-		endY := startY + rowsPerWorker
-		if i == numCPUs-1 {
-			endY = bounds.Max.Y
+// processYCbCrRows converts rows [y0, y1) reading the planes directly. The
+// chroma index mirrors image.YCbCr.COffset, including its truncating
+// division for negative coordinates; unknown ratios use YCbCrAt.
+func processYCbCrRows(src *image.YCbCr, dst *image.Gray, y0, y1 int, hist *colorHist) {
+	r := src.Rect
+	dx, dy, ok := chromaDivisors(src.SubsampleRatio)
+	for y := y0; y < y1; y++ {
+		out := dst.Pix[(y-r.Min.Y)*dst.Stride : (y-r.Min.Y)*dst.Stride+r.Dx()]
+		if !ok {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				cr, cg, cb, ca := src.YCbCrAt(x, y).RGBA()
+				out[x-r.Min.X] = hist.gray(cr, cg, cb, ca)
+			}
+			continue
 		}
-		if startY >= bounds.Max.Y {
-			break
+		yRow := src.Y[(y-r.Min.Y)*src.YStride:]
+		cRow := (y/dy - r.Min.Y/dy) * src.CStride
+		if hist != nil {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				ci := cRow + x/dx - r.Min.X/dx
+				c := color.YCbCr{Y: yRow[x-r.Min.X], Cb: src.Cb[ci], Cr: src.Cr[ci]}
+				cr, cg, cb, ca := c.RGBA()
+				out[x-r.Min.X] = hist.gray(cr, cg, cb, ca)
+			}
+			continue
+		}
+		// YCbCr is always opaque
+		for x := r.Min.X; x < r.Max.X; x++ {
+			ci := cRow + x/dx - r.Min.X/dx
+			c := color.YCbCr{Y: yRow[x-r.Min.X], Cb: src.Cb[ci], Cr: src.Cr[ci]}
+			cr, cg, cb, _ := c.RGBA()
+			out[x-r.Min.X] = opaqueGray(uint8(cr>>8), uint8(cg>>8), uint8(cb>>8))
 		}
+	}
+}
 
-		wg.Add(1)
-		go func(sY, eY int) {
-			defer wg.Done()
-			for y := sY; y < eY; y++ {
-				for x := bounds.Min.X; x < bounds.Max.X; x++ {
-					r, g, b, a := src.RGBAAt(x, y).RGBA()
-					dst.Pix[(y-bounds.Min.Y)*dst.Stride+(x-bounds.Min.X)] = rgbaToGray(r, g, b, a)
-				}
+// Type-specific processors for RGBA
+func processRGBARows(src *image.RGBA, dst *image.Gray, y0, y1 int, hist *colorHist) {
+	bounds := src.Bounds()
+	for y := y0; y < y1; y++ {
+		row := dst.Pix[(y-bounds.Min.Y)*dst.Stride:]
+		if hist != nil {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, a := src.RGBAAt(x, y).RGBA()
+				row[x-bounds.Min.X] = hist.gray(r, g, b, a)
 			}
-		}(startY, endY)
+			continue
+		}
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := src.RGBAAt(x, y).RGBA()
+			row[x-bounds.Min.X] = rgbaToGray(r, g, b, a)
+		}
 	}
-	wg.Wait()
 }
 
 // Type-specific processors for NRGBA (created by imaging library)
-func processNRGBA(src *image.NRGBA, dst *image.Gray) {
+func processNRGBARows(src *image.NRGBA, dst *image.Gray, y0, y1 int, hist *colorHist) {
 	bounds := src.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+	for y := y0; y < y1; y++ {
+		row := dst.Pix[(y-bounds.Min.Y)*dst.Stride:]
+		if hist != nil {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, a := src.NRGBAAt(x, y).RGBA()
+				row[x-bounds.Min.X] = hist.gray(r, g, b, a)
+			}
+			continue
+		}
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			r, g, b, a := src.NRGBAAt(x, y).RGBA()
-			dst.Pix[(y-bounds.Min.Y)*dst.Stride+(x-bounds.Min.X)] = rgbaToGray(r, g, b, a)
+			row[x-bounds.Min.X] = rgbaToGray(r, g, b, a)
 		}
 	}
 }
 
-func processNRGBAParallel(src *image.NRGBA, dst *image.Gray) {
-	bounds := src.Bounds()
-	numCPUs := runtime.NumCPU()
-	rowsPerWorker := bounds.Dy() / numCPUs
-	if rowsPerWorker == 0 {
-		rowsPerWorker = 1
+// processOpaqueRows converts rows [y0, y1) of an opaque RGBA or NRGBA
+// image from its pixel bytes. With every alpha at 255 the premultiplied
+// and non-premultiplied values are the same and rgbaToGray takes neither
+// its alpha branch nor its divides, so the channels feed opaqueGray as is.
+func processOpaqueRows(pix []uint8, stride int, bounds image.Rectangle, dst *image.Gray, y0, y1 int) {
+	w := bounds.Dx()
+	for y := y0; y < y1; y++ {
+		src := pix[(y-bounds.Min.Y)*stride : (y-bounds.Min.Y)*stride+4*w]
+		out := dst.Pix[(y-bounds.Min.Y)*dst.Stride : (y-bounds.Min.Y)*dst.Stride+w]
+		for x := range out {
+			out[x] = opaqueGray(src[4*x], src[4*x+1], src[4*x+2])
+		}
 	}
+}
 
-	var wg sync.WaitGroup
-	for i := range numCPUs {
-		startY := bounds.Min.Y + i*rowsPerWorker
-		endY := startY + rowsPerWorker
-		if i == numCPUs-1 {
-			endY = bounds.Max.Y
-		}
-		if startY >= bounds.Max.Y {
-			break
-		}
+// GrayRowReader is implemented by images with a bulk reader, such as tiled
+// or RAW decoders whose At is slow. ReadGrayRow fills dst, of length
+// Bounds().Dx(), with row y converted as ToGrayscale would. ToGrayscaleFast
+// calls it once per row, in order, instead of At; if it fails, the image is
+// converted through At instead.
+type GrayRowReader interface {
+	ReadGrayRow(y int, dst []uint8) error
+}
 
-		wg.Add(1)
-		go func(sY, eY int) {
-			defer wg.Done()
-			for y := sY; y < eY; y++ {
-				for x := bounds.Min.X; x < bounds.Max.X; x++ {
-					r, g, b, a := src.NRGBAAt(x, y).RGBA()
-					dst.Pix[(y-bounds.Min.Y)*dst.Stride+(x-bounds.Min.X)] = rgbaToGray(r, g, b, a)
-				}
-			}
-		}(startY, endY)
+func readGrayRows(src GrayRowReader, bounds image.Rectangle, dst *image.Gray) error {
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := dst.Pix[(y-bounds.Min.Y)*dst.Stride : (y-bounds.Min.Y)*dst.Stride+bounds.Dx()]
+		if err := src.ReadGrayRow(y, row); err != nil {
+			return err
+		}
 	}
-	wg.Wait()
+	return nil
 }
 
-// Generic processor using interface
-func processGeneric(src image.Image, dst *image.Gray) {
+// processRGBA64Rows converts rows [y0, y1) through RGBA64At, which returns
+// the same values as At(x, y).RGBA() without allocating a color.Color per
+// pixel. Most image types implement it, including Paletted, Gray16, CMYK
+// and image/draw's own fast paths.
+func processRGBA64Rows(src image.RGBA64Image, dst *image.Gray, y0, y1 int, hist *colorHist) {
 	bounds := src.Bounds()
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+	for y := y0; y < y1; y++ {
+		row := dst.Pix[(y-bounds.Min.Y)*dst.Stride:]
+		if hist != nil {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := src.RGBA64At(x, y)
+				row[x-bounds.Min.X] = hist.gray(uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A))
+			}
+			continue
+		}
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
-			processPixel(src, dst, x, y)
+			c := src.RGBA64At(x, y)
+			row[x-bounds.Min.X] = rgbaToGray(uint32(c.R), uint32(c.G), uint32(c.B), uint32(c.A))
 		}
 	}
 }
 
-func processGenericParallel(src image.Image, dst *image.Gray) {
+func processGeneric(src image.Image, dst *image.Gray) {
 	bounds := src.Bounds()
-	numCPUs := runtime.NumCPU()
-	rowsPerWorker := bounds.Dy() / numCPUs
-	if rowsPerWorker == 0 {
-		rowsPerWorker = 1
-	}
+	processGenericRows(src, dst, bounds.Min.Y, bounds.Max.Y, nil)
+}
 
-	var wg sync.WaitGroup
-	for i := range numCPUs {
-		startY := bounds.Min.Y + i*rowsPerWorker
-		endY := startY + rowsPerWorker
-		if i == numCPUs-1 {
-			endY = bounds.Max.Y
+func processGenericRows(src image.Image, dst *image.Gray, y0, y1 int, hist *colorHist) {
+	bounds := src.Bounds()
+	for y := y0; y < y1; y++ {
+		if hist != nil {
+			row := dst.Pix[(y-bounds.Min.Y)*dst.Stride:]
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, a := src.At(x, y).RGBA()
+				row[x-bounds.Min.X] = hist.gray(r, g, b, a)
+			}
+			continue
 		}
-		if startY >= bounds.Max.Y {
-			break
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			processPixel(src, dst, x, y)
 		}
-
-		wg.Add(1)
-		go func(sY, eY int) {
-			defer wg.Done()
-			for y := sY; y < eY; y++ {
-				for x := bounds.Min.X; x < bounds.Max.X; x++ {
-					processPixel(src, dst, x, y)
-				}
-			}
-		}(startY, endY)
 	}
-	wg.Wait()
 }
 
 // rgbaToGray converts RGBA values to grayscale using the correct formula
@@ -301,6 +407,11 @@ func rgbaToGray(r, g, b, a uint32) uint8 {
 	return uint8(l)
 }
 
+// opaqueGray is rgbaToGray for an opaque pixel given as 8-bit channels
+func opaqueGray(r, g, b uint8) uint8 {
+	return uint8((uint32(r)*299 + uint32(g)*587 + uint32(b)*114 + 500) / 1000)
+}
+
 func processPixel(img image.Image, grayImg *image.Gray, x, y int) {
 	r, g, b, a := img.At(x, y).RGBA()
 	// RGBA returns values in [0, 65535] and they are alpha-premultiplied.
@@ -323,3 +434,26 @@ func processPixel(img image.Image, grayImg *image.Gray, x, y int) {
 	l := (r8*299 + g8*587 + b8*114 + 500) / 1000
 	grayImg.SetGray(x, y, color.Gray{Y: uint8(l)})
 }
+
+// GrayscalePath reports which conversion ToGrayscaleFast uses for img:
+// "gray" (no conversion), "ycbcr", "rgba" or "nrgba" for the type-specific
+// fast paths, "grayrow" for a GrayRowReader, "rgba64" for other
+// image.RGBA64Image types, or "generic" for the slower At fallback
+func GrayscalePath(img image.Image) string {
+	switch img.(type) {
+	case *image.Gray:
+		return "gray"
+	case *image.YCbCr:
+		return "ycbcr"
+	case *image.RGBA:
+		return "rgba"
+	case *image.NRGBA:
+		return "nrgba"
+	case GrayRowReader:
+		return "grayrow"
+	case image.RGBA64Image:
+		return "rgba64"
+	default:
+		return "generic"
+	}
+}
@@ -0,0 +1,291 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/K0ng2/imagehash-go/internal/benchdata"
+	"github.com/K0ng2/imagehash-go/internal/conformance"
+)
+
+// grayscaleInputs returns one image of each type with a fast path
+func grayscaleInputs(w, h int) map[string]image.Image {
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	nrgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	rgba64 := image.NewRGBA64(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.NRGBA{uint8(x * 7), uint8(y * 5), uint8(x ^ y), uint8(128 + (x+y)%128)}
+			rgba.Set(x, y, c)
+			nrgba.SetNRGBA(x, y, c)
+			rgba64.Set(x, y, c)
+		}
+	}
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i * 3)
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i] = uint8(i * 5)
+		ycbcr.Cr[i] = uint8(255 - i)
+	}
+	return map[string]image.Image{"RGBA": rgba, "NRGBA": nrgba, "YCbCr": ycbcr, "RGBA64": rgba64, "generic": genericImage{rgba64}}
+}
+
+func TestToGrayscaleFast_SerialAndParallelAgree(t *testing.T) {
+	// Force real fan-out even on single-CPU machines
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	for name, img := range grayscaleInputs(97, 61) {
+		t.Run(name, func(t *testing.T) {
+			serial := toGrayscaleFast(img, math.MaxInt)
+			parallel := toGrayscaleFast(img, 0)
+			if !bytes.Equal(serial.Pix, parallel.Pix) {
+				t.Error("serial and parallel grayscale differ")
+			}
+
+			h1 := AverageHash(img, 8, WithParallelGrayscaleThreshold(math.MaxInt))
+			h2 := AverageHash(img, 8, WithParallelGrayscaleThreshold(0))
+			if h1.ToString() != h2.ToString() {
+				t.Errorf("hash differs by path: %s vs %s", h1.ToString(), h2.ToString())
+			}
+		})
+	}
+}
+
+var grayscaleBenchSizes = []int{32, 64, 128, 256, 512, 1024}
+
+// BenchmarkGrayscalePaths is the size x path matrix; vary the worker count
+// with -cpu, e.g. go test -bench GrayscalePaths -cpu 1,2,4,8
+func BenchmarkGrayscalePaths(b *testing.B) {
+	for _, size := range grayscaleBenchSizes {
+		img := grayscaleInputs(size, size)["RGBA"]
+		for _, path := range []struct {
+			name      string
+			threshold int
+		}{{"serial", math.MaxInt}, {"parallel", 0}} {
+			b.Run(fmt.Sprintf("size=%d/%s", size, path.name), func(b *testing.B) {
+				for b.Loop() {
+					toGrayscaleFast(img, path.threshold)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkGrayscaleCrossover logs the smallest benchmarked size at which
+// the parallel path beats the serial one at the current GOMAXPROCS
+func BenchmarkGrayscaleCrossover(b *testing.B) {
+	for b.Loop() {
+		crossover := "never"
+		for _, size := range grayscaleBenchSizes {
+			img := grayscaleInputs(size, size)["RGBA"]
+			serial := timePerOp(func() { toGrayscaleFast(img, math.MaxInt) })
+			parallel := timePerOp(func() { toGrayscaleFast(img, 0) })
+			b.Logf("GOMAXPROCS=%d size=%dx%d serial=%v parallel=%v", runtime.GOMAXPROCS(0), size, size, serial, parallel)
+			if crossover == "never" && parallel < serial {
+				crossover = fmt.Sprintf("%dx%d", size, size)
+			}
+		}
+		b.Logf("GOMAXPROCS=%d parallel grayscale pays off from %s", runtime.GOMAXPROCS(0), crossover)
+	}
+}
+
+// timePerOp runs f repeatedly for at least 50ms and returns the mean duration
+func timePerOp(f func()) time.Duration {
+	f() // warm up
+	var n int
+	start := time.Now()
+	for time.Since(start) < 50*time.Millisecond {
+		f()
+		n++
+	}
+	return time.Since(start) / time.Duration(n)
+}
+
+func TestGrayscalePath(t *testing.T) {
+	inputs := grayscaleInputs(4, 4)
+	inputs["gray"] = image.NewGray(image.Rect(0, 0, 4, 4))
+	inputs["grayrow"] = newSlowImage(4, 4, -1)
+	want := map[string]string{"RGBA": "rgba", "NRGBA": "nrgba", "YCbCr": "ycbcr", "RGBA64": "rgba64", "generic": "generic", "gray": "gray", "grayrow": "grayrow"}
+	for name, img := range inputs {
+		if got := GrayscalePath(img); got != want[name] {
+			t.Errorf("GrayscalePath(%s) = %q, want %q", name, got, want[name])
+		}
+	}
+}
+
+func TestYCbCrPlaneOffsets(t *testing.T) {
+	ratios := []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440, image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410,
+		// Unknown ratios fall back to YCbCrAt
+		image.YCbCrSubsampleRatio(99),
+	}
+	// Odd offsets put the image origin inside a chroma sample. Negative
+	// coordinates are left out: YCbCrAt itself indexes out of range there.
+	bounds := []image.Rectangle{
+		image.Rect(3, 1, 40, 26),
+		image.Rect(7, 5, 30, 22),
+	}
+	for _, ratio := range ratios {
+		var images []*image.YCbCr
+		for _, r := range bounds {
+			images = append(images, conformance.FillYCbCr(image.NewYCbCr(r, ratio)))
+		}
+		images = append(images, conformance.FillYCbCr(image.NewYCbCr(image.Rect(0, 0, 48, 32), ratio)).SubImage(image.Rect(5, 3, 42, 30)).(*image.YCbCr))
+
+		for _, img := range images {
+			r := img.Rect
+			want := image.NewGray(r)
+			processGeneric(genericImage{img}, want)
+
+			for _, threshold := range []int{0, math.MaxInt} {
+				got := toGrayscaleFast(img, threshold)
+				if !bytes.Equal(got.Pix, want.Pix) {
+					t.Errorf("%s %v threshold %d: fast path differs from YCbCrAt", ratio, r, threshold)
+				}
+			}
+		}
+	}
+}
+
+// slowImage has a deliberately slow At, like some RAW and tiled TIFF
+// decoders, and a bulk GrayRowReader
+type slowImage struct {
+	*image.RGBA
+	// failRow makes ReadGrayRow fail at that row when non-negative
+	failRow int
+}
+
+// slowSink keeps the work of slowImage.At from being optimized away
+var slowSink int
+
+func (s slowImage) At(x, y int) color.Color {
+	// Stand in for per-pixel tile lookup and decompression
+	for i := range 200 {
+		slowSink += i
+	}
+	return s.RGBA.RGBAAt(x, y)
+}
+
+func (s slowImage) ReadGrayRow(y int, dst []uint8) error {
+	if y == s.failRow {
+		return fmt.Errorf("tile for row %d unavailable", y)
+	}
+	b := s.Rect
+	for x := range dst {
+		r, g, bl, a := s.RGBA.RGBAAt(b.Min.X+x, y).RGBA()
+		dst[x] = rgbaToGray(r, g, bl, a)
+	}
+	return nil
+}
+
+func newSlowImage(w, h, failRow int) slowImage {
+	img := image.NewRGBA(image.Rect(3, 2, 3+w, 2+h))
+	conformance.Fill(img)
+	return slowImage{RGBA: img, failRow: failRow}
+}
+
+func TestGrayRowReader(t *testing.T) {
+	for _, failRow := range []int{-1, 10} {
+		img := newSlowImage(40, 30, failRow)
+		if got := GrayscalePath(img); got != "grayrow" {
+			t.Errorf("GrayscalePath = %q, want grayrow", got)
+		}
+		want := image.NewGray(img.Rect)
+		processGeneric(genericImage{img}, want)
+		if got := ToGrayscaleFast(img); !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("failRow %d: GrayRowReader conversion differs from At", failRow)
+		}
+	}
+}
+
+// BenchmarkSlowAt compares the At fallback with the GrayRowReader path on
+// an image whose At is expensive
+func BenchmarkSlowAt(b *testing.B) {
+	img := newSlowImage(512, 512, -1)
+	b.Run("At", func(b *testing.B) {
+		for b.Loop() {
+			ToGrayscaleFast(genericImage{img})
+		}
+	})
+	b.Run("GrayRowReader", func(b *testing.B) {
+		for b.Loop() {
+			ToGrayscaleFast(img)
+		}
+	})
+}
+
+// TestToGrayscaleFast_OpaqueFastPath compares the opaque loops with the
+// per-pixel At conversion, for opaque and mixed-alpha images and sub-images
+func TestToGrayscaleFast_OpaqueFastPath(t *testing.T) {
+	r := rand.New(rand.NewSource(979))
+	for _, opaque := range []bool{true, false} {
+		rgba := image.NewRGBA(image.Rect(-3, 2, 90, 61))
+		nrgba := image.NewNRGBA(rgba.Rect)
+		for y := rgba.Rect.Min.Y; y < rgba.Rect.Max.Y; y++ {
+			for x := rgba.Rect.Min.X; x < rgba.Rect.Max.X; x++ {
+				c := color.NRGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), 255}
+				if !opaque && (x+y)%7 == 0 {
+					c.A = uint8(r.Intn(255))
+				}
+				rgba.Set(x, y, c)
+				nrgba.SetNRGBA(x, y, c)
+			}
+		}
+		inner := image.Rect(5, 9, 71, 40)
+		inputs := map[string]image.Image{
+			"RGBA": rgba, "NRGBA": nrgba,
+			"RGBA sub-image": rgba.SubImage(inner), "NRGBA sub-image": nrgba.SubImage(inner),
+		}
+		for name, img := range inputs {
+			if got := img.(interface{ Opaque() bool }).Opaque(); got != opaque {
+				t.Fatalf("%s: Opaque() = %v, want %v", name, got, opaque)
+			}
+			want := ToGrayscale(img)
+			for _, threshold := range []int{math.MaxInt, 0} {
+				if got := toGrayscaleFast(img, threshold); !bytes.Equal(got.Pix, want.Pix) || got.Rect != want.Rect {
+					t.Errorf("%s, opaque %v, threshold %d: differs from the At conversion", name, opaque, threshold)
+				}
+			}
+		}
+	}
+
+	ycbcr := grayscaleInputs(37, 23)["YCbCr"]
+	if got, want := ToGrayscaleFast(ycbcr), ToGrayscale(ycbcr); !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("YCbCr differs from the At conversion")
+	}
+}
+
+// BenchmarkGrayscaleOpaqueNRGBA converts an opaque screenshot through the
+// opaque loop and, with one translucent pixel in its last row, through the
+// alpha-aware loop after a full Opaque scan
+func BenchmarkGrayscaleOpaqueNRGBA(b *testing.B) {
+	var shot *image.NRGBA
+	for _, in := range benchdata.Corpus() {
+		if in.Name == "screenshot" {
+			shot = in.Image.(*image.NRGBA)
+		}
+	}
+	translucent := image.NewNRGBA(shot.Rect)
+	copy(translucent.Pix, shot.Pix)
+	translucent.Pix[len(translucent.Pix)-1] = 254
+
+	for name, img := range map[string]*image.NRGBA{"opaque": shot, "one-translucent": translucent} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(img.Pix)))
+			for b.Loop() {
+				toGrayscaleFast(img, math.MaxInt)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package imagehashgo
+
+import (
+	"context"
+	"fmt"
+	"image"
+)
+
+// Hasher computes hashes of one kind with fixed parameters and options.
+// A Hasher is immutable and safe for concurrent use, except when built with
+// WithCaptureIntermediate: every call writes the same destination, so
+// concurrent calls race on it. Capture with the hash functions instead,
+// with a destination per call.
+type Hasher struct {
+	kind     HashKind
+	hashSize int
+	opts     []Option
+	// resolved is opts frozen when the Hasher was built
+	resolved ResolvedOptions
+}
+
+// NewHasher returns a Hasher for the given kind and hash size, from 2 to
+// MaxHashSize. Options are applied to every call, as for the corresponding
+// hash function.
+func NewHasher(kind HashKind, hashSize int, opts ...Option) (*Hasher, error) {
+	if _, err := ParseHashKind(string(kind)); err != nil {
+		return nil, err
+	}
+	if hashSize < 2 {
+		return nil, fmt.Errorf("hash size must be at least 2, got %d", hashSize)
+	}
+	if err := checkHashSize(hashSize); err != nil {
+		return nil, err
+	}
+	return &Hasher{
+		kind:     kind,
+		hashSize: hashSize,
+		opts:     append([]Option(nil), opts...),
+		resolved: ResolveOptions(opts...),
+	}, nil
+}
+
+// Kind returns the algorithm used by the Hasher. Under WithAutoAlgorithm it
+// is replaced per image; the Kind of each hash names the one that ran.
+func (h *Hasher) Kind() HashKind {
+	return h.kind
+}
+
+// HashSize returns the hash size used by the Hasher
+func (h *Hasher) HashSize() int {
+	return h.hashSize
+}
+
+// Options returns the output-changing options of the Hasher
+func (h *Hasher) Options() ResolvedOptions {
+	return h.resolved
+}
+
+// Hash computes the hash of img
+func (h *Hasher) Hash(img image.Image) (*ImageHash, error) {
+	return h.HashContext(context.Background(), img)
+}
+
+// HashContext computes the hash of img, giving up with ctx.Err() once ctx
+// is done. The grayscale conversion, which dominates for large images,
+// checks ctx every few dozen rows and the remaining steps are skipped once
+// it is done; a preprocessing step runs to completion once started.
+func (h *Hasher) HashContext(ctx context.Context, img image.Image) (*ImageHash, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	hash, err := Hash(img, h.kind, h.hashSize, append(h.opts[:len(h.opts):len(h.opts)], withContext(ctx))...)
+	if err := ctx.Err(); err != nil {
+		// The hash may come from a partly converted image
+		return nil, err
+	}
+	return hash, err
+}
+
+// HashWithQuality computes the hash of img and its Quality, as the
+// package-level HashWithQuality does, so that a batch can exclude
+// low-information images from deduplication
+func (h *Hasher) HashWithQuality(img image.Image) (*ImageHash, Quality, error) {
+	return HashWithQuality(img, h.kind, h.hashSize, h.opts...)
+}
+
+// Fingerprint identifies everything that determines the hash bits: the
+// algorithm, the hash size and every output-changing option, including the
+// preprocessing pipeline. Hashes are only comparable when computed by
+// Hashers with equal fingerprints.
+func (h *Hasher) Fingerprint() string {
+	return fmt.Sprintf("%s/%d/%s", h.kind, h.hashSize, h.resolved)
+}
@@ -0,0 +1,57 @@
+package imagehashgo
+
+import (
+	"context"
+	"errors"
+	"image"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/K0ng2/imagehash-go/internal/conformance"
+)
+
+func TestHasher_HashContextCancel(t *testing.T) {
+	// The generic path converts a large image slowly enough to cancel
+	// midway on any machine
+	big := genericImage{conformance.Fill(image.NewRGBA(image.Rect(0, 0, 3000, 3000)))}
+	for _, threshold := range []int{math.MaxInt, 0} {
+		h, err := NewHasher(KindPerceptual, 8, WithParallelGrayscaleThreshold(threshold))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		start := time.Now()
+		hash, err := h.HashContext(ctx, big)
+		elapsed := time.Since(start)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) || hash != nil {
+			t.Fatalf("threshold %d: HashContext = %v, %v, want DeadlineExceeded", threshold, hash, err)
+		}
+		if elapsed > 250*time.Millisecond {
+			t.Errorf("threshold %d: returned %v after the 10ms deadline", threshold, elapsed)
+		}
+	}
+}
+
+func TestHasher_HashContext(t *testing.T) {
+	img := getBenchImage()
+	h, err := NewHasher(KindDifference, 8, WithPreprocess(NewPreprocess(AutoCrop(4))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := h.Hash(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	got, err := h.HashContext(ctx, img)
+	if err != nil || got.ToString() != want.ToString() {
+		t.Errorf("HashContext = %v, %v, want %s", got, err, want.ToString())
+	}
+
+	cancel()
+	if _, err := h.HashContext(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled context: error = %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+// Package hashtest checks alternative grayscale conversions and hash
+// functions, such as the fast paths of a fork, against reference ones over
+// a corpus of image layouts. It is the harness the package validates its
+// own fast paths with:
+//
+//	func TestMyHash(t *testing.T) {
+//		ref := func(img image.Image) *imagehashgo.ImageHash { return MyHash(hashtest.Generic(img)) }
+//		hashtest.CheckHashEquivalence(t, MyHash, ref, hashtest.Layouts(), 0)
+//	}
+//
+// Failures name the corpus image by index, type and bounds, and the first
+// pixels or bits that differ.
+package hashtest
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+	"github.com/K0ng2/imagehash-go/internal/conformance"
+)
+
+// maxReported is how many differing pixels or bits a failure lists
+const maxReported = 8
+
+// genericImage hides the concrete type of an image
+type genericImage struct {
+	image.Image
+}
+
+// Generic returns img behind a type of its own, so that code switching on
+// the image type takes its generic At-based path: an implementation run on
+// Generic(img) is the reference for its own fast paths on img.
+func Generic(img image.Image) image.Image {
+	return genericImage{img}
+}
+
+// Layouts returns the corpus of the package's conformance suite: every
+// standard image type at odd sizes, with non-zero origins, padded strides,
+// as sub-images, and YCbCr at every subsample ratio. The images are fresh
+// on every call.
+func Layouts() []image.Image {
+	layouts := conformance.Layouts()
+	images := make([]image.Image, len(layouts))
+	for i, l := range layouts {
+		images[i] = l.Image
+	}
+	return images
+}
+
+// describe names corpus image i for a failure message
+func describe(i int, img image.Image) string {
+	var detail string
+	switch src := img.(type) {
+	case *image.YCbCr:
+		detail = fmt.Sprintf(" %s, y stride %d", src.SubsampleRatio, src.YStride)
+	case *image.RGBA:
+		detail = fmt.Sprintf(" stride %d", src.Stride)
+	case *image.NRGBA:
+		detail = fmt.Sprintf(" stride %d", src.Stride)
+	case *image.Gray:
+		detail = fmt.Sprintf(" stride %d", src.Stride)
+	}
+	return fmt.Sprintf("corpus image %d (%T%s, bounds %v)", i, img, detail, img.Bounds())
+}
+
+// CheckGrayscaleEquivalence reports, for every corpus image, a fast
+// conversion whose bounds or pixels differ from the reference. A failure
+// lists the first differing pixels by image coordinates and the number of
+// differing pixels.
+func CheckGrayscaleEquivalence(t testing.TB, fast, reference func(image.Image) *image.Gray, corpus []image.Image) {
+	t.Helper()
+	for i, img := range corpus {
+		got, want := fast(img), reference(img)
+		switch {
+		case got == nil || want == nil:
+			t.Errorf("%s: fast returned %v, reference %v", describe(i, img), got != nil, want != nil)
+			continue
+		case got.Bounds() != want.Bounds():
+			t.Errorf("%s: fast bounds %v, reference %v", describe(i, img), got.Bounds(), want.Bounds())
+			continue
+		}
+
+		var diffs []string
+		count := 0
+		b := want.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if g, w := got.GrayAt(x, y).Y, want.GrayAt(x, y).Y; g != w {
+					if count < maxReported {
+						diffs = append(diffs, fmt.Sprintf("(%d,%d) %d != %d", x, y, g, w))
+					}
+					count++
+				}
+			}
+		}
+		if count > 0 {
+			t.Errorf("%s: %d of %d pixels differ (fast != reference): %s", describe(i, img), count, b.Dx()*b.Dy(), strings.Join(diffs, ", "))
+		}
+	}
+}
+
+// CheckHashEquivalence reports, for every corpus image, a fast hash whose
+// shape differs from the reference hash or that differs from it in more
+// than maxBitDiff bits. A failure gives both hashes in hex and the first
+// differing bits by row and column.
+func CheckHashEquivalence(t testing.TB, fast, reference func(image.Image) *imagehashgo.ImageHash, corpus []image.Image, maxBitDiff int) {
+	t.Helper()
+	for i, img := range corpus {
+		got, want := fast(img), reference(img)
+		if got == nil || want == nil {
+			t.Errorf("%s: fast returned %v, reference %v", describe(i, img), got != nil, want != nil)
+			continue
+		}
+		dist, err := got.Distance(want)
+		if err != nil {
+			gr, gc := got.Shape()
+			wr, wc := want.Shape()
+			t.Errorf("%s: fast hash is %dx%d, reference %dx%d", describe(i, img), gr, gc, wr, wc)
+			continue
+		}
+		if dist > maxBitDiff {
+			t.Errorf("%s: %d bits differ, at most %d allowed: fast %s, reference %s, differing %s",
+				describe(i, img), dist, maxBitDiff, got.ToString(), want.ToString(), diffBits(got, want))
+		}
+	}
+}
+
+// diffBits lists the first bits where two hashes of one shape differ
+func diffBits(a, b *imagehashgo.ImageHash) string {
+	sa, sb := a.Snapshot(), b.Snapshot()
+	var diffs []string
+	for i := range sa.Rows * sa.Cols {
+		if (sa.Bits[i/8]^sb.Bits[i/8])&(0x80>>(i%8)) != 0 {
+			if len(diffs) == maxReported {
+				diffs = append(diffs, "...")
+				break
+			}
+			diffs = append(diffs, fmt.Sprintf("(row %d, col %d)", i/sa.Cols, i%sa.Cols))
+		}
+	}
+	return strings.Join(diffs, ", ")
+}
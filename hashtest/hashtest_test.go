@@ -0,0 +1,105 @@
+package hashtest
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// The package's own fast paths, checked through the exported helpers
+
+func TestGrayscaleFastPaths(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	reference := func(img image.Image) *image.Gray { return imagehashgo.ToGrayscale(Generic(img)) }
+	CheckGrayscaleEquivalence(t, imagehashgo.ToGrayscaleFast, reference, Layouts())
+	CheckGrayscaleEquivalence(t, imagehashgo.ToGrayscale, reference, Layouts())
+}
+
+func TestHashFastPaths(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for name, hash := range map[string]func(image.Image) *imagehashgo.ImageHash{
+		"AverageHash":            func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.AverageHash(img, 8) },
+		"DifferenceHash":         func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.DifferenceHash(img, 8) },
+		"DifferenceHashVertical": func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.DifferenceHashVertical(img, 8) },
+		"PerceptualHash":         func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.PerceptualHash(img, 8, 4) },
+		"PerceptualHash/16":      func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.PerceptualHash(img, 16, 4) },
+		"AverageHash/parallel": func(img image.Image) *imagehashgo.ImageHash {
+			return imagehashgo.AverageHash(img, 8, imagehashgo.WithParallelGrayscaleThreshold(0))
+		},
+		"AverageHash/serial": func(img image.Image) *imagehashgo.ImageHash {
+			return imagehashgo.AverageHash(img, 8, imagehashgo.WithParallelGrayscaleThreshold(math.MaxInt))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reference := func(img image.Image) *imagehashgo.ImageHash { return hash(Generic(img)) }
+			CheckHashEquivalence(t, hash, reference, Layouts(), 0)
+		})
+	}
+}
+
+// recorder collects the failures a helper reports
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestFailureMessages(t *testing.T) {
+	corpus := Layouts()[:3]
+
+	// A conversion that breaks one pixel of the second image
+	broken := func(img image.Image) *image.Gray {
+		// A copy: ToGrayscale returns a Gray image itself
+		gray := *imagehashgo.ToGrayscale(img)
+		gray.Pix = slices.Clone(gray.Pix)
+		if img == corpus[1] {
+			b := gray.Bounds()
+			gray.Pix[gray.PixOffset(b.Min.X+2, b.Min.Y+1)] ^= 0xff
+		}
+		return &gray
+	}
+	r := &recorder{TB: t}
+	CheckGrayscaleEquivalence(r, broken, imagehashgo.ToGrayscale, corpus)
+	b := corpus[1].Bounds()
+	want := fmt.Sprintf("corpus image 1 (%T", corpus[1])
+	pixel := fmt.Sprintf("1 of %d pixels differ", b.Dx()*b.Dy())
+	at := fmt.Sprintf("(%d,%d)", b.Min.X+2, b.Min.Y+1)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], want) || !strings.Contains(r.errors[0], pixel) || !strings.Contains(r.errors[0], at) {
+		t.Errorf("grayscale failures %q, want one naming %s and pixel %s", r.errors, want, at)
+	}
+
+	// A hash with two bits flipped passes at 2 bits and names them at 1
+	hash := func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.AverageHash(img, 8) }
+	flipped := func(img image.Image) *imagehashgo.ImageHash {
+		v, _ := hash(img).ToUint64()
+		h, _ := imagehashgo.FromUint64(v^(1<<63|1<<54), 8, 8)
+		return h
+	}
+	r = &recorder{TB: t}
+	CheckHashEquivalence(r, flipped, hash, corpus, 2)
+	if len(r.errors) != 0 {
+		t.Errorf("within maxBitDiff: %q", r.errors)
+	}
+	CheckHashEquivalence(r, flipped, hash, corpus, 1)
+	if len(r.errors) != len(corpus) || !strings.Contains(r.errors[0], "2 bits differ") ||
+		!strings.Contains(r.errors[0], "(row 0, col 0), (row 1, col 1)") {
+		t.Errorf("hash failures %q", r.errors)
+	}
+
+	r = &recorder{TB: t}
+	CheckHashEquivalence(r, func(img image.Image) *imagehashgo.ImageHash { return imagehashgo.AverageHash(img, 4) }, hash, corpus[:1], 64)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "fast hash is 4x4, reference 8x8") {
+		t.Errorf("shape failures %q", r.errors)
+	}
+}
@@ -1,20 +1,41 @@
 package imagehashgo
 
 import (
+	"encoding/hex"
 	"fmt"
 	"image"
 	"math"
-	"sort"
+	"slices"
 	"sync"
 
-	"github.com/disintegration/imaging"
+	"github.com/K0ng2/imagehash-go/internal/bitio"
 )
 
 // ImageHash represents an image hash
+//
+// Bit order: cells are stored row-major, and every serialization in this
+// package (hex strings, HashSnapshot, ToUint64) packs them MSB-first, so the
+// top-left cell is the most significant bit. For an 8x8 hash:
+//
+//	         col 0   col 1        col 7
+//	row 0  [ bit63 ][ bit62 ] ... [ bit56 ]   -> hex chars 0-1
+//	row 1  [ bit55 ][ bit54 ] ... [ bit48 ]   -> hex chars 2-3
+//	 ...
+//	row 7  [ bit7  ][ bit6  ] ... [ bit0  ]   -> hex chars 14-15
+//
+// This matches Python imagehash, which builds the integer with
+// int(bit_string, 2). Use the *LSB helpers or ReverseBitOrder to exchange
+// hashes with libraries that put the top-left cell in bit 0.
+//
+// An ImageHash is immutable once built and safe for concurrent use; only
+// GobDecode, which fills a new value, modifies it.
 type ImageHash struct {
 	hash []bool
 	rows int
 	cols int
+	// kind names the algorithm that produced the hash; empty when unknown,
+	// e.g. for parsed hashes
+	kind HashKind
 }
 
 // NewImageHash creates a new ImageHash
@@ -31,6 +52,9 @@ func (h *ImageHash) Distance(other *ImageHash) (int, error) {
 	if h.rows != other.rows || h.cols != other.cols {
 		return 0, fmt.Errorf("ImageHashes must be of the same shape: (%d, %d) vs (%d, %d)", h.rows, h.cols, other.rows, other.cols)
 	}
+	if len(h.hash) != len(other.hash) {
+		return 0, fmt.Errorf("ImageHashes must have the same length: %d vs %d bits", len(h.hash), len(other.hash))
+	}
 
 	dist := 0
 	for i := range h.hash {
@@ -41,6 +65,47 @@ func (h *ImageHash) Distance(other *ImageHash) (int, error) {
 	return dist, nil
 }
 
+// MaskedDistance returns the Hamming distance between this hash and another,
+// skipping every bit whose position is set in ignore (e.g. from RegionMask)
+func (h *ImageHash) MaskedDistance(other *ImageHash, ignore []bool) (int, error) {
+	if h.rows != other.rows || h.cols != other.cols {
+		return 0, fmt.Errorf("ImageHashes must be of the same shape: (%d, %d) vs (%d, %d)", h.rows, h.cols, other.rows, other.cols)
+	}
+	if len(h.hash) != len(other.hash) {
+		return 0, fmt.Errorf("ImageHashes must have the same length: %d vs %d bits", len(h.hash), len(other.hash))
+	}
+	if len(ignore) != len(h.hash) {
+		return 0, fmt.Errorf("mask has %d bits, hash has %d", len(ignore), len(h.hash))
+	}
+
+	dist := 0
+	for i := range h.hash {
+		if !ignore[i] && h.hash[i] != other.hash[i] {
+			dist++
+		}
+	}
+	return dist, nil
+}
+
+// RegionMask returns the cells of a rows x cols hash that overlap region,
+// given in percent of the image size as for WithIgnoreRegion.
+// It is meaningful for AverageHash and DifferenceHash, whose cells map to
+// image areas, but not for PerceptualHash. It returns nil when rows or cols
+// is not positive.
+func RegionMask(region image.Rectangle, rows, cols int) []bool {
+	if rows <= 0 || cols <= 0 {
+		return nil
+	}
+	o := newOptions([]Option{WithIgnoreRegion(region)})
+	mask := make([]bool, rows*cols)
+	for y := range rows {
+		for x := range cols {
+			mask[y*cols+x] = o.ignoredCell(x, y, cols, rows)
+		}
+	}
+	return mask
+}
+
 // ToString returns the hex string representation of the hash
 func (h *ImageHash) ToString() string {
 	if len(h.hash) == 0 {
@@ -55,40 +120,47 @@ func (h *ImageHash) ToString() string {
 	// int(bit_string, 2)
 	// This means the last bit of the array is the least significant bit of the integer.
 
+	// Packed MSB-first, the hex digits of the bytes are the hex digits of
+	// that integer; a trailing nibble is dropped when the length is odd.
 	hexLen := (len(h.hash) + 3) / 4
-	result := make([]byte, hexLen)
-
-	for i := range hexLen {
-		var val uint8
-		for j := range 4 {
-			bitIdx := i*4 + j
-			if bitIdx < len(h.hash) && h.hash[bitIdx] {
-				val |= 1 << (3 - uint(j))
-			}
-		}
-		if val < 10 {
-			result[i] = '0' + val
-		} else {
-			result[i] = 'a' + (val - 10)
-		}
-	}
-
-	return string(result)
+	return hex.EncodeToString(packBits(h.hash))[:hexLen]
 }
 
-// HexToHash converts a hex string back to an ImageHash
+// MaxHashBits is the largest hash, in bits, accepted by the parsing
+// functions (HexToHash, FromSnapshot, ParseEnsembleHashes): a 128x128 hash.
+// Larger inputs are rejected so that untrusted strings cannot force large
+// allocations.
+const MaxHashBits = 128 * 128
+
+// MaxHashSize is the largest hashSize of the square algorithms, whose
+// hashes have hashSize*hashSize bits
+const MaxHashSize = 128
+
+// HexToHash converts a hex string back to an ImageHash. Upper- and
+// lower-case digits are accepted; anything else, including whitespace and a
+// "0x" prefix, is an error, as are the empty string and strings longer than
+// MaxHashBits bits. The shape is square when the bit count is a perfect
+// square, and a single row otherwise.
 func HexToHash(hexStr string) (*ImageHash, error) {
 	bitsPerHex := 4
+	if hexStr == "" {
+		return nil, fmt.Errorf("empty hex hash")
+	}
+	if len(hexStr) > MaxHashBits/bitsPerHex {
+		return nil, fmt.Errorf("hex hash has %d characters, the maximum is %d", len(hexStr), MaxHashBits/bitsPerHex)
+	}
 	totalBits := len(hexStr) * bitsPerHex
-	hashSize := int(math.Sqrt(float64(totalBits)))
-	// Check if it's a square
-	if hashSize*hashSize != totalBits {
-		// Not a square, maybe it's just a flat hash
-		// For now, assume square as most imagehashes are
+	rows, cols := int(math.Sqrt(float64(totalBits))), 0
+	if rows*rows == totalBits {
+		cols = rows
+	} else {
+		// Not a square, keep it as a flat hash so the shape always
+		// matches the bit count
+		rows, cols = 1, totalBits
 	}
 
-	hash := make([]bool, totalBits)
-	for i, r := range hexStr {
+	var w bitio.BitWriter
+	for _, r := range hexStr {
 		var val uint8
 		if r >= '0' && r <= '9' {
 			val = uint8(r - '0')
@@ -97,124 +169,206 @@ func HexToHash(hexStr string) (*ImageHash, error) {
 		} else if r >= 'A' && r <= 'F' {
 			val = uint8(r - 'A' + 10)
 		} else {
-			return nil, fmt.Errorf("invalid hex character: %c", r)
-		}
-
-		for j := range 4 {
-			if (val & (1 << (3 - uint(j)))) != 0 {
-				hash[i*4+j] = true
-			}
+			return nil, fmt.Errorf("invalid hex character: %q", r)
 		}
+		w.WriteUint(uint64(val), bitsPerHex)
 	}
 
 	return &ImageHash{
-		hash: hash,
-		rows: hashSize,
-		cols: hashSize,
+		hash: unpackBits(w.Bytes(), totalBits),
+		rows: rows,
+		cols: cols,
 	}, nil
 }
 
+// HexToHashShape is HexToHash for a known shape, and the inverse of
+// ToString for every shape: the string must have (rows*cols+3)/4 digits,
+// and the padding bits after the last cell must be zero.
+func HexToHashShape(hexStr string, rows, cols int) (*ImageHash, error) {
+	if rows <= 0 || cols <= 0 || rows > MaxHashBits || cols > MaxHashBits || rows*cols > MaxHashBits {
+		return nil, fmt.Errorf("invalid hash shape: (%d, %d)", rows, cols)
+	}
+	n := rows * cols
+	if want := (n + 3) / 4; len(hexStr) != want {
+		return nil, fmt.Errorf("hex hash %q has %d characters, shape (%d, %d) needs %d", hexStr, len(hexStr), rows, cols, want)
+	}
+	h, err := HexToHash(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(h.hash[n:], true) {
+		return nil, fmt.Errorf("hex hash %q has non-zero padding bits", hexStr)
+	}
+	h.hash = h.hash[:n]
+	h.rows, h.cols = rows, cols
+	return h, nil
+}
+
 // AverageHash computes the Average Hash of an image
-func AverageHash(img image.Image, hashSize int) *ImageHash {
+func AverageHash(img image.Image, hashSize int, opts ...Option) *ImageHash {
 	if hashSize < 2 {
 		hashSize = 8
 	}
+	o := newOptions(opts)
+	rows, cols := o.grid(img, hashSize)
 
 	// 1. Convert to grayscale using fast path
-	gray := ToGrayscaleFast(img)
+	gray := o.grayscale(img)
 
-	// 2. Resize to hashSize x hashSize
-	resized := imaging.Resize(gray, hashSize, hashSize, imaging.Lanczos)
-	// imaging.Resize returns *image.NRGBA, convert to grayscale pixels
-	grayResized := ToGrayscaleFast(resized)
+	// 2. Resize to cols x rows (hashSize x hashSize unless WithAspectBuckets)
+	grayResized := o.resize(gray, cols, rows)
+	o.captureGray(grayResized)
+	return o.averageHashResized(grayResized, rows, cols)
+}
 
-	// 3. Compute average pixel value
-	var sum uint64
-	for y := range hashSize {
-		for x := range hashSize {
+// averageHashResized runs the threshold steps of AverageHash on a grayscale
+// image already resized to cols x rows
+func (o options) averageHashResized(grayResized *image.Gray, rows, cols int) *ImageHash {
+	// 3. Compute average pixel value of the cells that are not ignored
+	var sum, count uint64
+	for y := range rows {
+		for x := range cols {
+			if o.ignoredCell(x, y, cols, rows) {
+				continue
+			}
 			sum += uint64(grayResized.Pix[y*grayResized.Stride+x])
+			count++
 		}
 	}
-	avg := float64(sum) / float64(hashSize*hashSize)
 
-	// 4. Create hash
-	hash := make([]bool, hashSize*hashSize)
-	for y := range hashSize {
-		for x := range hashSize {
-			hash[y*hashSize+x] = float64(grayResized.Pix[y*grayResized.Stride+x]) > avg
+	// 4. Create hash, leaving ignored cells false.
+	// p > sum/count is evaluated as p*count > sum to stay in integers.
+	hash := make([]bool, rows*cols)
+	for y := range rows {
+		for x := range cols {
+			if o.ignoredCell(x, y, cols, rows) {
+				continue
+			}
+			hash[y*cols+x] = uint64(grayResized.Pix[y*grayResized.Stride+x])*count > sum
 		}
 	}
 
-	return &ImageHash{
-		hash: hash,
-		rows: hashSize,
-		cols: hashSize,
-	}
+	return builtHash(hash, rows, cols, KindAverage)
 }
 
 // DifferenceHash computes the Difference Hash of an image
-func DifferenceHash(img image.Image, hashSize int) *ImageHash {
+func DifferenceHash(img image.Image, hashSize int, opts ...Option) *ImageHash {
+	h := DifferenceHashThresholded(img, hashSize, 0, opts...)
+	h.kind = KindDifference
+	return h
+}
+
+// DifferenceHashThresholded is DifferenceHash where a bit is set only if the
+// right pixel is brighter than the left one by more than minDelta, so that
+// near-flat regions (e.g. the paper of scanned documents) stay 0 instead
+// of flipping with noise and brightness. The shape is hashSize x hashSize,
+// as for DifferenceHash, and minDelta 0 gives the same bits. Its Kind is
+// empty: only compare hashes made with the same minDelta.
+func DifferenceHashThresholded(img image.Image, hashSize int, minDelta uint8, opts ...Option) *ImageHash {
 	if hashSize < 2 {
 		hashSize = 8
 	}
+	o := newOptions(opts)
+	rows, cols := o.grid(img, hashSize)
+	hash := make([]bool, rows*cols)
+	differenceCells(img, rows, cols, o, func(x, y int, left, right uint8) {
+		hash[y*cols+x] = int(right) > int(left)+int(minDelta)
+	})
+
+	return builtHash(hash, rows, cols, "")
+}
+
+// TriDifferenceHash is a three-state DifferenceHash: every cell emits two
+// bits, (1, 0) if the right pixel is brighter than the left one by more
+// than minDelta, (0, 1) if it is darker by more than minDelta, and (0, 0)
+// if they are approximately equal. Opposite gradients are then 2 apart and
+// a gradient vs a flat cell only 1, which keeps near-flat regions stable.
+//
+// The shape is hashSize rows of 2*hashSize columns, the two bits of cell
+// (x, y) being columns 2x and 2x+1 of row y. The shape is not square, so
+// store it with Snapshot (or gob), or read its hex back with
+// HexToHashShape; HexToHash reads it as a single row. Its Kind is empty.
+func TriDifferenceHash(img image.Image, hashSize int, minDelta uint8, opts ...Option) *ImageHash {
+	if hashSize < 2 {
+		hashSize = 8
+	}
+
+	o := newOptions(opts)
+	rows, cells := o.grid(img, hashSize)
+	cols := 2 * cells
+	hash := make([]bool, rows*cols)
+	differenceCells(img, rows, cells, o, func(x, y int, left, right uint8) {
+		hash[y*cols+2*x] = int(right) > int(left)+int(minDelta)
+		hash[y*cols+2*x+1] = int(left) > int(right)+int(minDelta)
+	})
+
+	return builtHash(hash, rows, cols, "")
+}
 
+// differenceCells resizes img to (cols + 1) x rows and calls cell with
+// every horizontally adjacent pixel pair that is not ignored
+func differenceCells(img image.Image, rows, cols int, o options, cell func(x, y int, left, right uint8)) {
 	// 1. Convert to grayscale using fast path
-	gray := ToGrayscaleFast(img)
+	gray := o.grayscale(img)
 
-	// 2. Resize to (hashSize + 1) x hashSize
-	resized := imaging.Resize(gray, hashSize+1, hashSize, imaging.Lanczos)
-	grayResized := ToGrayscaleFast(resized)
+	// 2. Resize to (cols + 1) x rows
+	grayResized := o.resize(gray, cols+1, rows)
+	o.captureGray(grayResized)
+	o.differencePairs(grayResized, rows, cols, cell)
+}
 
-	// 3. Compute differences between columns
+// differencePairs runs the comparison step of differenceCells on a
+// grayscale image already resized to (cols + 1) x rows
+func (o options) differencePairs(grayResized *image.Gray, rows, cols int, cell func(x, y int, left, right uint8)) {
+	// 3. Compare adjacent columns
 	pixels := grayResized.Pix
-	// grayResized has hashSize+1 columns and hashSize rows
-	hash := make([]bool, hashSize*hashSize)
-	for y := range hashSize {
-		for x := range hashSize {
+	for y := range rows {
+		for x := range cols {
+			if o.ignoredCell(x, y, cols+1, rows) || o.ignoredCell(x+1, y, cols+1, rows) {
+				continue
+			}
 			// p[x, y] vs p[x+1, y]
-			left := pixels[y*grayResized.Stride+x]
-			right := pixels[y*grayResized.Stride+x+1]
-			hash[y*hashSize+x] = right > left
+			cell(x, y, pixels[y*grayResized.Stride+x], pixels[y*grayResized.Stride+x+1])
 		}
 	}
-
-	return &ImageHash{
-		hash: hash,
-		rows: hashSize,
-		cols: hashSize,
-	}
 }
 
 // DifferenceHashVertical computes the vertical Difference Hash of an image
-func DifferenceHashVertical(img image.Image, hashSize int) *ImageHash {
+func DifferenceHashVertical(img image.Image, hashSize int, opts ...Option) *ImageHash {
 	if hashSize < 2 {
 		hashSize = 8
 	}
+	o := newOptions(opts)
+	rows, cols := o.grid(img, hashSize)
 
 	// 1. Convert to grayscale using fast path
-	gray := ToGrayscaleFast(img)
+	gray := o.grayscale(img)
 
-	// 2. Resize to hashSize x (hashSize + 1)
-	resized := imaging.Resize(gray, hashSize, hashSize+1, imaging.Lanczos)
-	grayResized := ToGrayscaleFast(resized)
+	// 2. Resize to cols x (rows + 1)
+	grayResized := o.resize(gray, cols, rows+1)
+	o.captureGray(grayResized)
+	return o.verticalHashResized(grayResized, rows, cols)
+}
 
+// verticalHashResized runs the comparison step of DifferenceHashVertical on
+// a grayscale image already resized to cols x (rows + 1)
+func (o options) verticalHashResized(grayResized *image.Gray, rows, cols int) *ImageHash {
 	// 3. Compute differences between rows
 	pixels := grayResized.Pix
-	hash := make([]bool, hashSize*hashSize)
-	for y := range hashSize {
-		for x := range hashSize {
+	hash := make([]bool, rows*cols)
+	for y := range rows {
+		for x := range cols {
+			if o.ignoredCell(x, y, cols, rows+1) || o.ignoredCell(x, y+1, cols, rows+1) {
+				continue
+			}
 			// p[x, y] vs p[x, y+1]
 			top := pixels[y*grayResized.Stride+x]
 			bottom := pixels[(y+1)*grayResized.Stride+x]
-			hash[y*hashSize+x] = bottom > top
+			hash[y*cols+x] = bottom > top
 		}
 	}
 
-	return &ImageHash{
-		hash: hash,
-		rows: hashSize,
-		cols: hashSize,
-	}
+	return builtHash(hash, rows, cols, KindDifferenceVertical)
 }
 
 // Memory pools for pixel buffers
@@ -234,7 +388,7 @@ var (
 )
 
 // PerceptualHash computes the Perceptual Hash of an image
-func PerceptualHash(img image.Image, hashSize int, highfreqFactor int) *ImageHash {
+func PerceptualHash(img image.Image, hashSize int, highfreqFactor int, opts ...Option) *ImageHash {
 	if hashSize < 2 {
 		hashSize = 8
 	}
@@ -244,21 +398,31 @@ func PerceptualHash(img image.Image, hashSize int, highfreqFactor int) *ImageHas
 
 	imgSize := hashSize * highfreqFactor
 
+	// 1. Convert to grayscale using fast path, filling any ignored region
+	o := newOptions(opts)
+	gray := o.fillIgnored(o.grayscale(img))
+
+	// 2. Resize to imgSize x imgSize
+	grayResized := resizeGray(gray, imgSize, imgSize)
+	o.captureGray(grayResized)
+	return perceptualHashResized(grayResized, hashSize, o.median)
+}
+
+// perceptualHashResized runs the DCT steps of PerceptualHash on a grayscale
+// image already resized to imgSize x imgSize, thresholding at median
+func perceptualHashResized(grayResized *image.Gray, hashSize int, median MedianStrategy) *ImageHash {
+	imgSize := grayResized.Rect.Dx()
+
 	// Use optimized fast DCT for common sizes
 	if imgSize == 32 && hashSize == 8 {
-		return perceptualHashFast32(img)
+		return perceptualHashFast32(grayResized, median)
 	} else if imgSize == 64 && hashSize == 8 {
-		return perceptualHashFast64(img)
+		return perceptualHashFast64(grayResized, median)
+	} else if imgSize == 16 {
+		return perceptualHashFast16(grayResized, hashSize, median)
 	}
 
 	// Fallback to general implementation for other sizes
-	// 1. Convert to grayscale using fast path
-	gray := ToGrayscaleFast(img)
-
-	// 2. Resize to imgSize x imgSize
-	resized := imaging.Resize(gray, imgSize, imgSize, imaging.Lanczos)
-	grayResized := ToGrayscaleFast(resized)
-
 	// 3. Compute 2D DCT
 	pixels := grayResized.Pix
 	matrix := make([][]float64, imgSize)
@@ -280,31 +444,15 @@ func PerceptualHash(img image.Image, hashSize int, highfreqFactor int) *ImageHas
 		}
 	}
 
-	// 5. Compute median
-	med := median(dctLowFreq)
-
-	// 6. Create hash
-	hash := make([]bool, hashSize*hashSize)
-	for i, val := range dctLowFreq {
-		hash[i] = val > med
-	}
-
-	return &ImageHash{
-		hash: hash,
-		rows: hashSize,
-		cols: hashSize,
-	}
+	// 5. Threshold at the median
+	h := thresholdHash(dctLowFreq, hashSize, hashSize, median.Median)
+	h.kind = KindPerceptual
+	return h
 }
 
-// perceptualHashFast64 uses optimized DCT for 64x64 -> 8x8 hash (default params)
-func perceptualHashFast64(img image.Image) *ImageHash {
-	// 1. Convert to grayscale using fast path
-	gray := ToGrayscaleFast(img)
-
-	// 2. Resize to 64x64
-	resized := imaging.Resize(gray, 64, 64, imaging.Lanczos)
-	grayResized := ToGrayscaleFast(resized)
-
+// perceptualHashFast64 uses optimized DCT for 64x64 -> 8x8 hash (default params).
+// grayResized must already be 64x64.
+func perceptualHashFast64(grayResized *image.Gray, median MedianStrategy) *ImageHash {
 	// 3. Get pixel buffer from pool
 	pixelsPtr := pixelPool64.Get().(*[]float64)
 	defer pixelPool64.Put(pixelsPtr)
@@ -320,33 +468,37 @@ func perceptualHashFast64(img image.Image) *ImageHash {
 	}
 
 	// 5. Compute fast DCT (returns 8x8 low freq coefficients)
-	dctLowFreq := DCT2DFast64(pixelsPtr)
+	dctLowFreq := dct2DFast64(pixelsPtr)
 
-	// 6. Compute median
-	med := medianFast64(dctLowFreq[:])
+	// 6. Threshold at the median
+	h := thresholdHash(dctLowFreq[:], 8, 8, median.Median)
+	h.kind = KindPerceptual
+	return h
+}
 
-	// 7. Create hash
-	hash := make([]bool, 64)
-	for i, val := range dctLowFreq {
-		hash[i] = val > med
+// perceptualHashFast16 uses optimized DCT for 16x16 -> hashSize x hashSize
+// hashes, e.g. the 16-bit PerceptualHash(img, 4, 4).
+// grayResized must already be 16x16.
+func perceptualHashFast16(grayResized *image.Gray, hashSize int, median MedianStrategy) *ImageHash {
+	var buf [16 * 16]float64
+	pix := grayResized.Pix
+	for i := range 16 {
+		rowStride := i * grayResized.Stride
+		for j := range 16 {
+			buf[i*16+j] = float64(pix[rowStride+j])
+		}
 	}
+	pixels := buf[:]
 
-	return &ImageHash{
-		hash: hash,
-		rows: 8,
-		cols: 8,
-	}
+	dctLowFreq := dct2DFast16(pixels, hashSize)
+	h := thresholdHash(dctLowFreq, hashSize, hashSize, median.Median)
+	h.kind = KindPerceptual
+	return h
 }
 
-// perceptualHashFast32 uses optimized DCT for 32x32 -> 8x8 hash
-func perceptualHashFast32(img image.Image) *ImageHash {
-	// 1. Convert to grayscale using fast path
-	gray := ToGrayscaleFast(img)
-
-	// 2. Resize to 32x32
-	resized := imaging.Resize(gray, 32, 32, imaging.Lanczos)
-	grayResized := ToGrayscaleFast(resized)
-
+// perceptualHashFast32 uses optimized DCT for 32x32 -> 8x8 hash.
+// grayResized must already be 32x32.
+func perceptualHashFast32(grayResized *image.Gray, median MedianStrategy) *ImageHash {
 	// 3. Get pixel buffer from pool
 	pixelsPtr := pixelPool32.Get().(*[]float64)
 	defer pixelPool32.Put(pixelsPtr)
@@ -362,54 +514,10 @@ func perceptualHashFast32(img image.Image) *ImageHash {
 	}
 
 	// 5. Compute fast DCT (returns 8x8 low freq coefficients)
-	dctLowFreq := DCT2DFast32(pixelsPtr, 8)
-
-	// 6. Compute median
-	med := median(dctLowFreq)
-
-	// 7. Create hash
-	hash := make([]bool, 64)
-	for i, val := range dctLowFreq {
-		hash[i] = val > med
-	}
-
-	return &ImageHash{
-		hash: hash,
-		rows: 8,
-		cols: 8,
-	}
-}
-
-func median(data []float64) float64 {
-	length := len(data)
-	if length == 0 {
-		return 0
-	}
-
-	// Make a copy to avoid modifying original data
-	sorted := make([]float64, length)
-	copy(sorted, data)
-
-	sort.Float64s(sorted)
-
-	if length%2 == 0 {
-		return (sorted[length/2-1] + sorted[length/2]) / 2
-	}
-	return sorted[length/2]
-}
-
-// medianFast64 is optimized for fixed-size 64-element array
-func medianFast64(data []float64) float64 {
-	if len(data) != 64 {
-		return median(data)
-	}
-
-	// Make a copy to avoid modifying original data
-	var sorted [64]float64
-	copy(sorted[:], data)
-
-	sort.Float64s(sorted[:])
+	dctLowFreq := dct2DFast32(*pixelsPtr, 8)
 
-	// For even length (64), return average of middle two elements
-	return (sorted[31] + sorted[32]) / 2
+	// 6. Threshold at the median
+	h := thresholdHash(dctLowFreq, 8, 8, median.Median)
+	h.kind = KindPerceptual
+	return h
 }
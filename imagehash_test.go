@@ -6,6 +6,8 @@ import (
 	_ "image/png"
 	"os"
 	"testing"
+
+	"github.com/K0ng2/imagehash-go/internal/benchdata"
 )
 
 func TestImagePng(t *testing.T) {
@@ -159,6 +161,33 @@ func TestImageHash_ToString_RoundTrip(t *testing.T) {
 	}
 }
 
+func TestHexToHashShape(t *testing.T) {
+	// 10 bits: HexToHash reads the 3 digits back as 12 bits
+	h := &ImageHash{hash: []bool{true, false, true, true, false, true, false, false, true, true}, rows: 2, cols: 5}
+	got, err := HexToHashShape(h.ToString(), 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := got.Distance(h); err != nil || d != 0 {
+		t.Errorf("round trip of %s: distance %d, %v", h.ToString(), d, err)
+	}
+
+	for _, tt := range []struct {
+		hex        string
+		rows, cols int
+	}{
+		{"b4d", 2, 5},  // padding bits set
+		{"b4", 2, 5},   // too short
+		{"b400", 2, 5}, // too long
+		{"b40", 0, 5},  // invalid shape
+		{"b4g", 2, 5},  // invalid digit
+	} {
+		if _, err := HexToHashShape(tt.hex, tt.rows, tt.cols); err == nil {
+			t.Errorf("HexToHashShape(%q, %d, %d) should fail", tt.hex, tt.rows, tt.cols)
+		}
+	}
+}
+
 func TestAverageHash_SolidColor(t *testing.T) {
 	// Create a red image
 	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
@@ -238,34 +267,158 @@ func getBenchImage() image.Image {
 	return img
 }
 
-func BenchmarkAverageHash(b *testing.B) {
-	img := getBenchImage()
+// benchCorpus runs fn as one sub-benchmark per image of the benchdata
+// corpus, reporting allocations and the pixel bytes hashed per second
+func benchCorpus(b *testing.B, fn func(img image.Image)) {
+	for _, in := range benchdata.Corpus() {
+		b.Run(in.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(in.Bytes)
+			for b.Loop() {
+				fn(in.Image)
+			}
+		})
+	}
+}
 
-	for b.Loop() {
+func BenchmarkAverageHash(b *testing.B) {
+	benchCorpus(b, func(img image.Image) {
 		AverageHash(img, 8)
-	}
+	})
 }
 
 func BenchmarkPerceptualHash(b *testing.B) {
-	img := getBenchImage()
-
-	for b.Loop() {
+	benchCorpus(b, func(img image.Image) {
 		PerceptualHash(img, 8, 4)
-	}
+	})
 }
 
 func BenchmarkDifferenceHash(b *testing.B) {
-	img := getBenchImage()
-
-	for b.Loop() {
+	benchCorpus(b, func(img image.Image) {
 		DifferenceHash(img, 8)
-	}
+	})
 }
 
 func BenchmarkDifferenceHashVertical(b *testing.B) {
-	img := getBenchImage()
-
-	for b.Loop() {
+	benchCorpus(b, func(img image.Image) {
 		DifferenceHashVertical(img, 8)
+	})
+}
+
+// uint64Hash is FromUint64 for shapes known to fit
+func uint64Hash(v uint64, rows, cols int) *ImageHash {
+	h, err := FromUint64(v, rows, cols)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+func TestFromUint64LSB_VendorInterop(t *testing.T) {
+	native := DifferenceHash(getBenchImage(), 8)
+
+	// The vendor's LSB-first integer for the dHash of image.png, where bit
+	// 0 is the top-left cell; our hex for it is 12189e3333968e0c
+	const vendor = 0x307169cccc791848
+	imported, err := FromUint64LSB(vendor, 8, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dist, err := imported.Distance(native); err != nil || dist != 0 {
+		t.Errorf("Distance() = %d, %v; want 0", dist, err)
+	}
+	if got := imported.ToString(); got != "12189e3333968e0c" {
+		t.Errorf("FromUint64LSB() = %s, want 12189e3333968e0c", got)
+	}
+
+	lsb, err := native.ToUint64LSB()
+	if err != nil || lsb != vendor {
+		t.Errorf("ToUint64LSB() = %#x, %v; want %#x", lsb, err, uint64(vendor))
+	}
+	msb, err := native.ReverseBitOrder().ToUint64()
+	if err != nil || msb != vendor {
+		t.Errorf("ReverseBitOrder().ToUint64() = %#x, %v; want %#x", msb, err, uint64(vendor))
+	}
+
+	for _, shape := range [][2]int{{8, 9}, {0, 8}, {8, -1}, {1, 65}} {
+		if _, err := FromUint64(0, shape[0], shape[1]); err == nil {
+			t.Errorf("FromUint64 shape %v: expected error", shape)
+		}
+		if _, err := FromUint64LSB(0, shape[0], shape[1]); err == nil {
+			t.Errorf("FromUint64LSB shape %v: expected error", shape)
+		}
+	}
+}
+
+func TestImageHash_ToUint64_MatchesHex(t *testing.T) {
+	h, err := HexToHash("12189e3333968e0c")
+	if err != nil {
+		t.Fatalf("HexToHash() error = %v", err)
+	}
+	v, err := h.ToUint64()
+	if err != nil || v != 0x12189e3333968e0c {
+		t.Errorf("ToUint64() = %x, %v; want 12189e3333968e0c", v, err)
+	}
+	if got := uint64Hash(v, 8, 8).ToString(); got != "12189e3333968e0c" {
+		t.Errorf("uint64Hash() = %s", got)
+	}
+	if _, err := (&ImageHash{hash: make([]bool, 16), rows: 4, cols: 4}).ToUint64(); err == nil {
+		t.Error("ToUint64() on a 16-bit hash expected error")
+	}
+}
+
+func BenchmarkAverageHash_IntegerPipeline(b *testing.B) {
+	benchCorpus(b, func(img image.Image) {
+		AverageHash(img, 8, WithIntegerPipeline())
+	})
+}
+
+func BenchmarkDifferenceHash_IntegerPipeline(b *testing.B) {
+	benchCorpus(b, func(img image.Image) {
+		DifferenceHash(img, 8, WithIntegerPipeline())
+	})
+}
+
+func TestDifferenceHash_PreSizedInput(t *testing.T) {
+	// 9x8 input: each row increases except for a dip at column 4, so bit
+	// x is set when column x+1 is brighter than column x
+	img := image.NewGray(image.Rect(0, 0, 9, 8))
+	row := []uint8{10, 20, 30, 40, 50, 5, 60, 70, 80}
+	for y := range 8 {
+		copy(img.Pix[y*img.Stride:], row)
+	}
+	// Row bits: 1111 0111 -> "f7" for every row
+	if got := DifferenceHash(img, 8).ToString(); got != "f7f7f7f7f7f7f7f7" {
+		t.Errorf("DifferenceHash() = %s, want f7f7f7f7f7f7f7f7", got)
+	}
+
+	vertical := image.NewGray(image.Rect(0, 0, 8, 9))
+	for y, v := range row {
+		for x := range 8 {
+			vertical.Pix[y*vertical.Stride+x] = v
+		}
+	}
+	// Rows 0-3 and 5-7 are all ones, row 4 all zeros
+	if got := DifferenceHashVertical(vertical, 8).ToString(); got != "ffffffff00ffffff" {
+		t.Errorf("DifferenceHashVertical() = %s, want ffffffff00ffffff", got)
+	}
+}
+
+func TestAverageHash_PreSizedInput(t *testing.T) {
+	// 8x8 input with the left half at 200 and the right half at 10, so the
+	// left four bits of every row are set. The last pixel is 107, just above
+	// the resulting mean of 6817/64 = 106.5.
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := range 8 {
+		for x := range 8 {
+			img.Pix[y*img.Stride+x] = 10
+			if x < 4 {
+				img.Pix[y*img.Stride+x] = 200
+			}
+		}
+	}
+	img.Pix[7*img.Stride+7] = 107
+	if got := AverageHash(img, 8).ToString(); got != "f0f0f0f0f0f0f0f1" {
+		t.Errorf("AverageHash() = %s, want f0f0f0f0f0f0f0f1", got)
 	}
 }
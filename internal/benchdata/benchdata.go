@@ -0,0 +1,206 @@
+// Package benchdata generates the benchmark corpus: one deterministic image
+// per input layout the hashing code has a fast path (or a slow fallback)
+// for, so that benchmarks report every layout instead of whichever type a
+// single test image happens to decode to.
+//
+// The images are generated on first use rather than committed; the JPEG
+// and GIF ones are encoded and decoded again to get the exact types the
+// standard decoders return.
+package benchdata
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// Image is one input of the corpus
+type Image struct {
+	// Name identifies the layout, e.g. "ycbcr420"; it is used as the
+	// sub-benchmark name
+	Name string
+	// Image is the decoded image
+	Image image.Image
+	// Bytes is the size of the pixel data hashed, for b.SetBytes
+	Bytes int64
+}
+
+var (
+	corpusOnce sync.Once
+	corpus     []Image
+)
+
+// Corpus returns the benchmark images, in a fixed order:
+//
+//	ycbcr420      1920x1080 JPEG, 4:2:0 *image.YCbCr
+//	nrgba-alpha   1024x768 *image.NRGBA with a transparent gradient
+//	screenshot    1280x800 opaque *image.NRGBA of flat panels and text
+//	paletted-gif  800x600 GIF frame, *image.Paletted
+//	gray16        1024x768 *image.Gray16
+//	rgba-padded   1024x768 *image.RGBA sub-image with a padded stride
+//	panorama      12000x600 JPEG, 4:2:0 *image.YCbCr
+//
+// The images are shared and must not be modified.
+func Corpus() []Image {
+	corpusOnce.Do(func() {
+		corpus = []Image{
+			{Name: "ycbcr420", Image: viaJPEG(scene(1920, 1080, 1))},
+			{Name: "nrgba-alpha", Image: withAlpha(scene(1024, 768, 2))},
+			{Name: "screenshot", Image: screenshot(1280, 800, 7)},
+			{Name: "paletted-gif", Image: viaGIF(scene(800, 600, 3))},
+			{Name: "gray16", Image: gray16(scene(1024, 768, 4))},
+			{Name: "rgba-padded", Image: padded(scene(1024, 768, 5))},
+			{Name: "panorama", Image: viaJPEG(scene(12000, 600, 6))},
+		}
+		for i := range corpus {
+			corpus[i].Bytes = pixelBytes(corpus[i].Image)
+		}
+	})
+	return corpus
+}
+
+// scene draws a photo-like w x h image: smooth color gradients, a few
+// bright and dark discs, and light noise, seeded by seed
+func scene(w, h int, seed uint64) *image.RGBA {
+	rng := rand.New(rand.NewPCG(seed, 974))
+	type disc struct {
+		x, y, r float64
+		c       color.RGBA
+	}
+	discs := make([]disc, 12)
+	for i := range discs {
+		discs[i] = disc{
+			x: rng.Float64() * float64(w), y: rng.Float64() * float64(h),
+			r: (0.05 + 0.15*rng.Float64()) * float64(min(w, h)),
+			c: color.RGBA{uint8(rng.IntN(256)), uint8(rng.IntN(256)), uint8(rng.IntN(256)), 255},
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		v := float64(y) / float64(h)
+		for x := range w {
+			u := float64(x) / float64(w)
+			c := color.RGBA{
+				uint8(255 * u),
+				uint8(255 * v),
+				uint8(128 + 100*math.Sin(6*u+3*v)),
+				255,
+			}
+			for _, d := range discs {
+				if dx, dy := float64(x)-d.x, float64(y)-d.y; dx*dx+dy*dy < d.r*d.r {
+					c = d.c
+				}
+			}
+			n := uint8(rng.IntN(8))
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R^n, c.G^n, c.B^n, 255
+		}
+	}
+	return img
+}
+
+// screenshot draws an opaque w x h NRGBA like a screen capture: a title
+// bar, flat panels and lines of dark glyph-sized boxes on white
+func screenshot(w, h int, seed uint64) *image.NRGBA {
+	rng := rand.New(rand.NewPCG(seed, 974))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	fill := func(r image.Rectangle, c color.NRGBA) {
+		draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+	}
+	fill(img.Rect, color.NRGBA{255, 255, 255, 255})
+	fill(image.Rect(0, 0, w, 32), color.NRGBA{45, 45, 48, 255})
+	fill(image.Rect(0, 32, w/5, h), color.NRGBA{240, 240, 244, 255})
+	for y := 48; y+12 < h; y += 20 {
+		x := w/5 + 24
+		for x < w-24 && rng.IntN(12) != 0 {
+			word := 6 * (2 + rng.IntN(8))
+			fill(image.Rect(x, y, min(x+word, w-24), y+12), color.NRGBA{30, 30, 30, 255})
+			x += word + 6
+		}
+	}
+	return img
+}
+
+// viaJPEG returns img encoded and decoded as a JPEG, a 4:2:0 *image.YCbCr
+func viaJPEG(img image.Image) image.Image {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		panic(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// viaGIF returns img encoded and decoded as a single GIF frame, an
+// *image.Paletted
+func viaGIF(img image.Image) image.Image {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, img, &gif.Options{NumColors: 256, Drawer: draw.FloydSteinberg}); err != nil {
+		panic(err)
+	}
+	out, err := gif.Decode(&buf)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// withAlpha returns img as an *image.NRGBA whose alpha falls from opaque
+// at the top to transparent at the bottom
+func withAlpha(img *image.RGBA) *image.NRGBA {
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	for y := range b.Dy() {
+		a := uint8(255 - 255*y/b.Dy())
+		for x := range b.Dx() {
+			i := img.PixOffset(x, y)
+			copy(out.Pix[i:i+3], img.Pix[i:i+3])
+			out.Pix[i+3] = a
+		}
+	}
+	return out
+}
+
+// gray16 returns the luma of img as an *image.Gray16
+func gray16(img *image.RGBA) *image.Gray16 {
+	out := image.NewGray16(img.Bounds())
+	draw.Draw(out, out.Rect, img, image.Point{}, draw.Src)
+	return out
+}
+
+// padded returns img as a sub-image of a larger RGBA, so that its stride
+// is wider than its rows and its origin is not at (0, 0)
+func padded(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	big := image.NewRGBA(image.Rect(0, 0, b.Dx()+37, b.Dy()+11))
+	inner := image.Rect(13, 5, 13+b.Dx(), 5+b.Dy())
+	draw.Draw(big, inner, img, b.Min, draw.Src)
+	return big.SubImage(inner).(*image.RGBA)
+}
+
+// pixelBytes returns the size of the pixel data of img within its bounds
+func pixelBytes(img image.Image) int64 {
+	b := img.Bounds()
+	n := int64(b.Dx()) * int64(b.Dy())
+	switch img := img.(type) {
+	case *image.YCbCr:
+		cw, ch := img.CStride, len(img.Cb)/max(img.CStride, 1)
+		return n + 2*int64(cw)*int64(ch)
+	case *image.Paletted, *image.Gray:
+		return n
+	case *image.Gray16:
+		return 2 * n
+	default:
+		return 4 * n
+	}
+}
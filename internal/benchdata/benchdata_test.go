@@ -0,0 +1,57 @@
+package benchdata
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCorpusLayouts(t *testing.T) {
+	corpus := Corpus()
+	byName := make(map[string]image.Image)
+	for _, in := range corpus {
+		if in.Bytes <= 0 {
+			t.Errorf("%s: Bytes = %d", in.Name, in.Bytes)
+		}
+		byName[in.Name] = in.Image
+	}
+	if len(byName) != 7 {
+		t.Fatalf("corpus has %d distinct images, want 7", len(byName))
+	}
+
+	for _, name := range []string{"ycbcr420", "panorama"} {
+		img, ok := byName[name].(*image.YCbCr)
+		if !ok || img.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+			t.Errorf("%s: %T is not a 4:2:0 YCbCr", name, byName[name])
+		}
+	}
+	if b := byName["panorama"].Bounds(); b.Dx() != 12000 || b.Dy() != 600 {
+		t.Errorf("panorama bounds = %v", b)
+	}
+
+	nrgba, ok := byName["nrgba-alpha"].(*image.NRGBA)
+	if !ok {
+		t.Fatalf("nrgba-alpha: %T", byName["nrgba-alpha"])
+	}
+	if a := nrgba.NRGBAAt(0, nrgba.Rect.Dy()-1).A; a == 255 {
+		t.Error("nrgba-alpha: bottom row is opaque")
+	}
+
+	if shot, ok := byName["screenshot"].(*image.NRGBA); !ok || !shot.Opaque() {
+		t.Errorf("screenshot: %T is not an opaque NRGBA", byName["screenshot"])
+	}
+
+	if _, ok := byName["paletted-gif"].(*image.Paletted); !ok {
+		t.Errorf("paletted-gif: %T", byName["paletted-gif"])
+	}
+	if _, ok := byName["gray16"].(*image.Gray16); !ok {
+		t.Errorf("gray16: %T", byName["gray16"])
+	}
+
+	rgba, ok := byName["rgba-padded"].(*image.RGBA)
+	if !ok {
+		t.Fatalf("rgba-padded: %T", byName["rgba-padded"])
+	}
+	if rgba.Stride == 4*rgba.Rect.Dx() || rgba.Rect.Min == (image.Point{}) {
+		t.Errorf("rgba-padded: stride %d, bounds %v; want a padded sub-image", rgba.Stride, rgba.Rect)
+	}
+}
@@ -0,0 +1,116 @@
+// Package bitio packs and unpacks bit sequences MSB-first, the order used by
+// every serialization of imagehashgo: the first bit written is the most
+// significant bit of the first byte, and a partial final byte is padded
+// with zero bits.
+package bitio
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrShortRead is returned when a BitReader runs out of bits
+var ErrShortRead = errors.New("bitio: not enough bits")
+
+// BitWriter accumulates bits MSB-first. The zero value is ready to use.
+type BitWriter struct {
+	buf []byte
+	n   int
+}
+
+// Append writes a single bit
+func (w *BitWriter) Append(bit bool) {
+	if w.n%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if bit {
+		w.buf[w.n/8] |= 1 << (7 - uint(w.n%8))
+	}
+	w.n++
+}
+
+// WriteBits writes every bit of bits in order
+func (w *BitWriter) WriteBits(bits []bool) {
+	for _, b := range bits {
+		w.Append(b)
+	}
+}
+
+// WriteUint writes the low n bits of v, most significant first
+func (w *BitWriter) WriteUint(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.Append(v&(1<<uint(i)) != 0)
+	}
+}
+
+// Len returns the number of bits written
+func (w *BitWriter) Len() int {
+	return w.n
+}
+
+// Bytes returns the packed bits, zero-padded to a whole byte. The slice
+// aliases the writer's buffer until the next write.
+func (w *BitWriter) Bytes() []byte {
+	return w.buf
+}
+
+// BitReader reads bits MSB-first from a byte slice
+type BitReader struct {
+	buf []byte
+	pos int
+}
+
+// NewBitReader returns a reader over data
+func NewBitReader(data []byte) *BitReader {
+	return &BitReader{buf: data}
+}
+
+// ReadBit reads a single bit
+func (r *BitReader) ReadBit() (bool, error) {
+	if r.pos >= len(r.buf)*8 {
+		return false, ErrShortRead
+	}
+	bit := r.buf[r.pos/8]&(1<<(7-uint(r.pos%8))) != 0
+	r.pos++
+	return bit, nil
+}
+
+// ReadBits reads the next n bits
+func (r *BitReader) ReadBits(n int) ([]bool, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("bitio: negative bit count %d", n)
+	}
+	if r.Remaining() < n {
+		return nil, ErrShortRead
+	}
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i], _ = r.ReadBit()
+	}
+	return bits, nil
+}
+
+// ReadUint reads the next n (at most 64) bits as an unsigned integer,
+// most significant first
+func (r *BitReader) ReadUint(n int) (uint64, error) {
+	if n < 0 || n > 64 {
+		return 0, fmt.Errorf("bitio: cannot read %d bits into a uint64", n)
+	}
+	if r.Remaining() < n {
+		return 0, ErrShortRead
+	}
+	var v uint64
+	for range n {
+		bit, _ := r.ReadBit()
+		v <<= 1
+		if bit {
+			v |= 1
+		}
+	}
+	return v, nil
+}
+
+// Remaining returns the number of unread bits, including padding
+func (r *BitReader) Remaining() int {
+	return len(r.buf)*8 - r.pos
+}
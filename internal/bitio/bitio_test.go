@@ -0,0 +1,89 @@
+package bitio
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBitWriter(t *testing.T) {
+	tests := []struct {
+		name string
+		bits []bool
+		want []byte
+	}{
+		{"empty", nil, nil},
+		{"single one", []bool{true}, []byte{0x80}},
+		{"partial byte", []bool{true, false, true}, []byte{0xa0}},
+		{"full byte", []bool{true, true, true, true, false, false, false, true}, []byte{0xf1}},
+		{"byte boundary plus one", []bool{false, false, false, false, false, false, false, true, true}, []byte{0x01, 0x80}},
+		{"two bytes", []bool{true, false, false, false, false, false, false, false, false, false, false, false, false, false, false, true}, []byte{0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w BitWriter
+			w.WriteBits(tt.bits)
+			if !bytes.Equal(w.Bytes(), tt.want) {
+				t.Errorf("Bytes() = %x, want %x", w.Bytes(), tt.want)
+			}
+			if w.Len() != len(tt.bits) {
+				t.Errorf("Len() = %d, want %d", w.Len(), len(tt.bits))
+			}
+
+			r := NewBitReader(w.Bytes())
+			got, err := r.ReadBits(len(tt.bits))
+			if err != nil {
+				t.Fatalf("ReadBits() error = %v", err)
+			}
+			if !slices.Equal(got, tt.bits) && len(tt.bits) > 0 {
+				t.Errorf("ReadBits() = %v, want %v", got, tt.bits)
+			}
+			if pad := r.Remaining(); pad != len(tt.want)*8-len(tt.bits) {
+				t.Errorf("Remaining() = %d", pad)
+			}
+		})
+	}
+}
+
+func TestUint(t *testing.T) {
+	for _, n := range []int{0, 1, 4, 7, 8, 9, 16, 63, 64} {
+		var v uint64 = 0xfedcba9876543210
+		if n < 64 {
+			v &= 1<<uint(n) - 1
+		}
+		var w BitWriter
+		w.Append(true)
+		w.WriteUint(v, n)
+		r := NewBitReader(w.Bytes())
+		if first, _ := r.ReadBit(); !first {
+			t.Fatalf("n=%d: leading bit lost", n)
+		}
+		got, err := r.ReadUint(n)
+		if err != nil || got != v {
+			t.Errorf("n=%d: ReadUint() = %x, %v; want %x", n, got, err, v)
+		}
+	}
+}
+
+func TestBitReader_Errors(t *testing.T) {
+	r := NewBitReader([]byte{0xff})
+	if _, err := r.ReadBits(9); !errors.Is(err, ErrShortRead) {
+		t.Errorf("ReadBits(9) error = %v, want ErrShortRead", err)
+	}
+	if _, err := r.ReadUint(65); err == nil {
+		t.Error("ReadUint(65) expected error")
+	}
+	if _, err := r.ReadBits(-1); err == nil {
+		t.Error("ReadBits(-1) expected error")
+	}
+	if bits, err := r.ReadBits(8); err != nil || len(bits) != 8 {
+		t.Errorf("ReadBits(8) = %v, %v", bits, err)
+	}
+	if _, err := r.ReadBit(); !errors.Is(err, ErrShortRead) {
+		t.Errorf("ReadBit() at end error = %v, want ErrShortRead", err)
+	}
+	if bits, err := NewBitReader(nil).ReadBits(0); err != nil || len(bits) != 0 {
+		t.Errorf("ReadBits(0) on empty input = %v, %v", bits, err)
+	}
+}
@@ -0,0 +1,109 @@
+// Package conformance builds the image layouts the conformance suite and
+// the hashtest package check fast paths on: every legal but unusual layout
+// of the standard image types, such as sub-images, padded strides,
+// non-zero origins and every YCbCr subsample ratio.
+package conformance
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Layout is one image of the corpus
+type Layout struct {
+	// Name identifies the layout, e.g. "RGBA/stride+offset"
+	Name  string
+	Image image.Image
+}
+
+// Pixel is the color of (x, y) in every corpus image
+func Pixel(x, y int) color.NRGBA {
+	return color.NRGBA{uint8(x*7 + y), uint8(y*5 + x*x), uint8(x ^ (y * 3)), uint8(96 + (x*y)%160)}
+}
+
+// Fill sets every pixel of img to Pixel and returns it
+func Fill(img draw.Image) draw.Image {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			img.Set(x, y, Pixel(x, y))
+		}
+	}
+	return img
+}
+
+// FillYCbCr fills the planes of img with varying samples and returns it
+func FillYCbCr(img *image.YCbCr) *image.YCbCr {
+	for i := range img.Y {
+		img.Y[i] = uint8(i*13 + i/7)
+	}
+	for i := range img.Cb {
+		img.Cb[i] = uint8(i * 5)
+		img.Cr[i] = uint8(255 - i*3)
+	}
+	return img
+}
+
+// GrayPalette is the 256 gray levels
+func GrayPalette() []color.Color {
+	p := make([]color.Color, 256)
+	for i := range p {
+		p[i] = color.Gray{Y: uint8(i)}
+	}
+	return p
+}
+
+// strideRGBA returns an RGBA image whose rows are padded beyond 4*width
+// with garbage that must never be read
+func strideRGBA(r image.Rectangle, pad int) *image.RGBA {
+	img := &image.RGBA{Stride: 4*r.Dx() + pad, Rect: r}
+	img.Pix = make([]uint8, img.Stride*r.Dy())
+	for i := range img.Pix {
+		img.Pix[i] = 0xa5
+	}
+	Fill(img)
+	return img
+}
+
+// Layouts returns the corpus, sorted by name
+func Layouts() []Layout {
+	const w, h = 83, 59
+	r := image.Rect(0, 0, w, h)
+	offset := image.Rect(-17, 23, w-17, h+23)
+
+	images := map[string]image.Image{
+		"RGBA":                Fill(image.NewRGBA(r)),
+		"RGBA/offset":         Fill(image.NewRGBA(offset)),
+		"RGBA/stride":         strideRGBA(r, 12),
+		"RGBA/stride+offset":  strideRGBA(offset, 5*4),
+		"NRGBA":               Fill(image.NewNRGBA(r)),
+		"NRGBA/offset":        Fill(image.NewNRGBA(offset)),
+		"Gray":                Fill(image.NewGray(r)),
+		"Gray/offset":         Fill(image.NewGray(offset)),
+		"RGBA64":              Fill(image.NewRGBA64(r)),
+		"Paletted":            Fill(image.NewPaletted(r, GrayPalette())),
+		"RGBA/sub":            Fill(image.NewRGBA(image.Rect(0, 0, 2*w, 2*h))).(*image.RGBA).SubImage(image.Rect(w/2, h/3, w/2+w, h/3+h)),
+		"NRGBA/sub":           Fill(image.NewNRGBA(image.Rect(0, 0, 2*w, 2*h))).(*image.NRGBA).SubImage(image.Rect(w/2, h/3, w/2+w, h/3+h)),
+		"Gray/sub":            Fill(image.NewGray(image.Rect(0, 0, 2*w, 2*h))).(*image.Gray).SubImage(image.Rect(w/2, h/3, w/2+w, h/3+h)),
+		"RGBA/sub-of-offset":  Fill(image.NewRGBA(offset)).(*image.RGBA).SubImage(offset.Inset(3)),
+		"NRGBA/stride-shared": Fill(image.NewNRGBA(image.Rect(0, 0, w, 2*h))).(*image.NRGBA).SubImage(image.Rect(0, h, w, 2*h)),
+	}
+	for _, ratio := range []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440, image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410,
+	} {
+		images["YCbCr/"+ratio.String()] = FillYCbCr(image.NewYCbCr(r, ratio))
+		images["YCbCr/"+ratio.String()+"/offset"] = FillYCbCr(image.NewYCbCr(offset, ratio))
+		// An odd origin misaligns the sub-image with the chroma blocks
+		images["YCbCr/"+ratio.String()+"/sub"] = FillYCbCr(image.NewYCbCr(image.Rect(0, 0, 2*w, 2*h), ratio)).SubImage(image.Rect(5, 3, 5+w, 3+h))
+	}
+
+	layouts := make([]Layout, 0, len(images))
+	for name, img := range images {
+		layouts = append(layouts, Layout{name, img})
+	}
+	sort.Slice(layouts, func(i, j int) bool { return layouts[i].Name < layouts[j].Name })
+	return layouts
+}
@@ -0,0 +1,101 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+	"time"
+)
+
+// HashKind identifies the algorithm that produced a hash. The values match
+// the names used by the Python imagehash command line tools.
+type HashKind string
+
+const (
+	KindAverage            HashKind = "ahash"
+	KindPerceptual         HashKind = "phash"
+	KindDifference         HashKind = "dhash"
+	KindDifferenceVertical HashKind = "dhash_v"
+)
+
+// Kind returns the algorithm that produced the hash, or "" when unknown
+// (e.g. for hashes parsed from hex)
+func (h *ImageHash) Kind() HashKind {
+	return h.kind
+}
+
+// ParseHashKind returns the HashKind named s
+func ParseHashKind(s string) (HashKind, error) {
+	switch kind := HashKind(s); kind {
+	case KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("unknown hash kind: %q", s)
+	}
+}
+
+// Hash computes a hash of the given kind. PerceptualHash uses the default
+// highfreqFactor of 4. Unlike the algorithm functions, Hash applies
+// WithPreprocess and WithAutoAlgorithm, and it returns an error when
+// hashSize exceeds MaxHashSize.
+func Hash(img image.Image, kind HashKind, hashSize int, opts ...Option) (*ImageHash, error) {
+	if err := checkHashSize(hashSize); err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	if o.logger == nil {
+		return o.hash(img, kind, hashSize, opts)
+	}
+	start := time.Now()
+	h, err := o.hash(img, kind, hashSize, opts)
+	if err != nil {
+		o.logger.Debug("hash failed", "kind", kind, "size", hashSize, "err", err)
+		return nil, err
+	}
+	o.logger.Debug("hash finish", "kind", h.kind, "size", hashSize, "duration", time.Since(start), "hash", h.ToString())
+	return h, nil
+}
+
+// hash is Hash once the hash size is checked
+func (o options) hash(img image.Image, kind HashKind, hashSize int, opts []Option) (*ImageHash, error) {
+	if p := o.preprocess; p != nil {
+		var err error
+		if img, err = p.Apply(img); err != nil {
+			return nil, err
+		}
+	}
+	if o.canceled() {
+		return nil, o.ctx.Err()
+	}
+	if o.autoAlgorithm {
+		gray, sig := o.grayscaleWithSig(img)
+		class := measureClass(gray, sig).class()
+		kind = RecommendedAlgorithms(class)[0]
+		if o.logger != nil {
+			o.logger.Debug("auto algorithm", "class", class, "kind", kind)
+		}
+		// The algorithm functions take *image.Gray as is, so the image is
+		// not converted again
+		img = gray
+	}
+
+	switch kind {
+	case KindAverage:
+		return AverageHash(img, hashSize, opts...), nil
+	case KindPerceptual:
+		return PerceptualHash(img, hashSize, 4, opts...), nil
+	case KindDifference:
+		return DifferenceHash(img, hashSize, opts...), nil
+	case KindDifferenceVertical:
+		return DifferenceHashVertical(img, hashSize, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown hash kind: %q", kind)
+	}
+}
+
+// checkHashSize rejects the hash sizes the algorithm functions panic on
+func checkHashSize(hashSize int) error {
+	if hashSize > MaxHashSize {
+		return fmt.Errorf("hash size %d exceeds the maximum of %d", hashSize, MaxHashSize)
+	}
+	return nil
+}
@@ -0,0 +1,729 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"image"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// The laws below are checked with testing/quick over random hashes of
+// random shapes. Every exported serialization and distance must appear in
+// one of the law tables; TestLawTablesCoverAPI fails otherwise.
+
+// lawConfig makes the law tests reproducible
+func lawConfig() *quick.Config {
+	return &quick.Config{MaxCount: 300, Rand: rand.New(rand.NewSource(1))}
+}
+
+var lawKinds = []HashKind{"", KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical}
+
+// lawShape favours the common square sizes but covers every small shape,
+// including bit counts that are not whole nibbles or bytes
+func lawShape(r *rand.Rand) (rows, cols int) {
+	if r.Intn(4) == 0 {
+		n := []int{4, 8, 16}[r.Intn(3)]
+		return n, n
+	}
+	return 1 + r.Intn(12), 1 + r.Intn(12)
+}
+
+func lawRandomHash(r *rand.Rand, rows, cols int) *ImageHash {
+	bits := make([]bool, rows*cols)
+	for i := range bits {
+		bits[i] = r.Intn(2) == 1
+	}
+	return &ImageHash{hash: bits, rows: rows, cols: cols, kind: lawKinds[r.Intn(len(lawKinds))]}
+}
+
+// lawNear returns a copy of h with up to two bits flipped
+func lawNear(r *rand.Rand, h *ImageHash) *ImageHash {
+	bits := slices.Clone(h.hash)
+	for range r.Intn(3) {
+		i := r.Intn(len(bits))
+		bits[i] = !bits[i]
+	}
+	return &ImageHash{hash: bits, rows: h.rows, cols: h.cols, kind: h.kind}
+}
+
+// lawHash is a random hash for testing/quick
+type lawHash struct{ *ImageHash }
+
+func (lawHash) Generate(r *rand.Rand, _ int) reflect.Value {
+	rows, cols := lawShape(r)
+	return reflect.ValueOf(lawHash{lawRandomHash(r, rows, cols)})
+}
+
+// lawTriple is three hashes of one shape. The second and third are often
+// near the first, so that small and zero distances are exercised too.
+type lawTriple [3]*ImageHash
+
+func (lawTriple) Generate(r *rand.Rand, _ int) reflect.Value {
+	rows, cols := lawShape(r)
+	var t lawTriple
+	t[0] = lawRandomHash(r, rows, cols)
+	for i := 1; i < 3; i++ {
+		if r.Intn(2) == 0 {
+			t[i] = lawNear(r, t[0])
+		} else {
+			t[i] = lawRandomHash(r, rows, cols)
+		}
+	}
+	return reflect.ValueOf(t)
+}
+
+// lawVector is a grayscale vector as returned by GrayVector
+type lawVector []float64
+
+func (lawVector) Generate(r *rand.Rand, _ int) reflect.Value {
+	// A fixed length, so that any three vectors are comparable
+	v := make(lawVector, 16)
+	for i := range v {
+		v[i] = float64(r.Intn(256))
+	}
+	return reflect.ValueOf(v)
+}
+
+// serializationLaw is a round trip through one serialization
+type serializationLaw struct {
+	name string
+	// api lists the exported functions and methods ("Type.Method") the
+	// round trip exercises
+	api []string
+	// applies reports whether the serialization can represent h; nil means
+	// every hash
+	applies   func(h *ImageHash) bool
+	roundTrip func(h *ImageHash) (*ImageHash, error)
+	// keepsShape and keepsKind report what survives besides the bits
+	keepsShape, keepsKind bool
+}
+
+var serializationLaws = []serializationLaw{
+	{
+		name: "hex with shape",
+		api:  []string{"ImageHash.ToString", "HexToHashShape"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			return HexToHashShape(h.ToString(), h.rows, h.cols)
+		},
+		keepsShape: true,
+	},
+	{
+		// Without a shape only whole nibbles can be recovered
+		name:    "hex",
+		api:     []string{"ImageHash.ToString", "HexToHash"},
+		applies: func(h *ImageHash) bool { return len(h.hash)%4 == 0 },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			return HexToHash(h.ToString())
+		},
+	},
+	{
+		// Python pads partial nibbles on the left, ToString on the right
+		name: "python",
+		api:  []string{"ImageHash.ToString", "ParsePythonHash"},
+		applies: func(h *ImageHash) bool {
+			return h.kind != "" && h.rows == h.cols && h.rows >= 2 && len(h.hash)%4 == 0
+		},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			return ParsePythonHash(h.ToString(), string(h.kind), h.rows)
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name:    "uint64",
+		api:     []string{"ImageHash.ToUint64", "FromUint64"},
+		applies: func(h *ImageHash) bool { return len(h.hash) == 64 },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			v, err := h.ToUint64()
+			if err != nil {
+				return nil, err
+			}
+			return FromUint64(v, h.rows, h.cols)
+		},
+		keepsShape: true,
+	},
+	{
+		name:    "uintN",
+		api:     []string{"ImageHash.ToUintN", "FromUint64"},
+		applies: func(h *ImageHash) bool { return len(h.hash) <= 64 },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			v, err := h.ToUintN(len(h.hash))
+			if err != nil {
+				return nil, err
+			}
+			return FromUint64(v, h.rows, h.cols)
+		},
+		keepsShape: true,
+	},
+	{
+		name:    "uint64 LSB-first",
+		api:     []string{"ImageHash.ToUint64LSB", "FromUint64LSB"},
+		applies: func(h *ImageHash) bool { return len(h.hash) == 64 },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			v, err := h.ToUint64LSB()
+			if err != nil {
+				return nil, err
+			}
+			return FromUint64LSB(v, h.rows, h.cols)
+		},
+		keepsShape: true,
+	},
+	{
+		name: "crockford base32",
+		api:  []string{"ImageHash.ToCrockfordBase32", "FromCrockfordBase32"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			return FromCrockfordBase32(h.ToCrockfordBase32(), h.rows, h.cols)
+		},
+		keepsShape: true,
+	},
+	{
+		name:    "compact ID",
+		api:     []string{"ImageHash.ToCompactID", "ParseCompactID"},
+		applies: func(h *ImageHash) bool { return h.kind != "" && h.rows == h.cols },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			id, err := h.ToCompactID()
+			if err != nil {
+				return nil, err
+			}
+			return ParseCompactID(id)
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name: "reverse bit order",
+		api:  []string{"ImageHash.ReverseBitOrder"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			return h.ReverseBitOrder().ReverseBitOrder(), nil
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name: "snapshot",
+		api:  []string{"ImageHash.Snapshot", "FromSnapshot"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			return FromSnapshot(h.Snapshot())
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name: "gob",
+		api:  []string{"ImageHash.GobEncode", "ImageHash.GobDecode"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			data, err := h.GobEncode()
+			if err != nil {
+				return nil, err
+			}
+			var out ImageHash
+			return &out, out.GobDecode(data)
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name: "hash stream",
+		api: []string{"WriteHashes", "ReadHashes", "ReadHashesSeq",
+			"HashWriter.Write", "HashReader.Read"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			var buf bytes.Buffer
+			if err := WriteHashes(&buf, []*ImageHash{h}); err != nil {
+				return nil, err
+			}
+			hs, err := ReadHashes(&buf)
+			if err != nil || len(hs) != 1 {
+				return nil, fmt.Errorf("read %d hashes: %v", len(hs), err)
+			}
+			return hs[0], nil
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name: "packed matrix",
+		api:  []string{"PackMatrix", "WritePackedMatrix", "UnpackMatrix"},
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			data, rowBytes, err := PackMatrix([]*ImageHash{h})
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			if _, err := WritePackedMatrix(&buf, []*ImageHash{h}); err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(buf.Bytes(), data) {
+				return nil, errors.New("WritePackedMatrix and PackMatrix differ")
+			}
+			hs, err := UnpackMatrix(data, rowBytes, h.rows, h.cols)
+			if err != nil {
+				return nil, err
+			}
+			return hs[0], nil
+		},
+		keepsShape: true,
+	},
+	{
+		name:    "budgeted",
+		api:     []string{"PackBudgeted", "UnpackBudgeted"},
+		applies: func(h *ImageHash) bool { return h.kind != "" },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			data, err := PackBudgeted(map[HashKind]*ImageHash{h.kind: h}, 4+(len(h.hash)+7)/8)
+			if err != nil {
+				return nil, err
+			}
+			hs, err := UnpackBudgeted(data)
+			return hs[h.kind].Hash, err
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+	{
+		name:    "ensemble string",
+		api:     []string{"EnsembleHashes.String", "ParseEnsembleHashes"},
+		applies: func(h *ImageHash) bool { return h.kind != "" },
+		roundTrip: func(h *ImageHash) (*ImageHash, error) {
+			e, err := ParseEnsembleHashes(EnsembleHashes{h.kind: h}.String())
+			return e[h.kind], err
+		},
+		keepsShape: true,
+		keepsKind:  true,
+	},
+}
+
+func TestSerializationLaws(t *testing.T) {
+	for _, law := range serializationLaws {
+		t.Run(law.name, func(t *testing.T) {
+			prop := func(lh lawHash) bool {
+				h := lh.ImageHash
+				if law.applies != nil && !law.applies(h) {
+					return true
+				}
+				got, err := law.roundTrip(h)
+				if err != nil {
+					t.Logf("(%d, %d): %v", h.rows, h.cols, err)
+					return false
+				}
+				return slices.Equal(got.hash, h.hash) &&
+					(!law.keepsShape || got.rows == h.rows && got.cols == h.cols) &&
+					(!law.keepsKind || got.kind == h.kind)
+			}
+			if err := quick.Check(prop, lawConfig()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// metricLaw is one way of computing the Hamming distance between hashes
+type metricLaw struct {
+	name string
+	api  []string
+	dist func(a, b *ImageHash) (float64, error)
+	// normalized distances are divided by the bit count
+	normalized bool
+	// mismatchFails is whether hashes of different shapes are rejected;
+	// packed bytes and cross-size comparisons do not see the shape
+	mismatchFails bool
+}
+
+var metricLaws = []metricLaw{
+	{
+		name: "Distance",
+		api:  []string{"ImageHash.Distance"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			d, err := a.Distance(b)
+			return float64(d), err
+		},
+		mismatchFails: true,
+	},
+	{
+		name: "MaskedDistance without mask",
+		api:  []string{"ImageHash.MaskedDistance"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			d, err := a.MaskedDistance(b, make([]bool, len(a.hash)))
+			return float64(d), err
+		},
+		mismatchFails: true,
+	},
+	{
+		name: "DistanceToBytes",
+		api:  []string{"ImageHash.DistanceToBytes"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			d, err := a.DistanceToBytes(b.Snapshot().Bits)
+			return float64(d), err
+		},
+	},
+	{
+		name: "DistanceBytes",
+		api:  []string{"DistanceBytes"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			d, err := DistanceBytes(a.Snapshot().Bits, b.Snapshot().Bits, len(a.hash))
+			return float64(d), err
+		},
+	},
+	{
+		name: "DistanceMatrix",
+		api:  []string{"DistanceMatrix", "DistanceMatrixInto"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			if d := DistanceMatrix([]*ImageHash{a, b})[1]; d >= 0 {
+				return float64(d), nil
+			}
+			return 0, errors.New("shape mismatch")
+		},
+		mismatchFails: true,
+	},
+	{
+		name: "NearestN",
+		api:  []string{"NearestN", "NearestNInto"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			if m := NearestN(a, []*ImageHash{b}, 1); len(m) == 1 {
+				return float64(m[0].Distance), nil
+			}
+			return 0, errors.New("shape mismatch")
+		},
+		mismatchFails: true,
+	},
+	{
+		name: "Explain",
+		api:  []string{"Explain"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			e, err := Explain(a, b)
+			return float64(e.Distance), err
+		},
+		mismatchFails: true,
+	},
+	{
+		name: "Explain normalized",
+		api:  []string{"Explain"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			e, err := Explain(a, b)
+			return e.Normalized, err
+		},
+		normalized:    true,
+		mismatchFails: true,
+	},
+	{
+		name: "CombinedHash.Distance with both halves",
+		api:  []string{"CombinedHash.Distance", "CombinedHash.Hash"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			d, _, err := CombinedHash{a, a}.Distance(CombinedHash{b, b})
+			return d, err
+		},
+		normalized:    true,
+		mismatchFails: true,
+	},
+	{
+		name: "CrossSizeDistance",
+		api:  []string{"CrossSizeDistance"},
+		dist: func(a, b *ImageHash) (float64, error) {
+			return CrossSizeDistance(a, b)
+		},
+		normalized: true,
+	},
+}
+
+func TestMetricLaws(t *testing.T) {
+	for _, law := range metricLaws {
+		t.Run(law.name, func(t *testing.T) {
+			prop := func(tr lawTriple) bool {
+				a, b, c := tr[0], tr[1], tr[2]
+				want, _ := a.Distance(b)
+				if law.normalized {
+					// Exact: both sides are the same integer division
+					return law.checkMetric(t, a, b, c, float64(want)/float64(len(a.hash)))
+				}
+				return law.checkMetric(t, a, b, c, float64(want))
+			}
+			if err := quick.Check(prop, lawConfig()); err != nil {
+				t.Error(err)
+			}
+
+			if !law.mismatchFails {
+				return
+			}
+			mismatch := func(lh lawHash) bool {
+				h := lh.ImageHash
+				other := &ImageHash{hash: make([]bool, (h.rows+1)*h.cols), rows: h.rows + 1, cols: h.cols}
+				_, err1 := law.dist(h, other)
+				_, err2 := law.dist(other, h)
+				return err1 != nil && err2 != nil
+			}
+			if err := quick.Check(mismatch, lawConfig()); err != nil {
+				t.Errorf("shape mismatch: %v", err)
+			}
+		})
+	}
+}
+
+// checkMetric verifies that law agrees with Distance (want is d(a, b)) and
+// is a metric: zero exactly on equal hashes, symmetric, within range and
+// obeying the triangle inequality
+func (law metricLaw) checkMetric(t *testing.T, a, b, c *ImageHash, want float64) bool {
+	var d [3][3]float64
+	hs := []*ImageHash{a, b, c}
+	for i := range 3 {
+		for j := range 3 {
+			var err error
+			if d[i][j], err = law.dist(hs[i], hs[j]); err != nil {
+				t.Logf("(%d, %d): %v", a.rows, a.cols, err)
+				return false
+			}
+		}
+	}
+	limit := float64(len(a.hash))
+	if law.normalized {
+		limit = 1
+	}
+	const eps = 1e-12
+	for i := range 3 {
+		for j := range 3 {
+			switch {
+			case d[i][j] < 0 || d[i][j] > limit:
+				t.Logf("d = %v outside [0, %v]", d[i][j], limit)
+				return false
+			case (d[i][j] == 0) != slices.Equal(hs[i].hash, hs[j].hash):
+				t.Logf("d = %v for equal=%v", d[i][j], slices.Equal(hs[i].hash, hs[j].hash))
+				return false
+			case d[i][j] != d[j][i]:
+				t.Logf("asymmetric: %v vs %v", d[i][j], d[j][i])
+				return false
+			}
+			for k := range 3 {
+				if d[i][k] > d[i][j]+d[j][k]+eps {
+					t.Logf("triangle: %v > %v + %v", d[i][k], d[i][j], d[j][k])
+					return false
+				}
+			}
+		}
+	}
+	if d[0][1] != want {
+		t.Logf("distance %v, Distance gives %v", d[0][1], want)
+		return false
+	}
+	return true
+}
+
+// propertyLaws are the remaining laws, each a function for quick.Check
+var propertyLaws = []struct {
+	name  string
+	api   []string
+	check any
+}{
+	{
+		name: "MaskedDistance <= Distance",
+		api:  []string{"ImageHash.MaskedDistance", "RegionMask"},
+		check: func(tr lawTriple, x0, y0, x1, y1 uint8) bool {
+			a, b := tr[0], tr[1]
+			mask := RegionMask(percentRect(x0, y0, x1, y1), a.rows, a.cols)
+			masked, err := a.MaskedDistance(b, mask)
+			full, _ := a.Distance(b)
+			back, _ := b.MaskedDistance(a, mask)
+			return err == nil && masked <= full && masked == back
+		},
+	},
+	{
+		name: "Fold does not increase Distance",
+		api:  []string{"ImageHash.Fold"},
+		check: func(tr lawTriple) bool {
+			a, b := tr[0], tr[1]
+			d, _ := a.Distance(b)
+			for rows := a.rows; ; rows /= 2 {
+				fa, err := a.Fold(rows)
+				fb, _ := b.Fold(rows)
+				if err != nil {
+					return false
+				}
+				folded, _ := fa.Distance(fb)
+				if folded > d || (rows == a.rows && !slices.Equal(fa.hash, a.hash)) {
+					return false
+				}
+				if rows%2 != 0 {
+					return true
+				}
+			}
+		},
+	},
+	{
+		name: "ConstantTimeMatch agrees with Distance",
+		api:  []string{"ConstantTimeMatch"},
+		check: func(tr lawTriple, slack int8) bool {
+			a, b := tr[0], tr[1]
+			d, _ := a.Distance(b)
+			maxDist := d + int(slack)%3
+			ok, err := ConstantTimeMatch(a, b, maxDist)
+			return err == nil && ok == (d <= maxDist)
+		},
+	},
+	{
+		name: "ColorSig.Distance is a normalized metric",
+		api:  []string{"ColorSig.Distance"},
+		check: func(a, b, c ColorSig) bool {
+			ab, ba, bc, ac := a.Distance(b), b.Distance(a), b.Distance(c), a.Distance(c)
+			return a.Distance(a) == 0 && (ab == 0) == (a == b) && ab == ba &&
+				ab >= 0 && ab <= 1 && ac <= ab+bc+1e-12
+		},
+	},
+	{
+		name: "L1Distance and L2Distance are metrics",
+		api:  []string{"L1Distance", "L2Distance"},
+		check: func(a, b, c lawVector) bool {
+			for _, dist := range []func(a, b []float64) (float64, error){L1Distance, L2Distance} {
+				ab, _ := dist(a, b)
+				ba, _ := dist(b, a)
+				bc, _ := dist(b, c)
+				ac, _ := dist(a, c)
+				aa, _ := dist(a, a)
+				if aa != 0 || (ab == 0) != slices.Equal(a, b) || math.Abs(ab-ba) > 1e-9 || ac > ab+bc+1e-9 {
+					return false
+				}
+			}
+			return true
+		},
+	},
+	{
+		name: "CrossSizeDistance of pooled hashes",
+		api:  []string{"CrossSizeDistance"},
+		check: func(lh lawHash) bool {
+			// A hash against itself scaled up by 2 is at distance 0
+			h := lh.ImageHash
+			big := &ImageHash{hash: make([]bool, 4*len(h.hash)), rows: 2 * h.rows, cols: 2 * h.cols}
+			for y := range big.rows {
+				for x := range big.cols {
+					big.hash[y*big.cols+x] = h.hash[y/2*h.cols+x/2]
+				}
+			}
+			d1, err1 := CrossSizeDistance(big, h)
+			d2, err2 := CrossSizeDistance(h, big)
+			return err1 == nil && err2 == nil && d1 == 0 && d2 == 0
+		},
+	},
+}
+
+// percentRect builds a rectangle in percent from four random bytes
+func percentRect(x0, y0, x1, y1 uint8) image.Rectangle {
+	return image.Rect(int(x0)%101, int(y0)%101, int(x1)%101, int(y1)%101)
+}
+
+func TestPropertyLaws(t *testing.T) {
+	for _, law := range propertyLaws {
+		t.Run(law.name, func(t *testing.T) {
+			if err := quick.Check(law.check, lawConfig()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// serializationPattern and metricPattern match the exported names that
+// must be covered by a law
+var (
+	serializationPattern = regexp.MustCompile(`^(To|From|HexTo|Parse|Pack|Unpack|Write|Read|Gob|Marshal|Unmarshal|Snapshot)`)
+	metricPattern        = regexp.MustCompile(`Distance|Match|Nearest`)
+)
+
+// lawExempt lists exported names matching the patterns that are not hash
+// serializations or hash metrics, and where they are tested instead
+var lawExempt = map[string]string{
+	"ToGrayscale":              "image conversion",
+	"ToGrayscaleFast":          "image conversion",
+	"ParseHashKind":            "parses a kind name, not a hash",
+	"Explanation.MarshalJSON":  "one-way rendering, TestExplainRender",
+	"ShiftTolerantDistance":    "compares images, TestShiftTolerantDistanceIdentity",
+	"Ensemble.Match":           "evaluates a rule over several hashes, TestEnsemble_Match",
+	"MatchProfile.Match":       "evaluates a profile over two images, TestMatchProfile_Builtin",
+	"MatchProfile.MatchHashes": "evaluates a profile over several hashes, TestLoadMatchProfile",
+	"MatchPair":                "evaluates a profile over two images, TestMatchPair_FramedCopy",
+	"LoadMatchProfile":         "reads a rules file, not a hash, TestLoadMatchProfile_Errors",
+	"BuiltinMatchProfile":      "returns a built-in rules profile, TestMatchProfile_Builtin",
+	"SketchDistance":           "only approximates Distance, TestSketch_Correlation",
+	"MatchProbability":         "maps a distance to a probability, TestMatchProbability_Monotonic",
+	"MaxMeaningfulDistance":    "reads a calibration table, TestMaxMeaningfulDistance",
+	"BudgetedHash.Distance":    "folds, then defers to Distance, TestPackBudgeted_RoundTrip",
+}
+
+// TestLawTablesCoverAPI fails when an exported serialization or distance
+// is added without a law, or a law names an API that no longer exists
+func TestLawTablesCoverAPI(t *testing.T) {
+	exported := exportedAPI(t)
+	covered := make(map[string]bool)
+	var listed []string
+	for _, law := range serializationLaws {
+		listed = append(listed, law.api...)
+	}
+	for _, law := range metricLaws {
+		listed = append(listed, law.api...)
+	}
+	for _, law := range propertyLaws {
+		listed = append(listed, law.api...)
+	}
+	for name := range lawExempt {
+		listed = append(listed, name)
+	}
+	for _, name := range listed {
+		if !exported[name] {
+			t.Errorf("law table lists %s, which is not an exported function or method", name)
+		}
+		covered[name] = true
+	}
+
+	for name := range exported {
+		method := name[strings.LastIndex(name, ".")+1:]
+		if (serializationPattern.MatchString(method) || metricPattern.MatchString(method)) && !covered[name] {
+			t.Errorf("%s looks like a serialization or distance but has no law; add it to a law table or to lawExempt", name)
+		}
+	}
+}
+
+// exportedAPI returns the exported functions and methods ("Type.Method")
+// declared in the package's non-test files
+func exportedAPI(t *testing.T) map[string]bool {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	api := make(map[string]bool)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !fn.Name.IsExported() {
+				continue
+			}
+			name := fn.Name.Name
+			if fn.Recv != nil {
+				typ := fn.Recv.List[0].Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+				ident, ok := typ.(*ast.Ident)
+				if !ok || !ident.IsExported() {
+					continue
+				}
+				name = ident.Name + "." + name
+			}
+			api[name] = true
+		}
+	}
+	return api
+}
@@ -0,0 +1,167 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sync"
+)
+
+// medianPool holds scratch buffers for Median
+var medianPool = sync.Pool{
+	New: func() any {
+		p := make([]float64, 0, 256)
+		return &p
+	},
+}
+
+// MedianStrategy selects the median that PerceptualHash and
+// TextPerceptualHash threshold their coefficients at, see WithMedian. The
+// strategies only differ for an even number of values; for odd counts
+// they all return the middle value, as numpy.median does.
+type MedianStrategy int
+
+const (
+	// MedianInterpolated is the mean of the two middle values, as
+	// numpy.median and so Python imagehash. It is the default.
+	MedianInterpolated MedianStrategy = iota
+	// MedianLower is the lower of the two middle values
+	MedianLower
+	// MedianHigher is the higher of the two middle values
+	MedianHigher
+)
+
+// String returns the name of the strategy
+func (s MedianStrategy) String() string {
+	switch s {
+	case MedianInterpolated:
+		return "interpolated"
+	case MedianLower:
+		return "lower"
+	case MedianHigher:
+		return "higher"
+	default:
+		return fmt.Sprintf("MedianStrategy(%d)", int(s))
+	}
+}
+
+// Median returns the median of data with numpy.median's rule: the middle
+// value for odd lengths and the mean of the two middle values for even
+// lengths. It is MedianInterpolated.Median.
+func Median(data []float64) float64 {
+	return MedianInterpolated.Median(data)
+}
+
+// Median returns the median of data under strategy s without modifying
+// data. It returns 0 for an empty slice and NaN if any value is NaN;
+// unknown strategies interpolate.
+//
+// It runs a quickselect over a pooled copy of data, falling back to
+// median-of-medians pivots when partitioning degenerates, so it is O(n)
+// even for adversarial inputs.
+func (s MedianStrategy) Median(data []float64) float64 {
+	n := len(data)
+	if n == 0 {
+		return 0
+	}
+
+	scratchPtr := medianPool.Get().(*[]float64)
+	defer medianPool.Put(scratchPtr)
+	scratch := append((*scratchPtr)[:0], data...)
+	*scratchPtr = scratch
+	for _, v := range scratch {
+		if math.IsNaN(v) {
+			return math.NaN()
+		}
+	}
+
+	k := n / 2
+	selectKth(scratch, k)
+	if n%2 == 1 || s == MedianHigher {
+		return scratch[k]
+	}
+	// selectKth leaves the smaller half in scratch[:k], so the lower middle
+	// value is its maximum
+	lower := scratch[0]
+	for _, v := range scratch[1:k] {
+		lower = max(lower, v)
+	}
+	if s == MedianLower {
+		return lower
+	}
+	return (lower + scratch[k]) / 2
+}
+
+// selectKth reorders a so that a[k] is the value it would have if a were
+// sorted, with no larger values before it and no smaller ones after it.
+// a must not contain NaN.
+func selectKth(a []float64, k int) {
+	lo, hi := 0, len(a)-1
+	// After this many median-of-three rounds, switch to median-of-medians
+	budget := 2 * bits.Len(uint(len(a)))
+	for lo < hi {
+		var pivot float64
+		if budget > 0 {
+			budget--
+			pivot = medianOfThree(a[lo], a[lo+(hi-lo)/2], a[hi])
+		} else {
+			pivot = medianOfMedians(a[lo : hi+1])
+		}
+
+		// Three-way partition: a[lo:lt] < pivot, a[lt:gt+1] == pivot,
+		// a[gt+1:hi+1] > pivot. The pivot is an element of a, so the
+		// middle part is never empty and every round makes progress.
+		lt, i, gt := lo, lo, hi
+		for i <= gt {
+			switch {
+			case a[i] < pivot:
+				a[lt], a[i] = a[i], a[lt]
+				lt++
+				i++
+			case a[i] > pivot:
+				a[i], a[gt] = a[gt], a[i]
+				gt--
+			default:
+				i++
+			}
+		}
+
+		switch {
+		case k < lt:
+			hi = lt - 1
+		case k > gt:
+			lo = gt + 1
+		default:
+			return
+		}
+	}
+}
+
+func medianOfThree(a, b, c float64) float64 {
+	if a > b {
+		a, b = b, a
+	}
+	if b > c {
+		b = c
+	}
+	return max(a, b)
+}
+
+// medianOfMedians returns an element of a guaranteed to have at least ~30%
+// of a on either side, without modifying a
+func medianOfMedians(a []float64) float64 {
+	medians := make([]float64, 0, (len(a)+4)/5)
+	var group [5]float64
+	for start := 0; start < len(a); start += 5 {
+		g := group[:copy(group[:], a[start:min(start+5, len(a))])]
+		// Insertion sort the group of at most five
+		for i := 1; i < len(g); i++ {
+			for j := i; j > 0 && g[j] < g[j-1]; j-- {
+				g[j], g[j-1] = g[j-1], g[j]
+			}
+		}
+		medians = append(medians, g[len(g)/2])
+	}
+	selectKth(medians, len(medians)/2)
+	return medians[len(medians)/2]
+}
@@ -0,0 +1,262 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+	"math"
+	mathrand "math/rand"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"testing"
+	"testing/quick"
+)
+
+// referenceMedian is the sort-based median Median must agree with
+func referenceMedian(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(data)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[n/2]
+}
+
+func TestMedian_MatchesSort(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for trial := range 2000 {
+		n := 1 + r.IntN(300)
+		data := make([]float64, n)
+		for i := range data {
+			switch trial % 4 {
+			case 0: // continuous values
+				data[i] = r.NormFloat64()
+			case 1: // many duplicates
+				data[i] = float64(r.IntN(5))
+			case 2: // sorted, a common worst case for naive pivots
+				data[i] = float64(i)
+			default: // organ pipe
+				data[i] = float64(min(i, n-i))
+			}
+		}
+		original := slices.Clone(data)
+		if got, want := Median(data), referenceMedian(data); got != want {
+			t.Fatalf("trial %d (n=%d): Median = %v, want %v", trial, n, got, want)
+		}
+		if !slices.Equal(data, original) {
+			t.Fatalf("trial %d: Median modified its input", trial)
+		}
+	}
+}
+
+func TestMedian_EdgeCases(t *testing.T) {
+	if got := Median(nil); got != 0 {
+		t.Errorf("Median(nil) = %v, want 0", got)
+	}
+	if got := Median([]float64{1, math.NaN(), 3}); !math.IsNaN(got) {
+		t.Errorf("Median with NaN = %v, want NaN", got)
+	}
+	if got := Median([]float64{math.Inf(-1), 2, math.Inf(1), 3}); got != 2.5 {
+		t.Errorf("Median with infinities = %v, want 2.5", got)
+	}
+	if got := Median([]float64{-0.0, 0.0}); got != 0 {
+		t.Errorf("Median of signed zeros = %v, want 0", got)
+	}
+}
+
+func TestSelectKth_MedianOfMediansFallback(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	data := make([]float64, 5000)
+	for i := range data {
+		data[i] = float64(r.IntN(1000))
+	}
+	want := slices.Sorted(slices.Values(data))
+	for _, k := range []int{0, 1, 2499, 2500, 4999} {
+		a := slices.Clone(data)
+		if got := medianOfMedians(a); !slices.Contains(data, got) {
+			t.Fatalf("medianOfMedians returned %v, not an element", got)
+		}
+		selectKth(a, k)
+		if a[k] != want[k] {
+			t.Errorf("selectKth(%d) = %v, want %v", k, a[k], want[k])
+		}
+	}
+}
+
+// numpyMedian follows numpy.median: sort (numpy partitions), then
+// np.mean of the middle slice, one value for odd n and two for even n,
+// computed as their sum divided by the count. NaN propagates.
+func numpyMedian(data []float64) float64 {
+	n := len(data)
+	if n == 0 {
+		return 0
+	}
+	sorted := slices.Clone(data)
+	sort.Float64s(sorted)
+	if math.IsNaN(sorted[0]) {
+		// sort.Float64s orders NaN first
+		return math.NaN()
+	}
+	lo, hi := n/2, n/2+1
+	if n%2 == 0 {
+		lo--
+	}
+	var sum float64
+	for _, v := range sorted[lo:hi] {
+		sum += v
+	}
+	return sum / float64(hi-lo)
+}
+
+// referenceStrategy is the sort-based median of each strategy
+func referenceStrategy(s MedianStrategy, data []float64) float64 {
+	switch s {
+	case MedianLower:
+		sorted := slices.Sorted(slices.Values(data))
+		return sorted[(len(data)-1)/2]
+	case MedianHigher:
+		sorted := slices.Sorted(slices.Values(data))
+		return sorted[len(data)/2]
+	default:
+		return numpyMedian(data)
+	}
+}
+
+var medianStrategies = []MedianStrategy{MedianInterpolated, MedianLower, MedianHigher}
+
+// TestMedianStrategy_Quick checks every strategy against its reference on
+// random data of both parities: each generated slice is also checked
+// without its last value.
+func TestMedianStrategy_Quick(t *testing.T) {
+	sameFloat := func(a, b float64) bool {
+		return a == b || math.IsNaN(a) && math.IsNaN(b)
+	}
+	property := func(data []float64, dupes uint8) bool {
+		if len(data) == 0 {
+			return true
+		}
+		// Repeated values, so that the middle values are often equal
+		for i := range int(dupes) % len(data) {
+			data[i] = data[len(data)-1-i]
+		}
+		for _, d := range [][]float64{data, data[:len(data)-1]} {
+			if len(d) == 0 {
+				continue
+			}
+			for _, s := range medianStrategies {
+				if got, want := s.Median(d), referenceStrategy(s, d); !sameFloat(got, want) {
+					t.Logf("%s.Median(%v) = %v, want %v", s, d, got, want)
+					return false
+				}
+			}
+			if got := Median(d); !sameFloat(got, numpyMedian(d)) {
+				t.Logf("Median(%v) = %v, numpy %v", d, got, numpyMedian(d))
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 2000, Rand: mathrand.New(mathrand.NewSource(962))}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMedianStrategy_Parity(t *testing.T) {
+	odd := []float64{5, 1, 4, 2, 3}
+	even := []float64{4, 1, 3, 2}
+	for _, s := range medianStrategies {
+		if got := s.Median(odd); got != 3 {
+			t.Errorf("%s.Median(odd) = %v, want the middle value 3", s, got)
+		}
+	}
+	want := map[MedianStrategy]float64{MedianInterpolated: 2.5, MedianLower: 2, MedianHigher: 3}
+	for s, w := range want {
+		if got := s.Median(even); got != w {
+			t.Errorf("%s.Median(even) = %v, want %v", s, got, w)
+		}
+	}
+	if got := MedianStrategy(9).String(); got != "MedianStrategy(9)" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+// TestWithMedian checks that the fast DCT paths and the generic one all
+// threshold at the selected median, against the captured DCT input
+func TestWithMedian(t *testing.T) {
+	img := noiseImage(90, 70, 962)
+	for _, c := range []struct {
+		name                     string
+		hashSize, highfreqFactor int
+	}{
+		{"fast16", 4, 4},
+		{"fast32", 8, 4},
+		{"fast64", 8, 8},
+		{"generic", 6, 4},
+		{"odd", 5, 4},
+	} {
+		for _, s := range medianStrategies {
+			var gray *image.Gray
+			got := PerceptualHash(img, c.hashSize, c.highfreqFactor, WithMedian(s), WithCaptureIntermediate(&gray))
+			dct := DCT2D(grayMatrix(gray))
+			var low []float64
+			for y := range c.hashSize {
+				low = append(low, dct[y][:c.hashSize]...)
+			}
+			want := thresholdHash(low, c.hashSize, c.hashSize, s.Median)
+			if got.ToString() != want.ToString() {
+				t.Errorf("%s/%s: got %s, want %s", c.name, s, got.ToString(), want.ToString())
+			}
+		}
+	}
+
+	// Without ties, MedianHigher leaves the upper middle value unset
+	page := textPage(962)
+	base := TextPerceptualHash(page, 8)
+	higher := TextPerceptualHash(page, 8, WithMedian(MedianHigher))
+	if d, _ := base.Distance(higher); d != 1 {
+		t.Errorf("TextPerceptualHash with MedianHigher is %d bits from the default, want 1", d)
+	}
+
+	def, _ := NewHasher(KindPerceptual, 8)
+	lower, _ := NewHasher(KindPerceptual, 8, WithMedian(MedianLower))
+	if def.Fingerprint() == lower.Fingerprint() {
+		t.Errorf("fingerprint %q does not record the median strategy", lower.Fingerprint())
+	}
+}
+
+// grayMatrix converts a grayscale image to the matrix DCT2D takes
+func grayMatrix(gray *image.Gray) [][]float64 {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	m := make([][]float64, h)
+	for y := range h {
+		m[y] = make([]float64, w)
+		for x := range w {
+			m[y][x] = float64(gray.Pix[y*gray.Stride+x])
+		}
+	}
+	return m
+}
+
+func BenchmarkMedian(b *testing.B) {
+	r := rand.New(rand.NewPCG(5, 6))
+	for _, n := range []int{64, 256, 4096} {
+		data := make([]float64, n)
+		for i := range data {
+			data[i] = r.NormFloat64()
+		}
+		b.Run(fmt.Sprintf("quickselect/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				Median(data)
+			}
+		})
+		b.Run(fmt.Sprintf("sort/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				referenceMedian(data)
+			}
+		})
+	}
+}
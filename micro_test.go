@@ -0,0 +1,136 @@
+package imagehashgo
+
+import (
+	"image"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// smoothImage returns a random smooth 64x48 image: a sum of a few
+// sinusoids, closer to photographs than per-pixel noise
+func smoothImage(r *rand.Rand) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 64, 48))
+	type wave struct{ fx, fy, phase, amp float64 }
+	waves := make([]wave, 3)
+	for i := range waves {
+		waves[i] = wave{r.Float64() * 6, r.Float64() * 6, r.Float64() * 2 * math.Pi, r.Float64()}
+	}
+	for y := range 48 {
+		for x := range 64 {
+			v := 0.0
+			for _, w := range waves {
+				v += w.amp * math.Sin(w.fx*float64(x)/64*2*math.Pi+w.fy*float64(y)/48*2*math.Pi+w.phase)
+			}
+			img.Pix[y*img.Stride+x] = uint8(min(max(128+40*v, 0), 255))
+		}
+	}
+	return img
+}
+
+func TestMicroHash_RoundTrip(t *testing.T) {
+	img := getBenchImage()
+	for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical} {
+		h, _ := Hash(img, kind, 4)
+		if h.rows != 4 || h.cols != 4 || len(h.hash) != 16 {
+			t.Fatalf("%s: shape (%d, %d)", kind, h.rows, h.cols)
+		}
+		s := h.ToString()
+		back, err := HexToHash(s)
+		if err != nil || len(s) != 4 {
+			t.Fatalf("%s: HexToHash(%q) = %v", kind, s, err)
+		}
+		if d, err := h.Distance(back); err != nil || d != 0 {
+			t.Errorf("%s: hex round trip distance %d, %v", kind, d, err)
+		}
+
+		v, err := h.ToUintN(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := uint64Hash(v, 4, 4); got.ToString() != s {
+			t.Errorf("%s: ToUintN(16) = %#x, unpacks to %s, want %s", kind, v, got.ToString(), s)
+		}
+	}
+
+	h := AverageHash(img, 8)
+	if _, err := h.ToUintN(16); err == nil {
+		t.Error("ToUintN(16) on a 64-bit hash should fail")
+	}
+	if _, err := h.ToUintN(65); err == nil {
+		t.Error("ToUintN(65) should fail")
+	}
+	if v, err := h.ToUintN(64); err != nil || v != mustUint64(t, h) {
+		t.Errorf("ToUintN(64) = %#x, %v; want ToUint64", v, err)
+	}
+}
+
+func mustUint64(t *testing.T, h *ImageHash) uint64 {
+	t.Helper()
+	v, err := h.ToUint64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestPerceptualHash_Fast16MatchesDCT2D(t *testing.T) {
+	r := rand.New(rand.NewPCG(8, 9))
+	for range 200 {
+		img := smoothImage(r)
+		resized := resizeGray(img, 16, 16)
+		for _, hashSize := range []int{2, 4, 8} {
+			matrix := make([][]float64, 16)
+			for y := range matrix {
+				matrix[y] = make([]float64, 16)
+				for x := range matrix[y] {
+					matrix[y][x] = float64(resized.Pix[y*resized.Stride+x])
+				}
+			}
+			dct := DCT2D(matrix)
+			low := make([]float64, 0, hashSize*hashSize)
+			for y := range hashSize {
+				low = append(low, dct[y][:hashSize]...)
+			}
+			med := Median(low)
+
+			fast := perceptualHashFast16(resized, hashSize, MedianInterpolated)
+			for i, v := range low {
+				if fast.hash[i] != (v > med) {
+					t.Fatalf("hashSize %d: bit %d differs from the DCT2D path", hashSize, i)
+				}
+			}
+		}
+	}
+}
+
+// TestMicroHash_Collisions measures how often unrelated images share a
+// 16-bit hash, the figure quoted in the README
+func TestMicroHash_Collisions(t *testing.T) {
+	const n = 1000
+	r := rand.New(rand.NewPCG(10, 11))
+	images := make([]*image.Gray, n)
+	for i := range images {
+		images[i] = smoothImage(r)
+	}
+
+	for _, kind := range []HashKind{KindAverage, KindPerceptual, KindDifference} {
+		counts := make(map[uint64]int)
+		for _, img := range images {
+			h, _ := Hash(img, kind, 4)
+			v, _ := h.ToUintN(16)
+			counts[v]++
+		}
+		pairs := 0
+		for _, c := range counts {
+			pairs += c * (c - 1) / 2
+		}
+		rate := float64(pairs) / float64(n*(n-1)/2)
+		t.Logf("%-5s 16-bit: %d distinct of %d, colliding pair rate %.2e (uniform %.2e)", kind, len(counts), n, rate, 1.0/65536)
+		// Real hashes are far from uniform, but a 16-bit prefilter is still
+		// only useful if collisions stay rare
+		if rate > 0.01 {
+			t.Errorf("%s: colliding pair rate %.2e is above 1%%", kind, rate)
+		}
+	}
+}
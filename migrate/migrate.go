@@ -0,0 +1,350 @@
+// Package migrate plans the move of stored hashes to a new hashing
+// configuration, such as a new option that changes the hash bits, when the
+// originals can only be re-decoded a few at a time. PlanMigration hashes a
+// random sample of them under both configurations and fits how often every
+// bit flips; the resulting MigrationModel gives the threshold to use when
+// comparing a hash of the old configuration with one of the new, for as
+// long as both kinds are stored.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/rand/v2"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// StoredHash is a hash computed under the old configuration, with the ID
+// its original is fetched by
+type StoredHash struct {
+	ID   uint64
+	Hash *imagehashgo.ImageHash
+}
+
+// DefaultSampleSize is the number of originals PlanMigration re-hashes
+// unless WithSampleSize is given
+const DefaultSampleSize = 200
+
+// DefaultRecall is the share of old-configuration matches AdjustThreshold
+// keeps unless WithRecall is given
+const DefaultRecall = 0.95
+
+// PlanOption configures PlanMigration
+type PlanOption func(*planOptions)
+
+type planOptions struct {
+	sampleSize int
+	seed       uint64
+	recall     float64
+	kind       imagehashgo.HashKind
+}
+
+// WithSampleSize sets the number of originals to re-hash. The per-bit
+// rates are estimated to within about 1/sqrt(n).
+func WithSampleSize(n int) PlanOption {
+	return func(o *planOptions) {
+		o.sampleSize = n
+	}
+}
+
+// WithSeed sets the seed of the sample, 1 by default, so that a plan can
+// be repeated over the same originals
+func WithSeed(seed uint64) PlanOption {
+	return func(o *planOptions) {
+		o.seed = seed
+	}
+}
+
+// WithRecall sets the share of old-configuration matches AdjustThreshold
+// must keep, from 0.5 to 1 (exclusive); DefaultRecall by default
+func WithRecall(recall float64) PlanOption {
+	return func(o *planOptions) {
+		o.recall = recall
+	}
+}
+
+// WithKind sets the algorithm of the stored hashes, for hashes that do not
+// carry one (such as hashes parsed from hex)
+func WithKind(kind imagehashgo.HashKind) PlanOption {
+	return func(o *planOptions) {
+		o.kind = kind
+	}
+}
+
+// MigrationModel is the per-bit flip model fitted by PlanMigration. It is a
+// plain value; do not modify FlipRates while its methods run.
+type MigrationModel struct {
+	// Kind, Rows and Cols describe the stored hashes
+	Kind       imagehashgo.HashKind
+	Rows, Cols int
+	// Samples is the number of originals hashed under both configurations
+	Samples int
+	// Failed is the number of sampled originals the sampler or the hashing
+	// failed on; they are left out of the fit
+	Failed int
+	// Stale is the number of samples whose old-configuration hash differs
+	// from the stored one. Many stale samples mean the old configuration
+	// (or the kind and size) does not describe the stored hashes, or that
+	// the originals have changed since.
+	Stale int
+	// FlipRates is the observed probability that each bit of the hash, in
+	// row-major order, differs between the two configurations
+	FlipRates []float64
+	// Recall is the share of matches AdjustThreshold keeps
+	Recall float64
+}
+
+// pipeline hashes one image under one configuration
+type pipeline func(img image.Image) (*imagehashgo.ImageHash, error)
+
+// newPipeline builds the pipeline of a configuration; tests replace it
+// with synthetic pipelines
+var newPipeline = func(kind imagehashgo.HashKind, hashSize int, r imagehashgo.ResolvedOptions) (pipeline, error) {
+	opts, err := r.Options()
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := imagehashgo.NewHasher(kind, hashSize, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return hasher.Hash, nil
+}
+
+// PlanMigration fits a MigrationModel for moving old, hashed with oldOpts,
+// to newOpts. It re-hashes a random sample of old (DefaultSampleSize
+// originals unless WithSampleSize is given) under both configurations,
+// fetching every original with sampler, and counts the bits that differ.
+//
+// The stored hashes must share one kind and shape; the kind is read from
+// the hashes unless WithKind is given. Originals the sampler fails on are
+// counted in Failed and skipped; PlanMigration fails only when the
+// configurations are invalid or no sample could be hashed.
+func PlanMigration(old []StoredHash, sampler func(id uint64) (image.Image, error), oldOpts, newOpts imagehashgo.ResolvedOptions, opts ...PlanOption) (MigrationModel, error) {
+	o := planOptions{sampleSize: DefaultSampleSize, seed: 1, recall: DefaultRecall}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.sampleSize < 1 {
+		return MigrationModel{}, fmt.Errorf("sample size must be at least 1, got %d", o.sampleSize)
+	}
+	if o.recall < 0.5 || o.recall >= 1 {
+		return MigrationModel{}, fmt.Errorf("recall must be from 0.5 to 1 (exclusive), got %g", o.recall)
+	}
+	if len(old) == 0 {
+		return MigrationModel{}, errors.New("no stored hashes to plan from")
+	}
+
+	m := MigrationModel{Kind: o.kind, Recall: o.recall}
+	for i, s := range old {
+		if s.Hash == nil {
+			return MigrationModel{}, fmt.Errorf("stored hash %d (id %d) is nil", i, s.ID)
+		}
+		rows, cols := s.Hash.Shape()
+		if i == 0 {
+			m.Rows, m.Cols = rows, cols
+			if m.Kind == "" {
+				m.Kind = s.Hash.Kind()
+			}
+		} else if rows != m.Rows || cols != m.Cols {
+			return MigrationModel{}, fmt.Errorf("stored hash %d (id %d) is %dx%d, the first is %dx%d", i, s.ID, rows, cols, m.Rows, m.Cols)
+		}
+	}
+	if m.Kind == "" {
+		return MigrationModel{}, errors.New("stored hashes carry no kind; give it with WithKind")
+	}
+	hashSize := 0
+	for hashSize*hashSize < m.Rows*m.Cols {
+		hashSize++
+	}
+	if hashSize*hashSize != m.Rows*m.Cols {
+		return MigrationModel{}, fmt.Errorf("stored hashes of %dx%d bits match no hash size", m.Rows, m.Cols)
+	}
+
+	oldPipe, err := newPipeline(m.Kind, hashSize, oldOpts)
+	if err != nil {
+		return MigrationModel{}, fmt.Errorf("old configuration: %w", err)
+	}
+	newPipe, err := newPipeline(m.Kind, hashSize, newOpts)
+	if err != nil {
+		return MigrationModel{}, fmt.Errorf("new configuration: %w", err)
+	}
+
+	flips := make([]int, m.Rows*m.Cols)
+	var firstErr error
+	for _, i := range sample(len(old), o.sampleSize, o.seed) {
+		s := old[i]
+		a, b, err := hashBoth(s.ID, sampler, oldPipe, newPipe)
+		if err == nil {
+			ar, ac := a.Shape()
+			br, bc := b.Shape()
+			if ar*ac != len(flips) || br*bc != len(flips) {
+				err = fmt.Errorf("id %d: the configurations give hashes of another size than the stored ones", s.ID)
+			}
+		}
+		if err != nil {
+			m.Failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		m.Samples++
+		if d, err := a.Distance(s.Hash); err != nil || d != 0 {
+			m.Stale++
+		}
+		// Both are packed MSB-first in the same bit order
+		pa, pb := a.Snapshot().Bits, b.Snapshot().Bits
+		for j := range flips {
+			if (pa[j/8]^pb[j/8])&(0x80>>(j%8)) != 0 {
+				flips[j]++
+			}
+		}
+	}
+	if m.Samples == 0 {
+		return MigrationModel{}, fmt.Errorf("no sample could be hashed: %w", firstErr)
+	}
+
+	m.FlipRates = make([]float64, len(flips))
+	for j, n := range flips {
+		m.FlipRates[j] = float64(n) / float64(m.Samples)
+	}
+	return m, nil
+}
+
+// hashBoth fetches the original id and hashes it under both pipelines
+func hashBoth(id uint64, sampler func(id uint64) (image.Image, error), oldPipe, newPipe pipeline) (a, b *imagehashgo.ImageHash, err error) {
+	img, err := sampler(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("id %d: %w", id, err)
+	}
+	if a, err = oldPipe(img); err != nil {
+		return nil, nil, fmt.Errorf("id %d: old configuration: %w", id, err)
+	}
+	if b, err = newPipe(img); err != nil {
+		return nil, nil, fmt.Errorf("id %d: new configuration: %w", id, err)
+	}
+	return a, b, nil
+}
+
+// sample returns min(k, n) distinct indexes below n in random order, by
+// Floyd's algorithm, so that the cost does not grow with n
+func sample(n, k int, seed uint64) []int {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	k = min(k, n)
+	chosen := make(map[int]bool, k)
+	out := make([]int, 0, k)
+	for j := n - k; j < n; j++ {
+		t := rng.IntN(j + 1)
+		if chosen[t] {
+			t = j
+		}
+		chosen[t] = true
+		out = append(out, t)
+	}
+	rng.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	return out
+}
+
+// AdjustThreshold returns the threshold to use when comparing a hash of the
+// old configuration with one of the new, so that pairs within oldThreshold
+// of each other under the old configuration still match with probability
+// at least Recall. Every flip widens or narrows the distance of a pair by
+// one; the bits are taken to flip independently, at their FlipRates, and a
+// pair at distance d to differ at a random d of the bits. The result is at
+// least oldThreshold and at most the number of bits.
+func (m MigrationModel) AdjustThreshold(oldThreshold int) int {
+	n := len(m.FlipRates)
+	oldThreshold = min(max(oldThreshold, 0), n)
+	change := m.changeDist(oldThreshold)
+	cum := 0.0
+	for k := -n; k <= n; k++ {
+		cum += change[k+n]
+		if k >= 0 && cum >= m.Recall-1e-12 {
+			return min(oldThreshold+k, n)
+		}
+	}
+	return n
+}
+
+// DriftEstimate is the expected effect of comparing hashes of the old
+// configuration with hashes of the new
+type DriftEstimate struct {
+	// Threshold is AdjustThreshold of the old threshold
+	Threshold int
+	// FalseReject is the probability that a pair at exactly the old
+	// threshold no longer matches at Threshold, at most 1 - Recall
+	FalseReject float64
+	// UnadjustedFalseReject is the same probability when the old threshold
+	// is kept, the recall lost by not adjusting
+	UnadjustedFalseReject float64
+	// FalseAccept is the probability that a pair one bit beyond the old
+	// threshold, which did not match, matches at Threshold: the price of
+	// the wider threshold
+	FalseAccept float64
+}
+
+// EstimateError reports the expected drift in false rejects and false
+// accepts around oldThreshold, under the same model as AdjustThreshold
+func (m MigrationModel) EstimateError(oldThreshold int) DriftEstimate {
+	n := len(m.FlipRates)
+	oldThreshold = min(max(oldThreshold, 0), n)
+	e := DriftEstimate{Threshold: m.AdjustThreshold(oldThreshold)}
+
+	at := m.changeDist(oldThreshold)
+	for k := -n; k <= n; k++ {
+		if oldThreshold+k > e.Threshold {
+			e.FalseReject += at[k+n]
+		}
+		if k > 0 {
+			e.UnadjustedFalseReject += at[k+n]
+		}
+	}
+	if oldThreshold < n {
+		beyond := m.changeDist(oldThreshold + 1)
+		for k := -n; k <= n; k++ {
+			if oldThreshold+1+k <= e.Threshold {
+				e.FalseAccept += beyond[k+n]
+			}
+		}
+	}
+	return e
+}
+
+// changeDist returns the distribution of the change in distance, from -n
+// to n (index k+n), of a pair d bits apart out of n when one side moves to
+// the new configuration: bit j flips with probability FlipRates[j], which
+// adds one when the pair agreed there (probability 1-d/n) and removes one
+// otherwise
+func (m MigrationModel) changeDist(d int) []float64 {
+	n := len(m.FlipRates)
+	dist := make([]float64, 2*n+1)
+	dist[n] = 1
+	lo, hi := n, n
+	next := make([]float64, 2*n+1)
+	differ := 0.0
+	if n > 0 {
+		differ = float64(d) / float64(n)
+	}
+	for _, p := range m.FlipRates {
+		if p == 0 {
+			continue
+		}
+		up, down := p*(1-differ), p*differ
+		clear(next[max(lo-1, 0):min(hi+2, len(next))])
+		for i := lo; i <= hi; i++ {
+			next[i] += dist[i] * (1 - p)
+			if i+1 < len(next) {
+				next[i+1] += dist[i] * up
+			}
+			if i > 0 {
+				next[i-1] += dist[i] * down
+			}
+		}
+		lo, hi = max(lo-1, 0), min(hi+1, 2*n)
+		dist, next = next, dist
+	}
+	return dist
+}
@@ -0,0 +1,257 @@
+package migrate
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// idImage carries the ID of a synthetic original to the synthetic pipelines
+type idImage struct {
+	image.Image
+	id uint64
+}
+
+// oldBits is the old-configuration hash of the synthetic original id
+func oldBits(id uint64, n int) []bool {
+	rng := rand.New(rand.NewPCG(id, 1))
+	bits := make([]bool, n)
+	for i := range bits {
+		bits[i] = rng.IntN(2) == 1
+	}
+	return bits
+}
+
+// migrated flips every bit of bits with probability rate, seeded by id
+func migrated(bits []bool, id uint64, rate float64) []bool {
+	rng := rand.New(rand.NewPCG(id, 2))
+	out := make([]bool, len(bits))
+	for i, b := range bits {
+		out[i] = b != (rng.Float64() < rate)
+	}
+	return out
+}
+
+// withSyntheticPipelines makes the default configuration hash an idImage
+// to oldBits and any other configuration to oldBits flipped at rate
+func withSyntheticPipelines(t *testing.T, rate float64) {
+	saved := newPipeline
+	t.Cleanup(func() { newPipeline = saved })
+	newPipeline = func(_ imagehashgo.HashKind, hashSize int, r imagehashgo.ResolvedOptions) (pipeline, error) {
+		n := hashSize * hashSize
+		return func(img image.Image) (*imagehashgo.ImageHash, error) {
+			id := img.(idImage).id
+			bits := oldBits(id, n)
+			if !r.Equal(imagehashgo.ResolvedOptions{}) {
+				bits = migrated(bits, id, rate)
+			}
+			return imagehashgo.NewImageHash(bits, hashSize, hashSize), nil
+		}, nil
+	}
+}
+
+func syntheticStore(n int) ([]StoredHash, func(id uint64) (image.Image, error)) {
+	old := make([]StoredHash, n)
+	for i := range old {
+		id := uint64(1000 + i)
+		old[i] = StoredHash{ID: id, Hash: imagehashgo.NewImageHash(oldBits(id, 64), 8, 8)}
+	}
+	sampler := func(id uint64) (image.Image, error) {
+		if id%50 == 0 {
+			return nil, errors.New("original deleted")
+		}
+		return idImage{id: id}, nil
+	}
+	return old, sampler
+}
+
+func TestPlanMigration_RecoversFlipRate(t *testing.T) {
+	const rate = 0.05
+	withSyntheticPipelines(t, rate)
+	old, sampler := syntheticStore(5000)
+	newOpts := imagehashgo.ResolveOptions(imagehashgo.WithIntegerPipeline())
+
+	m, err := PlanMigration(old, sampler, imagehashgo.ResolvedOptions{}, newOpts, WithSampleSize(2000), WithKind(imagehashgo.KindDifference))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Samples+m.Failed != 2000 || m.Failed == 0 || m.Stale != 0 {
+		t.Errorf("Samples %d, Failed %d, Stale %d", m.Samples, m.Failed, m.Stale)
+	}
+	mean := 0.0
+	for _, p := range m.FlipRates {
+		mean += p / float64(len(m.FlipRates))
+	}
+	if math.Abs(mean-rate) > 0.005 {
+		t.Errorf("mean flip rate %.4f, want %.2f", mean, rate)
+	}
+
+	exact := MigrationModel{FlipRates: make([]float64, 64), Recall: DefaultRecall}
+	for i := range exact.FlipRates {
+		exact.FlipRates[i] = rate
+	}
+	for _, threshold := range []int{0, 5, 10, 20} {
+		got, want := m.AdjustThreshold(threshold), exact.AdjustThreshold(threshold)
+		if got < want-1 || got > want+1 || got <= threshold {
+			t.Errorf("AdjustThreshold(%d) = %d, exact model gives %d", threshold, got, want)
+		}
+	}
+
+	// Pairs at the old threshold, one side migrated, must keep the recall
+	rng := rand.New(rand.NewPCG(7, 7))
+	const threshold, pairs = 10, 4000
+	adjusted := m.AdjustThreshold(threshold)
+	kept, keptUnadjusted := 0, 0
+	for i := range pairs {
+		a := oldBits(uint64(i), 64)
+		b := append([]bool(nil), a...)
+		for _, j := range rng.Perm(64)[:threshold] {
+			b[j] = !b[j]
+		}
+		b = migrated(b, uint64(i)+1e6, rate)
+		d := 0
+		for j := range a {
+			if a[j] != b[j] {
+				d++
+			}
+		}
+		if d <= adjusted {
+			kept++
+		}
+		if d <= threshold {
+			keptUnadjusted++
+		}
+	}
+	recall := float64(kept) / pairs
+	if recall < DefaultRecall-0.02 {
+		t.Errorf("recall at adjusted threshold %d = %.3f, want about %.2f", adjusted, recall, DefaultRecall)
+	}
+
+	e := m.EstimateError(threshold)
+	if e.Threshold != adjusted || e.FalseReject > 1-DefaultRecall+1e-9 || e.FalseAccept <= 0 {
+		t.Errorf("EstimateError(%d) = %+v", threshold, e)
+	}
+	if unadjusted := 1 - float64(keptUnadjusted)/pairs; math.Abs(unadjusted-e.UnadjustedFalseReject) > 0.05 {
+		t.Errorf("UnadjustedFalseReject %.3f, simulated %.3f", e.UnadjustedFalseReject, unadjusted)
+	}
+}
+
+func TestPlanMigration_SameConfiguration(t *testing.T) {
+	withSyntheticPipelines(t, 0.2)
+	old, sampler := syntheticStore(100)
+	m, err := PlanMigration(old, sampler, imagehashgo.ResolvedOptions{}, imagehashgo.ResolvedOptions{}, WithKind(imagehashgo.KindAverage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, threshold := range []int{0, 10, 64, 100} {
+		if got := m.AdjustThreshold(threshold); got != min(threshold, 64) {
+			t.Errorf("AdjustThreshold(%d) = %d, want it unchanged", threshold, got)
+		}
+	}
+	if e := m.EstimateError(10); e != (DriftEstimate{Threshold: 10}) {
+		t.Errorf("EstimateError(10) = %+v, want no drift", e)
+	}
+}
+
+func TestPlanMigration_RealPipelines(t *testing.T) {
+	images := make(map[uint64]image.Image)
+	var old []StoredHash
+	for id := range uint64(12) {
+		img := image.NewRGBA(image.Rect(0, 0, 96, 64))
+		rng := rand.New(rand.NewPCG(id, 3))
+		for y := range 64 {
+			for x := range 96 {
+				v := uint8((x*int(id+1) + y*3) % 256)
+				img.Set(x, y, color.RGBA{v, uint8(rng.IntN(256)), 255 - v, 255})
+			}
+		}
+		images[id] = img
+		old = append(old, StoredHash{ID: id, Hash: imagehashgo.DifferenceHash(img, 8)})
+	}
+	old = append(old, StoredHash{ID: 99, Hash: old[0].Hash})
+	sampler := func(id uint64) (image.Image, error) {
+		if img, ok := images[id]; ok {
+			return img, nil
+		}
+		return nil, errors.New("not found")
+	}
+
+	newOpts := imagehashgo.ResolveOptions(imagehashgo.WithIntegerPipeline())
+	m, err := PlanMigration(old, sampler, imagehashgo.ResolvedOptions{}, newOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Kind != imagehashgo.KindDifference || m.Rows != 8 || m.Cols != 8 {
+		t.Errorf("model of %s %dx%d", m.Kind, m.Rows, m.Cols)
+	}
+	if m.Samples != 12 || m.Failed != 1 || m.Stale != 0 {
+		t.Errorf("Samples %d, Failed %d, Stale %d", m.Samples, m.Failed, m.Stale)
+	}
+	if got := m.AdjustThreshold(8); got < 8 {
+		t.Errorf("AdjustThreshold(8) = %d", got)
+	}
+
+	// The stored hashes were not made with the integer pipeline
+	m, err = PlanMigration(old, sampler, newOpts, imagehashgo.ResolvedOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Stale == 0 {
+		t.Error("hashes recomputed under the wrong old configuration are not reported stale")
+	}
+}
+
+func TestPlanMigration_Errors(t *testing.T) {
+	stored := []StoredHash{{ID: 1, Hash: imagehashgo.DifferenceHash(image.NewGray(image.Rect(0, 0, 9, 8)), 8)}}
+	blur := imagehashgo.ResolvedOptions{Preprocess: "blur"}
+	if _, err := PlanMigration(stored, nil, imagehashgo.ResolvedOptions{}, blur); err == nil {
+		t.Error("unknown preprocess step: expected error")
+	}
+
+	withSyntheticPipelines(t, 0.1)
+	old, sampler := syntheticStore(10)
+	none := imagehashgo.ResolvedOptions{}
+	failing := func(uint64) (image.Image, error) { return nil, errors.New("offline") }
+	mixed := append([]StoredHash{{ID: 1, Hash: imagehashgo.NewImageHash(make([]bool, 16), 4, 4)}}, old...)
+
+	for name, run := range map[string]func() error{
+		"no kind":      func() error { _, err := PlanMigration(old, sampler, none, none); return err },
+		"empty":        func() error { _, err := PlanMigration(nil, sampler, none, none); return err },
+		"mixed shapes": func() error { _, err := PlanMigration(mixed, sampler, none, none, WithKind("dhash")); return err },
+		"all failing":  func() error { _, err := PlanMigration(old, failing, none, none, WithKind("dhash")); return err },
+		"bad recall": func() error {
+			_, err := PlanMigration(old, sampler, none, none, WithKind("dhash"), WithRecall(1))
+			return err
+		},
+		"bad sample": func() error {
+			_, err := PlanMigration(old, sampler, none, none, WithKind("dhash"), WithSampleSize(0))
+			return err
+		},
+	} {
+		if err := run(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	for _, tt := range []struct{ n, k int }{{10, 3}, {10, 10}, {5, 20}, {1000000, 50}} {
+		got := sample(tt.n, tt.k, 1)
+		if len(got) != min(tt.n, tt.k) {
+			t.Errorf("sample(%d, %d) has %d indexes", tt.n, tt.k, len(got))
+		}
+		seen := make(map[int]bool)
+		for _, i := range got {
+			if i < 0 || i >= tt.n || seen[i] {
+				t.Errorf("sample(%d, %d) = %v", tt.n, tt.k, got)
+				break
+			}
+			seen[i] = true
+		}
+	}
+}
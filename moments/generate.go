@@ -0,0 +1,3 @@
+package moments
+
+//go:generate go run ../gen/moments -dir .
@@ -0,0 +1,325 @@
+// Package moments computes color moment hashes modeled on OpenCV's
+// cv::img_hash::ColorMomentHash. Unlike the bit hashes of the parent
+// package, a color moment hash is 42 float64 values: the 7 Hu moments of
+// each channel of the HSV and YCrCb versions of the image. Hu moments are
+// invariant to scale and rotation, so the hash tolerates those better than
+// the block-based hashes, at a higher cost.
+//
+// The pipeline follows OpenCV's 8-bit code paths: bicubic resize to
+// 512x512 (without antialiasing, as OpenCV does), 3x3 Gaussian blur, then
+// the fixed-point HSV and YCrCb conversions. The results have not been
+// checked against OpenCV's yet: testdata/golden.json has no OpenCV values
+// until gen/moments runs where cv2 with the contrib img_hash module is
+// installed, so do not exchange these hashes with OpenCV-based services.
+package moments
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// HashLen is the number of values in a color moment hash
+const HashLen = 42
+
+// resizeSize is the side of the square image the moments are computed on
+const resizeSize = 512
+
+// ColorMomentHash computes the color moment hash of img, following the
+// steps cv::img_hash::ColorMomentHash takes for the same pixels in BGR
+// order.
+// Alpha is dropped without compositing, like OpenCV's BGRA2BGR.
+func ColorMomentHash(img image.Image) ([HashLen]float64, error) {
+	var hash [HashLen]float64
+	b := img.Bounds()
+	if b.Empty() {
+		return hash, errors.New("empty image")
+	}
+
+	src := newPlanes(b.Dx(), b.Dy())
+	for y := range b.Dy() {
+		for x := range b.Dx() {
+			c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			i := y*src.w + x
+			src.p[0][i], src.p[1][i], src.p[2][i] = c.R, c.G, c.B
+		}
+	}
+	blurred := gaussianBlur3(resizeCubic(src, resizeSize, resizeSize))
+
+	hsv, ycrcb := newPlanes(resizeSize, resizeSize), newPlanes(resizeSize, resizeSize)
+	for i := range blurred.p[0] {
+		r, g, b := blurred.p[0][i], blurred.p[1][i], blurred.p[2][i]
+		hsv.p[0][i], hsv.p[1][i], hsv.p[2][i] = rgbToHSV(r, g, b)
+		ycrcb.p[0][i], ycrcb.p[1][i], ycrcb.p[2][i] = rgbToYCrCb(r, g, b)
+	}
+	for c := range 3 {
+		copy(hash[7*c:], huMoments(hsv.p[c], resizeSize, resizeSize))
+		copy(hash[21+7*c:], huMoments(ycrcb.p[c], resizeSize, resizeSize))
+	}
+	return hash, nil
+}
+
+// ColorMomentDistance returns the distance between two color moment
+// hashes as OpenCV's ColorMomentHash::compare does: their L2 distance
+// scaled by 10000. OpenCV's documentation suggests treating distances
+// below about 8 as the same image.
+func ColorMomentDistance(a, b [HashLen]float64) float64 {
+	var sum float64
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum) * 10000
+}
+
+// planes is an 8-bit image with three separate channels
+type planes struct {
+	w, h int
+	p    [3][]uint8
+}
+
+func newPlanes(w, h int) *planes {
+	pl := &planes{w: w, h: h}
+	for c := range pl.p {
+		pl.p[c] = make([]uint8, w*h)
+	}
+	return pl
+}
+
+// Fixed-point precision of OpenCV's 8-bit resize coefficients
+const (
+	resizeCoefBits  = 11
+	resizeCoefScale = 1 << resizeCoefBits
+)
+
+// cubicTaps returns, for every destination index, the first of its four
+// source indices and their fixed-point weights, as cv::resize computes
+// them for INTER_CUBIC
+func cubicTaps(srcLen, dstLen int) (first []int, weights [][4]int32) {
+	first = make([]int, dstLen)
+	weights = make([][4]int32, dstLen)
+	scale := float64(srcLen) / float64(dstLen)
+	for d := range dstLen {
+		f := float32((float64(d)+0.5)*scale - 0.5)
+		s := int(math.Floor(float64(f)))
+		f -= float32(s)
+		first[d] = s - 1
+
+		// interpolateCubic, in float32 like OpenCV
+		const a = float32(-0.75)
+		var c [4]float32
+		c[0] = ((a*(f+1)-5*a)*(f+1)+8*a)*(f+1) - 4*a
+		c[1] = ((a+2)*f-(a+3))*f*f + 1
+		c[2] = ((a+2)*(1-f)-(a+3))*(1-f)*(1-f) + 1
+		c[3] = 1 - c[0] - c[1] - c[2]
+		for k := range 4 {
+			weights[d][k] = int32(math.RoundToEven(float64(c[k] * resizeCoefScale)))
+		}
+	}
+	return first, weights
+}
+
+// clampIndex replicates the edge pixels, as cv::resize does for the taps
+// that fall outside the source
+func clampIndex(i, n int) int {
+	return min(max(i, 0), n-1)
+}
+
+// resizeCubic resizes src to w x h with OpenCV's 8-bit INTER_CUBIC
+// arithmetic: a horizontal then a vertical 4-tap pass in fixed point
+func resizeCubic(src *planes, w, h int) *planes {
+	xFirst, xWeights := cubicTaps(src.w, w)
+	yFirst, yWeights := cubicTaps(src.h, h)
+	dst := newPlanes(w, h)
+	rows := make([]int32, src.h*w)
+	for c := range 3 {
+		s := src.p[c]
+		for y := range src.h {
+			row := s[y*src.w : (y+1)*src.w]
+			for x := range w {
+				var v int32
+				for k := range 4 {
+					v += int32(row[clampIndex(xFirst[x]+k, src.w)]) * xWeights[x][k]
+				}
+				rows[y*w+x] = v
+			}
+		}
+		for y := range h {
+			for x := range w {
+				var v int64
+				for k := range 4 {
+					v += int64(rows[clampIndex(yFirst[y]+k, src.h)*w+x]) * int64(yWeights[y][k])
+				}
+				v = (v + 1<<(2*resizeCoefBits-1)) >> (2 * resizeCoefBits)
+				dst.p[c][y*w+x] = uint8(min(max(v, 0), 255))
+			}
+		}
+	}
+	return dst
+}
+
+// reflect101 maps an index outside [0, n) the way OpenCV's default
+// BORDER_REFLECT_101 does
+func reflect101(i, n int) int {
+	if n == 1 {
+		return 0
+	}
+	if i < 0 {
+		return -i
+	}
+	if i >= n {
+		return 2*n - 2 - i
+	}
+	return i
+}
+
+// gaussianBlur3 is cv::GaussianBlur with a 3x3 kernel and sigma 0, whose
+// kernel is [1 2 1]/4 in both directions
+func gaussianBlur3(src *planes) *planes {
+	w, h := src.w, src.h
+	dst := newPlanes(w, h)
+	for c := range 3 {
+		s := src.p[c]
+		for y := range h {
+			for x := range w {
+				var sum int
+				for dy := -1; dy <= 1; dy++ {
+					row := reflect101(y+dy, h) * w
+					wy := 2 - dy*dy
+					for dx := -1; dx <= 1; dx++ {
+						sum += wy * (2 - dx*dx) * int(s[row+reflect101(x+dx, w)])
+					}
+				}
+				dst.p[c][y*w+x] = uint8((sum + 8) >> 4)
+			}
+		}
+	}
+	return dst
+}
+
+// hsvShift is the fixed-point precision of OpenCV's 8-bit HSV conversion
+const hsvShift = 12
+
+var sDiv, hDiv [256]int
+
+func init() {
+	for i := 1; i < 256; i++ {
+		sDiv[i] = int(math.RoundToEven(float64(255<<hsvShift) / float64(i)))
+		hDiv[i] = int(math.RoundToEven(float64(180<<hsvShift) / (6 * float64(i))))
+	}
+}
+
+// rgbToHSV is cv::cvtColor's 8-bit BGR2HSV: H in [0, 180), S and V in
+// [0, 255]
+func rgbToHSV(r8, g8, b8 uint8) (h, s, v uint8) {
+	r, g, b := int(r8), int(g8), int(b8)
+	vmax := max(r, g, b)
+	diff := vmax - min(r, g, b)
+	sat := (diff*sDiv[vmax] + 1<<(hsvShift-1)) >> hsvShift
+
+	var hue int
+	switch vmax {
+	case r:
+		hue = g - b
+	case g:
+		hue = b - r + 2*diff
+	default:
+		hue = r - g + 4*diff
+	}
+	hue = (hue*hDiv[diff] + 1<<(hsvShift-1)) >> hsvShift
+	if hue < 0 {
+		hue += 180
+	}
+	return uint8(min(hue, 255)), uint8(sat), uint8(vmax)
+}
+
+// yuvShift is the fixed-point precision of OpenCV's 8-bit YCrCb conversion
+const yuvShift = 14
+
+// rgbToYCrCb is cv::cvtColor's 8-bit BGR2YCrCb
+func rgbToYCrCb(r8, g8, b8 uint8) (y, cr, cb uint8) {
+	const (
+		r2y, g2y, b2y = 4899, 9617, 1868
+		crScale       = 11682
+		cbScale       = 9241
+		half          = 1 << (yuvShift - 1)
+		delta         = 128 << yuvShift
+	)
+	r, g, b := int(r8), int(g8), int(b8)
+	yv := (r*r2y + g*g2y + b*b2y + half) >> yuvShift
+	crv := ((r-yv)*crScale + delta + half) >> yuvShift
+	cbv := ((b-yv)*cbScale + delta + half) >> yuvShift
+	return saturate(yv), saturate(crv), saturate(cbv)
+}
+
+func saturate(v int) uint8 {
+	return uint8(min(max(v, 0), 255))
+}
+
+// huMoments returns the 7 Hu moments of a w x h channel, computed like
+// cv::moments followed by cv::HuMoments
+func huMoments(p []uint8, w, h int) []float64 {
+	var m00, m10, m01, m20, m11, m02, m30, m21, m12, m03 float64
+	for y := range h {
+		// Row sums are exact in integers
+		var s0, s1, s2, s3 int64
+		for x, v := range p[y*w : (y+1)*w] {
+			xv := int64(x) * int64(v)
+			s0 += int64(v)
+			s1 += xv
+			s2 += int64(x) * xv
+			s3 += int64(x) * int64(x) * xv
+		}
+		fy := float64(y)
+		f0, f1, f2, f3 := float64(s0), float64(s1), float64(s2), float64(s3)
+		m00 += f0
+		m10 += f1
+		m01 += fy * f0
+		m20 += f2
+		m11 += fy * f1
+		m02 += fy * fy * f0
+		m30 += f3
+		m21 += fy * f2
+		m12 += fy * fy * f1
+		m03 += fy * fy * fy * f0
+	}
+
+	// Central and normalized moments, as cv::Moments completes them
+	var cx, cy, inv float64
+	if math.Abs(m00) > 2.220446049250313e-16 {
+		inv = 1 / m00
+		cx, cy = m10*inv, m01*inv
+	}
+	mu20 := m20 - m10*cx
+	mu11 := m11 - m10*cy
+	mu02 := m02 - m01*cy
+	mu30 := m30 - cx*(3*mu20+cx*m10)
+	mu21 := m21 - cx*(2*mu11+cx*m01) - cy*mu20
+	mu12 := m12 - cy*(2*mu11+cy*m10) - cx*mu02
+	mu03 := m03 - cy*(3*mu02+cy*m01)
+
+	s2 := inv * inv
+	s3 := s2 * math.Sqrt(math.Abs(inv))
+	nu20, nu11, nu02 := mu20*s2, mu11*s2, mu02*s2
+	nu30, nu21, nu12, nu03 := mu30*s3, mu21*s3, mu12*s3, mu03*s3
+
+	// cv::HuMoments
+	hu := make([]float64, 7)
+	t0, t1 := nu30+nu12, nu21+nu03
+	q0, q1 := t0*t0, t1*t1
+	n4 := 4 * nu11
+	s, d := nu20+nu02, nu20-nu02
+	hu[0] = s
+	hu[1] = d*d + n4*nu11
+	hu[3] = q0 + q1
+	hu[5] = d*(q0-q1) + n4*t0*t1
+	t0 *= q0 - 3*q1
+	t1 *= 3*q0 - q1
+	q0 = nu30 - 3*nu12
+	q1 = 3*nu21 - nu03
+	hu[2] = q0*q0 + q1*q1
+	hu[4] = q0*t0 + q1*t1
+	hu[6] = q1*t0 - q0*t1
+	return hu
+}
@@ -0,0 +1,288 @@
+package moments
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// goldenEntry mirrors the entries written by gen/moments
+type goldenEntry struct {
+	Image    string    `json:"image"`
+	Original string    `json:"original"`
+	Go       []float64 `json:"go"`
+	OpenCV   []float64 `json:"opencv"`
+}
+
+// openCVTolerance is the largest ColorMomentDistance accepted between our
+// hash and OpenCV's. It allows for off-by-one intermediate pixels, from
+// the SIMD paths of cv::resize and from JPEG decoders, while staying well
+// below the distance of 8 OpenCV treats as a match.
+const openCVTolerance = 1
+
+func readGolden(t *testing.T) []goldenEntry {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "golden.json"))
+	if err != nil {
+		t.Fatalf("reading golden.json: %v", err)
+	}
+	var entries []goldenEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("parsing golden.json: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("golden.json has no entries")
+	}
+	return entries
+}
+
+func toHash(t *testing.T, values []float64) [HashLen]float64 {
+	t.Helper()
+	var h [HashLen]float64
+	if len(values) != HashLen {
+		t.Fatalf("golden hash has %d values, want %d", len(values), HashLen)
+	}
+	copy(h[:], values)
+	return h
+}
+
+func decode(t *testing.T, path string) image.Image {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+	return img
+}
+
+// TestGolden recomputes every hash in testdata/golden.json and compares it
+// with the recorded OpenCV values, when there are any. Run `go generate`
+// to refresh the file after an intentional change.
+func TestGolden(t *testing.T) {
+	var missing int
+	for _, e := range readGolden(t) {
+		t.Run(e.Image, func(t *testing.T) {
+			got, err := ColorMomentHash(decode(t, e.Image))
+			if err != nil {
+				t.Fatalf("ColorMomentHash() error = %v", err)
+			}
+			if d := ColorMomentDistance(got, toHash(t, e.Go)); d > 1e-6 {
+				t.Errorf("distance to recorded hash = %g\ngot  %v\nwant %v", d, got, e.Go)
+			}
+			if len(e.OpenCV) == 0 {
+				missing++
+				return
+			}
+			if d := ColorMomentDistance(got, toHash(t, e.OpenCV)); d > openCVTolerance {
+				t.Errorf("distance to OpenCV hash = %g, want <= %d", d, openCVTolerance)
+			}
+		})
+	}
+	if missing > 0 {
+		t.Logf("%d golden hashes have no recorded OpenCV values", missing)
+	}
+}
+
+// TestRanking checks that every recompressed copy is closest to its own
+// original, and that originals rank by distance from each copy as they do
+// with OpenCV's values
+func TestRanking(t *testing.T) {
+	entries := readGolden(t)
+	var originals, copies []goldenEntry
+	for _, e := range entries {
+		if e.Original == "" {
+			originals = append(originals, e)
+		} else {
+			copies = append(copies, e)
+		}
+	}
+	if len(originals) < 2 || len(copies) == 0 {
+		t.Fatalf("golden.json has %d originals and %d copies", len(originals), len(copies))
+	}
+
+	// rank orders the originals by distance from c, using values
+	rank := func(c goldenEntry, values func(goldenEntry) []float64) []string {
+		byDist := slices.Clone(originals)
+		slices.SortStableFunc(byDist, func(a, b goldenEntry) int {
+			da := ColorMomentDistance(toHash(t, values(c)), toHash(t, values(a)))
+			db := ColorMomentDistance(toHash(t, values(c)), toHash(t, values(b)))
+			return cmpFloat(da, db)
+		})
+		names := make([]string, len(byDist))
+		for i, e := range byDist {
+			names[i] = e.Image
+		}
+		return names
+	}
+	goValues := func(e goldenEntry) []float64 { return e.Go }
+	cvValues := func(e goldenEntry) []float64 { return e.OpenCV }
+
+	haveOpenCV := true
+	for _, e := range entries {
+		haveOpenCV = haveOpenCV && len(e.OpenCV) > 0
+	}
+	for _, c := range copies {
+		ours := rank(c, goValues)
+		if ours[0] != c.Original {
+			t.Errorf("%s: nearest original is %s, want %s", c.Image, ours[0], c.Original)
+		}
+		if !haveOpenCV {
+			continue
+		}
+		if theirs := rank(c, cvValues); !slices.Equal(ours, theirs) {
+			t.Errorf("%s: ranking %v, OpenCV ranks %v", c.Image, ours, theirs)
+		}
+	}
+	if !haveOpenCV {
+		t.Log("no recorded OpenCV values, only checked the nearest originals")
+	}
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func TestColorMomentHash_Empty(t *testing.T) {
+	if _, err := ColorMomentHash(image.NewRGBA(image.Rect(0, 0, 0, 10))); err == nil {
+		t.Error("ColorMomentHash() of an empty image returned no error")
+	}
+}
+
+func TestColorMomentDistance(t *testing.T) {
+	var a, b [HashLen]float64
+	if d := ColorMomentDistance(a, a); d != 0 {
+		t.Errorf("ColorMomentDistance(a, a) = %g, want 0", d)
+	}
+	b[0], b[41] = 3e-4, 4e-4
+	if d := ColorMomentDistance(a, b); math.Abs(d-5) > 1e-9 {
+		t.Errorf("ColorMomentDistance() = %g, want 5", d)
+	}
+	if ColorMomentDistance(a, b) != ColorMomentDistance(b, a) {
+		t.Error("ColorMomentDistance() is not symmetric")
+	}
+}
+
+// TestRGBToHSV compares the fixed-point conversion with the floating-point
+// formula OpenCV documents for 8-bit images
+func TestRGBToHSV(t *testing.T) {
+	for r := 0; r < 256; r += 5 {
+		for g := 0; g < 256; g += 7 {
+			for b := 0; b < 256; b += 3 {
+				h, s, v := rgbToHSV(uint8(r), uint8(g), uint8(b))
+				fr, fg, fb := float64(r), float64(g), float64(b)
+				vmax := max(fr, fg, fb)
+				diff := vmax - min(fr, fg, fb)
+				var fs, fh float64
+				if vmax > 0 {
+					fs = 255 * diff / vmax
+				}
+				if diff > 0 {
+					switch vmax {
+					case fr:
+						fh = 60 * (fg - fb) / diff
+					case fg:
+						fh = 120 + 60*(fb-fr)/diff
+					default:
+						fh = 240 + 60*(fr-fg)/diff
+					}
+					if fh < 0 {
+						fh += 360
+					}
+				}
+				if int(v) != int(vmax) || math.Abs(float64(s)-fs) > 1 || math.Abs(float64(h)-fh/2) > 1 && math.Abs(float64(h)-fh/2) < 179 {
+					t.Fatalf("rgbToHSV(%d, %d, %d) = %d, %d, %d, want about %.1f, %.1f, %.0f", r, g, b, h, s, v, fh/2, fs, vmax)
+				}
+			}
+		}
+	}
+}
+
+func TestRGBToYCrCb(t *testing.T) {
+	tests := []struct {
+		r, g, b   uint8
+		y, cr, cb uint8
+	}{
+		{0, 0, 0, 0, 128, 128},
+		{255, 255, 255, 255, 128, 128},
+		{255, 0, 0, 76, 255, 85},
+		{0, 255, 0, 150, 21, 43},
+		{0, 0, 255, 29, 107, 255},
+	}
+	for _, tt := range tests {
+		y, cr, cb := rgbToYCrCb(tt.r, tt.g, tt.b)
+		if y != tt.y || cr != tt.cr || cb != tt.cb {
+			t.Errorf("rgbToYCrCb(%d, %d, %d) = %d, %d, %d, want %d, %d, %d", tt.r, tt.g, tt.b, y, cr, cb, tt.y, tt.cr, tt.cb)
+		}
+	}
+}
+
+// TestHuMoments_Rotation checks that Hu moments do not change when the
+// channel is rotated by 90 degrees
+func TestHuMoments_Rotation(t *testing.T) {
+	const w, h = 40, 24
+	p := make([]uint8, w*h)
+	rot := make([]uint8, w*h)
+	for y := range h {
+		for x := range w {
+			v := uint8((x*x + 3*y + x*y) % 256)
+			p[y*w+x] = v
+			rot[x*h+(h-1-y)] = v
+		}
+	}
+	a, b := huMoments(p, w, h), huMoments(rot, h, w)
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9*math.Abs(a[i])+1e-30 {
+			t.Errorf("hu[%d] = %g, rotated %g", i, a[i], b[i])
+		}
+	}
+}
+
+func TestResizeCubic_Uniform(t *testing.T) {
+	src := newPlanes(7, 5)
+	for c := range src.p {
+		for i := range src.p[c] {
+			src.p[c][i] = uint8(60 * (c + 1))
+		}
+	}
+	dst := gaussianBlur3(resizeCubic(src, 16, 16))
+	for c := range dst.p {
+		for i, v := range dst.p[c] {
+			if v != uint8(60*(c+1)) {
+				t.Fatalf("channel %d pixel %d = %d, want %d", c, i, v, 60*(c+1))
+			}
+		}
+	}
+}
+
+func BenchmarkColorMomentHash(b *testing.B) {
+	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for y := range 480 {
+		for x := range 640 {
+			img.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), uint8(x ^ y), 255})
+		}
+	}
+	for b.Loop() {
+		if _, err := ColorMomentHash(img); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
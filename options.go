@@ -0,0 +1,476 @@
+package imagehashgo
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log/slog"
+	"runtime"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// Option configures optional behaviour of the hashing functions
+type Option func(*options)
+
+type options struct {
+	// quantBits is the number of low bits cleared from every grayscale
+	// pixel before resizing (0 disables quantization)
+	quantBits int
+	// ignore is the region excluded from the hash statistics, in percent
+	// of the image size (empty when nothing is ignored)
+	ignore image.Rectangle
+	// integer selects the integer-only box filter resize
+	integer bool
+	// capture receives a copy of the pre-threshold grayscale image
+	capture **image.Gray
+	// parallelThreshold overrides ParallelGrayscaleThreshold when
+	// parallelThresholdSet is true
+	parallelThreshold    int
+	parallelThresholdSet bool
+	// preprocess runs before hashing in Hash and Hasher.Hash
+	preprocess *Preprocess
+	// median thresholds the DCT coefficients of the perceptual hashes
+	median MedianStrategy
+	// aspect lays the cells of the average and difference hashes out on
+	// the grid of AspectGrid
+	aspect bool
+	// autoAlgorithm replaces the kind given to Hash with the one
+	// recommended for the class of the image
+	autoAlgorithm bool
+	// ctx cancels the grayscale conversion of Hasher.HashContext; nil
+	// when the context can never be canceled
+	ctx context.Context
+	// logger receives Debug events; nil disables logging
+	logger *slog.Logger
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// WithDecoderTolerantQuantization clears the lowest bits of every grayscale
+// pixel before the image is resized, so that small decoder differences
+// (e.g. Go's image/jpeg vs libjpeg-turbo, which may differ by ±1) are far
+// less likely to flip hash bits. Values outside [0, 7] are clamped.
+//
+// Hashes computed with this option are not comparable to hashes produced by
+// the Python imagehash library or by this package without the option.
+func WithDecoderTolerantQuantization(bits int) Option {
+	return func(o *options) {
+		o.quantBits = min(max(bits, 0), 7)
+	}
+}
+
+// WithIgnoreRegion excludes a region of the image from the hash, for
+// example a watermark strip. The rectangle is given in percent of the image
+// width and height, so image.Rect(0, 88, 100, 100) ignores the bottom 12%.
+//
+// For AverageHash and the DifferenceHash variants, cells overlapping the
+// region are still emitted, so the hash shape does not change, but they are
+// always false and do not contribute to the average. For PerceptualHash
+// the region is filled with the mean of the remaining pixels before the DCT.
+func WithIgnoreRegion(rect image.Rectangle) Option {
+	return func(o *options) {
+		o.ignore = rect.Canon().Intersect(image.Rect(0, 0, 100, 100))
+	}
+}
+
+// WithIntegerPipeline makes AverageHash and the DifferenceHash variants use
+// an integer-only pipeline, for targets without a fast FPU: the Lanczos
+// resize is replaced by a box filter with fixed-point accumulation. The
+// grayscale conversion and thresholds are integer-only in both pipelines.
+//
+// The resulting hashes are deterministic but differ from the default
+// pipeline (and from Python imagehash). PerceptualHash ignores this option.
+func WithIntegerPipeline() Option {
+	return func(o *options) {
+		o.integer = true
+	}
+}
+
+// WithCaptureIntermediate stores in *dst a copy of the grayscale image the
+// hash is computed from: the resized image for AverageHash and the
+// DifferenceHash variants, and the input to the DCT for PerceptualHash.
+// The copy does not share memory with any internal buffer. Every hash
+// computed with the option writes *dst, so do not give it to a Hasher used
+// from several goroutines.
+func WithCaptureIntermediate(dst **image.Gray) Option {
+	return func(o *options) {
+		o.capture = dst
+	}
+}
+
+// WithParallelGrayscaleThreshold overrides ParallelGrayscaleThreshold for
+// one call: the grayscale conversion of the input image runs in parallel
+// only when it has more than pixels pixels. The hash is identical either way.
+func WithParallelGrayscaleThreshold(pixels int) Option {
+	return func(o *options) {
+		o.parallelThreshold = pixels
+		o.parallelThresholdSet = true
+	}
+}
+
+// WithLogger emits Debug events to l: the grayscale conversion path taken
+// for each image ("grayscale", with the path of GrayscalePath and whether it
+// ran in parallel), the algorithm chosen by WithAutoAlgorithm ("auto
+// algorithm"), and the outcome of Hash and Hasher.Hash ("hash finish", with
+// the kind, size, duration and hash, or "hash failed"). Without the option
+// nothing is measured or formatted.
+//
+// The package keeps no hash cache, so there are no cache events; callers
+// caching hashes by ContentKey should log their own hits and misses.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithPreprocess runs p on the image before hashing. It is applied by Hash
+// and Hasher.Hash, which can report step errors; the algorithm functions
+// (AverageHash, PerceptualHash, ...) ignore it, so call p.Apply yourself
+// when using them directly.
+func WithPreprocess(p *Preprocess) Option {
+	return func(o *options) {
+		o.preprocess = p
+	}
+}
+
+// WithMedian selects the median PerceptualHash and TextPerceptualHash
+// threshold their coefficients at. The default, MedianInterpolated, matches
+// numpy.median and Python imagehash; MedianLower and MedianHigher give
+// hashes that are not comparable with it. The other algorithms ignore this
+// option.
+func WithMedian(s MedianStrategy) Option {
+	return func(o *options) {
+		o.median = s
+	}
+}
+
+// ResolvedOptions is the frozen form of a list of Options: the settings
+// that change the hash bits, as comparable values. Options that only
+// affect performance or diagnostics, such as WithParallelGrayscaleThreshold
+// and WithCaptureIntermediate, are not represented, so equal
+// ResolvedOptions give equal hashes. A ResolvedOptions is a plain value,
+// immutable once returned and safe for concurrent use; use it as a map key
+// or compare it with ==.
+type ResolvedOptions struct {
+	// QuantBits is the WithDecoderTolerantQuantization bit count
+	QuantBits int
+	// Ignore is the WithIgnoreRegion rectangle, in percent of the image
+	// size; empty when nothing is ignored
+	Ignore image.Rectangle
+	// Integer is set by WithIntegerPipeline
+	Integer bool
+	// Preprocess is the String of the WithPreprocess pipeline
+	Preprocess string
+	// Median is the WithMedian strategy
+	Median MedianStrategy
+	// AspectBuckets is set by WithAspectBuckets
+	AspectBuckets bool
+	// AutoAlgorithm is set by WithAutoAlgorithm
+	AutoAlgorithm bool
+}
+
+// ResolveOptions applies opts in order, as the hashing functions do, and
+// returns the result
+func ResolveOptions(opts ...Option) ResolvedOptions {
+	return newOptions(opts).resolve()
+}
+
+// resolve returns the output-changing part of o
+func (o options) resolve() ResolvedOptions {
+	r := ResolvedOptions{
+		QuantBits:     o.quantBits,
+		Integer:       o.integer,
+		Preprocess:    o.preprocess.String(),
+		Median:        o.median,
+		AspectBuckets: o.aspect,
+		AutoAlgorithm: o.autoAlgorithm,
+	}
+	if !o.ignore.Empty() {
+		r.Ignore = o.ignore
+	}
+	return r
+}
+
+// Equal reports whether r and other give the same hashes
+func (r ResolvedOptions) Equal(other ResolvedOptions) bool {
+	return r == other
+}
+
+// String returns the canonical form of r: the non-default settings as
+// key=value pairs joined by ";", in a fixed order, and "" for the defaults
+func (r ResolvedOptions) String() string {
+	var parts []string
+	if r.QuantBits != 0 {
+		parts = append(parts, fmt.Sprintf("quant=%d", r.QuantBits))
+	}
+	if !r.Ignore.Empty() {
+		parts = append(parts, fmt.Sprintf("ignore=%d,%d,%d,%d", r.Ignore.Min.X, r.Ignore.Min.Y, r.Ignore.Max.X, r.Ignore.Max.Y))
+	}
+	if r.Integer {
+		parts = append(parts, "integer")
+	}
+	if r.Preprocess != "" {
+		parts = append(parts, "preprocess="+r.Preprocess)
+	}
+	if r.Median != MedianInterpolated {
+		parts = append(parts, "median="+r.Median.String())
+	}
+	if r.AspectBuckets {
+		parts = append(parts, "aspect")
+	}
+	if r.AutoAlgorithm {
+		parts = append(parts, "auto")
+	}
+	return strings.Join(parts, ";")
+}
+
+// Options returns Options that resolve to r, for hashing with settings
+// kept as a ResolvedOptions. It fails when r.Preprocess has a step that is
+// not one of this package's, such as a custom PreprocessStep.
+func (r ResolvedOptions) Options() ([]Option, error) {
+	opts := []Option{WithMedian(r.Median)}
+	if r.QuantBits != 0 {
+		opts = append(opts, WithDecoderTolerantQuantization(r.QuantBits))
+	}
+	if !r.Ignore.Empty() {
+		opts = append(opts, WithIgnoreRegion(r.Ignore))
+	}
+	if r.Integer {
+		opts = append(opts, WithIntegerPipeline())
+	}
+	if r.Preprocess != "" {
+		p, err := parsePreprocess(r.Preprocess)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPreprocess(p))
+	}
+	if r.AspectBuckets {
+		opts = append(opts, WithAspectBuckets())
+	}
+	if r.AutoAlgorithm {
+		opts = append(opts, WithAutoAlgorithm())
+	}
+	return opts, nil
+}
+
+// grayscale converts img to grayscale and applies the preprocessing
+// requested by o. The input image is never modified.
+func (o options) grayscale(img image.Image) *image.Gray {
+	threshold := ParallelGrayscaleThreshold
+	if o.parallelThresholdSet {
+		threshold = o.parallelThreshold
+	}
+	var done <-chan struct{}
+	if o.ctx != nil {
+		done = o.ctx.Done()
+	}
+	o.logGrayscale(img, threshold)
+	gray := convertGrayscale(img, threshold, nil, done)
+	if o.canceled() {
+		// The result is discarded: hand on a pixel to keep the resize cheap
+		return image.NewGray(image.Rect(0, 0, 1, 1))
+	}
+	if o.quantBits == 0 {
+		return gray
+	}
+
+	quantized := image.NewGray(gray.Bounds())
+	mask := ^uint8(1<<o.quantBits - 1)
+	for y := range gray.Rect.Dy() {
+		src := gray.Pix[y*gray.Stride : y*gray.Stride+gray.Rect.Dx()]
+		dst := quantized.Pix[y*quantized.Stride:]
+		for x, p := range src {
+			dst[x] = p & mask
+		}
+	}
+	return quantized
+}
+
+// logGrayscale emits the "grayscale" event for the conversion of img with
+// the given parallelism threshold
+func (o options) logGrayscale(img image.Image, threshold int) {
+	if o.logger == nil {
+		return
+	}
+	b := img.Bounds()
+	_, isGray := img.(*image.Gray)
+	parallel := !isGray && b.Dx()*b.Dy() > threshold && runtime.GOMAXPROCS(0) > 1
+	o.logger.Debug("grayscale", "path", GrayscalePath(img), "width", b.Dx(), "height", b.Dy(), "parallel", parallel)
+}
+
+// withContext makes the grayscale conversion stop early once ctx is done,
+// for Hasher.HashContext. A context that is never done is dropped.
+func withContext(ctx context.Context) Option {
+	return func(o *options) {
+		if ctx.Done() != nil {
+			o.ctx = ctx
+		}
+	}
+}
+
+// canceled reports whether the context of o is done
+func (o options) canceled() bool {
+	return o.ctx != nil && o.ctx.Err() != nil
+}
+
+// resize scales gray to w x h with the filter selected by o
+func (o options) resize(gray *image.Gray, w, h int) *image.Gray {
+	if o.integer {
+		return boxResizeGray(gray, w, h)
+	}
+	return resizeGray(gray, w, h)
+}
+
+// resizeGray scales gray to w x h with a Lanczos filter. A source that
+// already has the target size is used as is, as Pillow does. An empty
+// source gives a black image, as with boxResizeGray.
+func resizeGray(gray *image.Gray, w, h int) *image.Gray {
+	if gray.Rect.Dx() == w && gray.Rect.Dy() == h {
+		return gray
+	}
+	if gray.Rect.Empty() {
+		return image.NewGray(image.Rect(0, 0, w, h))
+	}
+	return nrgbaGrayExtract(imaging.Resize(gray, w, h, imaging.Lanczos))
+}
+
+// nrgbaGrayExtract copies the R channel of an NRGBA image resized from a
+// grayscale one. Its R, G and B are equal and alpha is opaque, so the luma
+// formula of ToGrayscaleFast would return R unchanged.
+func nrgbaGrayExtract(resized *image.NRGBA) *image.Gray {
+	w, h := resized.Rect.Dx(), resized.Rect.Dy()
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		src := resized.Pix[y*resized.Stride : y*resized.Stride+4*w]
+		dst := gray.Pix[y*gray.Stride : y*gray.Stride+w]
+		for x := range dst {
+			dst[x] = src[4*x]
+		}
+	}
+	return gray
+}
+
+// boxResizeGray scales src to w x h by averaging the source pixels covered
+// by each destination pixel, using only integer arithmetic. Source pixels
+// are split between destination pixels by area in 1/w (1/h) units.
+func boxResizeGray(src *image.Gray, w, h int) *image.Gray {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	if sw == 0 || sh == 0 {
+		return dst
+	}
+
+	// Horizontal pass: each row is summed into w columns, weighting every
+	// source pixel by its overlap in units of 1/w of a source pixel.
+	cols := make([]uint32, w*sh)
+	for y := range sh {
+		row := src.Pix[y*src.Stride : y*src.Stride+sw]
+		out := cols[y*w : y*w+w]
+		for i, p := range row {
+			// Source pixel i covers [i*w, (i+1)*w) in destination units of sw
+			start, end := i*w, (i+1)*w
+			for start < end {
+				x := start / sw
+				next := min((x+1)*sw, end)
+				out[x] += uint32(p) * uint32(next-start)
+				start = next
+			}
+		}
+	}
+
+	// Vertical pass: same weighting across rows, then normalize by the
+	// total weight sw*sh with rounding.
+	acc := make([]uint64, w)
+	for y := range h {
+		clear(acc)
+		start, end := y*sh, (y+1)*sh
+		for start < end {
+			sy := start / h
+			next := min((sy+1)*h, end)
+			weight := uint64(next - start)
+			for x, c := range cols[sy*w : sy*w+w] {
+				acc[x] += uint64(c) * weight
+			}
+			start = next
+		}
+		total := uint64(sw) * uint64(sh)
+		for x, a := range acc {
+			dst.Pix[y*dst.Stride+x] = uint8((a + total/2) / total)
+		}
+	}
+	return dst
+}
+
+// captureGray stores a copy of gray when WithCaptureIntermediate is set
+func (o options) captureGray(gray *image.Gray) {
+	if o.capture == nil {
+		return
+	}
+	c := image.NewGray(gray.Bounds())
+	for y := gray.Rect.Min.Y; y < gray.Rect.Max.Y; y++ {
+		copy(c.Pix[c.PixOffset(gray.Rect.Min.X, y):], gray.Pix[gray.PixOffset(gray.Rect.Min.X, y):gray.PixOffset(gray.Rect.Max.X, y)])
+	}
+	*o.capture = c
+}
+
+// ignoredCell reports whether cell (x, y) of a cols x rows grid overlaps
+// the ignored region
+func (o options) ignoredCell(x, y, cols, rows int) bool {
+	if o.ignore.Empty() {
+		return false
+	}
+	// Compare in units of 1/(100*cols) and 1/(100*rows) to stay in integers
+	return x*100 < o.ignore.Max.X*cols && (x+1)*100 > o.ignore.Min.X*cols &&
+		y*100 < o.ignore.Max.Y*rows && (y+1)*100 > o.ignore.Min.Y*rows
+}
+
+// fillIgnored returns gray with the ignored region replaced by the mean of
+// the remaining pixels. The input image is never modified.
+func (o options) fillIgnored(gray *image.Gray) *image.Gray {
+	if o.ignore.Empty() {
+		return gray
+	}
+
+	b := gray.Bounds()
+	region := image.Rect(
+		b.Min.X+b.Dx()*o.ignore.Min.X/100, b.Min.Y+b.Dy()*o.ignore.Min.Y/100,
+		b.Min.X+b.Dx()*o.ignore.Max.X/100, b.Min.Y+b.Dy()*o.ignore.Max.Y/100,
+	)
+	if region.Empty() {
+		return gray
+	}
+
+	var sum, count uint64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !image.Pt(x, y).In(region) {
+				sum += uint64(gray.Pix[gray.PixOffset(x, y)])
+				count++
+			}
+		}
+	}
+	mean := uint8(sum / max(count, 1))
+
+	filled := image.NewGray(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		copy(filled.Pix[filled.PixOffset(b.Min.X, y):], gray.Pix[gray.PixOffset(b.Min.X, y):gray.PixOffset(b.Max.X, y)])
+	}
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		row := filled.Pix[filled.PixOffset(region.Min.X, y):filled.PixOffset(region.Max.X, y)]
+		for i := range row {
+			row[i] = mean
+		}
+	}
+	return filled
+}
@@ -0,0 +1,557 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log/slog"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// decodeAsJPEG round-trips the bench image through image/jpeg so the
+// evaluation below works on real decoder output.
+func decodeAsJPEG(t *testing.T) *image.Gray {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, getBenchImage(), &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	img, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	return ToGrayscaleFast(img)
+}
+
+// withDecoderNoise emulates a second decoder by shifting every pixel by -1, 0 or +1
+func withDecoderNoise(gray *image.Gray, seed int64) *image.Gray {
+	noisy := image.NewGray(gray.Bounds())
+	copy(noisy.Pix, gray.Pix)
+	r := rand.New(rand.NewSource(seed))
+	for i, p := range noisy.Pix {
+		switch r.Intn(3) {
+		case 0:
+			if p > 0 {
+				noisy.Pix[i] = p - 1
+			}
+		case 1:
+			if p < 255 {
+				noisy.Pix[i] = p + 1
+			}
+		}
+	}
+	return noisy
+}
+
+func TestPerceptualHash_DecoderTolerantQuantization(t *testing.T) {
+	gray := decodeAsJPEG(t)
+	tolerant := WithDecoderTolerantQuantization(2)
+
+	for seed := range int64(5) {
+		noisy := withDecoderNoise(gray, seed)
+
+		plain, _ := PerceptualHash(gray, 8, 4).Distance(PerceptualHash(noisy, 8, 4))
+		dist, _ := PerceptualHash(gray, 8, 4, tolerant).Distance(PerceptualHash(noisy, 8, 4, tolerant))
+		if dist != 0 {
+			t.Errorf("seed %d: tolerant mode flipped %d bits, want 0", seed, dist)
+		}
+		t.Logf("seed %d: default mode flipped %d bits, tolerant mode %d", seed, plain, dist)
+	}
+
+	// Discrimination cost: a mirrored image must still be far away in tolerant mode
+	mirrored := image.NewGray(gray.Bounds())
+	w := gray.Rect.Dx()
+	for y := range gray.Rect.Dy() {
+		for x := range w {
+			mirrored.Pix[y*mirrored.Stride+x] = gray.Pix[y*gray.Stride+w-1-x]
+		}
+	}
+	plain, _ := PerceptualHash(gray, 8, 4).Distance(PerceptualHash(mirrored, 8, 4))
+	dist, _ := PerceptualHash(gray, 8, 4, tolerant).Distance(PerceptualHash(mirrored, 8, 4, tolerant))
+	t.Logf("mirrored distance: default %d, tolerant %d", plain, dist)
+	if dist < plain/2 {
+		t.Errorf("tolerant mode lost too much discrimination: %d vs %d", dist, plain)
+	}
+}
+
+func TestWithDecoderTolerantQuantization_DoesNotModifyInput(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i)
+	}
+	want := append([]uint8(nil), gray.Pix...)
+
+	PerceptualHash(gray, 8, 4, WithDecoderTolerantQuantization(3))
+	if !bytes.Equal(gray.Pix, want) {
+		t.Error("quantization modified the caller's image")
+	}
+}
+
+// withWatermark blends a striped white strip over the bottom 12% of img
+func withWatermark(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	marked := image.NewRGBA(b)
+	draw.Draw(marked, b, img, b.Min, draw.Src)
+	strip := image.Rect(b.Min.X, b.Max.Y-b.Dy()*12/100, b.Max.X, b.Max.Y)
+	for y := strip.Min.Y; y < strip.Max.Y; y++ {
+		for x := strip.Min.X; x < strip.Max.X; x++ {
+			if (x/8+y/8)%2 == 0 {
+				c := marked.RGBAAt(x, y)
+				marked.SetRGBA(x, y, color.RGBA{c.R/3 + 170, c.G/3 + 170, c.B/3 + 170, 255})
+			} else {
+				marked.SetRGBA(x, y, color.RGBA{40, 40, 40, 255})
+			}
+		}
+	}
+	return marked
+}
+
+func TestWithIgnoreRegion_Watermark(t *testing.T) {
+	img := getBenchImage()
+	marked := withWatermark(img)
+	bottom := image.Rect(0, 88, 100, 100)
+	ignore := WithIgnoreRegion(bottom)
+
+	tests := []struct {
+		name string
+		algo func(image.Image, ...Option) *ImageHash
+	}{
+		{"AverageHash", func(i image.Image, o ...Option) *ImageHash { return AverageHash(i, 8, o...) }},
+		{"PerceptualHash", func(i image.Image, o ...Option) *ImageHash { return PerceptualHash(i, 8, 4, o...) }},
+		{"DifferenceHash", func(i image.Image, o ...Option) *ImageHash { return DifferenceHash(i, 8, o...) }},
+		{"DifferenceHashVertical", func(i image.Image, o ...Option) *ImageHash { return DifferenceHashVertical(i, 8, o...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain, _ := tt.algo(img).Distance(tt.algo(marked))
+			dist, err := tt.algo(img, ignore).Distance(tt.algo(marked, ignore))
+			if err != nil {
+				t.Fatalf("Distance() error = %v", err)
+			}
+			t.Logf("watermarked distance: default %d, ignoring bottom strip %d", plain, dist)
+			if dist > 2 {
+				t.Errorf("distance with WithIgnoreRegion = %d, want <= 2", dist)
+			}
+		})
+	}
+
+	t.Run("MaskedDistance", func(t *testing.T) {
+		mask := RegionMask(bottom, 8, 8)
+		dist, err := AverageHash(img, 8, ignore).MaskedDistance(AverageHash(marked, 8, ignore), mask)
+		if err != nil || dist > 2 {
+			t.Errorf("MaskedDistance() = %d, %v; want <= 2", dist, err)
+		}
+		if !mask[63] || mask[0] {
+			t.Errorf("RegionMask() should cover only the bottom row, got %v", mask)
+		}
+	})
+}
+
+func TestBoxResizeGray(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []uint8{0, 30, 60, 90, 120, 150})
+
+	// Downscale to 1x1 is the plain mean, 2x1 splits the middle column
+	if got := boxResizeGray(src, 1, 1).Pix[0]; got != 75 {
+		t.Errorf("1x1 = %d, want 75", got)
+	}
+	if got := boxResizeGray(src, 2, 1).Pix; got[0] != 55 || got[1] != 95 {
+		t.Errorf("2x1 = %v, want [55 95]", got)
+	}
+	// Upscaling replicates pixels
+	if got := boxResizeGray(src, 6, 4).Pix; got[0] != 0 || got[1] != 0 || got[23] != 150 {
+		t.Errorf("6x4 = %v", got)
+	}
+}
+
+// TestNRGBAGrayExtract checks that reading R from a resized grayscale image
+// gives the same bytes as the full conversion, so hashes are unchanged
+func TestNRGBAGrayExtract(t *testing.T) {
+	padded := noiseImage(80, 60, 7).SubImage(image.Rect(5, 3, 75, 50)).(*image.Gray)
+	sources := map[string]*image.Gray{
+		"noise":  noiseImage(97, 61, 3),
+		"wave":   waveImage(2),
+		"bench":  ToGrayscaleFast(getBenchImage()),
+		"padded": padded,
+	}
+	for name, src := range sources {
+		for _, size := range [][2]int{{8, 8}, {9, 8}, {8, 9}, {32, 32}, {64, 64}, {200, 150}} {
+			resized := imaging.Resize(src, size[0], size[1], imaging.Lanczos)
+			want := ToGrayscaleFast(resized)
+			if got := nrgbaGrayExtract(resized); !bytes.Equal(got.Pix, want.Pix) || got.Rect != want.Rect {
+				t.Errorf("%s to %dx%d: extracted pixels differ from ToGrayscaleFast", name, size[0], size[1])
+			}
+		}
+	}
+
+	// Strides wider than the image are skipped
+	wide := imaging.Resize(sources["noise"], 40, 20, imaging.Lanczos)
+	sub := wide.SubImage(image.Rect(3, 2, 30, 17)).(*image.NRGBA)
+	if got, want := nrgbaGrayExtract(sub), ToGrayscaleFast(sub); !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("sub-image: extracted pixels differ from ToGrayscaleFast")
+	}
+}
+
+// BenchmarkResizeGray compares reading R after the Lanczos resize with the
+// full grayscale conversion it replaces
+func BenchmarkResizeGray(b *testing.B) {
+	gray := ToGrayscaleFast(getBenchImage())
+	for _, size := range []int{9, 32, 64} {
+		resized := imaging.Resize(gray, size, size, imaging.Lanczos)
+		b.Run(fmt.Sprintf("%d/extract", size), func(b *testing.B) {
+			for b.Loop() {
+				nrgbaGrayExtract(resized)
+			}
+		})
+		b.Run(fmt.Sprintf("%d/convert", size), func(b *testing.B) {
+			for b.Loop() {
+				ToGrayscaleFast(resized)
+			}
+		})
+		b.Run(fmt.Sprintf("%d/resize", size), func(b *testing.B) {
+			for b.Loop() {
+				resizeGray(gray, size, size)
+			}
+		})
+	}
+}
+
+func TestWithIntegerPipeline_Robustness(t *testing.T) {
+	img := getBenchImage()
+	b := img.Bounds()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+	reencoded, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	brighter := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			brighter.SetRGBA(x, y, color.RGBA{uint8(min(r>>8+12, 255)), uint8(min(g>>8+12, 255)), uint8(min(bl>>8+12, 255)), 255})
+		}
+	}
+	cropped := brighter.SubImage(image.Rect(b.Min.X+b.Dx()/50, b.Min.Y+b.Dy()/50, b.Max.X, b.Max.Y))
+
+	integer := WithIntegerPipeline()
+	algos := map[string]func(image.Image, ...Option) *ImageHash{
+		"AverageHash":            func(i image.Image, o ...Option) *ImageHash { return AverageHash(i, 8, o...) },
+		"DifferenceHash":         func(i image.Image, o ...Option) *ImageHash { return DifferenceHash(i, 8, o...) },
+		"DifferenceHashVertical": func(i image.Image, o ...Option) *ImageHash { return DifferenceHashVertical(i, 8, o...) },
+	}
+
+	for name, algo := range algos {
+		t.Run(name, func(t *testing.T) {
+			if algo(img, integer).ToString() != algo(img, integer).ToString() {
+				t.Fatal("integer pipeline is not deterministic")
+			}
+			var defaultTotal, integerTotal int
+			for _, modified := range []image.Image{reencoded, brighter, cropped} {
+				d, _ := algo(img).Distance(algo(modified))
+				i, _ := algo(img, integer).Distance(algo(modified, integer))
+				defaultTotal += d
+				integerTotal += i
+			}
+			t.Logf("total distance over modifications: default %d, integer %d", defaultTotal, integerTotal)
+			if integerTotal > defaultTotal+6 {
+				t.Errorf("integer pipeline robustness %d is not within 6 bits of default %d", integerTotal, defaultTotal)
+			}
+		})
+	}
+}
+
+func TestWithCaptureIntermediate_ReproducesHash(t *testing.T) {
+	img := getBenchImage()
+
+	t.Run("AverageHash", func(t *testing.T) {
+		var captured *image.Gray
+		h := AverageHash(img, 8, WithCaptureIntermediate(&captured))
+		if captured.Rect.Dx() != 8 || captured.Rect.Dy() != 8 {
+			t.Fatalf("captured %v, want 8x8", captured.Rect)
+		}
+		var sum int
+		for _, p := range captured.Pix {
+			sum += int(p)
+		}
+		avg := float64(sum) / 64
+		for i, p := range captured.Pix {
+			if h.hash[i] != (float64(p) > avg) {
+				t.Errorf("bit %d does not match the captured image", i)
+			}
+		}
+	})
+
+	t.Run("DifferenceHash", func(t *testing.T) {
+		var captured *image.Gray
+		h := DifferenceHash(img, 8, WithCaptureIntermediate(&captured))
+		if captured.Rect.Dx() != 9 || captured.Rect.Dy() != 8 {
+			t.Fatalf("captured %v, want 9x8", captured.Rect)
+		}
+		for y := range 8 {
+			for x := range 8 {
+				if h.hash[y*8+x] != (captured.GrayAt(x+1, y).Y > captured.GrayAt(x, y).Y) {
+					t.Errorf("bit (%d, %d) does not match the captured image", x, y)
+				}
+			}
+		}
+	})
+
+	for _, highfreq := range []int{4, 8, 3} {
+		t.Run(fmt.Sprintf("PerceptualHash/highfreq=%d", highfreq), func(t *testing.T) {
+			var captured *image.Gray
+			h := PerceptualHash(img, 8, highfreq, WithCaptureIntermediate(&captured))
+			size := 8 * highfreq
+			if captured.Rect.Dx() != size || captured.Rect.Dy() != size {
+				t.Fatalf("captured %v, want %dx%d", captured.Rect, size, size)
+			}
+			matrix := make([][]float64, size)
+			for y := range size {
+				matrix[y] = make([]float64, size)
+				for x := range size {
+					matrix[y][x] = float64(captured.GrayAt(x, y).Y)
+				}
+			}
+			dct := DCT2D(matrix)
+			low := make([]float64, 0, 64)
+			for y := range 8 {
+				low = append(low, dct[y][:8]...)
+			}
+			med := Median(low)
+			for i, v := range low {
+				if h.hash[i] != (v > med) {
+					t.Errorf("bit %d does not match the captured image", i)
+				}
+			}
+		})
+	}
+
+	t.Run("NoAliasing", func(t *testing.T) {
+		var first, second *image.Gray
+		PerceptualHash(img, 8, 4, WithCaptureIntermediate(&first))
+		want := append([]uint8(nil), first.Pix...)
+		PerceptualHash(image.NewGray(image.Rect(0, 0, 40, 40)), 8, 4, WithCaptureIntermediate(&second))
+		if !bytes.Equal(first.Pix, want) {
+			t.Error("captured image changed after a later hash")
+		}
+	})
+}
+
+// outputOptions registers every Option constructor that changes the hash
+// bits, with a non-default argument; TestResolveOptions_Registry fails
+// until a new constructor is added here or to diagnosticOptions
+var outputOptions = map[string]Option{
+	"WithDecoderTolerantQuantization": WithDecoderTolerantQuantization(2),
+	"WithIgnoreRegion":                WithIgnoreRegion(image.Rect(0, 88, 100, 100)),
+	"WithIntegerPipeline":             WithIntegerPipeline(),
+	"WithPreprocess":                  WithPreprocess(NewPreprocess(Composite(color.White))),
+	"WithMedian":                      WithMedian(MedianLower),
+	"WithAspectBuckets":               WithAspectBuckets(),
+	"WithAutoAlgorithm":               WithAutoAlgorithm(),
+}
+
+// diagnosticOptions are the Option constructors that never change the
+// hash bits, so ResolvedOptions leaves them out
+var diagnosticOptions = map[string]Option{
+	"WithCaptureIntermediate":        WithCaptureIntermediate(new(*image.Gray)),
+	"WithParallelGrayscaleThreshold": WithParallelGrayscaleThreshold(1),
+	"WithLogger":                     WithLogger(slog.New(slog.DiscardHandler)),
+}
+
+// optionConstructors returns the exported functions of the package that
+// return an Option
+func optionConstructors(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var names []string
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() || fn.Type.Results == nil || len(fn.Type.Results.List) != 1 {
+				continue
+			}
+			if ident, ok := fn.Type.Results.List[0].Type.(*ast.Ident); ok && ident.Name == "Option" {
+				names = append(names, fn.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+func TestResolveOptions_Registry(t *testing.T) {
+	for _, name := range optionConstructors(t) {
+		_, output := outputOptions[name]
+		_, diagnostic := diagnosticOptions[name]
+		if !output && !diagnostic {
+			t.Errorf("%s is not registered in outputOptions or diagnosticOptions", name)
+		}
+	}
+
+	base := ResolveOptions()
+	if base.String() != "" || !base.Equal(ResolvedOptions{}) {
+		t.Errorf("ResolveOptions() = %q, want the zero value", base)
+	}
+	seen := map[string]string{}
+	for name, opt := range outputOptions {
+		r := ResolveOptions(opt)
+		if r.Equal(base) || r.String() == "" {
+			t.Errorf("%s does not change the ResolvedOptions", name)
+		}
+		if other, ok := seen[r.String()]; ok {
+			t.Errorf("%s and %s resolve to the same string %q", name, other, r)
+		}
+		seen[r.String()] = name
+	}
+	for name, opt := range diagnosticOptions {
+		if r := ResolveOptions(opt); !r.Equal(base) {
+			t.Errorf("diagnostic option %s changes the ResolvedOptions to %q", name, r)
+		}
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	all := []Option{
+		WithAspectBuckets(), WithMedian(MedianHigher), nil,
+		WithIgnoreRegion(image.Rect(100, 100, 0, 88)), WithDecoderTolerantQuantization(9),
+	}
+	r := ResolveOptions(all...)
+	if want := "quant=7;ignore=0,88,100,100;median=higher;aspect"; r.String() != want {
+		t.Errorf("String() = %q, want %q", r, want)
+	}
+	if !r.Equal(ResolveOptions(all...)) {
+		t.Error("ResolveOptions is not deterministic")
+	}
+
+	// The last option wins, as in the hashing functions
+	if got := ResolveOptions(WithMedian(MedianLower), WithMedian(MedianInterpolated)); !got.Equal(ResolvedOptions{}) {
+		t.Errorf("later WithMedian did not override: %q", got)
+	}
+	// An empty region ignores nothing, wherever it is
+	if got := ResolveOptions(WithIgnoreRegion(image.Rect(50, 50, 50, 60))); !got.Equal(ResolvedOptions{}) {
+		t.Errorf("empty ignore region resolved to %q", got)
+	}
+
+	h, err := NewHasher(KindPerceptual, 8, all...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !h.Options().Equal(r) || h.Fingerprint() != "phash/8/"+r.String() {
+		t.Errorf("Hasher options %q, fingerprint %q", h.Options(), h.Fingerprint())
+	}
+}
+
+func TestResolvedOptions_Options(t *testing.T) {
+	for _, r := range []ResolvedOptions{
+		{},
+		ResolveOptions(WithAspectBuckets(), WithMedian(MedianLower), WithIntegerPipeline(), WithDecoderTolerantQuantization(2)),
+		ResolveOptions(WithIgnoreRegion(image.Rect(0, 88, 100, 100))),
+		ResolveOptions(WithPreprocess(NewPreprocess(AutoOrient(), Composite(nil), AutoCrop(8), Equalize()))),
+		ResolveOptions(WithPreprocess(NewPreprocess(Composite(color.NRGBA{0x10, 0x20, 0x30, 0x80})))),
+	} {
+		opts, err := r.Options()
+		if err != nil {
+			t.Errorf("%q: Options() error = %v", r, err)
+			continue
+		}
+		if got := ResolveOptions(opts...); !got.Equal(r) {
+			t.Errorf("Options() of %q resolves to %q", r, got)
+		}
+	}
+
+	if _, err := (ResolvedOptions{Preprocess: "autoorient|sharpen"}).Options(); err == nil {
+		t.Error("Options() with an unknown preprocess step expected error")
+	}
+}
+
+// recordHandler records the message and attribute keys of every record
+type recordHandler struct {
+	events []string
+	attrs  []map[string]slog.Value
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	keys := []string{r.Level.String(), r.Message}
+	attrs := map[string]slog.Value{}
+	r.Attrs(func(a slog.Attr) bool {
+		keys = append(keys, a.Key)
+		attrs[a.Key] = a.Value
+		return true
+	})
+	h.events = append(h.events, strings.Join(keys, " "))
+	h.attrs = append(h.attrs, attrs)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestWithLogger(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+
+	var h recordHandler
+	hash, err := Hash(img, KindAverage, 8, WithLogger(slog.New(&h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"DEBUG grayscale path width height parallel",
+		"DEBUG hash finish kind size duration hash",
+	}
+	if strings.Join(h.events, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events = %q, want %q", h.events, want)
+	}
+	if got := h.attrs[0]["path"].String(); got != "nrgba" {
+		t.Errorf("path = %q, want nrgba", got)
+	}
+	if got := h.attrs[1]["hash"].String(); got != hash.ToString() {
+		t.Errorf("hash = %q, want %q", got, hash.ToString())
+	}
+
+	h = recordHandler{}
+	if _, err := Hash(img, KindAverage, 8, WithLogger(slog.New(&h)), WithAutoAlgorithm()); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.events) < 2 || h.events[1] != "DEBUG auto algorithm class kind" {
+		t.Errorf("events with WithAutoAlgorithm = %q", h.events)
+	}
+
+	h = recordHandler{}
+	if _, err := Hash(img, "bogus", 8, WithLogger(slog.New(&h))); err == nil {
+		t.Fatal("Hash with an unknown kind expected error")
+	}
+	if len(h.events) == 0 || h.events[len(h.events)-1] != "DEBUG hash failed kind size err" {
+		t.Errorf("events for a failed hash = %q", h.events)
+	}
+}
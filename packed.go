@@ -0,0 +1,144 @@
+package imagehashgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// DistanceToBytes returns the Hamming distance between the hash and a hash
+// packed as in HashSnapshot.Bits (MSB-first, zero-padded to a whole byte),
+// without building an ImageHash for it. Only the bit count is checked, as
+// packed bytes carry no shape.
+func (h *ImageHash) DistanceToBytes(packed []byte) (int, error) {
+	if err := checkPacked(packed, len(h.hash)); err != nil {
+		return 0, err
+	}
+
+	dist := 0
+	for i, b := range packed {
+		// Pack the next 8 bits of the hash, the last byte zero-padded
+		cells := h.hash[8*i : min(8*i+8, len(h.hash))]
+		var q byte
+		for j, set := range cells {
+			if set {
+				q |= 0x80 >> j
+			}
+		}
+		dist += bits.OnesCount8(q ^ b)
+	}
+	return dist, nil
+}
+
+// DistanceBytes returns the Hamming distance between two hashes of n bits
+// packed as in HashSnapshot.Bits
+func DistanceBytes(a, b []byte, n int) (int, error) {
+	if err := checkPacked(a, n); err != nil {
+		return 0, err
+	}
+	if err := checkPacked(b, n); err != nil {
+		return 0, err
+	}
+
+	dist := 0
+	for len(a) >= 8 {
+		dist += bits.OnesCount64(binary.BigEndian.Uint64(a) ^ binary.BigEndian.Uint64(b))
+		a, b = a[8:], b[8:]
+	}
+	for i := range a {
+		dist += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return dist, nil
+}
+
+// checkPacked validates packed as n bits with zero padding, as FromSnapshot
+// does
+func checkPacked(packed []byte, n int) error {
+	if n <= 0 || n > MaxHashBits {
+		return fmt.Errorf("invalid bit count: %d", n)
+	}
+	if want := (n + 7) / 8; len(packed) != want {
+		return fmt.Errorf("packed hash has %d bytes, %d bits need %d", len(packed), n, want)
+	}
+	if n%8 != 0 && packed[len(packed)-1]&(0xff>>(n%8)) != 0 {
+		return fmt.Errorf("packed hash has non-zero padding bits")
+	}
+	return nil
+}
+
+// PackMatrix packs hashes of one shape into a contiguous row-major matrix
+// of len(hs) rows of rowBytes bytes, for binary embedding search services.
+// Each row is the hash packed as in HashSnapshot.Bits: cells row-major,
+// MSB-first, the last byte zero-padded. Hamming distance is the bytewise
+// popcount of XORed rows, as FAISS IndexBinaryFlat computes it; declare
+// the dimension as 8*rowBytes bits, the padding never differs.
+func PackMatrix(hs []*ImageHash) (data []byte, rowBytes int, err error) {
+	rowBytes, err = matrixRowBytes(hs)
+	if err != nil {
+		return nil, 0, err
+	}
+	data = make([]byte, 0, len(hs)*rowBytes)
+	for _, h := range hs {
+		data = append(data, packBits(h.hash)...)
+	}
+	return data, rowBytes, nil
+}
+
+// WritePackedMatrix writes the PackMatrix matrix of hs to w row by row,
+// without holding it in memory
+func WritePackedMatrix(w io.Writer, hs []*ImageHash) (rowBytes int, err error) {
+	rowBytes, err = matrixRowBytes(hs)
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(w)
+	for _, h := range hs {
+		if _, err := bw.Write(packBits(h.hash)); err != nil {
+			return 0, err
+		}
+	}
+	return rowBytes, bw.Flush()
+}
+
+// UnpackMatrix is the inverse of PackMatrix for hashes of shape
+// rows x cols. The hashes have no Kind.
+func UnpackMatrix(data []byte, rowBytes, rows, cols int) ([]*ImageHash, error) {
+	if err := checkShape(rows*cols, rows, cols); err != nil {
+		return nil, err
+	}
+	n := rows * cols
+	if want := (n + 7) / 8; rowBytes != want {
+		return nil, fmt.Errorf("shape (%d, %d) needs %d bytes per row, got %d", rows, cols, want, rowBytes)
+	}
+	if len(data)%rowBytes != 0 {
+		return nil, fmt.Errorf("matrix of %d bytes is not a whole number of %d-byte rows", len(data), rowBytes)
+	}
+
+	hs := make([]*ImageHash, 0, len(data)/rowBytes)
+	for i := 0; i < len(data); i += rowBytes {
+		row := data[i : i+rowBytes]
+		if err := checkPacked(row, n); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i/rowBytes, err)
+		}
+		hs = append(hs, &ImageHash{hash: unpackBits(row, n), rows: rows, cols: cols})
+	}
+	return hs, nil
+}
+
+// matrixRowBytes validates that hs is non-empty and of a single shape, and
+// returns the packed size of one hash
+func matrixRowBytes(hs []*ImageHash) (int, error) {
+	if len(hs) == 0 {
+		return 0, errors.New("no hashes to pack")
+	}
+	rows, cols := hs[0].rows, hs[0].cols
+	for i, h := range hs {
+		if h.rows != rows || h.cols != cols || len(h.hash) != rows*cols {
+			return 0, fmt.Errorf("hash %d has shape (%d, %d), hash 0 has (%d, %d)", i, h.rows, h.cols, rows, cols)
+		}
+	}
+	return (rows*cols + 7) / 8, nil
+}
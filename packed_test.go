@@ -0,0 +1,188 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"io"
+	"math/bits"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomShapedHash returns a random hash of the given shape
+func randomShapedHash(rng *rand.Rand, rows, cols int) *ImageHash {
+	bits := make([]bool, rows*cols)
+	for i := range bits {
+		bits[i] = rng.IntN(2) == 1
+	}
+	return &ImageHash{hash: bits, rows: rows, cols: cols}
+}
+
+func TestDistanceBytes(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	shapes := [][2]int{{8, 8}, {4, 4}, {16, 16}, {3, 5}, {1, 71}, {16, 32}}
+	for _, shape := range shapes {
+		for range 100 {
+			a := randomShapedHash(rng, shape[0], shape[1])
+			b := randomShapedHash(rng, shape[0], shape[1])
+			packed := b.Snapshot().Bits
+
+			// Reference: rebuild the ImageHash and use Distance
+			ref, err := FromSnapshot(HashSnapshot{Rows: shape[0], Cols: shape[1], Bits: packed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := a.Distance(ref)
+
+			if got, err := a.DistanceToBytes(packed); err != nil || got != want {
+				t.Fatalf("%v: DistanceToBytes = %d, %v, want %d", shape, got, err, want)
+			}
+			if got, err := DistanceBytes(a.Snapshot().Bits, packed, shape[0]*shape[1]); err != nil || got != want {
+				t.Fatalf("%v: DistanceBytes = %d, %v, want %d", shape, got, err, want)
+			}
+		}
+	}
+}
+
+func TestDistanceBytesInvalid(t *testing.T) {
+	h := uint64Hash(0, 3, 3)
+	tests := map[string][]byte{
+		"short":   {0},
+		"long":    {0, 0, 0},
+		"padding": {0, 0x01},
+	}
+	for name, packed := range tests {
+		if _, err := h.DistanceToBytes(packed); err == nil {
+			t.Errorf("DistanceToBytes %s: expected an error", name)
+		}
+		if _, err := DistanceBytes([]byte{0, 0}, packed, 9); err == nil {
+			t.Errorf("DistanceBytes %s: expected an error", name)
+		}
+	}
+	if _, err := DistanceBytes(nil, nil, 0); err == nil {
+		t.Error("DistanceBytes with 0 bits: expected an error")
+	}
+}
+
+func TestDistanceToBytesAllocs(t *testing.T) {
+	h := uint64Hash(0x0123456789abcdef, 8, 8)
+	packed := uint64Hash(0xfedcba9876543210, 8, 8).Snapshot().Bits
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = h.DistanceToBytes(packed)
+		_, _ = DistanceBytes(packed, packed, 64)
+	})
+	if allocs != 0 {
+		t.Errorf("allocated %v times per comparison", allocs)
+	}
+}
+
+// packedCorpus returns 1M random packed 64-bit hashes
+func packedCorpus() [][]byte {
+	rng := rand.New(rand.NewPCG(5, 6))
+	corpus := make([][]byte, 1<<20)
+	for i := range corpus {
+		corpus[i] = uint64Hash(rng.Uint64(), 8, 8).Snapshot().Bits
+	}
+	return corpus
+}
+
+func BenchmarkDistancePacked(b *testing.B) {
+	corpus := packedCorpus()
+	query := uint64Hash(0x0123456789abcdef, 8, 8)
+	packedQuery := query.Snapshot().Bits
+
+	b.Run("FromSnapshot+Distance", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			h, err := FromSnapshot(HashSnapshot{Rows: 8, Cols: 8, Bits: corpus[i%len(corpus)]})
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _ = query.Distance(h)
+		}
+	})
+	b.Run("DistanceToBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			_, _ = query.DistanceToBytes(corpus[i%len(corpus)])
+		}
+	})
+	b.Run("DistanceBytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			_, _ = DistanceBytes(packedQuery, corpus[i%len(corpus)], 64)
+		}
+	})
+}
+
+func TestPackMatrix(t *testing.T) {
+	rng := rand.New(rand.NewPCG(7, 8))
+	for _, shape := range [][2]int{{8, 8}, {3, 5}, {16, 16}} {
+		hs := make([]*ImageHash, 20)
+		for i := range hs {
+			hs[i] = randomShapedHash(rng, shape[0], shape[1])
+		}
+
+		data, rowBytes, err := PackMatrix(hs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (shape[0]*shape[1] + 7) / 8; rowBytes != want || len(data) != len(hs)*want {
+			t.Fatalf("%v: rowBytes %d, %d bytes, want %d and %d", shape, rowBytes, len(data), want, len(hs)*want)
+		}
+
+		var buf bytes.Buffer
+		if n, err := WritePackedMatrix(&buf, hs); err != nil || n != rowBytes || !bytes.Equal(buf.Bytes(), data) {
+			t.Errorf("%v: WritePackedMatrix differs from PackMatrix (rowBytes %d, %v)", shape, n, err)
+		}
+
+		back, err := UnpackMatrix(data, rowBytes, shape[0], shape[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, h := range hs {
+			// Rows are the documented bit order, and bytewise popcount of
+			// XORed rows is the Hamming distance
+			row := data[i*rowBytes : (i+1)*rowBytes]
+			if !bytes.Equal(row, h.Snapshot().Bits) {
+				t.Errorf("%v: row %d is not the snapshot bits", shape, i)
+			}
+			if d, _ := back[i].Distance(h); d != 0 {
+				t.Errorf("%v: hash %d did not round trip", shape, i)
+			}
+			popcount := 0
+			for j := range row {
+				popcount += bits.OnesCount8(row[j] ^ data[j])
+			}
+			if want, _ := h.Distance(hs[0]); popcount != want {
+				t.Errorf("%v: popcount distance %d, want %d", shape, popcount, want)
+			}
+		}
+	}
+}
+
+func TestPackMatrixInvalid(t *testing.T) {
+	if _, _, err := PackMatrix(nil); err == nil {
+		t.Error("expected an error for no hashes")
+	}
+	if _, _, err := PackMatrix([]*ImageHash{uint64Hash(0, 8, 8), uint64Hash(0, 4, 4)}); err == nil {
+		t.Error("expected an error for mixed shapes")
+	}
+	if _, err := WritePackedMatrix(io.Discard, []*ImageHash{uint64Hash(0, 8, 8), uint64Hash(0, 4, 4)}); err == nil {
+		t.Error("WritePackedMatrix: expected an error for mixed shapes")
+	}
+
+	for name, tt := range map[string]struct {
+		data       []byte
+		rowBytes   int
+		rows, cols int
+	}{
+		"row size": {make([]byte, 16), 4, 8, 8},
+		"partial":  {make([]byte, 12), 8, 8, 8},
+		"shape":    {make([]byte, 8), 8, 0, 8},
+		"padding":  {[]byte{0, 1}, 2, 3, 3},
+	} {
+		if _, err := UnpackMatrix(tt.data, tt.rowBytes, tt.rows, tt.cols); err == nil {
+			t.Errorf("UnpackMatrix %s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+)
+
+// PairResult is the verdict of MatchPair
+type PairResult struct {
+	Match bool
+	// Explanation gives the distance of every algorithm
+	Explanation EnsembleExplanation
+	// Settings describes the preprocessing steps of the profile as applied
+	// to both images, an AdaptiveStep followed by its decision, e.g.
+	// "autocrop(8): borders 0,255"
+	Settings []string
+}
+
+// MatchPair applies profile to two images, hashing both through the same
+// decided preprocessing. Profile.Match preprocesses each image on its own,
+// so an adaptive step may decide differently for each: AutoCrop removes the
+// black frame of one copy but the white margin of the other, and the
+// hashes then cover different parts of the same content. MatchPair takes
+// the decision of every AdaptiveStep from both images with its Combine and
+// applies it to both; other steps run on each image as in Match.
+func MatchPair(imgA, imgB image.Image, profile *MatchProfile) (PairResult, error) {
+	a, b := imgA, imgB
+	var res PairResult
+	for _, step := range profile.steps {
+		setting := step.String()
+		var err error
+		if s, ok := step.(AdaptiveStep); ok {
+			var d fmt.Stringer
+			if d, err = decidePair(s, a, b); err == nil {
+				setting += ": " + d.String()
+				if a, err = s.ApplyDecision(a, d); err == nil {
+					b, err = s.ApplyDecision(b, d)
+				}
+			}
+		} else if a, err = step.Apply(a); err == nil {
+			b, err = step.Apply(b)
+		}
+		if err != nil {
+			return PairResult{}, fmt.Errorf("preprocess %s: %w", step, err)
+		}
+		res.Settings = append(res.Settings, setting)
+	}
+
+	ha, err := profile.plain.Hash(a)
+	if err != nil {
+		return PairResult{}, err
+	}
+	hb, err := profile.plain.Hash(b)
+	if err != nil {
+		return PairResult{}, err
+	}
+	res.Match, res.Explanation = profile.MatchHashes(ha, hb)
+	return res, nil
+}
+
+// decidePair returns the decision of step for the pair a, b
+func decidePair(step AdaptiveStep, a, b image.Image) (fmt.Stringer, error) {
+	da, err := step.Decide(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := step.Decide(b)
+	if err != nil {
+		return nil, err
+	}
+	return step.Combine(da, db), nil
+}
@@ -0,0 +1,134 @@
+package imagehashgo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"testing"
+)
+
+// framedPair returns a picture on a wide white margin and the same picture
+// framed in black, as a scan and a screenshot of the same page might be
+func framedPair() (page, framed image.Image) {
+	p := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	draw.Draw(p, p.Rect, image.White, image.Point{}, draw.Src)
+	for y := 50; y < 150; y++ {
+		for x := 30; x < 170; x++ {
+			v := 110 + 80*math.Sin(float64(x)/9)*math.Cos(float64(y)/13)
+			p.Set(x, y, color.RGBA{uint8(v), uint8(v / 2), 90, 255})
+		}
+	}
+	f := image.NewRGBA(image.Rect(0, 0, 260, 240))
+	draw.Draw(f, f.Rect, image.Black, image.Point{}, draw.Src)
+	draw.Draw(f, p.Rect.Add(image.Pt(30, 20)), p, image.Point{}, draw.Src)
+	return p, f
+}
+
+func TestMatchPair_FramedCopy(t *testing.T) {
+	p, err := parseMatchProfile(strings.NewReader(`
+combine: all
+preprocess: [composite, autocrop(8)]
+algorithms:
+  - algo: phash
+    threshold: 6
+  - algo: dhash
+    threshold: 6
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, framed := framedPair()
+
+	// Each on its own, AutoCrop removes the margin of the page but only
+	// the frame of the framed copy
+	if match, exp, err := p.Match(page, framed); err != nil || match {
+		t.Fatalf("Match = %v, %v (%s), want independent hashing to diverge", match, err, exp)
+	}
+
+	for _, pair := range [][2]image.Image{{page, framed}, {framed, page}} {
+		res, err := MatchPair(pair[0], pair[1], p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Match {
+			t.Errorf("MatchPair rejected the framed copy (%s)", res.Explanation)
+		}
+		for _, l := range res.Explanation.Leaves {
+			if l.Distance != 0 {
+				t.Errorf("%s distance %d, want 0", l.Kind, l.Distance)
+			}
+		}
+		want := []string{"composite(ffffffffffffffff)", "autocrop(8): borders 0,255"}
+		if strings.Join(res.Settings, "|") != strings.Join(want, "|") {
+			t.Errorf("Settings = %q, want %q", res.Settings, want)
+		}
+	}
+}
+
+func TestMatchPair_AgreesWithMatch(t *testing.T) {
+	photo, copy, edited, other := profilePairs(t)
+	for _, name := range []string{"strict", "loose"} {
+		p, err := BuiltinMatchProfile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Without an adaptive step both images are preprocessed as in Match
+		for _, b := range []image.Image{copy, edited, other} {
+			match, exp, err := p.Match(photo, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := MatchPair(photo, b, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Match != match || res.Explanation.String() != exp.String() {
+				t.Errorf("%s: MatchPair = %v (%s), Match = %v (%s)", name, res.Match, res.Explanation, match, exp)
+			}
+		}
+	}
+}
+
+func TestAutoCrop_Decision(t *testing.T) {
+	page, framed := framedPair()
+	step := AutoCrop(8).(AdaptiveStep)
+	for _, img := range []image.Image{page, framed, image.NewGray(image.Rect(0, 0, 20, 20))} {
+		want, _ := step.Apply(img)
+		d, err := step.Decide(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := step.ApplyDecision(img, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !samePixels(got, want) {
+			t.Errorf("decision %s: ApplyDecision gives %v, Apply %v", d, got.Bounds(), want.Bounds())
+		}
+	}
+
+	if d, _ := step.Decide(image.NewGray(image.Rect(0, 0, 20, 20))); d.String() != "no border" {
+		t.Errorf("uniform image decision = %s", d)
+	}
+	if _, err := step.ApplyDecision(page, image.Pt(1, 2)); err == nil {
+		t.Error("foreign decision expected error")
+	}
+}
+
+// samePixels reports whether a and b hold the same grayscale pixels
+func samePixels(a, b image.Image) bool {
+	ga, gb := ToGrayscaleFast(a), ToGrayscaleFast(b)
+	if ga.Rect.Size() != gb.Rect.Size() {
+		return false
+	}
+	for y := range ga.Rect.Dy() {
+		for x := range ga.Rect.Dx() {
+			if ga.GrayAt(ga.Rect.Min.X+x, ga.Rect.Min.Y+y) != gb.GrayAt(gb.Rect.Min.X+x, gb.Rect.Min.Y+y) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package imagehashgo
+
+import "image"
+
+// PHashPrecomp caches what PerceptualHash needs of one image for its 8x8
+// and 16x16 hashes: the grayscale image resized to 32x32 and 64x64, as
+// uint8, 5 KiB in all. A pipeline that buckets on 8x8 hashes can then
+// confirm candidates at 16x16 without decoding the image again; only the
+// DCT is computed per hash.
+//
+// The grayscale images are kept rather than the DCT coefficients: a 16x16
+// hash taken from the 16x16 corner of a fast 64x64 DCT (DCT2DLowFreq)
+// differs in rounding from the general DCT PerceptualHash uses, which flips
+// the bits of coefficients at the median on symmetric images. A PHashPrecomp
+// is immutable and safe for concurrent use.
+type PHashPrecomp struct {
+	gray32, gray64 *image.Gray
+	median         MedianStrategy
+}
+
+// PerceptualPrecompute converts img to grayscale once and caches the
+// resized images of PerceptualHash(img, 8, 4, opts...) and
+// PerceptualHash(img, 16, 4, opts...). As with Hash, WithPreprocess is
+// applied first, and its error is returned.
+func PerceptualPrecompute(img image.Image, opts ...Option) (*PHashPrecomp, error) {
+	o := newOptions(opts)
+	if o.preprocess != nil {
+		var err error
+		if img, err = o.preprocess.Apply(img); err != nil {
+			return nil, err
+		}
+	}
+
+	gray := o.fillIgnored(o.grayscale(img))
+	return &PHashPrecomp{
+		gray32: ownedGray(resizeGray(gray, 32, 32), gray),
+		gray64: ownedGray(resizeGray(gray, 64, 64), gray),
+		median: o.median,
+	}, nil
+}
+
+// Hash8 returns the 8x8 PerceptualHash of the image, with the default
+// highfreqFactor of 4
+func (p *PHashPrecomp) Hash8() *ImageHash {
+	return perceptualHashResized(p.gray32, 8, p.median)
+}
+
+// Hash16 returns the 16x16 PerceptualHash of the image, with the default
+// highfreqFactor of 4
+func (p *PHashPrecomp) Hash16() *ImageHash {
+	return perceptualHashResized(p.gray64, 16, p.median)
+}
+
+// ownedGray returns resized, or a copy of it when resizeGray returned src
+// itself, which may be the caller's image
+func ownedGray(resized, src *image.Gray) *image.Gray {
+	if resized != src {
+		return resized
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	c := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		copy(c.Pix[y*c.Stride:y*c.Stride+w], src.Pix[y*src.Stride:y*src.Stride+w])
+	}
+	return c
+}
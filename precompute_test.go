@@ -0,0 +1,161 @@
+package imagehashgo
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// precomputeInputs returns the golden corpus and a few synthetic images
+func precomputeInputs(t *testing.T) map[string]image.Image {
+	t.Helper()
+	inputs := map[string]image.Image{
+		"bench":  getBenchImage(),
+		"wave":   waveImage(960),
+		"noise":  noiseImage(50, 70, 960),
+		"text":   textPage(960),
+		"gray32": noiseImage(32, 32, 961),
+		"gray64": noiseImage(64, 64, 962),
+		"origin": noiseImage(80, 80, 963).SubImage(image.Rect(7, 9, 71, 73)),
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range files {
+		if filepath.Ext(path) == ".json" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("decoding %s: %v", path, err)
+		}
+		inputs[filepath.Base(path)] = img
+	}
+	return inputs
+}
+
+func TestPerceptualPrecompute(t *testing.T) {
+	optionSets := map[string][]Option{
+		"default":    nil,
+		"ignore":     {WithIgnoreRegion(image.Rect(0, 80, 100, 100))},
+		"preprocess": {WithPreprocess(NewPreprocess(Composite(color.White), Equalize()))},
+	}
+	for name, img := range precomputeInputs(t) {
+		for optName, opts := range optionSets {
+			p, err := PerceptualPrecompute(img, opts...)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", name, optName, err)
+			}
+			// PerceptualHash does not apply WithPreprocess; Hash does
+			want8, err := Hash(img, KindPerceptual, 8, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want16, err := Hash(img, KindPerceptual, 16, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				got, want *ImageHash
+			}{{p.Hash8(), want8}, {p.Hash16(), want16}} {
+				if c.got.ToString() != c.want.ToString() || c.got.Kind() != KindPerceptual {
+					t.Errorf("%s/%s: got %s (%q), want %s", name, optName, c.got.ToString(), c.got.Kind(), c.want.ToString())
+				}
+			}
+		}
+	}
+}
+
+// TestPerceptualPrecompute_OwnsPixels checks that a grayscale input already
+// at a cached size is copied, not kept
+func TestPerceptualPrecompute_OwnsPixels(t *testing.T) {
+	img := noiseImage(32, 32, 964)
+	p, err := PerceptualPrecompute(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := p.Hash8().ToString()
+	for i := range img.Pix {
+		img.Pix[i] = 255 - img.Pix[i]
+	}
+	if got := p.Hash8().ToString(); got != want {
+		t.Errorf("Hash8 changed with the input image: %s, was %s", got, want)
+	}
+}
+
+type failingStep struct{}
+
+func (failingStep) Apply(image.Image) (image.Image, error) { return nil, errors.New("unreadable") }
+func (failingStep) String() string                         { return "failing" }
+
+func TestPerceptualPrecompute_PreprocessError(t *testing.T) {
+	_, err := PerceptualPrecompute(waveImage(1), WithPreprocess(NewPreprocess(failingStep{})))
+	if err == nil {
+		t.Error("PerceptualPrecompute() returned no error for a failing preprocess step")
+	}
+}
+
+// TestPerceptualPrecompute_FastBand quantifies why Hash16 does not use the
+// 16x16 corner of dct2DFast64Band: both DCTs agree to rounding, which only
+// matters for coefficients at the median, but symmetric images (checker,
+// tiny, tall) have many coefficients that are exactly 0 in theory.
+func TestPerceptualPrecompute_FastBand(t *testing.T) {
+	var total, differing int
+	for name, img := range precomputeInputs(t) {
+		p, err := PerceptualPrecompute(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pixels := make([]float64, 64*64)
+		for y := range 64 {
+			for x := range 64 {
+				pixels[y*64+x] = float64(p.gray64.Pix[y*p.gray64.Stride+x])
+			}
+		}
+		band := make([]float64, 16*16)
+		dct2DFast64Band(&pixels, 0, 16, band)
+		fast := thresholdHash(band, 16, 16, Median)
+		d, err := fast.Distance(p.Hash16())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d > 0 {
+			differing++
+			t.Logf("%s: fast band differs by %d bits", name, d)
+		}
+		total++
+	}
+	t.Logf("fast band differs from PerceptualHash(img, 16, 4) on %d of %d images", differing, total)
+}
+
+func BenchmarkPerceptualPrecompute(b *testing.B) {
+	img := getBenchImage()
+	b.Run("Precompute", func(b *testing.B) {
+		for b.Loop() {
+			p, _ := PerceptualPrecompute(img)
+			p.Hash8()
+			p.Hash16()
+		}
+	})
+	b.Run("Direct", func(b *testing.B) {
+		for b.Loop() {
+			PerceptualHash(img, 8, 4)
+			PerceptualHash(img, 16, 4)
+		}
+	})
+	p, _ := PerceptualPrecompute(img)
+	b.Run("Hash16", func(b *testing.B) {
+		for b.Loop() {
+			p.Hash16()
+		}
+	})
+}
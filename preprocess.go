@@ -0,0 +1,377 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// PreprocessStep is one image transformation of a Preprocess pipeline.
+// String describes the step and its parameters; it is part of the Hasher
+// fingerprint, so two steps with the same description must behave the same.
+type PreprocessStep interface {
+	Apply(img image.Image) (image.Image, error)
+	String() string
+}
+
+// AdaptiveStep is a PreprocessStep whose effect depends on a decision it
+// takes from the image, such as where the border of AutoCrop lies. Two
+// images hashed to be compared can take different decisions and so hash
+// different parts of the same content; MatchPair takes one decision for
+// both instead, from Decide on each and Combine. Apply(img) gives the same
+// pixels as ApplyDecision(img, d) with d from Decide(img).
+type AdaptiveStep interface {
+	PreprocessStep
+	// Decide returns the decision Apply would take for img
+	Decide(img image.Image) (fmt.Stringer, error)
+	// Combine returns the decision to apply to both images of a pair
+	Combine(a, b fmt.Stringer) fmt.Stringer
+	// ApplyDecision transforms img as decided, or returns an error for a
+	// decision that did not come from this step
+	ApplyDecision(img image.Image, d fmt.Stringer) (image.Image, error)
+}
+
+// Preprocess applies a sequence of steps in the declared order, e.g.
+//
+//	NewPreprocess(AutoOrient(), Composite(color.White), AutoCrop(8), Equalize())
+//
+// A Preprocess is immutable and safe for concurrent use as long as its steps
+// are.
+type Preprocess struct {
+	steps []PreprocessStep
+}
+
+// NewPreprocess returns a pipeline running steps in order. Nil steps are
+// dropped.
+func NewPreprocess(steps ...PreprocessStep) *Preprocess {
+	p := &Preprocess{}
+	for _, step := range steps {
+		if step != nil {
+			p.steps = append(p.steps, step)
+		}
+	}
+	return p
+}
+
+// Apply runs every step on img in order. A nil Preprocess returns img.
+func (p *Preprocess) Apply(img image.Image) (image.Image, error) {
+	if p == nil {
+		return img, nil
+	}
+	for _, step := range p.steps {
+		out, err := step.Apply(img)
+		if err != nil {
+			return nil, fmt.Errorf("preprocess %s: %w", step, err)
+		}
+		img = out
+	}
+	return img, nil
+}
+
+// String describes the pipeline as its steps joined by "|"
+func (p *Preprocess) String() string {
+	if p == nil {
+		return ""
+	}
+	names := make([]string, len(p.steps))
+	for i, step := range p.steps {
+		names[i] = step.String()
+	}
+	return strings.Join(names, "|")
+}
+
+// parsePreprocess rebuilds a pipeline from its String. Composite colors
+// are read back as the 16-bit premultiplied values String writes; the other
+// steps have the same form as in a MatchProfile.
+func parsePreprocess(s string) (*Preprocess, error) {
+	var steps []PreprocessStep
+	for _, name := range strings.Split(s, "|") {
+		if arg, ok := strings.CutPrefix(name, "composite("); ok && len(arg) == 17 {
+			var r, g, b, a uint16
+			if n, err := fmt.Sscanf(arg, "%4x%4x%4x%4x)", &r, &g, &b, &a); err == nil && n == 4 {
+				steps = append(steps, Composite(color.RGBA64{r, g, b, a}))
+				continue
+			}
+		}
+		step, err := parseProfileStep(name)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return NewPreprocess(steps...), nil
+}
+
+// ExifOriented is implemented by images that know their EXIF orientation
+// (1-8). AutoOrient uses it to undo the camera rotation.
+type ExifOriented interface {
+	Orientation() int
+}
+
+// OrientedImage attaches an EXIF orientation to a decoded image
+type OrientedImage struct {
+	image.Image
+	// EXIF orientation tag value, 1 (normal) to 8
+	Exif int
+}
+
+// Orientation implements the ExifOriented interface
+func (o OrientedImage) Orientation() int {
+	return o.Exif
+}
+
+type autoOrient struct{}
+
+// AutoOrient undoes the EXIF orientation of images implementing
+// ExifOriented, such as OrientedImage. Other images are passed through.
+func AutoOrient() PreprocessStep {
+	return autoOrient{}
+}
+
+func (autoOrient) Apply(img image.Image) (image.Image, error) {
+	o, ok := img.(ExifOriented)
+	if !ok {
+		return img, nil
+	}
+	if inner, ok := img.(OrientedImage); ok {
+		img = inner.Image
+	}
+	switch o.Orientation() {
+	case 0, 1:
+		return img, nil
+	case 2:
+		return imaging.FlipH(img), nil
+	case 3:
+		return imaging.Rotate180(img), nil
+	case 4:
+		return imaging.FlipV(img), nil
+	case 5:
+		return imaging.Transpose(img), nil
+	case 6:
+		return imaging.Rotate270(img), nil
+	case 7:
+		return imaging.Transverse(img), nil
+	case 8:
+		return imaging.Rotate90(img), nil
+	default:
+		return nil, fmt.Errorf("invalid EXIF orientation %d", o.Orientation())
+	}
+}
+
+func (autoOrient) String() string { return "autoorient" }
+
+type composite struct {
+	background color.Color
+}
+
+// Composite flattens transparency onto a solid background, so transparent
+// pixels hash like the background instead of like their hidden color.
+// A nil background is white.
+func Composite(background color.Color) PreprocessStep {
+	if background == nil {
+		background = color.White
+	}
+	return composite{background: background}
+}
+
+func (c composite) Apply(img image.Image) (image.Image, error) {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, &image.Uniform{c.background}, image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst, nil
+}
+
+func (c composite) String() string {
+	r, g, b, a := c.background.RGBA()
+	return fmt.Sprintf("composite(%04x%04x%04x%04x)", r, g, b, a)
+}
+
+type autoCrop struct {
+	tolerance uint8
+}
+
+// AutoCrop removes uniform borders: rows and columns whose grayscale values
+// all lie within tolerance of the top-left pixel. An image that is entirely
+// border is returned unchanged. The step is an AdaptiveStep, deciding the
+// border color.
+func AutoCrop(tolerance uint8) PreprocessStep {
+	return autoCrop{tolerance: tolerance}
+}
+
+func (c autoCrop) Apply(img image.Image) (image.Image, error) {
+	gray := ToGrayscaleFast(img)
+	b := gray.Bounds()
+	if b.Empty() {
+		return img, nil
+	}
+	crop, ok := c.cropRect(gray, b, gray.GrayAt(b.Min.X, b.Min.Y).Y)
+	if !ok {
+		return img, nil
+	}
+	return imaging.Crop(img, crop), nil
+}
+
+// cropRect returns r without its outer rows and columns whose values all
+// lie within tolerance of ref, or false when r is entirely border
+func (c autoCrop) cropRect(gray *image.Gray, r image.Rectangle, ref uint8) (image.Rectangle, bool) {
+	isBorder := func(x, y int) bool {
+		v := gray.GrayAt(x, y).Y
+		return max(v, ref)-min(v, ref) <= c.tolerance
+	}
+	rowIsBorder := func(y int) bool {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	colIsBorder := func(x, minY, maxY int) bool {
+		for y := minY; y < maxY; y++ {
+			if !isBorder(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	crop := r
+	for crop.Min.Y < crop.Max.Y && rowIsBorder(crop.Min.Y) {
+		crop.Min.Y++
+	}
+	if crop.Min.Y == crop.Max.Y {
+		return r, false
+	}
+	for rowIsBorder(crop.Max.Y - 1) {
+		crop.Max.Y--
+	}
+	for colIsBorder(crop.Min.X, crop.Min.Y, crop.Max.Y) {
+		crop.Min.X++
+	}
+	for colIsBorder(crop.Max.X-1, crop.Min.Y, crop.Max.Y) {
+		crop.Max.X--
+	}
+	return crop, true
+}
+
+// cropBorders is the decision of AutoCrop: the gray levels of the borders
+// to remove, sorted
+type cropBorders []uint8
+
+func (d cropBorders) String() string {
+	if len(d) == 0 {
+		return "no border"
+	}
+	levels := make([]string, len(d))
+	for i, v := range d {
+		levels[i] = fmt.Sprint(v)
+	}
+	return "borders " + strings.Join(levels, ",")
+}
+
+// Decide returns the gray level of the border Apply would remove, or no
+// border when the image has none or is entirely border
+func (c autoCrop) Decide(img image.Image) (fmt.Stringer, error) {
+	gray := ToGrayscaleFast(img)
+	b := gray.Bounds()
+	if b.Empty() {
+		return cropBorders{}, nil
+	}
+	ref := gray.GrayAt(b.Min.X, b.Min.Y).Y
+	if crop, ok := c.cropRect(gray, b, ref); !ok || crop == b {
+		return cropBorders{}, nil
+	}
+	return cropBorders{ref}, nil
+}
+
+// Combine takes the union of the borders. A border found on one image only
+// is one the other image lacks or hides under another border, as with a
+// white margin framed in black: removing both from both images leaves the
+// same content, where the intersection would leave one of them framed.
+func (c autoCrop) Combine(a, b fmt.Stringer) fmt.Stringer {
+	da, _ := a.(cropBorders)
+	db, _ := b.(cropBorders)
+	union := slices.Concat(da, db)
+	slices.Sort(union)
+	return cropBorders(slices.Compact(union))
+}
+
+// ApplyDecision removes the decided borders from the edges, each as often
+// as it is found, until none is left
+func (c autoCrop) ApplyDecision(img image.Image, d fmt.Stringer) (image.Image, error) {
+	borders, ok := d.(cropBorders)
+	if !ok {
+		return nil, fmt.Errorf("%s cannot apply decision %q", c, d)
+	}
+	gray := ToGrayscaleFast(img)
+	crop := gray.Bounds()
+	if crop.Empty() {
+		return img, nil
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, ref := range borders {
+			if r, ok := c.cropRect(gray, crop, ref); ok && r != crop {
+				crop, changed = r, true
+			}
+		}
+	}
+	return imaging.Crop(img, crop), nil
+}
+
+func (c autoCrop) String() string { return fmt.Sprintf("autocrop(%d)", c.tolerance) }
+
+type equalize struct{}
+
+// Equalize converts the image to grayscale and spreads its histogram over
+// the full 0-255 range
+func Equalize() PreprocessStep {
+	return equalize{}
+}
+
+func (equalize) Apply(img image.Image) (image.Image, error) {
+	gray := ToGrayscaleFast(img)
+	b := gray.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	var hist [256]int
+	for y := range h {
+		for _, p := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			hist[p]++
+		}
+	}
+
+	// Map through the cumulative histogram, ignoring the lowest occupied
+	// level so the darkest value maps to 0
+	var cdf [256]int
+	cdfMin, total := 0, w*h
+	for i, sum := 0, 0; i < 256; i++ {
+		sum += hist[i]
+		cdf[i] = sum
+		if cdfMin == 0 && sum > 0 {
+			cdfMin = sum
+		}
+	}
+	out := image.NewGray(b)
+	for y := range h {
+		src := gray.Pix[y*gray.Stride : y*gray.Stride+w]
+		dst := out.Pix[y*out.Stride:]
+		for x, p := range src {
+			if total == cdfMin {
+				// A single gray level has nothing to spread
+				dst[x] = p
+			} else {
+				dst[x] = uint8((cdf[p] - cdfMin) * 255 / (total - cdfMin))
+			}
+		}
+	}
+	return out, nil
+}
+
+func (equalize) String() string { return "equalize" }
@@ -0,0 +1,114 @@
+package imagehashgo
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// borderedImage is a gray 100 frame around a low-contrast gradient that
+// starts within AutoCrop's tolerance of the frame, with a dark square in it
+func borderedImage() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 120, 90))
+	for y := range 90 {
+		for x := range 120 {
+			v := uint8(100)
+			if x >= 15 && x < 105 && y >= 10 && y < 80 {
+				v = uint8(104 + (x-15)/4)
+				if x >= 60 && x < 90 && y >= 30 && y < 60 {
+					v = 20
+				}
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return img
+}
+
+func TestPreprocess_OrderMatters(t *testing.T) {
+	img := borderedImage()
+	cropFirst, err := NewHasher(KindDifference, 8, WithPreprocess(NewPreprocess(AutoCrop(8), Equalize())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalizeFirst, err := NewHasher(KindDifference, 8, WithPreprocess(NewPreprocess(Equalize(), AutoCrop(8))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h1, err := cropFirst.Hash(img)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	h2, err := equalizeFirst.Hash(img)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if h1.ToString() == h2.ToString() {
+		t.Errorf("crop-then-equalize and equalize-then-crop both gave %s", h1.ToString())
+	}
+	for _, h := range []*Hasher{cropFirst, equalizeFirst} {
+		again, _ := h.Hash(img)
+		if first, _ := h.Hash(img); first.ToString() != again.ToString() {
+			t.Errorf("%s is not deterministic", h.Fingerprint())
+		}
+	}
+	if cropFirst.Fingerprint() == equalizeFirst.Fingerprint() {
+		t.Errorf("fingerprints should differ, both %q", cropFirst.Fingerprint())
+	}
+}
+
+func TestPreprocess_Steps(t *testing.T) {
+	t.Run("AutoCrop", func(t *testing.T) {
+		out, err := AutoCrop(8).Apply(borderedImage())
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The first 20 gradient columns (104..108) are within tolerance
+		// of the frame, so they go with it
+		if got := out.Bounds(); got.Dx() != 90-20 || got.Dy() != 70 {
+			t.Errorf("cropped to %v", got)
+		}
+	})
+
+	t.Run("Composite", func(t *testing.T) {
+		img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+		img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0})
+		img.SetNRGBA(1, 0, color.NRGBA{0, 0, 0, 255})
+		out, _ := Composite(color.White).Apply(img)
+		if r, _, _, _ := out.At(0, 0).RGBA(); r != 0xffff {
+			t.Errorf("transparent pixel composited to %v", out.At(0, 0))
+		}
+		if r, _, _, _ := out.At(1, 0).RGBA(); r != 0 {
+			t.Errorf("opaque pixel composited to %v", out.At(1, 0))
+		}
+	})
+
+	t.Run("Equalize", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 4, 1))
+		copy(img.Pix, []uint8{100, 101, 102, 103})
+		out, _ := Equalize().Apply(img)
+		if got := out.(*image.Gray).Pix; got[0] != 0 || got[3] != 255 {
+			t.Errorf("Equalize() = %v", got)
+		}
+	})
+
+	t.Run("AutoOrient", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 3, 2))
+		img.SetGray(0, 0, color.Gray{Y: 255})
+		out, err := AutoOrient().Apply(OrientedImage{Image: img, Exif: 6})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Orientation 6 is undone by a 90 degree clockwise rotation
+		if b := out.Bounds(); b.Dx() != 2 || b.Dy() != 3 {
+			t.Fatalf("rotated bounds %v", b)
+		}
+		if r, _, _, _ := out.At(1, 0).RGBA(); r != 0xffff {
+			t.Errorf("top-left pixel not moved to top-right")
+		}
+		if _, err := AutoOrient().Apply(OrientedImage{Image: img, Exif: 9}); err == nil {
+			t.Error("invalid orientation expected error")
+		}
+	})
+}
@@ -0,0 +1,482 @@
+package imagehashgo
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MatchProfile is a declarative matching rule: which algorithms to compute
+// at which sizes, the distance each may reach, how their verdicts combine
+// and the preprocessing applied first. Profiles are read from files by
+// LoadMatchProfile, in a small subset of YAML:
+//
+//	# Every algorithm must agree
+//	combine: all            # any, all or weighted
+//	preprocess: [autoorient, composite, autocrop(8)]
+//	algorithms:
+//	  - algo: phash         # ahash, phash, dhash or dhash_v
+//	    size: 8             # default 8
+//	    threshold: 6        # 0 to size*size bits
+//	  - algo: dhash
+//	    threshold: 6
+//
+// With combine: weighted, every algorithm also has a weight (default 1),
+// and a pair matches when the algorithms within their thresholds carry at
+// least min_score (0 to 1, default 0.5) of the total weight. The
+// preprocess steps are autoorient, normalizecolor, composite (on white,
+// or composite(#rrggbb)), autocrop(tolerance) and equalize, run in order.
+//
+// A MatchProfile is immutable and safe for concurrent use.
+type MatchProfile struct {
+	name     string
+	combine  string
+	minScore float64
+	weights  map[HashKind]float64
+	ensemble *Ensemble
+	// steps and plain are the preprocessing and the hashers without it,
+	// for MatchPair
+	steps []PreprocessStep
+	plain *Ensemble
+}
+
+// profileCombine lists the combine values
+var profileCombine = []string{"any", "all", "weighted"}
+
+// builtinProfiles are the profiles BuiltinMatchProfile returns
+var builtinProfiles = map[string]string{
+	// strict accepts recompressed and lightly resized copies only
+	"strict": `
+combine: all
+algorithms:
+  - algo: phash
+    threshold: 6
+  - algo: dhash
+    threshold: 6
+`,
+	// loose also accepts crops of a few percent, color and contrast edits,
+	// and images that differ only in their orientation metadata or alpha
+	"loose": `
+combine: weighted
+min_score: 0.5
+preprocess: [autoorient, composite]
+algorithms:
+  - algo: phash
+    threshold: 14
+    weight: 2
+  - algo: dhash
+    threshold: 14
+  - algo: ahash
+    threshold: 12
+`,
+}
+
+// BuiltinMatchProfile returns a profile shipped with the package: "strict"
+// or "loose"
+func BuiltinMatchProfile(name string) (*MatchProfile, error) {
+	src, ok := builtinProfiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown built-in match profile %q", name)
+	}
+	p, err := parseMatchProfile(strings.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("built-in profile %s: %w", name, err)
+	}
+	p.name = name
+	return p, nil
+}
+
+// LoadMatchProfile reads the profile file at path. Unknown keys and
+// algorithms, out-of-range values and malformed lines are reported with
+// their line number.
+func LoadMatchProfile(path string) (*MatchProfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := parseMatchProfile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.name = path
+	return p, nil
+}
+
+// Name returns the built-in name or file path of the profile
+func (p *MatchProfile) Name() string {
+	return p.name
+}
+
+// Hash computes every hash the profile compares, after its preprocessing
+func (p *MatchProfile) Hash(img image.Image) (EnsembleHashes, error) {
+	return p.ensemble.Hash(img)
+}
+
+// MatchHashes applies the profile to hashes computed by Hash
+func (p *MatchProfile) MatchHashes(a, b EnsembleHashes) (bool, EnsembleExplanation) {
+	match, exp := p.ensemble.Match(a, b)
+	if p.combine != "weighted" {
+		return match, exp
+	}
+	var score, total float64
+	for _, l := range exp.Leaves {
+		total += p.weights[l.Kind]
+		if l.Match {
+			score += p.weights[l.Kind]
+		}
+	}
+	return score >= p.minScore*total, exp
+}
+
+// Match hashes both images and applies the profile
+func (p *MatchProfile) Match(a, b image.Image) (bool, EnsembleExplanation, error) {
+	ha, err := p.Hash(a)
+	if err != nil {
+		return false, EnsembleExplanation{}, err
+	}
+	hb, err := p.Hash(b)
+	if err != nil {
+		return false, EnsembleExplanation{}, err
+	}
+	match, exp := p.MatchHashes(ha, hb)
+	return match, exp, nil
+}
+
+// profileLine is a non-blank line of a profile, without its comment
+type profileLine struct {
+	n      int
+	indent int
+	text   string
+}
+
+// profileAlgo is one entry of the algorithms list
+type profileAlgo struct {
+	line      int
+	kind      HashKind
+	size      int
+	threshold int
+	weight    float64
+}
+
+func parseMatchProfile(r io.Reader) (*MatchProfile, error) {
+	var lines []profileLine
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		text := strings.TrimRight(stripProfileComment(sc.Text()), " \t")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if strings.Contains(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed, indent with spaces", n)
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		lines = append(lines, profileLine{n: n, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	combine, minScore := "any", 0.5
+	var steps []PreprocessStep
+	var algos []profileAlgo
+	seen := make(map[string]int)
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if l.indent != 0 {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.n)
+		}
+		key, value, err := profileKeyValue(l)
+		if err != nil {
+			return nil, err
+		}
+		if prev, dup := seen[key]; dup {
+			return nil, fmt.Errorf("line %d: %s already set on line %d", l.n, key, prev)
+		}
+		seen[key] = l.n
+
+		// The lines of a block value are the following indented ones
+		block := i + 1
+		for block < len(lines) && lines[block].indent > 0 {
+			block++
+		}
+		nested := lines[i+1 : block]
+		i = block - 1
+		if value != "" && len(nested) > 0 {
+			return nil, fmt.Errorf("line %d: unexpected indentation", nested[0].n)
+		}
+
+		switch key {
+		case "combine":
+			if !slices.Contains(profileCombine, value) {
+				return nil, fmt.Errorf("line %d: combine must be one of %s, got %q", l.n, strings.Join(profileCombine, ", "), value)
+			}
+			combine = value
+		case "min_score":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(f > 0 && f <= 1) {
+				return nil, fmt.Errorf("line %d: min_score must be a number above 0 and at most 1, got %q", l.n, value)
+			}
+			minScore = f
+		case "preprocess":
+			items, err := profileList(l, value, nested)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				step, err := parseProfileStep(item.text)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", item.n, err)
+				}
+				steps = append(steps, step)
+			}
+		case "algorithms":
+			if value != "" {
+				return nil, fmt.Errorf("line %d: algorithms must be a list of - algo: entries", l.n)
+			}
+			if algos, err = parseProfileAlgos(nested); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unknown key %q", l.n, key)
+		}
+	}
+	if len(algos) == 0 {
+		return nil, fmt.Errorf("profile lists no algorithms")
+	}
+	if n, ok := seen["min_score"]; ok && combine != "weighted" {
+		return nil, fmt.Errorf("line %d: min_score needs combine: weighted", n)
+	}
+
+	var opts []Option
+	if len(steps) > 0 {
+		opts = append(opts, WithPreprocess(NewPreprocess(steps...)))
+	}
+	p := &MatchProfile{combine: combine, minScore: minScore, weights: make(map[HashKind]float64), steps: steps}
+	leaves := make([]Rule, len(algos))
+	hashers := make([]*Hasher, len(algos))
+	plain := make([]*Hasher, len(algos))
+	for i, a := range algos {
+		h, err := NewHasher(a.kind, a.size, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", a.line, err)
+		}
+		hashers[i] = h
+		if plain[i], err = NewHasher(a.kind, a.size); err != nil {
+			return nil, fmt.Errorf("line %d: %w", a.line, err)
+		}
+		leaves[i] = Leaf(a.kind, a.threshold)
+		p.weights[a.kind] = a.weight
+	}
+	rule := Or(leaves...)
+	if combine == "all" {
+		rule = And(leaves...)
+	}
+	ensemble, err := NewEnsemble(rule, hashers...)
+	if err != nil {
+		return nil, err
+	}
+	p.ensemble = ensemble
+	if p.plain, err = NewEnsemble(rule, plain...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseProfileAlgos parses the entries of the algorithms list
+func parseProfileAlgos(lines []profileLine) ([]profileAlgo, error) {
+	var algos []profileAlgo
+	kinds := make(map[HashKind]int)
+	for len(lines) > 0 {
+		item := lines[0]
+		rest, ok := strings.CutPrefix(item.text, "- ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a \"- algo: ...\" list entry", item.n)
+		}
+		// The entry's first key follows the dash; the others are indented
+		// to line up with it
+		fieldIndent := item.indent + 2
+		fields := []profileLine{{n: item.n, indent: fieldIndent, text: rest}}
+		lines = lines[1:]
+		for len(lines) > 0 && lines[0].indent > item.indent {
+			if lines[0].indent != fieldIndent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", lines[0].n)
+			}
+			fields = append(fields, lines[0])
+			lines = lines[1:]
+		}
+		if len(lines) > 0 && lines[0].indent != item.indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lines[0].n)
+		}
+
+		a := profileAlgo{line: item.n, size: 8, threshold: -1, weight: 1}
+		seen := make(map[string]bool)
+		for _, f := range fields {
+			key, value, err := profileKeyValue(f)
+			if err != nil {
+				return nil, err
+			}
+			if seen[key] {
+				return nil, fmt.Errorf("line %d: %s set twice in one entry", f.n, key)
+			}
+			seen[key] = true
+			switch key {
+			case "algo":
+				if a.kind, err = ParseHashKind(value); err != nil {
+					return nil, fmt.Errorf("line %d: %w", f.n, err)
+				}
+			case "size":
+				if a.size, err = strconv.Atoi(value); err != nil || a.size < 2 || a.size > MaxHashSize {
+					return nil, fmt.Errorf("line %d: size must be an integer from 2 to %d, got %q", f.n, MaxHashSize, value)
+				}
+			case "threshold":
+				if a.threshold, err = strconv.Atoi(value); err != nil || a.threshold < 0 {
+					return nil, fmt.Errorf("line %d: threshold must be a non-negative integer, got %q", f.n, value)
+				}
+			case "weight":
+				if a.weight, err = strconv.ParseFloat(value, 64); err != nil || !(a.weight > 0) {
+					return nil, fmt.Errorf("line %d: weight must be a positive number, got %q", f.n, value)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: unknown algorithm key %q", f.n, key)
+			}
+		}
+		switch {
+		case a.kind == "":
+			return nil, fmt.Errorf("line %d: entry has no algo", item.n)
+		case a.threshold < 0:
+			return nil, fmt.Errorf("line %d: %s has no threshold", item.n, a.kind)
+		case a.threshold > a.size*a.size:
+			return nil, fmt.Errorf("line %d: %s threshold %d exceeds the %d bits of size %d", item.n, a.kind, a.threshold, a.size*a.size, a.size)
+		}
+		if prev, dup := kinds[a.kind]; dup {
+			return nil, fmt.Errorf("line %d: %s already listed on line %d", item.n, a.kind, prev)
+		}
+		kinds[a.kind] = item.n
+		algos = append(algos, a)
+	}
+	return algos, nil
+}
+
+// profileKeyValue splits a "key: value" line, unquoting the value
+func profileKeyValue(l profileLine) (key, value string, err error) {
+	key, value, ok := strings.Cut(l.text, ":")
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+	if !ok || key == "" || strings.ContainsAny(key, " \"'") {
+		return "", "", fmt.Errorf("line %d: expected key: value", l.n)
+	}
+	if value, err = unquoteProfile(value); err != nil {
+		return "", "", fmt.Errorf("line %d: %w", l.n, err)
+	}
+	return key, value, nil
+}
+
+// profileList returns the items of a flow list ("[a, b]") in value or of
+// a block list ("- a" lines) in nested, each with its line
+func profileList(l profileLine, value string, nested []profileLine) ([]profileLine, error) {
+	var items []profileLine
+	if value != "" {
+		inner, ok := strings.CutPrefix(value, "[")
+		if inner, ok = strings.CutSuffix(inner, "]"); !ok {
+			return nil, fmt.Errorf("line %d: expected a [a, b] list", l.n)
+		}
+		if strings.TrimSpace(inner) == "" {
+			return nil, nil
+		}
+		for item := range strings.SplitSeq(inner, ",") {
+			items = append(items, profileLine{n: l.n, text: strings.TrimSpace(item)})
+		}
+	}
+	for _, item := range nested {
+		text, ok := strings.CutPrefix(item.text, "- ")
+		if !ok || item.indent != nested[0].indent {
+			return nil, fmt.Errorf("line %d: expected a \"- item\" list entry", item.n)
+		}
+		items = append(items, profileLine{n: item.n, text: strings.TrimSpace(text)})
+	}
+	for i, item := range items {
+		text, err := unquoteProfile(item.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", item.n, err)
+		}
+		items[i].text = text
+	}
+	return items, nil
+}
+
+// unquoteProfile removes the quotes of a "double" or 'single' quoted scalar
+func unquoteProfile(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		s, err := strconv.Unquote(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", v)
+		}
+		return s, nil
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("invalid string %s", v)
+		}
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'"), nil
+	}
+	return v, nil
+}
+
+// stripProfileComment removes a # comment that starts a line or follows a
+// space, outside quotes, as YAML does
+func stripProfileComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quote != 0 && ch == '\\' && quote == '"':
+			i++
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '"' || ch == '\''):
+			quote = ch
+		case quote == 0 && ch == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseProfileStep parses one preprocess step of a profile
+func parseProfileStep(s string) (PreprocessStep, error) {
+	name, arg, hasArg := strings.Cut(s, "(")
+	if hasArg {
+		var ok bool
+		if arg, ok = strings.CutSuffix(arg, ")"); !ok {
+			return nil, fmt.Errorf("invalid preprocess step %q", s)
+		}
+	}
+	switch {
+	case name == "autoorient" && !hasArg:
+		return AutoOrient(), nil
+	case name == "normalizecolor" && !hasArg:
+		return NormalizeColorProfile(), nil
+	case name == "equalize" && !hasArg:
+		return Equalize(), nil
+	case name == "composite" && !hasArg:
+		return Composite(nil), nil
+	case name == "composite":
+		var r, g, b uint8
+		if n, err := fmt.Sscanf(arg, "#%02x%02x%02x", &r, &g, &b); err != nil || n != 3 || len(arg) != 7 {
+			return nil, fmt.Errorf("composite color must be #rrggbb, got %q", arg)
+		}
+		return Composite(color.RGBA{r, g, b, 255}), nil
+	case name == "autocrop" && hasArg:
+		tolerance, err := strconv.ParseUint(arg, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("autocrop tolerance must be from 0 to 255, got %q", arg)
+		}
+		return AutoCrop(uint8(tolerance)), nil
+	}
+	return nil, fmt.Errorf("unknown preprocess step %q", s)
+}
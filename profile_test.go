@@ -0,0 +1,172 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// profilePairs returns fixture pairs from the golden corpus: a photo with
+// a recompressed and resized copy, a brightened copy and an unrelated image
+func profilePairs(t *testing.T) (photo, copy, edited, other image.Image) {
+	t.Helper()
+	open := func(name string) image.Image {
+		img, err := imaging.Open(filepath.Join("testdata", "golden", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	photo, other = open("photo.jpg"), open("checker.png")
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, imaging.Resize(photo, 100, 0, imaging.Lanczos), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	copy, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited = imaging.AdjustGamma(imaging.AdjustContrast(photo, 25), 1.4)
+	return photo, copy, edited, other
+}
+
+func TestMatchProfile_Builtin(t *testing.T) {
+	photo, copy, edited, other := profilePairs(t)
+	tests := []struct {
+		profile string
+		a, b    image.Image
+		want    bool
+	}{
+		{"strict", photo, copy, true},
+		{"strict", photo, other, false},
+		{"loose", photo, copy, true},
+		{"loose", photo, edited, true},
+		{"loose", photo, other, false},
+	}
+	for _, tt := range tests {
+		p, err := BuiltinMatchProfile(tt.profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match, exp, err := p.Match(tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: %v (%s)", tt.profile, match, exp)
+		if match != tt.want {
+			t.Errorf("%s: match = %v, want %v (%s)", tt.profile, match, tt.want, exp)
+		}
+	}
+
+	if _, err := BuiltinMatchProfile("medium"); err == nil {
+		t.Error("unknown built-in profile loaded")
+	}
+}
+
+func TestLoadMatchProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	src := `# Transparent logos: composite on black first
+combine: weighted
+min_score: 0.75   # 3 of 4
+preprocess:
+  - composite(#000000)
+  - "autocrop(4)"
+algorithms:
+  - algo: dhash
+    size: 16
+    threshold: 40
+    weight: 3
+  - algo: 'ahash'
+    threshold: 10
+`
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadMatchProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != path {
+		t.Errorf("Name() = %q", p.Name())
+	}
+
+	// A transparent logo and the same logo on black hash alike once
+	// composited on black
+	logo := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	onBlack := image.NewRGBA(logo.Rect)
+	draw.Draw(onBlack, onBlack.Rect, image.Black, image.Point{}, draw.Src)
+	for y := 16; y < 48; y++ {
+		for x := 8; x < 40+y/2; x++ {
+			logo.SetNRGBA(x, y, color.NRGBA{250, 200, 20, 255})
+			onBlack.Set(x, y, color.NRGBA{250, 200, 20, 255})
+		}
+	}
+	hashes, err := p.Hash(logo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := hashes[KindDifference]; h.rows != 16 || len(hashes) != 2 {
+		t.Fatalf("hashes %s", hashes)
+	}
+	if match, exp, err := p.Match(logo, onBlack); err != nil || !match {
+		t.Errorf("Match = %v, %v (%s)", match, err, exp)
+	}
+
+	// Weights decide: dhash alone carries 3 of 4
+	dhashOnly := EnsembleHashes{KindDifference: hashes[KindDifference], KindAverage: hashes[KindAverage]}
+	flipped := EnsembleHashes{KindDifference: hashes[KindDifference], KindAverage: hashes[KindAverage].flipAll()}
+	if match, _ := p.MatchHashes(dhashOnly, flipped); !match {
+		t.Error("weighted profile rejected a pair matching on 3 of 4 weight")
+	}
+}
+
+// flipAll returns h with every bit inverted
+func (h *ImageHash) flipAll() *ImageHash {
+	bits := make([]bool, len(h.hash))
+	for i, b := range h.hash {
+		bits[i] = !b
+	}
+	return NewImageHash(bits, h.rows, h.cols)
+}
+
+func TestLoadMatchProfile_Errors(t *testing.T) {
+	tests := map[string]struct{ src, want string }{
+		"unknown algorithm": {"algorithms:\n  - algo: whash\n    threshold: 4\n", `line 2: unknown hash kind: "whash"`},
+		"threshold range":   {"algorithms:\n  - algo: dhash\n    size: 4\n    threshold: 17\n", "line 2: dhash threshold 17 exceeds the 16 bits of size 4"},
+		"negative":          {"algorithms:\n  - algo: dhash\n\n    threshold: -1\n", `line 4: threshold must be a non-negative integer, got "-1"`},
+		"no threshold":      {"combine: any\nalgorithms:\n  - algo: dhash\n", "line 3: dhash has no threshold"},
+		"size":              {"algorithms:\n  - algo: dhash\n    size: 1000\n    threshold: 4\n", "line 3: size must be an integer from 2 to"},
+		"unknown key":       {"combine: all\nmode: fast\n", `line 2: unknown key "mode"`},
+		"algorithm key":     {"algorithms:\n  - algo: dhash\n    thresold: 4\n", `line 3: unknown algorithm key "thresold"`},
+		"combine":           {"combine: most\n", `line 1: combine must be one of any, all, weighted, got "most"`},
+		"min_score":         {"combine: weighted\nmin_score: 2\n", "line 2: min_score must be a number above 0 and at most 1"},
+		"min_score unused":  {"min_score: 0.5\nalgorithms:\n  - algo: dhash\n    threshold: 4\n", "line 1: min_score needs combine: weighted"},
+		"duplicate algo":    {"algorithms:\n  - algo: dhash\n    threshold: 4\n  - algo: dhash\n    threshold: 8\n", "line 4: dhash already listed on line 2"},
+		"duplicate key":     {"combine: all\ncombine: any\n", "line 2: combine already set on line 1"},
+		"preprocess":        {"preprocess: [autoorient, sharpen]\n", `line 1: unknown preprocess step "sharpen"`},
+		"composite color":   {"preprocess:\n  - composite(red)\n", `line 2: composite color must be #rrggbb, got "red"`},
+		"indentation":       {"algorithms:\n  - algo: dhash\n      threshold: 4\n", "line 3: unexpected indentation"},
+		"not a list":        {"algorithms:\n  algo: dhash\n", `line 2: expected a "- algo: ..." list entry`},
+		"malformed":         {"combine all\n", "line 1: expected key: value"},
+		"empty":             {"# nothing\n", "profile lists no algorithms"},
+	}
+	for name, tt := range tests {
+		_, err := parseMatchProfile(strings.NewReader(tt.src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", name, err, tt.want)
+		}
+	}
+
+	if _, err := LoadMatchProfile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing profile file loaded")
+	}
+}
@@ -0,0 +1,84 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pythonShape returns the hash shape the Python imagehash function
+// algorithm produces for hashSize, and the matching HashKind if any
+func pythonShape(algorithm string, hashSize int) (rows, cols int, kind HashKind, err error) {
+	switch algorithm {
+	case "average_hash", "ahash":
+		kind = KindAverage
+	case "phash":
+		kind = KindPerceptual
+	case "dhash":
+		kind = KindDifference
+	case "dhash_vertical", "dhash_v":
+		kind = KindDifferenceVertical
+	case "phash_simple":
+	case "whash":
+		// whash asserts a power of 2, and a hash_size of at least 2 here
+		if hashSize&(hashSize-1) != 0 {
+			return 0, 0, "", fmt.Errorf("whash hash size must be a power of 2, got %d", hashSize)
+		}
+	case "colorhash":
+		// hashSize is binbits: 14 color bins of binbits bits each
+		if hashSize < 1 {
+			return 0, 0, "", fmt.Errorf("colorhash binbits must be at least 1, got %d", hashSize)
+		}
+		return 14, hashSize, "", nil
+	case "crop_resistant_hash":
+		return 0, 0, "", fmt.Errorf("crop_resistant_hash strings hold one hash per segment and cannot be parsed as a single hash")
+	default:
+		return 0, 0, "", fmt.Errorf("unknown Python imagehash algorithm %q", algorithm)
+	}
+	if hashSize < 2 {
+		return 0, 0, "", fmt.Errorf("hash size must be at least 2, got %d", hashSize)
+	}
+	return hashSize, hashSize, kind, nil
+}
+
+// ParsePythonHash parses str() of a Python imagehash ImageHash, given the
+// function that produced it (e.g. "average_hash", "phash", "dhash",
+// "dhash_vertical", "whash", "colorhash"; the HashKind names also work) and
+// its hash_size (binbits for colorhash). Unlike HexToHash, which guesses a
+// square shape, the shape follows the Python library's rules, and the hex
+// string must have exactly the length Python prints for it.
+//
+// Python formats the bits as one integer, so when the bit count is not a
+// multiple of 4 the string is left-padded with zero bits; ToString pads on
+// the right instead, so such hashes do not round-trip through ToString.
+// crop_resistant_hash strings, which hold one hash per image segment, are
+// rejected.
+func ParsePythonHash(s string, algorithm string, hashSize int) (*ImageHash, error) {
+	rows, cols, kind, err := pythonShape(strings.ToLower(algorithm), hashSize)
+	if err != nil {
+		return nil, err
+	}
+	n := rows * cols
+	if n > MaxHashBits {
+		return nil, fmt.Errorf("shape (%d, %d) exceeds %d bits", rows, cols, MaxHashBits)
+	}
+	if want := (n + 3) / 4; len(s) != want {
+		return nil, fmt.Errorf("%s with hash size %d is %d hex characters, got %d", algorithm, hashSize, want, len(s))
+	}
+
+	padded, err := HexToHash(s)
+	if err != nil {
+		return nil, err
+	}
+	pad := len(padded.hash) - n
+	for _, b := range padded.hash[:pad] {
+		if b {
+			return nil, fmt.Errorf("hash %q has more than %d bits", s, n)
+		}
+	}
+	return &ImageHash{
+		hash: padded.hash[pad:],
+		rows: rows,
+		cols: cols,
+		kind: kind,
+	}, nil
+}
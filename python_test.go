@@ -0,0 +1,215 @@
+package imagehashgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+)
+
+// pythonStr formats bits like Python imagehash's _binary_array_to_hex:
+// one integer, zero-padded on the left to ceil(n/4) hex digits
+func pythonStr(bits []bool) string {
+	var sb strings.Builder
+	for _, b := range bits {
+		if b {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	v, _ := new(big.Int).SetString(sb.String(), 2)
+	return fmt.Sprintf("%0*x", (len(bits)+3)/4, v)
+}
+
+// TestParsePythonHash_Sizes parses, for every algorithm and hash size, the
+// string Python prints for a hash with only its first and last bit set. The
+// first bit lands in the leading digit, which Python pads on the left: 8
+// when the bit count is a multiple of 4, else 1, 2 or 4 (e.g. 25 bits of a
+// size-5 hash print as "1000001").
+func TestParsePythonHash_Sizes(t *testing.T) {
+	for _, algorithm := range []string{"average_hash", "phash", "phash_simple", "dhash", "dhash_vertical", "whash", "colorhash"} {
+		for _, size := range []int{5, 6, 7, 8, 9, 10, 12, 16} {
+			t.Run(fmt.Sprintf("%s/%d", algorithm, size), func(t *testing.T) {
+				rows, cols := size, size
+				if algorithm == "colorhash" {
+					rows = 14
+				}
+				n := rows * cols
+				lead := "8"
+				if n%4 != 0 {
+					lead = fmt.Sprint(1 << (n%4 - 1))
+				}
+				s := lead + strings.Repeat("0", (n+3)/4-2) + "1"
+				h, err := ParsePythonHash(s, algorithm, size)
+				if algorithm == "whash" && size&(size-1) != 0 {
+					if err == nil {
+						t.Error("whash of a size that is not a power of 2 expected error")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if h.rows != rows || h.cols != cols {
+					t.Fatalf("shape (%d, %d), want (%d, %d)", h.rows, h.cols, rows, cols)
+				}
+				for i, b := range h.hash {
+					if want := i == 0 || i == len(h.hash)-1; b != want {
+						t.Fatalf("bit %d = %v, want %v", i, b, want)
+					}
+				}
+				if got := pythonStr(h.hash); got != s {
+					t.Errorf("formatted back as %q", got)
+				}
+			})
+		}
+	}
+}
+
+// TestParsePythonHash_ImagePng parses the strings Python imagehash printed
+// for image.png (cmd/verify/verify.py) and compares them with the hashes Go
+// computes for it
+func TestParsePythonHash_ImagePng(t *testing.T) {
+	file, err := os.Open("image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		s, algorithm string
+		kind         HashKind
+	}{
+		{"ffefc3c3c3c3c3e7", "average_hash", KindAverage},
+		{"b19b9768cc64cc66", "phash", KindPerceptual},
+		{"12189e3333968e0c", "dhash", KindDifference},
+		{"04828010426626bd", "dhash_vertical", KindDifferenceVertical},
+	} {
+		py, err := ParsePythonHash(tt.s, tt.algorithm, 8)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.algorithm, err)
+		}
+		h, err := Hash(img, tt.kind, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, err := py.Distance(h); err != nil || d != 0 {
+			t.Errorf("%s: parsed %s, Go computes %s (%v)", tt.algorithm, py.ToString(), h.ToString(), err)
+		}
+	}
+}
+
+// pythonEntry is one entry of testdata/python/strings.json, see
+// gen/pythonstr
+type pythonEntry struct {
+	Algorithm string `json:"algorithm"`
+	HashSize  int    `json:"hash_size"`
+	Rows      int    `json:"rows"`
+	Cols      int    `json:"cols"`
+	Str       string `json:"str"`
+	Bits      string `json:"bits"`
+}
+
+// TestParsePythonHash_Python parses the strings Python imagehash printed
+// for image.png and compares them with the bits and shape Python held
+func TestParsePythonHash_Python(t *testing.T) {
+	data, err := os.ReadFile("testdata/python/strings.json")
+	if os.IsNotExist(err) {
+		t.Skip("testdata/python/strings.json missing: run go run ./gen/pythonstr with Python imagehash installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []pythonEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		h, err := ParsePythonHash(e.Str, e.Algorithm, e.HashSize)
+		if err != nil {
+			t.Errorf("%s/%d %q: %v", e.Algorithm, e.HashSize, e.Str, err)
+			continue
+		}
+		var bits strings.Builder
+		for _, b := range h.hash {
+			if b {
+				bits.WriteByte('1')
+			} else {
+				bits.WriteByte('0')
+			}
+		}
+		if h.rows != e.Rows || h.cols != e.Cols || bits.String() != e.Bits {
+			t.Errorf("%s/%d %q: shape (%d, %d) bits %s, Python (%d, %d) bits %s", e.Algorithm, e.HashSize, e.Str, h.rows, h.cols, bits.String(), e.Rows, e.Cols, e.Bits)
+		}
+	}
+}
+
+func TestParsePythonHash_Literals(t *testing.T) {
+	tests := []struct {
+		s          string
+		algorithm  string
+		hashSize   int
+		rows, cols int
+		ones       int
+	}{
+		// 25 bits, left-padded to 7 digits
+		{"1ffffff", "average_hash", 5, 5, 5, 25},
+		{"0000001", "dhash", 5, 5, 5, 1},
+		// colorhash(binbits=3) from the Python README
+		{"07007000000", "colorhash", 3, 14, 3, 6},
+		{"ffff", "whash", 4, 4, 4, 16},
+		{"ffffffffff", "phash_simple", 6, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm+"/"+tt.s, func(t *testing.T) {
+			h, err := ParsePythonHash(tt.s, tt.algorithm, tt.hashSize)
+			if tt.rows == 0 {
+				if err == nil {
+					t.Fatal("expected a length error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ones := 0
+			for _, b := range h.hash {
+				if b {
+					ones++
+				}
+			}
+			if h.rows != tt.rows || h.cols != tt.cols || ones != tt.ones {
+				t.Errorf("shape (%d, %d) with %d ones, want (%d, %d) with %d", h.rows, h.cols, ones, tt.rows, tt.cols, tt.ones)
+			}
+			if got := pythonStr(h.hash); got != tt.s {
+				t.Errorf("formatted back as %q", got)
+			}
+		})
+	}
+}
+
+func TestParsePythonHash_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		s, algorithm string
+		hashSize     int
+	}{
+		{"3ffffff", "average_hash", 5}, // 26 bits
+		{"ffff", "whash", 6},           // not a power of 2
+		{"f", "average_hash", 1},
+		{"ff,ff", "crop_resistant_hash", 8},
+		{"ffff", "wavelet", 4},
+		{"zzzzzzz", "colorhash", 2},
+	} {
+		if _, err := ParsePythonHash(tt.s, tt.algorithm, tt.hashSize); err == nil {
+			t.Errorf("ParsePythonHash(%q, %q, %d) expected error", tt.s, tt.algorithm, tt.hashSize)
+		}
+	}
+}
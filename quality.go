@@ -0,0 +1,100 @@
+package imagehashgo
+
+import "image"
+
+// Thresholds used by Quality.LowInformation and IsLowInformation
+const (
+	// LowInformationVariance is the grayscale variance below which an image
+	// is considered solid (a standard deviation of 5 gray levels)
+	LowInformationVariance = 25
+	// LowInformationMarginal is the fraction of marginal cells from which an
+	// image is considered near-solid
+	LowInformationMarginal = 0.5
+	// qualityMarginalDelta is the largest distance, in gray levels, between
+	// a cell and the mean for the cell to count as marginal
+	qualityMarginalDelta = 2
+)
+
+// Quality describes how much information an image carries for hashing.
+// Solid and near-solid images give degenerate hashes: the all-zero aHash,
+// or pHash bits decided by float noise, that match each other regardless
+// of content.
+type Quality struct {
+	// Variance of the grayscale pixel values
+	Variance float64
+	// Marginal is the fraction of the cells of an 8x8 average grid lying
+	// within 2 gray levels of the grid mean, whose hash bit flips easily
+	Marginal float64
+}
+
+// LowInformation reports whether hashes of the image should be excluded
+// from deduplication: the variance is below LowInformationVariance or at
+// least LowInformationMarginal of the cells are marginal
+func (q Quality) LowInformation() bool {
+	return q.Variance < LowInformationVariance || q.Marginal >= LowInformationMarginal
+}
+
+// HashWithQuality is Hash also returning the Quality of the image, measured
+// after WithPreprocess and the grayscale options are applied
+func HashWithQuality(img image.Image, kind HashKind, hashSize int, opts ...Option) (*ImageHash, Quality, error) {
+	o := newOptions(opts)
+	if o.preprocess != nil {
+		var err error
+		if img, err = o.preprocess.Apply(img); err != nil {
+			return nil, Quality{}, err
+		}
+		// Already applied, do not let Hash run it again
+		opts = append(opts[:len(opts):len(opts)], WithPreprocess(nil))
+	}
+
+	h, err := Hash(img, kind, hashSize, opts...)
+	if err != nil {
+		return nil, Quality{}, err
+	}
+	return h, o.quality(img), nil
+}
+
+// IsLowInformation reports whether img is solid or near-solid, see
+// Quality.LowInformation
+func IsLowInformation(img image.Image) bool {
+	return options{}.quality(img).LowInformation()
+}
+
+// quality measures img after the grayscale options of o
+func (o options) quality(img image.Image) Quality {
+	gray := o.grayscale(img)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w == 0 || h == 0 {
+		return Quality{Marginal: 1}
+	}
+
+	var sum, sumSq uint64
+	for y := range h {
+		for _, p := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			sum += uint64(p)
+			sumSq += uint64(p) * uint64(p)
+		}
+	}
+	n := float64(w * h)
+	mean := float64(sum) / n
+	q := Quality{Variance: max(float64(sumSq)/n-mean*mean, 0)}
+
+	grid := o.resize(gray, 8, 8)
+	var gridSum int
+	for y := range 8 {
+		for _, p := range grid.Pix[y*grid.Stride : y*grid.Stride+8] {
+			gridSum += int(p)
+		}
+	}
+	marginal := 0
+	for y := range 8 {
+		for _, p := range grid.Pix[y*grid.Stride : y*grid.Stride+8] {
+			// |p - gridSum/64| <= delta, in integers
+			if d := int(p)*64 - gridSum; max(d, -d) <= qualityMarginalDelta*64 {
+				marginal++
+			}
+		}
+	}
+	q.Marginal = float64(marginal) / 64
+	return q
+}
@@ -0,0 +1,101 @@
+package imagehashgo
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestHashWithQuality(t *testing.T) {
+	solid := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	draw.Draw(solid, solid.Bounds(), &image.Uniform{color.RGBA{40, 90, 160, 255}}, image.Point{}, draw.Src)
+
+	twoColor := image.NewRGBA(image.Rect(0, 0, 200, 150))
+	draw.Draw(twoColor, twoColor.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	draw.Draw(twoColor, image.Rect(0, 0, 100, 150), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+
+	photo := getBenchImage()
+
+	quality := func(img image.Image) Quality {
+		t.Helper()
+		h, q, err := HashWithQuality(img, KindPerceptual, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := PerceptualHash(img, 8, 4); h.ToString() != want.ToString() {
+			t.Errorf("hash = %s, want %s", h.ToString(), want.ToString())
+		}
+		return q
+	}
+	qs, q2, qp := quality(solid), quality(twoColor), quality(photo)
+	t.Logf("solid %+v, two-color %+v, photo %+v", qs, q2, qp)
+
+	if qs.Variance != 0 || qs.Marginal != 1 || !qs.LowInformation() {
+		t.Errorf("solid frame quality = %+v, want zero variance and all cells marginal", qs)
+	}
+	if q2.Marginal != 0 || q2.LowInformation() {
+		t.Errorf("two-color frame quality = %+v, want no marginal cells", q2)
+	}
+	if qp.Variance < 10*LowInformationVariance || qp.Marginal > LowInformationMarginal/2 || qp.LowInformation() {
+		t.Errorf("photo quality = %+v, want high variance and few marginal cells", qp)
+	}
+	if !(qs.Variance < qp.Variance && qp.Variance < q2.Variance) {
+		t.Errorf("variances not ordered solid < photo < two-color: %v, %v, %v", qs.Variance, qp.Variance, q2.Variance)
+	}
+
+	if !IsLowInformation(solid) || IsLowInformation(twoColor) || IsLowInformation(photo) {
+		t.Error("IsLowInformation disagrees with Quality.LowInformation")
+	}
+}
+
+func TestHashWithQualityNearSolid(t *testing.T) {
+	// A faint gradient still passes for solid
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := range 64 {
+		for x := range 64 {
+			img.Pix[y*img.Stride+x] = uint8(100 + x/16)
+		}
+	}
+	if !IsLowInformation(img) {
+		t.Errorf("near-solid image not low information: %+v", options{}.quality(img))
+	}
+}
+
+func TestHashWithQualityPreprocess(t *testing.T) {
+	img := getBenchImage()
+	p := NewPreprocess(Equalize())
+	h, _, err := HashWithQuality(img, KindAverage, 8, WithPreprocess(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Hash(img, KindAverage, 8, WithPreprocess(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.ToString() != want.ToString() {
+		t.Errorf("hash = %s, want %s", h.ToString(), want.ToString())
+	}
+
+	if _, _, err := HashWithQuality(img, "nope", 8); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+}
+
+func TestHasher_HashWithQuality(t *testing.T) {
+	solid := image.NewGray(image.Rect(0, 0, 64, 64))
+	hasher, err := NewHasher(KindDifference, 8, WithDecoderTolerantQuantization(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, img := range []image.Image{solid, getBenchImage()} {
+		h, q, err := hasher.HashWithQuality(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantH, wantQ, _ := HashWithQuality(img, KindDifference, 8, WithDecoderTolerantQuantization(2))
+		if h.ToString() != wantH.ToString() || q != wantQ {
+			t.Errorf("Hasher.HashWithQuality = %s %+v, HashWithQuality %s %+v", h.ToString(), q, wantH.ToString(), wantQ)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"runtime"
+)
+
+// PixelOrder is the byte layout of one pixel in a packed RGB buffer
+type PixelOrder int
+
+const (
+	// PixelRGB is 3 bytes per pixel: red, green, blue
+	PixelRGB PixelOrder = iota
+	// PixelBGR is 3 bytes per pixel: blue, green, red
+	PixelBGR
+	// PixelRGBX is 4 bytes per pixel: red, green, blue and an ignored
+	// byte, e.g. an alpha channel that is always opaque
+	PixelRGBX
+	// PixelBGRX is 4 bytes per pixel: blue, green, red and an ignored byte
+	PixelBGRX
+)
+
+// layout returns the bytes per pixel and the offsets of red, green and
+// blue within a pixel
+func (o PixelOrder) layout() (bpp, r, g, b int, ok bool) {
+	switch o {
+	case PixelRGB:
+		return 3, 0, 1, 2, true
+	case PixelBGR:
+		return 3, 2, 1, 0, true
+	case PixelRGBX:
+		return 4, 0, 1, 2, true
+	case PixelBGRX:
+		return 4, 2, 1, 0, true
+	}
+	return 0, 0, 0, 0, false
+}
+
+// checkPlane checks that buf holds height rows of rowBytes bytes, stride
+// bytes apart, the last row possibly unpadded
+func checkPlane(buf []byte, width, height, stride, bpp int) (rowBytes int, err error) {
+	if width < 1 || height < 1 {
+		return 0, fmt.Errorf("plane size %dx%d is empty or negative", width, height)
+	}
+	if width > math.MaxInt/bpp {
+		return 0, fmt.Errorf("plane width %d is too large", width)
+	}
+	rowBytes = width * bpp
+	if stride < rowBytes {
+		return 0, fmt.Errorf("stride %d is shorter than a row of %d bytes", stride, rowBytes)
+	}
+	if len(buf) < rowBytes || (height-1) > (len(buf)-rowBytes)/stride {
+		return 0, fmt.Errorf("buffer of %d bytes is too short for %d rows of %d bytes with stride %d", len(buf), height, rowBytes, stride)
+	}
+	return rowBytes, nil
+}
+
+// HashYPlane computes a hash of the given kind from a raw 8-bit luma plane,
+// such as the Y plane of a decoded video frame, without copying it: row r
+// is y[r*stride : r*stride+width]. The plane is used as the grayscale image
+// directly, so the result equals Hash of an *image.Gray holding the same
+// pixels. y is only read, and only for the duration of the call.
+//
+// The Y of a video frame is the encoder's luma, often limited to the video
+// range 16-235, while hashing the decoded RGB frame computes Pillow's L at
+// full range; the two usually differ by a few bits. Compare hashes of Y
+// planes with hashes of Y planes.
+func HashYPlane(y []byte, width, height, stride int, kind HashKind, hashSize int, opts ...Option) (*ImageHash, error) {
+	if _, err := checkPlane(y, width, height, stride, 1); err != nil {
+		return nil, err
+	}
+	gray := &image.Gray{Pix: y, Stride: stride, Rect: image.Rect(0, 0, width, height)}
+	return Hash(gray, kind, hashSize, opts...)
+}
+
+// HashRGBBuffer computes a hash of the given kind from a packed 8-bit RGB
+// buffer in the given pixel order, where row r starts at rgb[r*stride]. The
+// pixels are taken as opaque and converted to grayscale as ToGrayscaleFast
+// converts an opaque image.RGBA, so the result equals Hash of an
+// *image.RGBA holding the same colors. rgb is only read.
+func HashRGBBuffer(rgb []byte, width, height, stride int, order PixelOrder, kind HashKind, hashSize int, opts ...Option) (*ImageHash, error) {
+	bpp, ri, gi, bi, ok := order.layout()
+	if !ok {
+		return nil, fmt.Errorf("unknown pixel order %d", order)
+	}
+	if _, err := checkPlane(rgb, width, height, stride, bpp); err != nil {
+		return nil, err
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	rows := func(y0, y1 int) {
+		for y := y0; y < y1; y++ {
+			src := rgb[y*stride : y*stride+bpp*width]
+			out := gray.Pix[y*gray.Stride : y*gray.Stride+width]
+			for x := range out {
+				p := src[bpp*x : bpp*x+bpp]
+				out[x] = opaqueGray(p[ri], p[gi], p[bi])
+			}
+		}
+	}
+	o := newOptions(opts)
+	threshold := ParallelGrayscaleThreshold
+	if o.parallelThresholdSet {
+		threshold = o.parallelThreshold
+	}
+	if width*height > threshold && runtime.GOMAXPROCS(0) > 1 {
+		inParallel(gray.Rect, rows)
+	} else {
+		rows(0, height)
+	}
+	return Hash(gray, kind, hashSize, opts...)
+}
@@ -0,0 +1,119 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+var rawKinds = []HashKind{KindAverage, KindPerceptual, KindDifference, KindDifferenceVertical}
+
+// rawPlane returns a height-row plane of smooth content plus noise, rows
+// stride bytes apart with the padding filled with junk and the last row
+// unpadded, so reading past a row shows up in the hash or as a panic
+func rawPlane(r *rand.Rand, width, height, stride, bpp int) []byte {
+	buf := make([]byte, (height-1)*stride+bpp*width)
+	for i := range buf {
+		buf[i] = 0xAA
+	}
+	for y := range height {
+		for x := range bpp * width {
+			buf[y*stride+x] = uint8(x*3 + y*2 + r.Intn(40))
+		}
+	}
+	return buf
+}
+
+func TestHashYPlane_MatchesGray(t *testing.T) {
+	r := rand.New(rand.NewSource(986))
+	for _, size := range [][3]int{{97, 61, 128}, {64, 64, 64}, {320, 180, 336}} {
+		width, height, stride := size[0], size[1], size[2]
+		plane := rawPlane(r, width, height, stride, 1)
+		before := bytes.Clone(plane)
+
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		for y := range height {
+			copy(gray.Pix[y*gray.Stride:], plane[y*stride:y*stride+width])
+		}
+		for _, kind := range rawKinds {
+			for _, opts := range [][]Option{nil, {WithDecoderTolerantQuantization(2)}} {
+				got, err := HashYPlane(plane, width, height, stride, kind, 8, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := Hash(gray, kind, 8, opts...)
+				if got.ToString() != want.ToString() || got.Kind() != kind {
+					t.Errorf("%v %s: HashYPlane = %s, Hash of Gray = %s", size, kind, got.ToString(), want.ToString())
+				}
+			}
+		}
+		if !bytes.Equal(plane, before) {
+			t.Errorf("%v: HashYPlane modified the plane", size)
+		}
+	}
+}
+
+func TestHashRGBBuffer_MatchesRGBA(t *testing.T) {
+	r := rand.New(rand.NewSource(986))
+	width, height := 150, 90
+	for _, order := range []PixelOrder{PixelRGB, PixelBGR, PixelRGBX, PixelBGRX} {
+		bpp, ri, gi, bi, _ := order.layout()
+		stride := bpp*width + 7
+		buf := rawPlane(r, width, height, stride, bpp)
+
+		rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := range height {
+			for x := range width {
+				p := buf[y*stride+bpp*x:]
+				copy(rgba.Pix[y*rgba.Stride+4*x:], []byte{p[ri], p[gi], p[bi], 255})
+			}
+		}
+		for _, kind := range rawKinds {
+			// Serial and parallel conversions give the same hash
+			for _, threshold := range []int{0, 1 << 30} {
+				got, err := HashRGBBuffer(buf, width, height, stride, order, kind, 8, WithParallelGrayscaleThreshold(threshold))
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, _ := Hash(rgba, kind, 8)
+				if got.ToString() != want.ToString() {
+					t.Errorf("order %d %s: HashRGBBuffer = %s, Hash of RGBA = %s", order, kind, got.ToString(), want.ToString())
+				}
+			}
+		}
+	}
+}
+
+func TestRawBuffers_Errors(t *testing.T) {
+	plane := make([]byte, 10*8)
+	for name, args := range map[string][3]int{
+		"zero width":     {0, 8, 10},
+		"negative size":  {10, -1, 10},
+		"short stride":   {10, 8, 9},
+		"short buffer":   {10, 9, 10},
+		"huge stride":    {10, 8, 1 << 62},
+		"huge height":    {10, 1 << 62, 10},
+		"row past end":   {11, 8, 11},
+		"negative width": {-5, 8, 10},
+	} {
+		if _, err := HashYPlane(plane, args[0], args[1], args[2], KindAverage, 8); err == nil {
+			t.Errorf("HashYPlane %s: expected error", name)
+		}
+		if _, err := HashRGBBuffer(plane, args[0], args[1], 3*args[2], PixelRGB, KindAverage, 8); err == nil {
+			t.Errorf("HashRGBBuffer %s: expected error", name)
+		}
+	}
+	if _, err := HashYPlane(plane, 10, 8, 10, "whash", 8); err == nil {
+		t.Error("unknown kind expected error")
+	}
+	if _, err := HashRGBBuffer(make([]byte, 30*8), 10, 8, 30, PixelOrder(9), KindAverage, 8); err == nil {
+		t.Error("unknown pixel order expected error")
+	}
+	if _, err := HashRGBBuffer(make([]byte, 30*8), 10, 8, 30, PixelRGBX, KindAverage, 8); err == nil {
+		t.Error("RGBX stride of 3 bytes per pixel expected error")
+	}
+	if _, err := HashRGBBuffer(make([]byte, 30), 1<<62, 1, 30, PixelRGBX, KindAverage, 8); err == nil {
+		t.Error("overflowing width expected error")
+	}
+}
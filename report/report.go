@@ -0,0 +1,164 @@
+// Package report renders groups of similar images as a self-contained HTML
+// page, with thumbnails embedded as data URIs, for reviewing the output of
+// a deduplication run.
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"io"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// Image is one file of a group
+type Image struct {
+	Path string
+	// Size is the file size in bytes
+	Size          int64
+	Width, Height int
+	// Thumbnail is the decoded image, or nil when it is not available; it
+	// is scaled down by Render
+	Thumbnail image.Image
+}
+
+// Pair is the hash distance between two images of a group, given as
+// indexes into Group.Images
+type Pair struct {
+	A, B     int
+	Distance int
+}
+
+// Group is a set of images considered duplicates of each other
+type Group struct {
+	Images []Image
+	Pairs  []Pair
+	// Keeper is the index of the image suggested to keep
+	Keeper int
+}
+
+// Options configures Render. The zero value uses the defaults.
+type Options struct {
+	// Title of the page (default "Similar images")
+	Title string
+	// ThumbnailSize is the largest thumbnail side in pixels (default 128)
+	ThumbnailSize int
+	// MaxThumbnails caps the thumbnails embedded per group, keeper first,
+	// to bound the page size (default 8); other images are listed without
+	// one
+	MaxThumbnails int
+}
+
+// Render writes the HTML report for groups to w
+func Render(w io.Writer, groups []Group, opts Options) error {
+	if opts.Title == "" {
+		opts.Title = "Similar images"
+	}
+	if opts.ThumbnailSize <= 0 {
+		opts.ThumbnailSize = 128
+	}
+	if opts.MaxThumbnails <= 0 {
+		opts.MaxThumbnails = 8
+	}
+
+	page := pageData{Title: opts.Title}
+	for gi, g := range groups {
+		if g.Keeper < 0 || g.Keeper >= len(g.Images) {
+			return fmt.Errorf("group %d: keeper %d out of range", gi, g.Keeper)
+		}
+		group := groupData{Number: gi + 1}
+		thumbs := 0
+		for i, img := range g.Images {
+			row := imageData{Image: img, Keeper: i == g.Keeper}
+			// The keeper always gets a thumbnail, the others while the
+			// budget lasts
+			if img.Thumbnail != nil && (row.Keeper || thumbs < opts.MaxThumbnails-1) {
+				uri, err := thumbnailURI(img.Thumbnail, opts.ThumbnailSize)
+				if err != nil {
+					return fmt.Errorf("%s: %w", img.Path, err)
+				}
+				row.Thumb = uri
+				if !row.Keeper {
+					thumbs++
+				}
+			}
+			group.Images = append(group.Images, row)
+		}
+		for _, p := range g.Pairs {
+			if p.A < 0 || p.A >= len(g.Images) || p.B < 0 || p.B >= len(g.Images) {
+				return fmt.Errorf("group %d: pair (%d, %d) out of range", gi, p.A, p.B)
+			}
+			group.Pairs = append(group.Pairs, pairData{g.Images[p.A].Path, g.Images[p.B].Path, p.Distance})
+		}
+		page.Groups = append(page.Groups, group)
+	}
+	return pageTemplate.Execute(w, page)
+}
+
+// thumbnailURI scales img to fit in size x size and encodes it as a JPEG
+// data URI
+func thumbnailURI(img image.Image, size int) (template.URL, error) {
+	thumb := imagehashgo.Thumbnail(img, size)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 75}); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+type pageData struct {
+	Title  string
+	Groups []groupData
+}
+
+type groupData struct {
+	Number int
+	Images []imageData
+	Pairs  []pairData
+}
+
+type imageData struct {
+	Image
+	Keeper bool
+	Thumb  template.URL
+}
+
+type pairData struct {
+	A, B     string
+	Distance int
+}
+
+var pageTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+section { border-top: 1px solid #ccc; padding: 1em 0; }
+figure { display: inline-block; vertical-align: top; margin: 0 1em 1em 0; padding: 4px; border: 2px solid transparent; }
+figure.keeper { border-color: #2a2; }
+figcaption { font-size: small; max-width: 16em; word-break: break-all; }
+td { padding: 0 1em 0 0; font-size: small; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Groups}} groups</p>
+{{range .Groups}}<section class="group">
+<h2>Group {{.Number}}</h2>
+{{range .Images}}<figure class="image{{if .Keeper}} keeper{{end}}">
+{{if .Thumb}}<img src="{{.Thumb}}" alt="{{.Path}}">
+{{end}}<figcaption>{{if .Keeper}}<strong>keep</strong> {{end}}{{.Path}}<br>{{.Width}}x{{.Height}}, {{.Size}} bytes</figcaption>
+</figure>
+{{end}}{{if .Pairs}}<table class="pairs">
+{{range .Pairs}}<tr><td>{{.A}}</td><td>{{.B}}</td><td>{{.Distance}}</td></tr>
+{{end}}</table>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b, a := c.RGBA()
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+	}
+	return img
+}
+
+func TestRender(t *testing.T) {
+	red := solid(400, 300, color.RGBA{255, 0, 0, 255})
+	groups := []Group{
+		{
+			Images: []Image{
+				{Path: "a.jpg", Size: 1000, Width: 400, Height: 300, Thumbnail: red},
+				{Path: "b.jpg", Size: 2000, Width: 800, Height: 600, Thumbnail: red},
+				{Path: "<c>.jpg", Size: 500, Width: 200, Height: 150, Thumbnail: red},
+			},
+			Pairs:  []Pair{{0, 1, 2}, {0, 2, 3}, {1, 2, 1}},
+			Keeper: 1,
+		},
+		{
+			Images: []Image{{Path: "d.png", Width: 10, Height: 10}, {Path: "e.png", Width: 10, Height: 10}},
+			Pairs:  []Pair{{0, 1, 0}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Render(&buf, groups, Options{MaxThumbnails: 2}); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		"<title>Similar images</title>",
+		"<h2>Group 1</h2>",
+		"<h2>Group 2</h2>",
+		`<figure class="image keeper">` + "\n" + `<img src="data:image/jpeg;base64,`,
+		"<strong>keep</strong> b.jpg<br>800x600, 2000 bytes",
+		"&lt;c&gt;.jpg",
+		"<tr><td>b.jpg</td><td>&lt;c&gt;.jpg</td><td>1</td></tr>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if n := strings.Count(page, `<section class="group">`); n != 2 {
+		t.Errorf("got %d groups, want 2", n)
+	}
+	// The keeper plus one other image of group 1; group 2 has no images
+	if n := strings.Count(page, "data:image/jpeg;base64,"); n != 2 {
+		t.Errorf("got %d thumbnails, want 2", n)
+	}
+	if strings.Contains(page, "<c>") {
+		t.Error("paths must be HTML-escaped")
+	}
+}
+
+func TestRender_InvalidGroup(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Render(&buf, []Group{{Images: []Image{{Path: "a"}}, Keeper: 1}}, Options{}); err == nil {
+		t.Error("expected error for out-of-range keeper")
+	}
+	if err := Render(&buf, []Group{{Images: []Image{{Path: "a"}}, Pairs: []Pair{{0, 1, 0}}}}, Options{}); err == nil {
+		t.Error("expected error for out-of-range pair")
+	}
+}
@@ -0,0 +1,235 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"runtime"
+	"runtime/debug"
+)
+
+// reproFormat is the format field of the bundles DumpRepro writes
+const reproFormat = 1
+
+// reproBundle is the JSON document DumpRepro writes
+type reproBundle struct {
+	Format    int             `json:"format"`
+	Version   string          `json:"version"`
+	GOARCH    string          `json:"goarch"`
+	GoVersion string          `json:"go"`
+	Kind      HashKind        `json:"kind"`
+	HashSize  int             `json:"hash_size"`
+	Options   ResolvedOptions `json:"options"`
+	// Gray is the grayscale image the resize starts from, after the
+	// preprocessing and quantization, as a PNG
+	Gray []byte `json:"gray"`
+	// Resized is the output of the resize, as a PNG
+	Resized []byte `json:"resized"`
+	Hash    string `json:"hash"`
+	Rows    int    `json:"rows"`
+	Cols    int    `json:"cols"`
+}
+
+// ReproResult is the outcome of RunRepro
+type ReproResult struct {
+	// Reproduced is whether the current code computes the recorded hash
+	Reproduced bool
+	// Stage is the first stage whose output differs from the bundle,
+	// "resize" or "hash", or "" when the hash is reproduced
+	Stage string
+	// Detail describes the first difference at Stage
+	Detail string
+	// Recorded is the hash in the bundle and Got the hash computed now
+	Recorded, Got *ImageHash
+	// Version, GOARCH and GoVersion describe the build that wrote the
+	// bundle
+	Version, GOARCH, GoVersion string
+}
+
+// moduleVersion returns the version of this module in the running binary,
+// "(devel)" when it is the main module
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == "github.com/K0ng2/imagehash-go" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/K0ng2/imagehash-go" {
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// reproRun hashes gray, the grayscale image at working resolution, with
+// opts and returns the resized image the hash is computed from
+func reproRun(gray *image.Gray, kind HashKind, hashSize int, opts []Option) (*ImageHash, *image.Gray, error) {
+	var resized *image.Gray
+	h, err := Hash(gray, kind, hashSize, append(opts, WithCaptureIntermediate(&resized))...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h, resized, nil
+}
+
+// reproOptions returns the options of r without its preprocessing, which
+// the recorded grayscale image already went through, and without
+// WithAutoAlgorithm: the bundle records the kind that ran, and classifying
+// the grayscale image could choose another
+func reproOptions(r ResolvedOptions) ([]Option, error) {
+	r.Preprocess = ""
+	r.AutoAlgorithm = false
+	return r.Options()
+}
+
+// DumpRepro writes a bundle that reproduces the hash got of img, computed
+// with opts, on another machine: the grayscale image at working resolution
+// (after preprocessing, before the resize) and the resized one, the
+// options, the kind and size of got, and the library version, GOARCH and
+// Go version of this build. got is usually the hash a user reported; the
+// bundle holds no other pixels of img. It fails for preprocessing steps
+// ResolvedOptions.Options cannot rebuild; RunRepro does not rerun them.
+func DumpRepro(w io.Writer, img image.Image, opts ResolvedOptions, got *ImageHash) error {
+	if got == nil || got.kind == "" {
+		return errors.New("repro needs a hash with a kind")
+	}
+	hashSize := int(math.Sqrt(float64(len(got.hash))))
+	if hashSize*hashSize != len(got.hash) {
+		return fmt.Errorf("cannot tell the hash size of a %dx%d hash", got.rows, got.cols)
+	}
+	hashOpts, err := reproOptions(opts)
+	if err != nil {
+		return err
+	}
+	if opts.Preprocess != "" {
+		p, err := parsePreprocess(opts.Preprocess)
+		if err != nil {
+			return fmt.Errorf("cannot rerun preprocessing %s: %w", opts.Preprocess, err)
+		}
+		if img, err = p.Apply(img); err != nil {
+			return err
+		}
+	}
+	gray := newOptions(hashOpts).grayscale(img)
+	_, resized, err := reproRun(gray, got.kind, hashSize, hashOpts)
+	if err != nil {
+		return err
+	}
+
+	b := reproBundle{
+		Format: reproFormat, Version: moduleVersion(), GOARCH: runtime.GOARCH, GoVersion: runtime.Version(),
+		Kind: got.kind, HashSize: hashSize, Options: opts,
+		Hash: got.ToString(), Rows: got.rows, Cols: got.cols,
+	}
+	if b.Gray, err = encodeGrayPNG(gray); err != nil {
+		return err
+	}
+	if b.Resized, err = encodeGrayPNG(resized); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// RunRepro reads a bundle written by DumpRepro, hashes its grayscale image
+// with the current code and reports the first stage that differs from the
+// recorded one: the resized image, then the hash.
+func RunRepro(r io.Reader) (ReproResult, error) {
+	var b reproBundle
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return ReproResult{}, fmt.Errorf("read repro bundle: %w", err)
+	}
+	if b.Format != reproFormat {
+		return ReproResult{}, fmt.Errorf("unknown repro bundle format %d", b.Format)
+	}
+	recorded, err := HexToHashShape(b.Hash, b.Rows, b.Cols)
+	if err != nil {
+		return ReproResult{}, err
+	}
+	recorded.kind = b.Kind
+	gray, err := decodeGrayPNG(b.Gray)
+	if err != nil {
+		return ReproResult{}, fmt.Errorf("repro grayscale image: %w", err)
+	}
+	wantResized, err := decodeGrayPNG(b.Resized)
+	if err != nil {
+		return ReproResult{}, fmt.Errorf("repro resized image: %w", err)
+	}
+	opts, err := reproOptions(b.Options)
+	if err != nil {
+		return ReproResult{}, err
+	}
+	got, resized, err := reproRun(gray, b.Kind, b.HashSize, opts)
+	if err != nil {
+		return ReproResult{}, err
+	}
+
+	res := ReproResult{Recorded: recorded, Got: got, Version: b.Version, GOARCH: b.GOARCH, GoVersion: b.GoVersion}
+	if detail := grayDiff(resized, wantResized); detail != "" {
+		res.Stage, res.Detail = "resize", detail
+		return res, nil
+	}
+	if got.ToString() != recorded.ToString() || got.rows != recorded.rows || got.cols != recorded.cols {
+		d, _ := got.Distance(recorded)
+		res.Stage = "hash"
+		res.Detail = fmt.Sprintf("hash %s, recorded %s (%d bits differ) from the same resized image", got.ToString(), recorded.ToString(), d)
+		return res, nil
+	}
+	res.Reproduced = true
+	return res, nil
+}
+
+// grayDiff describes the first pixel where got differs from want, or
+// returns "" when they are equal
+func grayDiff(got, want *image.Gray) string {
+	if got.Rect.Size() != want.Rect.Size() {
+		return fmt.Sprintf("size %v, recorded %v", got.Rect.Size(), want.Rect.Size())
+	}
+	n := 0
+	var first string
+	for y := range want.Rect.Dy() {
+		for x := range want.Rect.Dx() {
+			g := got.GrayAt(got.Rect.Min.X+x, got.Rect.Min.Y+y).Y
+			w := want.GrayAt(want.Rect.Min.X+x, want.Rect.Min.Y+y).Y
+			if g != w {
+				if n == 0 {
+					first = fmt.Sprintf("pixel (%d,%d) is %d, recorded %d", x, y, g, w)
+				}
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d pixels differ, first %s", n, first)
+}
+
+func encodeGrayPNG(gray *image.Gray) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeGrayPNG(data []byte) (*image.Gray, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		return nil, fmt.Errorf("image is %T, not 8-bit gray", img)
+	}
+	return gray, nil
+}
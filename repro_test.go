@@ -0,0 +1,100 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestRepro_RoundTrip(t *testing.T) {
+	img := getBenchImage()
+	for _, opts := range [][]Option{
+		nil,
+		{WithDecoderTolerantQuantization(2), WithIgnoreRegion(image.Rect(0, 80, 100, 100))},
+		{WithPreprocess(NewPreprocess(AutoCrop(4), Equalize())), WithIntegerPipeline(), WithAspectBuckets()},
+	} {
+		for _, kind := range rawKinds {
+			got, err := Hash(img, kind, 8, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := DumpRepro(&buf, img, ResolveOptions(opts...), got); err != nil {
+				t.Fatal(err)
+			}
+			res, err := RunRepro(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.Reproduced || res.Stage != "" || res.Got.ToString() != got.ToString() || res.Recorded.Kind() != kind {
+				t.Errorf("%s %v: %+v", kind, ResolveOptions(opts...), res)
+			}
+			if res.GOARCH == "" || res.GoVersion == "" || res.Version == "" {
+				t.Errorf("%s: build not recorded: %+v", kind, res)
+			}
+		}
+	}
+}
+
+// editBundle decodes a bundle, applies edit and encodes it again
+func editBundle(t *testing.T, data []byte, edit func(b *reproBundle)) *bytes.Reader {
+	t.Helper()
+	var b reproBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		t.Fatal(err)
+	}
+	edit(&b)
+	out, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(out)
+}
+
+func TestRepro_Divergence(t *testing.T) {
+	img := getBenchImage()
+	got, _ := Hash(img, KindPerceptual, 8)
+	var buf bytes.Buffer
+	if err := DumpRepro(&buf, img, ResolvedOptions{}, got); err != nil {
+		t.Fatal(err)
+	}
+
+	// A resize that computes one pixel differently from the recording
+	res, err := RunRepro(editBundle(t, buf.Bytes(), func(b *reproBundle) {
+		resized, err := decodeGrayPNG(b.Resized)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resized.Pix[3*resized.Stride+5]++
+		b.Resized, _ = encodeGrayPNG(resized)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Reproduced || res.Stage != "resize" || !strings.Contains(res.Detail, "1 pixels differ, first pixel (5,3)") {
+		t.Errorf("injected resize divergence: %+v", res)
+	}
+
+	// A reported hash the recorded resized image does not give
+	res, err = RunRepro(editBundle(t, buf.Bytes(), func(b *reproBundle) {
+		b.Hash = got.flipAll().ToString()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Reproduced || res.Stage != "hash" || !strings.Contains(res.Detail, "64 bits differ") {
+		t.Errorf("reported hash divergence: %+v", res)
+	}
+
+	if _, err := RunRepro(editBundle(t, buf.Bytes(), func(b *reproBundle) { b.Format = 2 })); err == nil {
+		t.Error("unknown format expected error")
+	}
+	if _, err := RunRepro(strings.NewReader("{")); err == nil {
+		t.Error("malformed bundle expected error")
+	}
+	if err := DumpRepro(&buf, img, ResolvedOptions{}, NewImageHash(got.hash, 8, 8)); err == nil {
+		t.Error("hash without a kind expected error")
+	}
+}
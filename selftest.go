@@ -0,0 +1,251 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand/v2"
+)
+
+// selfTestExpected holds the stage outputs SelfTest compares against. It
+// is written by gen/golden (go generate) with SelfTestData.
+//
+//go:embed selftest.json
+var selfTestExpected []byte
+
+// selfTestTolerance bounds the deviation of a DCT coefficient, relative to
+// the largest coefficient of its stage. Reordered or fused floating point
+// operations move coefficients by about 1e-15 of that; a wrong table or
+// butterfly moves them by far more than 1e-9.
+const selfTestTolerance = 1e-9
+
+// selfTestStage is the output of one stage: DCT coefficients in Values,
+// or the hex hash or grayscale pixel digest in Digest, which must match
+// exactly
+type selfTestStage struct {
+	Stage  string    `json:"stage"`
+	Values []float64 `json:"values,omitempty"`
+	Digest string    `json:"digest,omitempty"`
+}
+
+// selfTestHook, when set by tests, may alter every computed stage before
+// it is compared, to simulate a deviating build
+var selfTestHook func(s *selfTestStage)
+
+// SelfTestError reports a SelfTest stage whose output deviates from the
+// embedded expected values
+type SelfTestError struct {
+	// Stage names the stage, e.g. "dct32", "gray/ycbcr" or "hash/phash/8/4"
+	Stage string
+	// Detail describes the first deviation
+	Detail string
+}
+
+func (e *SelfTestError) Error() string {
+	return fmt.Sprintf("selftest: stage %s: %s", e.Stage, e.Detail)
+}
+
+// SelfTest runs fixed deterministic inputs through the 8, 16, 32 and
+// 64-point DCT kernels, every grayscale fast path and each hash algorithm,
+// and compares the outputs with the values recorded when the package was
+// released. It checks that the running build and hardware, e.g. with a
+// different compiler or FMA use, still compute the same hashes.
+//
+// The result is nil or a join of one *SelfTestError per deviating stage.
+func SelfTest() error {
+	var expected []selfTestStage
+	if err := json.Unmarshal(selfTestExpected, &expected); err != nil {
+		return fmt.Errorf("selftest: embedded expected values: %w", err)
+	}
+	want := make(map[string]selfTestStage, len(expected))
+	for _, s := range expected {
+		want[s.Stage] = s
+	}
+
+	var errs []error
+	for _, got := range selfTestRun() {
+		if selfTestHook != nil {
+			selfTestHook(&got)
+		}
+		w, ok := want[got.Stage]
+		if !ok {
+			errs = append(errs, &SelfTestError{got.Stage, "no expected values"})
+			continue
+		}
+		delete(want, got.Stage)
+		if detail := compareStage(got, w); detail != "" {
+			errs = append(errs, &SelfTestError{got.Stage, detail})
+		}
+	}
+	for _, s := range expected {
+		if _, ok := want[s.Stage]; ok {
+			errs = append(errs, &SelfTestError{s.Stage, "stage did not run"})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SelfTestData returns the stage outputs of the running build in the
+// layout of the values SelfTest embeds. gen/golden writes them to
+// selftest.json; on a healthy build they equal the embedded values.
+func SelfTestData() ([]byte, error) {
+	data, err := json.MarshalIndent(selfTestRun(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// compareStage describes the first difference between got and want, or
+// returns "" when they match
+func compareStage(got, want selfTestStage) string {
+	if got.Digest != want.Digest {
+		return fmt.Sprintf("got %s, want %s", got.Digest, want.Digest)
+	}
+	if len(got.Values) != len(want.Values) {
+		return fmt.Sprintf("got %d values, want %d", len(got.Values), len(want.Values))
+	}
+	scale := 1.0
+	for _, v := range want.Values {
+		scale = max(scale, math.Abs(v))
+	}
+	for i, v := range got.Values {
+		if d := math.Abs(v - want.Values[i]); !(d <= selfTestTolerance*scale) {
+			return fmt.Sprintf("value %d is %g, want %g (off by %.3g of the largest)", i, v, want.Values[i], d/scale)
+		}
+	}
+	return ""
+}
+
+// selfTestRun computes every stage
+func selfTestRun() []selfTestStage {
+	var stages []selfTestStage
+	rng := rand.New(rand.NewPCG(968, 1))
+
+	// DCT kernels on random pixel values
+	pixels := func(n int) []float64 {
+		p := make([]float64, n)
+		for i := range p {
+			p[i] = float64(rng.IntN(256))
+		}
+		return p
+	}
+	dctTablesOnce.Do(initDCTTables)
+	dct8 := pixels(8 * 8)
+	for i := 0; i < len(dct8); i += 8 {
+		forwardDCT8(dct8[i : i+8])
+	}
+	in16, in32, in64 := pixels(16*16), pixels(32*32), pixels(64*64)
+	low64 := dct2DFast64(&in64)
+	stages = append(stages,
+		selfTestStage{Stage: "dct8", Values: dct8},
+		selfTestStage{Stage: "dct16", Values: dct2DFast16(in16, 8)},
+		selfTestStage{Stage: "dct32", Values: dct2DFast32(in32, 8)},
+		selfTestStage{Stage: "dct64", Values: low64[:]},
+	)
+
+	// Grayscale conversion of every fast path
+	img := selfTestImage(rng)
+	for _, src := range selfTestSources(img) {
+		gray := ToGrayscaleFast(src)
+		stages = append(stages, selfTestStage{Stage: "gray/" + GrayscalePath(src), Digest: digestGray(gray)})
+	}
+
+	// Each hash algorithm, with the sizes that select its DCT paths
+	for _, kind := range []HashKind{KindAverage, KindDifference, KindDifferenceVertical} {
+		h, _ := Hash(img, kind, 8)
+		stages = append(stages, selfTestStage{Stage: fmt.Sprintf("hash/%s/8", kind), Digest: h.ToString()})
+	}
+	for _, p := range [][2]int{{4, 4}, {8, 4}, {8, 8}, {16, 4}} {
+		h := PerceptualHash(img, p[0], p[1])
+		stages = append(stages, selfTestStage{Stage: fmt.Sprintf("hash/phash/%d/%d", p[0], p[1]), Digest: h.ToString()})
+	}
+	stages = append(stages,
+		selfTestStage{Stage: "hash/tri_dhash/8", Digest: TriDifferenceHash(img, 8, 4).ToString()},
+		selfTestStage{Stage: "hash/text_phash/8", Digest: TextPerceptualHash(img, 8).ToString()},
+	)
+	return stages
+}
+
+// selfTestImage returns a 96x64 image of smooth color gradients, with a
+// bright block and noise so that every hash has bits of both values
+func selfTestImage(rng *rand.Rand) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 96, 64))
+	for y := range 64 {
+		for x := range 96 {
+			c := color.RGBA{uint8(x * 2), uint8(y * 3), uint8(200 - x - y), 255}
+			if x > 50 && x < 80 && y > 10 && y < 40 {
+				c.R, c.G = 240, 230
+			}
+			n := uint8(rng.IntN(16))
+			c.R, c.G, c.B = c.R|n, c.G|n, c.B|n
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// selfTestSources returns img in a type for every ToGrayscaleFast path
+// but GrayRowReader, which only callers implement
+func selfTestSources(img *image.RGBA) []image.Image {
+	b := img.Bounds()
+	gray := image.NewGray(b)
+	nrgba := image.NewNRGBA(b)
+	rgba64 := image.NewRGBA64(b)
+	ycbcr := image.NewYCbCr(b, image.YCbCrSubsampleRatio420)
+	paletted := image.NewPaletted(b, selfTestPalette())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			gray.SetGray(x, y, color.Gray{Y: c.G})
+			// Half transparent on the left, to exercise un-premultiplying
+			if x < b.Dx()/2 {
+				nrgba.SetNRGBA(x, y, color.NRGBA{c.R, c.G, c.B, 128})
+			} else {
+				nrgba.SetNRGBA(x, y, color.NRGBA{c.R, c.G, c.B, 255})
+			}
+			rgba64.Set(x, y, c)
+			paletted.Set(x, y, c)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			ycbcr.Y[ycbcr.YOffset(x, y)] = yy
+			ycbcr.Cb[ycbcr.COffset(x, y)] = cb
+			ycbcr.Cr[ycbcr.COffset(x, y)] = cr
+		}
+	}
+	return []image.Image{gray, ycbcr, img, nrgba, rgba64, selfTestGeneric{paletted}}
+}
+
+// selfTestGeneric hides every method but those of image.Image, so that
+// ToGrayscaleFast takes its At fallback
+type selfTestGeneric struct{ image.Image }
+
+// selfTestPalette is a 6x6x6 color cube
+func selfTestPalette() color.Palette {
+	p := make(color.Palette, 0, 216)
+	for r := range 6 {
+		for g := range 6 {
+			for b := range 6 {
+				p = append(p, color.RGBA{uint8(r * 51), uint8(g * 51), uint8(b * 51), 255})
+			}
+		}
+	}
+	return p
+}
+
+// digestGray returns the first 16 bytes of the SHA-256 of the pixels of
+// gray, row by row, in hex
+func digestGray(gray *image.Gray) string {
+	var buf bytes.Buffer
+	for y := gray.Rect.Min.Y; y < gray.Rect.Max.Y; y++ {
+		buf.Write(gray.Pix[gray.PixOffset(gray.Rect.Min.X, y):gray.PixOffset(gray.Rect.Max.X, y)])
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:16])
+}
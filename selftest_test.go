@@ -0,0 +1,72 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	if err := SelfTest(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The embedded values are what gen/golden would write now
+	data, err := SelfTestData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, selfTestExpected) {
+		t.Error("selftest.json is stale; run go generate")
+	}
+}
+
+func TestSelfTest_Deviation(t *testing.T) {
+	defer func() { selfTestHook = nil }()
+	selfTestHook = func(s *selfTestStage) {
+		switch s.Stage {
+		case "dct32":
+			// A deviation far above FMA noise, in one coefficient
+			s.Values[5] += 1e-6 * s.Values[0]
+		case "dct64":
+			// FMA-sized noise everywhere is tolerated
+			for i := range s.Values {
+				s.Values[i] *= 1 + 1e-14
+			}
+		case "hash/phash/8/4":
+			s.Digest = "93136c6c6d6c6c92"
+		}
+	}
+
+	err := SelfTest()
+	if err == nil {
+		t.Fatal("SelfTest passed with injected deviations")
+	}
+	var stages []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var st *SelfTestError
+		if !errors.As(e, &st) {
+			t.Fatalf("%v is not a *SelfTestError", e)
+		}
+		stages = append(stages, st.Stage)
+	}
+	if want := []string{"dct32", "hash/phash/8/4"}; !slices.Equal(stages, want) {
+		t.Errorf("deviating stages %v, want %v\n%v", stages, want, err)
+	}
+}
+
+func TestSelfTest_MissingStage(t *testing.T) {
+	defer func() { selfTestHook = nil }()
+	selfTestHook = func(s *selfTestStage) {
+		if s.Stage == "gray/ycbcr" {
+			s.Stage = "gray/renamed"
+		}
+	}
+
+	err := SelfTest()
+	want := "selftest: stage gray/renamed: no expected values\nselftest: stage gray/ycbcr: stage did not run"
+	if err == nil || err.Error() != want {
+		t.Errorf("SelfTest() = %v, want %q", err, want)
+	}
+}
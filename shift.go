@@ -0,0 +1,103 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+)
+
+// ShiftTolerantDistance returns the smallest distance between the hash of
+// imgA and the hashes of imgB translated by every offset in
+// [-maxShift, maxShift]², and the offset that achieved it. Offsets are in
+// pixels of the grayscale image the hash is computed from (e.g. 9x8 for an
+// 8-bit DifferenceHash, 32x32 for PerceptualHash), where one pixel is a
+// whole cell of the hash; the pixels uncovered by a translation repeat the
+// edge. It absorbs the shifts between crops taken at different origins,
+// which flip many bits of otherwise equal images.
+//
+// imgA is hashed once. imgB is preprocessed, converted to grayscale and
+// resized once; each offset then costs a translation of the small resized
+// image and the threshold of the algorithm. Ties keep the offset closest
+// to (0, 0).
+func ShiftTolerantDistance(imgA, imgB image.Image, kind HashKind, hashSize, maxShift int, opts ...Option) (int, image.Point, error) {
+	if maxShift < 0 {
+		return 0, image.Point{}, fmt.Errorf("invalid maximum shift: %d", maxShift)
+	}
+	a, err := Hash(imgA, kind, hashSize, opts...)
+	if err != nil {
+		return 0, image.Point{}, err
+	}
+	if hashSize < 2 {
+		hashSize = 8
+	}
+
+	o := newOptions(opts)
+	if o.preprocess != nil {
+		if imgB, err = o.preprocess.Apply(imgB); err != nil {
+			return 0, image.Point{}, err
+		}
+	}
+	// Under WithAutoAlgorithm, imgB is thresholded as imgA was
+	kind = a.kind
+	rows, cols := o.grid(imgB, hashSize)
+	w, h := o.workingSize(imgB, kind, hashSize)
+	var resized *image.Gray
+	if kind == KindPerceptual {
+		resized = resizeGray(o.fillIgnored(o.grayscale(imgB)), w, h)
+	} else {
+		resized = o.resize(o.grayscale(imgB), w, h)
+	}
+
+	best, bestAt := -1, image.Point{}
+	shifted := image.NewGray(image.Rect(0, 0, w, h))
+	for dy := -maxShift; dy <= maxShift; dy++ {
+		for dx := -maxShift; dx <= maxShift; dx++ {
+			translateGray(resized, shifted, dx, dy)
+			d, err := a.Distance(o.hashResized(shifted, kind, rows, cols, hashSize))
+			if err != nil {
+				return 0, image.Point{}, err
+			}
+			at := image.Pt(dx, dy)
+			if best < 0 || d < best || d == best && shiftLen(at) < shiftLen(bestAt) {
+				best, bestAt = d, at
+			}
+		}
+	}
+	return best, bestAt, nil
+}
+
+// hashResized thresholds a grayscale image already resized to the working
+// size of kind, as the algorithm function does after its resize
+func (o options) hashResized(resized *image.Gray, kind HashKind, rows, cols, hashSize int) *ImageHash {
+	switch kind {
+	case KindAverage:
+		return o.averageHashResized(resized, rows, cols)
+	case KindPerceptual:
+		return perceptualHashResized(resized, hashSize, o.median)
+	case KindDifferenceVertical:
+		return o.verticalHashResized(resized, rows, cols)
+	default:
+		hash := make([]bool, rows*cols)
+		o.differencePairs(resized, rows, cols, func(x, y int, left, right uint8) {
+			hash[y*cols+x] = right > left
+		})
+		return builtHash(hash, rows, cols, KindDifference)
+	}
+}
+
+// translateGray fills dst, of src's size, with src moved by (dx, dy):
+// dst(x, y) = src(x-dx, y-dy), clamped to the edges of src
+func translateGray(src, dst *image.Gray, dx, dy int) {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	for y := range h {
+		sy := min(max(y-dy, 0), h-1)
+		srow := src.Pix[sy*src.Stride : sy*src.Stride+w]
+		drow := dst.Pix[y*dst.Stride : y*dst.Stride+w]
+		for x := range drow {
+			drow[x] = srow[min(max(x-dx, 0), w-1)]
+		}
+	}
+}
+
+func shiftLen(p image.Point) int {
+	return max(p.X, -p.X) + max(p.Y, -p.Y)
+}
@@ -0,0 +1,100 @@
+package imagehashgo
+
+import (
+	"image"
+	"math/rand/v2"
+	"testing"
+)
+
+// noiseImage returns a w x h image of random gray levels smoothed over
+// neighbouring pixels, where a one-pixel shift still moves a large share
+// of every resized cell
+func noiseImage(w, h int, seed uint64) *image.Gray {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	noise := make([]int, w*h)
+	for i := range noise {
+		noise[i] = rng.IntN(256)
+	}
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			sum, n := 0, 0
+			for yy := max(y-1, 0); yy <= min(y+1, h-1); yy++ {
+				for xx := max(x-1, 0); xx <= min(x+1, w-1); xx++ {
+					sum += noise[yy*w+xx]
+					n++
+				}
+			}
+			img.Pix[y*img.Stride+x] = uint8(sum / n)
+		}
+	}
+	return img
+}
+
+func TestShiftTolerantDistance(t *testing.T) {
+	a := noiseImage(36, 32, 1)
+	// b is a cropped one cell of the 8-bit hashes (4 pixels) further right
+	// and down
+	b := image.NewGray(a.Rect)
+	translateGray(a, b, -4, -4)
+
+	for _, kind := range []HashKind{KindDifference, KindAverage, KindDifferenceVertical} {
+		plain := func() int {
+			ha, _ := Hash(a, kind, 8)
+			hb, _ := Hash(b, kind, 8)
+			d, _ := ha.Distance(hb)
+			return d
+		}()
+		d, at, err := ShiftTolerantDistance(a, b, kind, 8, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: plain distance %d, shift-tolerant %d at %v", kind, plain, d, at)
+		// Only the first row and column, which the translation fills by
+		// repeating the edge, still differ
+		if d > 2*8-1 || 2*d > plain || at != image.Pt(1, 1) {
+			t.Errorf("%s: distance %d at %v, want at most 15 and half of plain %d, at (1,1)", kind, d, at, plain)
+		}
+		if plain < 8 {
+			t.Errorf("%s: plain distance %d, want the shift to flip many bits", kind, plain)
+		}
+	}
+}
+
+func TestShiftTolerantDistanceIdentity(t *testing.T) {
+	img := getBenchImage()
+	d, at, err := ShiftTolerantDistance(img, img, KindPerceptual, 8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 0 || at != (image.Point{}) {
+		t.Errorf("identical images: distance %d at %v, want 0 at (0,0)", d, at)
+	}
+
+	// Without a shift it is the plain distance, under every option that
+	// changes the threshold steps
+	other := noiseImage(120, 90, 2)
+	for _, kind := range rawKinds {
+		for _, opts := range [][]Option{
+			nil,
+			{WithIgnoreRegion(image.Rect(0, 80, 100, 100))},
+			{WithIntegerPipeline(), WithDecoderTolerantQuantization(2)},
+			{WithAspectBuckets()},
+			{WithMedian(MedianLower)},
+		} {
+			ha, _ := Hash(img, kind, 8, opts...)
+			hb, _ := Hash(other, kind, 8, opts...)
+			want, _ := ha.Distance(hb)
+			if d, _, err := ShiftTolerantDistance(img, other, kind, 8, 0, opts...); err != nil || d != want {
+				t.Errorf("%s %v: distance %d, %v without a shift, want %d", kind, ResolveOptions(opts...), d, err, want)
+			}
+		}
+	}
+
+	if _, _, err := ShiftTolerantDistance(img, img, "nope", 8, 1); err == nil {
+		t.Error("expected an error for an unknown kind")
+	}
+	if _, _, err := ShiftTolerantDistance(img, img, KindAverage, 8, -1); err == nil {
+		t.Error("expected an error for a negative shift")
+	}
+}
@@ -0,0 +1,121 @@
+package imagehashgo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/bits"
+)
+
+// MinSketchKeyLen is the shortest key Sketch accepts, in bytes
+const MinSketchKeyLen = 16
+
+// sketchTaps is the number of hash bits XORed into every sketch bit
+const sketchTaps = 3
+
+// sketchDomain separates the HMAC inputs of Sketch from other uses of the
+// same key; changing it changes every sketch
+const sketchDomain = "imagehash-go/sketch/v1"
+
+// Sketch returns a keyed random-projection sketch of h, of bits bits
+// packed as in HashSnapshot.Bits, for comparing hashes with a partner
+// without revealing them to anyone else. Every sketch bit is the XOR of 3
+// bits of h at positions chosen by HMAC-SHA256 under key, and of a secret
+// mask bit from the same HMAC. Sketches made with the same key and bits
+// from hashes of the same length are comparable with SketchDistance;
+// without the key the positions and masks are unknown, so the sketch does
+// not reveal the bits of h.
+//
+// Key holders are not kept out: with the positions and masks, every sketch
+// bit is a linear equation over the bits of h, and enough of them recover
+// h. bits is therefore capped at half the length of h, which leaves at
+// least 2^(n/2) n-bit hashes per sketch, but a partner still learns n/2
+// bits' worth of every hash it sees. Share the key only with parties that
+// may learn that much.
+//
+// For hashes d bits apart out of n, every sketch bit differs with
+// probability f = (1 - (1-2d/n)^3) / 2, about 3d/n for near pairs, so
+// SketchDistance is close to bits*f, within 2*sqrt(bits*f*(1-f)) in 95% of
+// cases. f levels off as d nears n/2, so sketches separate near pairs
+// best: over pairs of 256-bit hashes up to 32 bits apart, the distances of
+// 128-bit sketches correlate with the hash distances above 0.9.
+//
+// key must be at least MinSketchKeyLen bytes, and bits from 1 to half the
+// length of h.
+func Sketch(h *ImageHash, key []byte, bits int) ([]byte, error) {
+	if h == nil {
+		return nil, errors.New("sketch of a nil hash")
+	}
+	if len(key) < MinSketchKeyLen {
+		return nil, fmt.Errorf("sketch key has %d bytes, need at least %d", len(key), MinSketchKeyLen)
+	}
+	n := len(h.hash)
+	if n < 2*sketchTaps {
+		return nil, fmt.Errorf("hash of %d bits is too short to sketch", n)
+	}
+	if bits < 1 || bits > n/2 {
+		return nil, fmt.Errorf("sketch bits must be from 1 to %d, half the hash length, got %d", n/2, bits)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	sketch := make([]bool, bits)
+	for j := range sketch {
+		taps, mask := sketchTapsFor(mac, n, j)
+		bit := mask
+		for _, pos := range taps {
+			bit = bit != h.hash[pos]
+		}
+		sketch[j] = bit
+	}
+	return packBits(sketch), nil
+}
+
+// sketchTapsFor returns the distinct hash positions and the mask of sketch
+// bit j of an n-bit hash, from HMAC(domain, n, j, counter) blocks
+func sketchTapsFor(mac hash.Hash, n, j int) (taps [sketchTaps]int, mask bool) {
+	var msg [len(sketchDomain) + 12]byte
+	copy(msg[:], sketchDomain)
+	binary.BigEndian.PutUint32(msg[len(sketchDomain):], uint32(n))
+	binary.BigEndian.PutUint32(msg[len(sketchDomain)+4:], uint32(j))
+
+	found := 0
+	for counter := uint32(0); found < sketchTaps; counter++ {
+		binary.BigEndian.PutUint32(msg[len(sketchDomain)+8:], counter)
+		mac.Reset()
+		mac.Write(msg[:])
+		block := mac.Sum(nil)
+		if counter == 0 {
+			mask = block[0]&1 == 1
+		}
+		// Seven 4-byte candidates per block after the mask byte's word.
+		// The modulo bias is below n/2^32.
+		for off := 4; off+4 <= len(block) && found < sketchTaps; off += 4 {
+			pos := int(binary.BigEndian.Uint32(block[off:]) % uint32(n))
+			dup := false
+			for _, t := range taps[:found] {
+				dup = dup || t == pos
+			}
+			if !dup {
+				taps[found] = pos
+				found++
+			}
+		}
+	}
+	return taps, mask
+}
+
+// SketchDistance returns the Hamming distance between two sketches of the
+// same length, made by Sketch with the same key and bits
+func SketchDistance(a, b []byte) (int, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("sketches must have the same length: %d vs %d bytes", len(a), len(b))
+	}
+	dist := 0
+	for i := range a {
+		dist += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return dist, nil
+}
@@ -0,0 +1,113 @@
+package imagehashgo
+
+import (
+	"encoding/hex"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+var sketchKey = []byte("partner-shared-key-0123456789")
+
+// TestSketch_Pinned pins a sketch: a failure here means sketches made by
+// an earlier version no longer compare
+func TestSketch_Pinned(t *testing.T) {
+	h, err := HexToHash("ffd8e0c0c0e0f0f8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Sketch(h, sketchKey, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(s); got != "6beb394b" {
+		t.Errorf("Sketch = %s", got)
+	}
+}
+
+// TestSketch_Correlation checks the documented statistics over random
+// pairs of 256-bit hashes at distances from 0 to 32
+func TestSketch_Correlation(t *testing.T) {
+	rng := rand.New(rand.NewPCG(973, 1))
+	const bits, pairs = 128, 400
+	var xs, ys []float64
+	outside := 0
+	for i := range pairs {
+		h := randomBucketHash(rng, 16, 16)
+		d := i % 33
+		other := perturbed(rng, h, d)
+		sa, err := Sketch(h, sketchKey, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sb, err := Sketch(other, sketchKey, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd, err := SketchDistance(sa, sb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xs, ys = append(xs, float64(d)), append(ys, float64(sd))
+
+		// Within 2 standard deviations of bits*f
+		f := (1 - math.Pow(1-2*float64(d)/256, 3)) / 2
+		if math.Abs(float64(sd)-bits*f) > 2*math.Sqrt(bits*f*(1-f))+1 {
+			outside++
+		}
+	}
+	r := pearson(xs, ys)
+	t.Logf("correlation %.3f, %d of %d pairs outside 2 standard deviations", r, outside, pairs)
+	if r < 0.9 {
+		t.Errorf("correlation %.3f, want above 0.9", r)
+	}
+	if outside > pairs/10 {
+		t.Errorf("%d of %d sketch distances outside the 95%% bound", outside, pairs)
+	}
+}
+
+func pearson(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var sx, sy, sxx, syy, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		syy += ys[i] * ys[i]
+		sxy += xs[i] * ys[i]
+	}
+	return (n*sxy - sx*sy) / math.Sqrt((n*sxx-sx*sx)*(n*syy-sy*sy))
+}
+
+func TestSketch_Keyed(t *testing.T) {
+	rng := rand.New(rand.NewPCG(973, 2))
+	h := randomBucketHash(rng, 16, 16)
+	a, _ := Sketch(h, sketchKey, 128)
+	again, _ := Sketch(NewImageHash(h.hash, 16, 16), sketchKey, 128)
+	if d, _ := SketchDistance(a, again); d != 0 {
+		t.Errorf("same hash and key: sketch distance %d", d)
+	}
+
+	// Another key gives an unrelated sketch of the same hash
+	b, _ := Sketch(h, []byte("another-partner-key-0123456789"), 128)
+	if d, _ := SketchDistance(a, b); d < 40 || d > 88 {
+		t.Errorf("different keys: sketch distance %d, want about 64", d)
+	}
+}
+
+func TestSketch_Errors(t *testing.T) {
+	h := randomBucketHash(rand.New(rand.NewPCG(973, 3)), 8, 8)
+	for name, call := range map[string]func() error{
+		"nil hash":  func() error { _, err := Sketch(nil, sketchKey, 32); return err },
+		"short key": func() error { _, err := Sketch(h, sketchKey[:MinSketchKeyLen-1], 32); return err },
+		"no key":    func() error { _, err := Sketch(h, nil, 32); return err },
+		"zero bits": func() error { _, err := Sketch(h, sketchKey, 0); return err },
+		"too many":  func() error { _, err := Sketch(h, sketchKey, 33); return err },
+		"tiny hash": func() error { _, err := Sketch(NewImageHash([]bool{true, false}, 1, 2), sketchKey, 1); return err },
+		"lengths":   func() error { _, err := SketchDistance(make([]byte, 8), make([]byte, 32)); return err },
+	} {
+		if call() == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/K0ng2/imagehash-go/internal/bitio"
+)
+
+// HashSnapshot is an exported, encoder-friendly copy of an ImageHash.
+// Reflection-based encoders (gob, msgpack, JSON, ...) can serialize it
+// directly, unlike ImageHash whose fields are unexported.
+//
+// Bits holds the hash packed MSB-first in the same order as ToString,
+// padded with zero bits up to a whole byte.
+type HashSnapshot struct {
+	Rows int
+	Cols int
+	Bits []byte
+	Kind string
+}
+
+// Snapshot returns an exported copy of the hash
+func (h *ImageHash) Snapshot() HashSnapshot {
+	return HashSnapshot{
+		Rows: h.rows,
+		Cols: h.cols,
+		Bits: packBits(h.hash),
+		Kind: string(h.kind),
+	}
+}
+
+// FromSnapshot rebuilds an ImageHash from a snapshot, validating that the
+// packed bits match the recorded shape
+func FromSnapshot(s HashSnapshot) (*ImageHash, error) {
+	if s.Rows <= 0 || s.Cols <= 0 || s.Rows > MaxHashBits || s.Cols > MaxHashBits || s.Rows*s.Cols > MaxHashBits {
+		return nil, fmt.Errorf("invalid snapshot shape: (%d, %d)", s.Rows, s.Cols)
+	}
+
+	n := s.Rows * s.Cols
+	if want := (n + 7) / 8; len(s.Bits) != want {
+		return nil, fmt.Errorf("snapshot has %d bytes, shape (%d, %d) needs %d", len(s.Bits), s.Rows, s.Cols, want)
+	}
+	if n%8 != 0 && s.Bits[len(s.Bits)-1]&(0xff>>(n%8)) != 0 {
+		return nil, fmt.Errorf("snapshot has non-zero padding bits")
+	}
+
+	return &ImageHash{
+		hash: unpackBits(s.Bits, n),
+		rows: s.Rows,
+		cols: s.Cols,
+		kind: HashKind(s.Kind),
+	}, nil
+}
+
+// GobEncode implements gob.GobEncoder
+func (h *ImageHash) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(h.Snapshot()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder
+func (h *ImageHash) GobDecode(data []byte) error {
+	var s HashSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	decoded, err := FromSnapshot(s)
+	if err != nil {
+		return err
+	}
+	*h = *decoded
+	return nil
+}
+
+// packBits packs bits MSB-first into bytes
+func packBits(bits []bool) []byte {
+	var w bitio.BitWriter
+	w.WriteBits(bits)
+	return w.Bytes()
+}
+
+// unpackBits is the inverse of packBits for the first n bits
+func unpackBits(packed []byte, n int) []bool {
+	bits, err := bitio.NewBitReader(packed).ReadBits(n)
+	if err != nil {
+		panic(err)
+	}
+	return bits
+}
@@ -0,0 +1,82 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func TestImageHash_GobRoundTrip(t *testing.T) {
+	img := getBenchImage()
+	type cacheEntry struct {
+		Path string
+		Hash *ImageHash
+	}
+	type snapshotEntry struct {
+		Path string
+		Hash HashSnapshot
+	}
+
+	for _, h := range []*ImageHash{
+		PerceptualHash(img, 8, 4),
+		DifferenceHash(img, 5),
+		{hash: []bool{true, false, true}, rows: 1, cols: 3},
+	} {
+		t.Run("interface "+h.ToString(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(cacheEntry{Path: "a.png", Hash: h}); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			var got cacheEntry
+			if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			assertSameHash(t, got.Hash, h)
+		})
+
+		t.Run("snapshot "+h.ToString(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := gob.NewEncoder(&buf).Encode(snapshotEntry{Path: "a.png", Hash: h.Snapshot()}); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			var got snapshotEntry
+			if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			restored, err := FromSnapshot(got.Hash)
+			if err != nil {
+				t.Fatalf("FromSnapshot() error = %v", err)
+			}
+			assertSameHash(t, restored, h)
+		})
+	}
+}
+
+func TestFromSnapshot_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		snap HashSnapshot
+	}{
+		{name: "zero shape", snap: HashSnapshot{Rows: 0, Cols: 8, Bits: []byte{}}},
+		{name: "too few bytes", snap: HashSnapshot{Rows: 8, Cols: 8, Bits: make([]byte, 7)}},
+		{name: "too many bytes", snap: HashSnapshot{Rows: 2, Cols: 2, Bits: make([]byte, 2)}},
+		{name: "padding bits set", snap: HashSnapshot{Rows: 1, Cols: 3, Bits: []byte{0x01}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromSnapshot(tt.snap); err == nil {
+				t.Error("FromSnapshot() expected error, got nil")
+			}
+		})
+	}
+}
+
+func assertSameHash(t *testing.T, got, want *ImageHash) {
+	t.Helper()
+	if got.rows != want.rows || got.cols != want.cols || got.kind != want.kind {
+		t.Fatalf("got shape (%d, %d) kind %q, want (%d, %d) kind %q", got.rows, got.cols, got.kind, want.rows, want.cols, want.kind)
+	}
+	if dist, err := got.Distance(want); err != nil || dist != 0 {
+		t.Errorf("Distance() = %d, %v; want 0", dist, err)
+	}
+}
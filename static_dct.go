@@ -1,46 +1,115 @@
 package imagehashgo
 
-import "math"
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// DCT2DLowFreq computes the DCT-II of a size x size matrix, flattened
+// row-major in input, with the precomputed tables of the fast transforms,
+// and returns the hashSize x hashSize low-frequency coefficients, flattened
+// row-major. Size must be 16, 32 or 64, and 0 <= hashSize <= size. The
+// transform works in place: input is overwritten.
+func DCT2DLowFreq(input []float64, size, hashSize int) ([]float64, error) {
+	if size != 16 && size != 32 && size != 64 {
+		return nil, fmt.Errorf("unsupported DCT size %d, wanted 16, 32 or 64", size)
+	}
+	if len(input) != size*size {
+		return nil, fmt.Errorf("%d values do not fit a %dx%d DCT", len(input), size, size)
+	}
+	if hashSize < 0 || hashSize > size {
+		return nil, fmt.Errorf("invalid hash size %d, wanted 0 <= hashSize <= %d", hashSize, size)
+	}
+	switch size {
+	case 16:
+		return dct2DFast16(input, hashSize), nil
+	case 32:
+		return dct2DFast32(input, hashSize), nil
+	}
+	flattens := make([]float64, hashSize*hashSize)
+	if hashSize > 0 {
+		dct2DFast64Band(&input, 0, hashSize, flattens)
+	}
+	return flattens, nil
+}
 
 // DCT2DFast64 computes a 64x64 DCT-II optimized with precomputed tables
 // Returns the flattened 8x8 low-frequency coefficients for perceptual hashing
+// It panics unless input holds 64*64 values.
+//
+// Deprecated: Use DCT2DLowFreq(*input, 64, 8), which returns an error
+// instead of panicking.
 func DCT2DFast64(input *[]float64) [64]float64 {
+	return dct2DFast64(input)
+}
+
+// dct2DFast64 returns the 8x8 low-frequency coefficients of the 64x64 DCT
+// of input
+func dct2DFast64(input *[]float64) [64]float64 {
+	var flattens [64]float64
+	dct2DFast64Band(input, 0, 8, flattens[:])
+	return flattens
+}
+
+// dct2DFast64Band writes the coefficients of rows and columns lo to hi-1 of
+// the 64x64 DCT of input to dst, flattened row-major, e.g. the
+// mid-frequency band used by TextPerceptualHash
+func dct2DFast64Band(input *[]float64, lo, hi int, dst []float64) {
 	if len(*input) != 64*64 {
 		panic("incorrect input size, wanted 64x64")
 	}
+	dctTablesOnce.Do(initDCTTables)
+
+	// Only coefficients below hi are kept, so within the 8x8 corner the
+	// pruned transform does; it leaves the rest of each row as scratch
+	transform := forwardDCT64
+	if hi <= 8 {
+		transform = forwardDCT64Low8
+	}
 
 	// DCT on rows
 	for i := range 64 {
-		forwardDCT64((*input)[i*64 : (i*64)+64])
+		transform((*input)[i*64 : (i*64)+64])
 	}
 
-	// DCT on columns (only first 8 columns needed for 8x8 output)
+	// DCT on columns (only the columns of the band are needed)
+	n := hi - lo
 	var row [64]float64
-	var flattens [64]float64
-	for i := range 8 {
+	for i := lo; i < hi; i++ {
 		for j := range 64 {
 			row[j] = (*input)[64*j+i]
 		}
-		forwardDCT64(row[:])
-		// Extract only first 8 rows (low frequency)
-		for j := range 8 {
-			flattens[8*j+i] = row[j]
+		transform(row[:])
+		// Extract only the rows of the band
+		for j := lo; j < hi; j++ {
+			dst[n*(j-lo)+i-lo] = row[j]
 		}
 	}
-	return flattens
 }
 
 // DCT2DFast32 computes a 32x32 DCT-II optimized with precomputed tables
 // Returns the flattened low-frequency coefficients
+// It panics unless input holds 32*32 values and 0 <= hashSize <= 32.
+//
+// Deprecated: Use DCT2DLowFreq(*input, 32, hashSize), which returns an
+// error instead of panicking.
 func DCT2DFast32(input *[]float64, hashSize int) []float64 {
-	size := 32
-	if len(*input) != size*size {
-		panic("incorrect input size, wanted 32x32")
+	flattens, err := DCT2DLowFreq(*input, 32, hashSize)
+	if err != nil {
+		panic(err)
 	}
+	return flattens
+}
 
-	// DCT on rows
+// dct2DFast32 is DCT2DLowFreq for a 32x32 input, without the checks
+func dct2DFast32(input []float64, hashSize int) []float64 {
+	size := 32
+	dctTablesOnce.Do(initDCTTables)
+
+	// DCT on rows (only the first hashSize coefficients are needed)
 	for i := range size {
-		forwardDCT32((*input)[i*size : (i*size)+size])
+		forwardDCT32Low(input[i*size:(i*size)+size], hashSize)
 	}
 
 	// DCT on columns (only first hashSize columns needed)
@@ -48,9 +117,48 @@ func DCT2DFast32(input *[]float64, hashSize int) []float64 {
 	flattens := make([]float64, hashSize*hashSize)
 	for i := range hashSize {
 		for j := range size {
-			row[j] = (*input)[size*j+i]
+			row[j] = input[size*j+i]
 		}
-		forwardDCT32(row)
+		forwardDCT32Low(row, hashSize)
+		for j := range hashSize {
+			flattens[hashSize*j+i] = row[j]
+		}
+	}
+	return flattens
+}
+
+// DCT2DFast16 computes a 16x16 DCT-II optimized with precomputed tables
+// Returns the flattened low-frequency coefficients
+// It panics unless input holds 16*16 values and 0 <= hashSize <= 16.
+//
+// Deprecated: Use DCT2DLowFreq(*input, 16, hashSize), which returns an
+// error instead of panicking.
+func DCT2DFast16(input *[]float64, hashSize int) []float64 {
+	flattens, err := DCT2DLowFreq(*input, 16, hashSize)
+	if err != nil {
+		panic(err)
+	}
+	return flattens
+}
+
+// dct2DFast16 is DCT2DLowFreq for a 16x16 input, without the checks
+func dct2DFast16(input []float64, hashSize int) []float64 {
+	size := 16
+	dctTablesOnce.Do(initDCTTables)
+
+	// DCT on rows (only the first hashSize coefficients are needed)
+	for i := range size {
+		forwardDCT16Low(input[i*size:(i*size)+size], hashSize)
+	}
+
+	// DCT on columns (only first hashSize columns needed)
+	var row [16]float64
+	flattens := make([]float64, hashSize*hashSize)
+	for i := range hashSize {
+		for j := range size {
+			row[j] = input[size*j+i]
+		}
+		forwardDCT16Low(row[:], hashSize)
 		for j := range hashSize {
 			flattens[hashSize*j+i] = row[j]
 		}
@@ -75,6 +183,77 @@ func forwardDCT64(input []float64) {
 	input[62], input[63] = temp[31], temp[63]
 }
 
+// forwardDCT64Low8 performs forwardDCT64 but computes only outputs 0..7,
+// leaving the rest of input as scratch. Output 2i is output i of the even
+// half and output 2i+1 the sum of outputs i and i+1 of the odd half, so
+// only the first 4 and 5 outputs of the two 32-point halves are needed.
+// Every output it computes takes the same operations in the same order as
+// in forwardDCT64, so they are equal bit for bit.
+func forwardDCT64Low8(input []float64) {
+	var temp [64]float64
+	for i := range 32 {
+		x, y := input[i], input[63-i]
+		temp[i] = x + y
+		temp[i+32] = (x - y) / dct64[i]
+	}
+	forwardDCT32Low(temp[:32], 4)
+	forwardDCT32Low(temp[32:], 5)
+	for i := range 4 {
+		input[i*2+0] = temp[i]
+		input[i*2+1] = temp[i+32] + temp[i+32+1]
+	}
+}
+
+// forwardDCT32Low performs forwardDCT32 but computes only outputs 0..k-1,
+// bit for bit as forwardDCT32 does, leaving the rest of input as scratch
+func forwardDCT32Low(input []float64, k int) {
+	if k >= 32 {
+		forwardDCT32(input)
+		return
+	}
+	var temp [32]float64
+	for i := range 16 {
+		x, y := input[i], input[31-i]
+		temp[i] = x + y
+		temp[i+16] = (x - y) / dct32[i]
+	}
+	// k < 32, so the odd outputs needed never reach the last one, which
+	// is not a sum
+	even, odd := (k+1)/2, k/2
+	forwardDCT16Low(temp[:16], even)
+	forwardDCT16Low(temp[16:], odd+1)
+	for i := range even {
+		input[i*2] = temp[i]
+	}
+	for i := range odd {
+		input[i*2+1] = temp[i+16] + temp[i+16+1]
+	}
+}
+
+// forwardDCT16Low performs forwardDCT16 but computes only outputs 0..k-1,
+// bit for bit as forwardDCT16 does, leaving the rest of input as scratch
+func forwardDCT16Low(input []float64, k int) {
+	if k >= 16 {
+		forwardDCT16(input)
+		return
+	}
+	var temp [16]float64
+	for i := range 8 {
+		x, y := input[i], input[15-i]
+		temp[i] = x + y
+		temp[i+8] = (x - y) / dct16[i]
+	}
+	// The 8-point kernels are unrolled, so they run in full
+	forwardDCT8(temp[:8])
+	forwardDCT8(temp[8:])
+	for i := range (k + 1) / 2 {
+		input[i*2] = temp[i]
+	}
+	for i := range k / 2 {
+		input[i*2+1] = temp[i+8] + temp[i+8+1]
+	}
+}
+
 // forwardDCT32 performs in-place DCT-II
 func forwardDCT32(input []float64) {
 	var temp [32]float64
@@ -122,10 +301,10 @@ func forwardDCT8(input []float64) {
 	a[1] = x1 + y1
 	a[2] = x2 + y2
 	a[3] = x3 + y3
-	b[0] = (x0 - y0) / 1.9615705608064609
-	b[1] = (x1 - y1) / 1.6629392246050907
-	b[2] = (x2 - y2) / 1.1111404660392046
-	b[3] = (x3 - y3) / 0.3901806440322566
+	b[0] = (x0 - y0) / dct8[0]
+	b[1] = (x1 - y1) / dct8[1]
+	b[2] = (x2 - y2) / dct8[2]
+	b[3] = (x3 - y3) / dct8[3]
 
 	forwardDCT4(a[:])
 	forwardDCT4(b[:])
@@ -147,16 +326,16 @@ func forwardDCT4(input []float64) {
 
 	t0 := x0 + y0
 	t1 := x1 + y1
-	t2 := (x0 - y0) / 1.8477590650225735
-	t3 := (x1 - y1) / 0.7653668647301797
+	t2 := (x0 - y0) / dct4[0]
+	t3 := (x1 - y1) / dct4[1]
 
 	x, y := t0, t1
 	t0 += t1
-	t1 = (x - y) / 1.4142135623730951
+	t1 = (x - y) / dct2[0]
 
 	x, y = t2, t3
 	t2 += t3
-	t3 = (x - y) / 1.4142135623730951
+	t3 = (x - y) / dct2[0]
 
 	input[0] = t0
 	input[1] = t2 + t3
@@ -164,44 +343,33 @@ func forwardDCT4(input []float64) {
 	input[3] = t3
 }
 
-// Precomputed DCT cosine tables
-// These eliminate expensive math.Cos() calls during DCT computation
+// DCT cosine tables for the butterfly kernels. dctN[i] holds
+// 2*cos((i+0.5)*pi/N), the divisor applied to the odd half of an N-point
+// transform. They are computed on first use of the fast DCT so that
+// programs which never call PerceptualHash pay nothing at startup.
+var (
+	dctTablesOnce sync.Once
 
-var dct64 = [32]float64{
-	1.9615705608064609, 1.9615705608064609, 1.961570560806461, 1.9615705608064609,
-	1.9615705608064609, 1.9615705608064609, 1.961570560806461, 1.9615705608064609,
-	1.8477590650225735, 1.8477590650225735, 1.8477590650225735, 1.8477590650225735,
-	1.8477590650225735, 1.8477590650225735, 1.8477590650225735, 1.8477590650225735,
-	1.6629392246050907, 1.6629392246050907, 1.6629392246050907, 1.6629392246050907,
-	1.6629392246050907, 1.6629392246050907, 1.6629392246050907, 1.6629392246050907,
-	1.4142135623730951, 1.4142135623730951, 1.4142135623730951, 1.4142135623730951,
-	1.4142135623730951, 1.4142135623730951, 1.4142135623730951, 1.4142135623730951,
-}
-
-var dct32 = [16]float64{
-	1.9615705608064609, 1.8477590650225735, 1.6629392246050907, 1.4142135623730951,
-	1.1111404660392046, 0.7653668647301797, 0.3901806440322566, 1.9615705608064609,
-	1.8477590650225735, 1.6629392246050907, 1.4142135623730951, 1.1111404660392046,
-	0.7653668647301797, 0.3901806440322566, 1.9615705608064609, 1.8477590650225735,
-}
+	dct64 [32]float64
+	dct32 [16]float64
+	dct16 [8]float64
+	dct8  [4]float64
+	dct4  [2]float64
+	dct2  [1]float64
+)
 
-var dct16 = [8]float64{
-	1.9615705608064609, 1.8477590650225735, 1.6629392246050907, 1.4142135623730951,
-	1.1111404660392046, 0.7653668647301797, 0.3901806440322566, 1.9615705608064609,
-}
-
-func init() {
-	// Compute actual DCT tables at initialization
-	// For DCT-64
-	for i := range 32 {
-		dct64[i] = math.Cos((float64(i)+0.5)*math.Pi/float64(64)) * 2
-	}
-	// For DCT-32
-	for i := range 16 {
-		dct32[i] = math.Cos((float64(i)+0.5)*math.Pi/float64(32)) * 2
-	}
-	// For DCT-16
-	for i := range 8 {
-		dct16[i] = math.Cos((float64(i)+0.5)*math.Pi/float64(16)) * 2
+// initDCTTables fills every table from the same closed-form expression
+func initDCTTables() {
+	fill := func(table []float64) {
+		n := float64(2 * len(table))
+		for i := range table {
+			table[i] = math.Cos((float64(i)+0.5)*math.Pi/n) * 2
+		}
 	}
+	fill(dct64[:])
+	fill(dct32[:])
+	fill(dct16[:])
+	fill(dct8[:])
+	fill(dct4[:])
+	fill(dct2[:])
 }
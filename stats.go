@@ -0,0 +1,145 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// Stats is the distance distribution of a corpus of hashes, one entry per
+// hash shape in order of first appearance. It marshals to JSON as is, and
+// String renders it as text.
+type Stats struct {
+	Shapes []ShapeStats `json:"shapes"`
+}
+
+// ShapeStats is the distance distribution between the hashes of one shape
+type ShapeStats struct {
+	Rows   int `json:"rows"`
+	Cols   int `json:"cols"`
+	Hashes int `json:"hashes"`
+	// Pairs is the number of pairs measured; Exhaustive is true when they
+	// are all the pairs of the corpus rather than a random sample
+	Pairs      int  `json:"pairs"`
+	Exhaustive bool `json:"exhaustive"`
+	// Histogram counts the pairs at each distance, 0 to Rows*Cols
+	Histogram []int   `json:"histogram"`
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+	// Collision is the estimated probability that two random hashes of the
+	// corpus are within each threshold, 0 to Rows*Cols: multiply by 1e6 for
+	// the expected false positives per million comparisons
+	Collision []float64 `json:"collision"`
+}
+
+// CorpusStats measures the distances between hashes to help choose a
+// threshold. Hashes are partitioned by shape; for each shape, every pair
+// is measured when there are at most sampleSize pairs, otherwise
+// sampleSize random distinct pairs (with replacement) drawn from seed, so
+// the same corpus, sampleSize and seed always give the same Stats.
+// Shapes with fewer than two hashes are reported with no pairs, and nil
+// hashes are skipped.
+func CorpusStats(hashes []*ImageHash, sampleSize int, seed uint64) Stats {
+	type shape struct{ rows, cols int }
+	var order []shape
+	groups := make(map[shape][]*ImageHash)
+	for _, h := range hashes {
+		if h == nil {
+			continue
+		}
+		s := shape{h.rows, h.cols}
+		if _, ok := groups[s]; !ok {
+			order = append(order, s)
+		}
+		groups[s] = append(groups[s], h)
+	}
+
+	var stats Stats
+	for _, s := range order {
+		stats.Shapes = append(stats.Shapes, shapeStats(groups[s], s.rows, s.cols, sampleSize, seed))
+	}
+	return stats
+}
+
+func shapeStats(hashes []*ImageHash, rows, cols, sampleSize int, seed uint64) ShapeStats {
+	n := len(hashes)
+	st := ShapeStats{
+		Rows:      rows,
+		Cols:      cols,
+		Hashes:    n,
+		Histogram: make([]int, rows*cols+1),
+		Collision: make([]float64, rows*cols+1),
+	}
+	add := func(a, b *ImageHash) {
+		// Hashes of one shape always have a distance
+		d, _ := hamming(a, b)
+		st.Histogram[d]++
+		st.Pairs++
+	}
+
+	if total := n * (n - 1) / 2; total <= sampleSize {
+		st.Exhaustive = true
+		for i := range n {
+			for j := i + 1; j < n; j++ {
+				add(hashes[i], hashes[j])
+			}
+		}
+	} else {
+		rng := rand.New(rand.NewPCG(seed, uint64(rows)<<32|uint64(cols)))
+		for range sampleSize {
+			i := rng.IntN(n)
+			j := rng.IntN(n - 1)
+			if j >= i {
+				j++
+			}
+			add(hashes[i], hashes[j])
+		}
+	}
+	if st.Pairs == 0 {
+		return st
+	}
+
+	// Mean, median (the average of the middle two for an even count, as
+	// Median) and the cumulative distribution
+	var sum, cum int
+	lo, hi := -1, -1
+	for d, c := range st.Histogram {
+		sum += d * c
+		if lo < 0 && cum+c > (st.Pairs-1)/2 {
+			lo = d
+		}
+		if hi < 0 && cum+c > st.Pairs/2 {
+			hi = d
+		}
+		cum += c
+		st.Collision[d] = float64(cum) / float64(st.Pairs)
+	}
+	st.Mean = float64(sum) / float64(st.Pairs)
+	st.Median = float64(lo+hi) / 2
+	return st
+}
+
+// String renders the stats as text: a summary per shape and the collision
+// estimates for every threshold up to the median
+func (s Stats) String() string {
+	var sb strings.Builder
+	for i, st := range s.Shapes {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		kind := "sampled"
+		if st.Exhaustive {
+			kind = "all"
+		}
+		fmt.Fprintf(&sb, "%dx%d: %d hashes, %d pairs (%s)\n", st.Rows, st.Cols, st.Hashes, st.Pairs, kind)
+		if st.Pairs == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "mean %.2f, median %.1f\n", st.Mean, st.Median)
+		fmt.Fprintf(&sb, "%9s %12s %12s\n", "threshold", "collision", "per million")
+		for t := 0; float64(t) <= st.Median; t++ {
+			fmt.Fprintf(&sb, "%9d %12.3g %12.1f\n", t, st.Collision[t], st.Collision[t]*1e6)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,121 @@
+package imagehashgo
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func randomHashes(n int, seed uint64) []*ImageHash {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	hashes := make([]*ImageHash, n)
+	for i := range hashes {
+		hashes[i] = uint64Hash(rng.Uint64(), 8, 8)
+	}
+	return hashes
+}
+
+// binomialCDF returns P(X <= k) for X ~ Binomial(n, 1/2)
+func binomialCDF(n, k int) float64 {
+	var p float64
+	for i := 0; i <= k; i++ {
+		lg, _ := math.Lgamma(float64(n + 1))
+		li, _ := math.Lgamma(float64(i + 1))
+		lni, _ := math.Lgamma(float64(n - i + 1))
+		p += math.Exp(lg - li - lni - float64(n)*math.Ln2)
+	}
+	return p
+}
+
+// Distances between uniformly random 64-bit hashes follow Binomial(64, 1/2)
+func TestCorpusStatsRandom(t *testing.T) {
+	stats := CorpusStats(randomHashes(2000, 1), 200000, 7)
+	if len(stats.Shapes) != 1 {
+		t.Fatalf("got %d shapes, want 1", len(stats.Shapes))
+	}
+	st := stats.Shapes[0]
+	if st.Exhaustive || st.Pairs != 200000 {
+		t.Errorf("pairs = %d (exhaustive %v), want 200000 sampled", st.Pairs, st.Exhaustive)
+	}
+	if math.Abs(st.Mean-32) > 0.1 || st.Median != 32 {
+		t.Errorf("mean %.3f median %.1f, want 32 and 32", st.Mean, st.Median)
+	}
+	for _, threshold := range []int{20, 24, 28, 32} {
+		want := binomialCDF(64, threshold)
+		if got := st.Collision[threshold]; math.Abs(got-want) > 0.01 {
+			t.Errorf("collision at %d = %.4f, want %.4f", threshold, got, want)
+		}
+	}
+	if st.Collision[64] != 1 {
+		t.Errorf("collision at 64 = %v, want 1", st.Collision[64])
+	}
+
+	again := CorpusStats(randomHashes(2000, 1), 200000, 7)
+	if again.String() != stats.String() {
+		t.Error("same seed gave different stats")
+	}
+}
+
+// A corpus of near-duplicate clusters has a known exhaustive histogram
+func TestCorpusStatsExhaustive(t *testing.T) {
+	// Three copies of 0 and one hash at distance 4 from them
+	hashes := []*ImageHash{uint64Hash(0, 8, 8), uint64Hash(0, 8, 8), uint64Hash(0, 8, 8), uint64Hash(0xf, 8, 8)}
+	st := CorpusStats(hashes, 1000, 1).Shapes[0]
+	if !st.Exhaustive || st.Pairs != 6 {
+		t.Fatalf("pairs = %d (exhaustive %v), want all 6", st.Pairs, st.Exhaustive)
+	}
+	if st.Histogram[0] != 3 || st.Histogram[4] != 3 {
+		t.Errorf("histogram = %v", st.Histogram)
+	}
+	if st.Mean != 2 || st.Median != 2 {
+		t.Errorf("mean %v median %v, want 2 and 2", st.Mean, st.Median)
+	}
+	if st.Collision[0] != 0.5 || st.Collision[3] != 0.5 || st.Collision[4] != 1 {
+		t.Errorf("collision = %v", st.Collision[:5])
+	}
+}
+
+func TestCorpusStatsShapes(t *testing.T) {
+	hashes := []*ImageHash{
+		uint64Hash(0, 4, 4), uint64Hash(0, 8, 8), uint64Hash(1, 4, 4),
+		uint64Hash(3, 4, 4), uint64Hash(0, 2, 2),
+	}
+	stats := CorpusStats(hashes, 100, 1)
+	if len(stats.Shapes) != 3 {
+		t.Fatalf("got %d shapes, want 3", len(stats.Shapes))
+	}
+	want := []struct{ rows, hashes, pairs int }{{4, 3, 3}, {8, 1, 0}, {2, 1, 0}}
+	for i, w := range want {
+		st := stats.Shapes[i]
+		if st.Rows != w.rows || st.Hashes != w.hashes || st.Pairs != w.pairs || len(st.Histogram) != w.rows*w.rows+1 {
+			t.Errorf("shape %d = %dx%d with %d hashes and %d pairs, want %dx%d with %d and %d",
+				i, st.Rows, st.Cols, st.Hashes, st.Pairs, w.rows, w.rows, w.hashes, w.pairs)
+		}
+	}
+	if len(CorpusStats(nil, 100, 1).Shapes) != 0 {
+		t.Error("empty corpus has shapes")
+	}
+}
+
+func TestCorpusStatsRender(t *testing.T) {
+	stats := CorpusStats(randomHashes(50, 2), 100, 1)
+
+	text := stats.String()
+	if !strings.HasPrefix(text, "8x8: 50 hashes, 100 pairs (sampled)\n") || !strings.Contains(text, "per million") {
+		t.Errorf("unexpected text:\n%s", text)
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Stats
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.String() != text {
+		t.Errorf("JSON round trip changed the stats: %s", data)
+	}
+}
@@ -0,0 +1,262 @@
+package imagehashgo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Hash streams are a compact binary format for many hashes:
+//
+//	magic  "IHS1"
+//	count  uvarint, number of shapes in the table (1 to 256)
+//	table  count x (kind: uvarint length + bytes, rows: uvarint, cols: uvarint)
+//	records
+//
+// With a single shape every record is the hash packed MSB-first (as in
+// HashSnapshot), (rows*cols+7)/8 bytes. With several shapes (heterogeneous
+// mode) every record starts with a one-byte index into the table.
+const streamMagic = "IHS1"
+
+// maxStreamShapes is the table size addressable by a one-byte index
+const maxStreamShapes = 256
+
+// StreamShape is one entry of a hash stream's shape table
+type StreamShape struct {
+	Kind HashKind
+	Rows int
+	Cols int
+}
+
+func (s StreamShape) recordBytes() int {
+	return (s.Rows*s.Cols + 7) / 8
+}
+
+// Shape returns the number of rows and columns of the hash
+func (h *ImageHash) Shape() (rows, cols int) {
+	return h.rows, h.cols
+}
+
+func (h *ImageHash) streamShape() StreamShape {
+	return StreamShape{Kind: h.kind, Rows: h.rows, Cols: h.cols}
+}
+
+// HashWriter writes a hash stream. Call Flush when done. A HashWriter is
+// not safe for concurrent use.
+type HashWriter struct {
+	w      *bufio.Writer
+	shapes map[StreamShape]int
+}
+
+// NewHashWriter writes the stream header for the given shape table. One
+// shape gives a homogeneous stream; several (at most 256) a heterogeneous
+// one, where each record costs one extra byte.
+func NewHashWriter(w io.Writer, shapes ...StreamShape) (*HashWriter, error) {
+	if len(shapes) == 0 || len(shapes) > maxStreamShapes {
+		return nil, fmt.Errorf("hash stream needs 1 to %d shapes, got %d", maxStreamShapes, len(shapes))
+	}
+	hw := &HashWriter{w: bufio.NewWriter(w), shapes: make(map[StreamShape]int, len(shapes))}
+	hw.w.WriteString(streamMagic)
+	hw.writeUvarint(uint64(len(shapes)))
+	for i, s := range shapes {
+		if s.Rows <= 0 || s.Cols <= 0 || s.Rows*s.Cols > MaxHashBits {
+			return nil, fmt.Errorf("invalid stream shape: (%d, %d)", s.Rows, s.Cols)
+		}
+		if _, dup := hw.shapes[s]; dup {
+			return nil, fmt.Errorf("duplicate stream shape %v", s)
+		}
+		hw.shapes[s] = i
+		hw.writeUvarint(uint64(len(s.Kind)))
+		hw.w.WriteString(string(s.Kind))
+		hw.writeUvarint(uint64(s.Rows))
+		hw.writeUvarint(uint64(s.Cols))
+	}
+	return hw, nil
+}
+
+func (hw *HashWriter) writeUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	hw.w.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+// Write appends h, whose kind and shape must be in the table
+func (hw *HashWriter) Write(h *ImageHash) error {
+	i, ok := hw.shapes[h.streamShape()]
+	if !ok {
+		return fmt.Errorf("hash %s (%d, %d) is not in the stream's shape table", h.kind, h.rows, h.cols)
+	}
+	if len(hw.shapes) > 1 {
+		hw.w.WriteByte(byte(i))
+	}
+	_, err := hw.w.Write(packBits(h.hash))
+	return err
+}
+
+// Flush writes any buffered records to the underlying writer
+func (hw *HashWriter) Flush() error {
+	return hw.w.Flush()
+}
+
+// HashReader reads a hash stream. A HashReader is not safe for concurrent
+// use.
+type HashReader struct {
+	r      *bufio.Reader
+	shapes []StreamShape
+	buf    []byte
+}
+
+// NewHashReader reads the stream header from r
+func NewHashReader(r io.Reader) (*HashReader, error) {
+	hr := &HashReader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(streamMagic))
+	if _, err := io.ReadFull(hr.r, magic); err != nil {
+		return nil, fmt.Errorf("reading hash stream header: %w", err)
+	}
+	if string(magic) != streamMagic {
+		return nil, fmt.Errorf("not a hash stream")
+	}
+
+	count, err := binary.ReadUvarint(hr.r)
+	if err != nil {
+		return nil, fmt.Errorf("reading hash stream header: %w", err)
+	}
+	if count == 0 || count > maxStreamShapes {
+		return nil, fmt.Errorf("hash stream has %d shapes", count)
+	}
+	for range count {
+		s, err := hr.readShape()
+		if err != nil {
+			return nil, fmt.Errorf("reading hash stream header: %w", err)
+		}
+		hr.shapes = append(hr.shapes, s)
+	}
+	return hr, nil
+}
+
+func (hr *HashReader) readShape() (StreamShape, error) {
+	n, err := binary.ReadUvarint(hr.r)
+	if err != nil {
+		return StreamShape{}, err
+	}
+	if n > 64 {
+		return StreamShape{}, fmt.Errorf("kind name of %d bytes", n)
+	}
+	kind := make([]byte, n)
+	if _, err := io.ReadFull(hr.r, kind); err != nil {
+		return StreamShape{}, err
+	}
+	rows, err := binary.ReadUvarint(hr.r)
+	if err != nil {
+		return StreamShape{}, err
+	}
+	cols, err := binary.ReadUvarint(hr.r)
+	if err != nil {
+		return StreamShape{}, err
+	}
+	if rows == 0 || cols == 0 || rows > MaxHashBits || cols > MaxHashBits || rows*cols > MaxHashBits {
+		return StreamShape{}, fmt.Errorf("invalid shape (%d, %d)", rows, cols)
+	}
+	return StreamShape{Kind: HashKind(kind), Rows: int(rows), Cols: int(cols)}, nil
+}
+
+// Shapes returns the stream's shape table; more than one entry means the
+// stream is heterogeneous
+func (hr *HashReader) Shapes() []StreamShape {
+	return hr.shapes
+}
+
+// Read returns the next hash, with the kind and shape of its table entry,
+// or io.EOF at the end of the stream
+func (hr *HashReader) Read() (*ImageHash, error) {
+	shape := hr.shapes[0]
+	if len(hr.shapes) > 1 {
+		i, err := hr.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if int(i) >= len(hr.shapes) {
+			return nil, fmt.Errorf("record references shape %d of %d", i, len(hr.shapes))
+		}
+		shape = hr.shapes[i]
+	}
+
+	n := shape.Rows * shape.Cols
+	if size := shape.recordBytes(); cap(hr.buf) < size {
+		hr.buf = make([]byte, size)
+	} else {
+		hr.buf = hr.buf[:size]
+	}
+	if _, err := io.ReadFull(hr.r, hr.buf); err != nil {
+		if errors.Is(err, io.EOF) && len(hr.shapes) == 1 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("truncated hash stream record: %w", err)
+	}
+	return &ImageHash{hash: unpackBits(hr.buf, n), rows: shape.Rows, cols: shape.Cols, kind: shape.Kind}, nil
+}
+
+// WriteHashes writes hashes as a hash stream. The shape table lists the
+// distinct kinds and shapes in order of first appearance, so the stream is
+// heterogeneous only if the hashes are.
+func WriteHashes(w io.Writer, hashes []*ImageHash) error {
+	var shapes []StreamShape
+	seen := make(map[StreamShape]bool)
+	for _, h := range hashes {
+		if s := h.streamShape(); !seen[s] {
+			seen[s] = true
+			shapes = append(shapes, s)
+		}
+	}
+	if len(shapes) == 0 {
+		// An empty stream still needs a valid header
+		shapes = []StreamShape{{Rows: 8, Cols: 8}}
+	}
+
+	hw, err := NewHashWriter(w, shapes...)
+	if err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if err := hw.Write(h); err != nil {
+			return err
+		}
+	}
+	return hw.Flush()
+}
+
+// ReadHashes reads every hash of a hash stream
+func ReadHashes(r io.Reader) ([]*ImageHash, error) {
+	var hashes []*ImageHash
+	for h, err := range ReadHashesSeq(r) {
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, nil
+}
+
+// ReadHashesSeq iterates over the hashes of a hash stream. A header or
+// record error is yielded once and ends the sequence; breaking out of the
+// loop stops reading from r.
+func ReadHashesSeq(r io.Reader) iter.Seq2[*ImageHash, error] {
+	return func(yield func(*ImageHash, error) bool) {
+		hr, err := NewHashReader(r)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			h, err := hr.Read()
+			if err == io.EOF {
+				return
+			}
+			if !yield(h, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestHashStream_Heterogeneous(t *testing.T) {
+	img := getBenchImage()
+	var hashes []*ImageHash
+	for i := range 4 {
+		hashes = append(hashes,
+			DifferenceHash(img, 8+i%2),
+			PerceptualHash(img, 16, 4),
+			TriDifferenceHash(img, 5, 2),
+		)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteHashes(&buf, hashes); err != nil {
+		t.Fatal(err)
+	}
+
+	hr, err := NewHashReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantShapes := []StreamShape{{KindDifference, 8, 8}, {KindPerceptual, 16, 16}, {"", 5, 10}, {KindDifference, 9, 9}}
+	if !slices.Equal(hr.Shapes(), wantShapes) {
+		t.Errorf("Shapes() = %v, want %v", hr.Shapes(), wantShapes)
+	}
+	for i, want := range hashes {
+		got, err := hr.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		rows, cols := got.Shape()
+		wantRows, wantCols := want.Shape()
+		if got.Kind() != want.Kind() || rows != wantRows || cols != wantCols {
+			t.Fatalf("record %d: %s (%d, %d), want %s (%d, %d)", i, got.Kind(), rows, cols, want.Kind(), wantRows, wantCols)
+		}
+		if d, _ := got.Distance(want); d != 0 {
+			t.Errorf("record %d differs by %d bits", i, d)
+		}
+	}
+	if _, err := hr.Read(); err != io.EOF {
+		t.Errorf("after the last record: %v, want io.EOF", err)
+	}
+}
+
+func TestHashStream_Homogeneous(t *testing.T) {
+	img := getBenchImage()
+	hashes := []*ImageHash{AverageHash(img, 8), AverageHash(img, 8), AverageHash(img, 8)}
+
+	var buf bytes.Buffer
+	if err := WriteHashes(&buf, hashes); err != nil {
+		t.Fatal(err)
+	}
+	// Header: magic, count, kind "ahash", rows, cols; then 8 bytes a record
+	if want := 4 + 1 + 1 + 5 + 1 + 1 + 3*8; buf.Len() != want {
+		t.Errorf("stream is %d bytes, want %d", buf.Len(), want)
+	}
+
+	got, err := ReadHashes(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[2].ToString() != hashes[2].ToString() || got[2].Kind() != KindAverage {
+		t.Errorf("ReadHashes = %v", got)
+	}
+
+	buf.Reset()
+	if err := WriteHashes(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadHashes(&buf); err != nil || len(got) != 0 {
+		t.Errorf("empty stream: %v, %v", got, err)
+	}
+}
+
+func TestHashStream_Errors(t *testing.T) {
+	img := getBenchImage()
+	var buf bytes.Buffer
+	if err := WriteHashes(&buf, []*ImageHash{AverageHash(img, 8), DifferenceHash(img, 8)}); err != nil {
+		t.Fatal(err)
+	}
+	stream := buf.Bytes()
+
+	if _, err := ReadHashes(bytes.NewReader(stream[:len(stream)-1])); err == nil || err == io.EOF {
+		t.Errorf("truncated record: %v, want an error", err)
+	}
+	bad := slices.Clone(stream)
+	bad[len(bad)-9] = 7 // shape index of the second record
+	if _, err := ReadHashes(bytes.NewReader(bad)); err == nil {
+		t.Error("out-of-range shape index should fail")
+	}
+	if _, err := ReadHashes(bytes.NewReader([]byte("IHS0"))); err == nil {
+		t.Error("bad magic should fail")
+	}
+
+	hw, err := NewHashWriter(io.Discard, StreamShape{KindAverage, 8, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hw.Write(DifferenceHash(img, 8)); err == nil {
+		t.Error("writing a shape missing from the table should fail")
+	}
+	if _, err := NewHashWriter(io.Discard); err == nil {
+		t.Error("empty shape table should fail")
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestReadHashesSeq(t *testing.T) {
+	hashes := randomHashes(5000, 8)
+	var buf bytes.Buffer
+	if err := WriteHashes(&buf, hashes); err != nil {
+		t.Fatal(err)
+	}
+
+	i := 0
+	for h, err := range ReadHashesSeq(bytes.NewReader(buf.Bytes())) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.ToString() != hashes[i].ToString() {
+			t.Fatalf("record %d = %s, want %s", i, h.ToString(), hashes[i].ToString())
+		}
+		i++
+	}
+	if i != len(hashes) {
+		t.Errorf("got %d hashes, want %d", i, len(hashes))
+	}
+
+	// Breaking early stops reading the underlying stream
+	cr := &countingReader{r: bytes.NewReader(buf.Bytes())}
+	for range ReadHashesSeq(cr) {
+		break
+	}
+	if cr.n >= buf.Len() {
+		t.Errorf("read %d of %d bytes after an early break", cr.n, buf.Len())
+	}
+
+	// Errors are yielded once and end the sequence
+	var errs int
+	for _, err := range ReadHashesSeq(bytes.NewReader([]byte("IHS0"))) {
+		if err == nil {
+			t.Error("bad magic yielded a hash")
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("bad magic yielded %d errors, want 1", errs)
+	}
+}
@@ -0,0 +1,87 @@
+package imagehashgo
+
+import "image"
+
+// SynthesizeFromHash builds a size x size grayscale image that hashes back
+// to h, for test fixtures that must reproduce a hash without the original
+// image. The algorithm is taken from h.Kind: KindDifference and
+// KindDifferenceVertical get a ramp per row (column) stepping up for every
+// set bit, anything else gets the AverageHash block pattern of dark and
+// light cells. Re-hashing with the same algorithm, hash size and default
+// options yields h exactly.
+//
+// The cell structure has to survive the resize, so size should be a
+// multiple of the grid: rows and cols for AverageHash, rows and cols+1
+// (rows+1 and cols) for DifferenceHash (DifferenceHashVertical), and a few
+// pixels per cell at least. PerceptualHash is not supported, and the
+// all-ones AverageHash cannot be produced by any image.
+func SynthesizeFromHash(h *ImageHash, size int) *image.Gray {
+	var grid [][]uint8
+	switch h.kind {
+	case KindDifference:
+		grid = make([][]uint8, h.rows)
+		for y := range h.rows {
+			grid[y] = ramp(h.hash[y*h.cols : (y+1)*h.cols])
+		}
+	case KindDifferenceVertical:
+		grid = make([][]uint8, h.rows+1)
+		for y := range grid {
+			grid[y] = make([]uint8, h.cols)
+		}
+		column := make([]bool, h.rows)
+		for x := range h.cols {
+			for y := range h.rows {
+				column[y] = h.hash[y*h.cols+x]
+			}
+			for y, v := range ramp(column) {
+				grid[y][x] = v
+			}
+		}
+	default:
+		grid = make([][]uint8, h.rows)
+		for y := range h.rows {
+			grid[y] = make([]uint8, h.cols)
+			for x := range h.cols {
+				if h.hash[y*h.cols+x] {
+					grid[y][x] = 255
+				}
+			}
+		}
+	}
+
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	if len(grid) == 0 || len(grid[0]) == 0 {
+		return img
+	}
+	gh, gw := len(grid), len(grid[0])
+	for y := range size {
+		row := grid[y*gh/size]
+		pix := img.Pix[y*img.Stride : y*img.Stride+size]
+		for x := range pix {
+			pix[x] = row[x*gw/size]
+		}
+	}
+	return img
+}
+
+// ramp returns len(bits)+1 values where each value is above the previous
+// one exactly when the corresponding bit is set, centered on mid-gray
+func ramp(bits []bool) []uint8 {
+	step := max(200/max(len(bits), 1), 1)
+	path := make([]int, len(bits)+1)
+	lo, hi := 0, 0
+	for i, b := range bits {
+		if b {
+			path[i+1] = path[i] + step
+		} else {
+			path[i+1] = path[i] - step
+		}
+		lo, hi = min(lo, path[i+1]), max(hi, path[i+1])
+	}
+	offset := 128 - (lo+hi)/2
+	out := make([]uint8, len(path))
+	for i, p := range path {
+		out[i] = uint8(min(max(p+offset, 0), 255))
+	}
+	return out
+}
@@ -0,0 +1,56 @@
+package imagehashgo
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestSynthesizeFromHash(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	algorithms := []struct {
+		kind HashKind
+		grid func(hashSize int) (w, h int)
+	}{
+		{KindAverage, func(n int) (int, int) { return n, n }},
+		{KindDifference, func(n int) (int, int) { return n + 1, n }},
+		{KindDifferenceVertical, func(n int) (int, int) { return n, n + 1 }},
+	}
+	for _, alg := range algorithms {
+		for _, hashSize := range []int{4, 8, 16} {
+			w, h := alg.grid(hashSize)
+			// A multiple of both grid dimensions, with a few pixels per cell
+			size := w * h
+			for size < 64 {
+				size += w * h
+			}
+			for range 50 {
+				bits := make([]bool, hashSize*hashSize)
+				for i := range bits {
+					bits[i] = rng.IntN(2) == 1
+				}
+				if alg.kind == KindAverage {
+					// The all-ones aHash is impossible
+					bits[0] = false
+				}
+				want := &ImageHash{hash: bits, rows: hashSize, cols: hashSize, kind: alg.kind}
+
+				got, err := Hash(SynthesizeFromHash(want, size), alg.kind, hashSize)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d, _ := got.Distance(want); d != 0 {
+					t.Fatalf("%s size %d: re-hash differs by %d bits\nwant %s\ngot  %s", alg.kind, hashSize, d, want.ToString(), got.ToString())
+				}
+			}
+		}
+	}
+}
+
+func TestSynthesizeFromHashAllZero(t *testing.T) {
+	want := uint64Hash(0, 8, 8)
+	want.kind = KindAverage
+	got := AverageHash(SynthesizeFromHash(want, 64), 8)
+	if d, _ := got.Distance(want); d != 0 {
+		t.Errorf("all-zero aHash re-hashed to %s", got.ToString())
+	}
+}
@@ -0,0 +1,55 @@
+package imagehashgo
+
+import "image"
+
+// textDCTFactor is the ratio between the DCT size and the hash size of
+// TextPerceptualHash, e.g. a 64x64 DCT for 8x8 hashes
+const textDCTFactor = 8
+
+// TextPerceptualHash computes a perceptual hash for screenshots and scans
+// of text. PerceptualHash keeps the lowest DCT frequencies, where pages of
+// the same layout look alike: margins and line spacing, not the words. This
+// hash thresholds the band of rows and columns hashSize to 2*hashSize-1 of
+// a hashSize*8 DCT instead, which follows the word and line structure.
+//
+// It is less robust than PerceptualHash to blur and downscaling, which
+// remove those frequencies, and its bits mean something else: only compare
+// it with other TextPerceptualHash values. Its Kind is empty.
+func TextPerceptualHash(img image.Image, hashSize int, opts ...Option) *ImageHash {
+	if hashSize < 2 {
+		hashSize = 8
+	}
+	imgSize := hashSize * textDCTFactor
+
+	o := newOptions(opts)
+	gray := resizeGray(o.fillIgnored(o.grayscale(img)), imgSize, imgSize)
+	o.captureGray(gray)
+
+	var band []float64
+	if imgSize == 64 {
+		pixelsPtr := pixelPool64.Get().(*[]float64)
+		defer pixelPool64.Put(pixelsPtr)
+		pixels := *pixelsPtr
+		for y := range 64 {
+			for x := range 64 {
+				pixels[y*64+x] = float64(gray.Pix[y*gray.Stride+x])
+			}
+		}
+		band = make([]float64, hashSize*hashSize)
+		dct2DFast64Band(pixelsPtr, hashSize, 2*hashSize, band)
+	} else {
+		matrix := make([][]float64, imgSize)
+		for y := range imgSize {
+			matrix[y] = make([]float64, imgSize)
+			for x := range imgSize {
+				matrix[y][x] = float64(gray.Pix[y*gray.Stride+x])
+			}
+		}
+		dct := DCT2D(matrix)
+		band = make([]float64, 0, hashSize*hashSize)
+		for y := hashSize; y < 2*hashSize; y++ {
+			band = append(band, dct[y][hashSize:2*hashSize]...)
+		}
+	}
+	return thresholdHash(band, hashSize, hashSize, o.median.Median)
+}
@@ -0,0 +1,152 @@
+package imagehashgo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math/rand/v2"
+	"testing"
+)
+
+// textPage renders a page of a document: the same margins, line spacing
+// and paragraph layout for every seed, with words of 5x7 pseudo-glyphs
+// whose strokes depend on the seed, like different pages of one document
+func textPage(seed uint64) *image.Gray {
+	const w, h = 320, 400
+	page := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+
+	r := rand.New(rand.NewPCG(seed, 955))
+	for line := range 24 {
+		y0 := 24 + line*14
+		if line%8 == 7 {
+			continue // paragraph break
+		}
+		for x0 := 24; x0 < w-24; {
+			word := 2 + r.IntN(7)
+			if x0+word*6 > w-24 {
+				break
+			}
+			for range word {
+				glyph := r.Uint64()
+				for gy := range 7 {
+					for gx := range 5 {
+						if glyph>>(gy*5+gx)&1 == 1 {
+							page.SetGray(x0+gx, y0+gy, color.Gray{})
+						}
+					}
+				}
+				x0 += 6
+			}
+			x0 += 6
+		}
+	}
+	return page
+}
+
+func recompress(t *testing.T, img image.Image, quality int) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// separation returns, over pages of one synthetic document, the smallest
+// distance between different pages, the largest distance between a page
+// and its JPEG recompression, and how many page pairs are within
+// threshold of each other
+func separation(t *testing.T, pages, threshold int, hash func(image.Image) *ImageHash) (minBetween, maxWithin, collisions int) {
+	t.Helper()
+	var hashes []*ImageHash
+	minBetween = -1
+	for i := range pages {
+		page := textPage(uint64(i))
+		h := hash(page)
+		d, err := h.Distance(hash(recompress(t, page, 75)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		maxWithin = max(maxWithin, d)
+		for _, other := range hashes {
+			d, _ := h.Distance(other)
+			if minBetween < 0 || d < minBetween {
+				minBetween = d
+			}
+			if d <= threshold {
+				collisions++
+			}
+		}
+		hashes = append(hashes, h)
+	}
+	return minBetween, maxWithin, collisions
+}
+
+// TestTextPerceptualHash_Separation compares PerceptualHash and
+// TextPerceptualHash on pages of one synthetic document. With pHash more
+// than half of the page pairs are within 10 bits; the text hash keeps every
+// pair further apart than any page is from its recompressed copy.
+func TestTextPerceptualHash_Separation(t *testing.T) {
+	const pages, threshold = 30, 10
+	pMin, pMax, pCollisions := separation(t, pages, threshold, func(img image.Image) *ImageHash {
+		return PerceptualHash(img, 8, 4)
+	})
+	tMin, tMax, tCollisions := separation(t, pages, threshold, func(img image.Image) *ImageHash {
+		return TextPerceptualHash(img, 8)
+	})
+	pairs := pages * (pages - 1) / 2
+	t.Logf("phash: different pages >= %d bits, copies <= %d bits, %d/%d pairs within %d", pMin, pMax, pCollisions, pairs, threshold)
+	t.Logf("text:  different pages >= %d bits, copies <= %d bits, %d/%d pairs within %d", tMin, tMax, tCollisions, pairs, threshold)
+
+	if tMin <= tMax {
+		t.Errorf("text hash: closest pages %d bits apart, not more than the %d bits of a recompressed copy", tMin, tMax)
+	}
+	if tCollisions != 0 || tCollisions >= pCollisions {
+		t.Errorf("text hash: %d pairs within %d bits, want 0 and fewer than pHash's %d", tCollisions, threshold, pCollisions)
+	}
+}
+
+func TestTextPerceptualHash_Sizes(t *testing.T) {
+	a, b := textPage(1), textPage(2)
+	for _, size := range []int{4, 8, 16} {
+		ha, hb := TextPerceptualHash(a, size), TextPerceptualHash(b, size)
+		if ha.rows != size || ha.cols != size || ha.Kind() != "" {
+			t.Errorf("size %d: got %dx%d hash of kind %q", size, ha.rows, ha.cols, ha.Kind())
+		}
+		if d, _ := ha.Distance(hb); d < size*size/8 {
+			t.Errorf("size %d: different pages only %d bits apart", size, d)
+		}
+		if again := TextPerceptualHash(a, size); again.ToString() != ha.ToString() {
+			t.Errorf("size %d: hash is not deterministic", size)
+		}
+	}
+}
+
+// TestTextPerceptualHash_FastMatchesDCT2D checks the 64x64 fast path
+// against the general DCT
+func TestTextPerceptualHash_FastMatchesDCT2D(t *testing.T) {
+	gray := resizeGray(textPage(3), 64, 64)
+	matrix := make([][]float64, 64)
+	for y := range 64 {
+		matrix[y] = make([]float64, 64)
+		for x := range 64 {
+			matrix[y][x] = float64(gray.Pix[y*gray.Stride+x])
+		}
+	}
+	dct := DCT2D(matrix)
+	var band []float64
+	for y := 8; y < 16; y++ {
+		band = append(band, dct[y][8:16]...)
+	}
+	want := thresholdHash(band, 8, 8, Median)
+	if got := TextPerceptualHash(textPage(3), 8); got.ToString() != want.ToString() {
+		t.Errorf("fast path %s, DCT2D %s", got.ToString(), want.ToString())
+	}
+}
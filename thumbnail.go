@@ -0,0 +1,80 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// thumbnailFactor is how many times the working size of a hash, along both
+// sides, a thumbnail must be for HashAndThumbnail to hash the thumbnail
+// instead of the image
+const thumbnailFactor = 4
+
+// HashAndThumbnail computes a hash of the given kind, as Hash does, and an
+// *image.NRGBA thumbnail of img whose longest side is at most thumbMax
+// pixels, for applications that display what they hash. The thumbnail is
+// resampled with Lanczos, or copied when img already fits.
+//
+// The thumbnail is made first. When it is at least four times the size of
+// the image the hash is computed from (e.g. 9x8 pixels for an 8-bit
+// DifferenceHash, 32x32 for PerceptualHash) along both sides, the hash is
+// computed from it, so the full image is resized once; otherwise, and
+// under WithPreprocess, the hash is computed from img. A hash from the
+// thumbnail sees pixels resized twice and differs from Hash of img in at
+// most 2 bits on the test corpus, typically none: compare it with a
+// threshold, not for equality.
+func HashAndThumbnail(img image.Image, kind HashKind, hashSize, thumbMax int, opts ...Option) (*ImageHash, image.Image, error) {
+	if _, err := ParseHashKind(string(kind)); err != nil {
+		return nil, nil, err
+	}
+	if thumbMax < 1 {
+		return nil, nil, fmt.Errorf("thumbnail size must be at least 1, got %d", thumbMax)
+	}
+
+	thumb := Thumbnail(img, thumbMax)
+	src := img
+	o := newOptions(opts)
+	if o.preprocess == nil && thumb.Rect.Size() != img.Bounds().Size() {
+		w, h := o.workingSize(img, kind, hashSize)
+		tw, th := o.workingSize(thumb, kind, hashSize)
+		// Aspect buckets may lay a slightly different ratio on another grid
+		if tw == w && th == h && thumb.Rect.Dx() >= thumbnailFactor*w && thumb.Rect.Dy() >= thumbnailFactor*h {
+			src = thumb
+		}
+	}
+	hash, err := Hash(src, kind, hashSize, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return hash, thumb, nil
+}
+
+// Thumbnail returns an *image.NRGBA copy of img scaled down, with Lanczos
+// resampling, so that its longest side is at most maxSide pixels. It is the
+// resize of HashAndThumbnail, for thumbnails made apart from hashing.
+// Images that already fit are copied unscaled, and a maxSide below 1 gives
+// an empty image.
+func Thumbnail(img image.Image, maxSide int) *image.NRGBA {
+	return imaging.Fit(img, maxSide, maxSide, imaging.Lanczos)
+}
+
+// workingSize returns the size of the grayscale image a hash of the given
+// kind of img is computed from
+func (o options) workingSize(img image.Image, kind HashKind, hashSize int) (w, h int) {
+	if hashSize < 2 {
+		hashSize = 8
+	}
+	if kind == KindPerceptual {
+		return 4 * hashSize, 4 * hashSize
+	}
+	rows, cols := o.grid(img, hashSize)
+	switch kind {
+	case KindDifference:
+		return cols + 1, rows
+	case KindDifferenceVertical:
+		return cols, rows + 1
+	}
+	return cols, rows
+}
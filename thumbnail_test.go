@@ -0,0 +1,86 @@
+package imagehashgo
+
+import (
+	"image"
+	"testing"
+)
+
+// The hash from a thumbnail sees the pixels resized twice; on the corpus
+// it stays within 2 bits of Hash, and equals it when the thumbnail is too
+// small to hash from
+func TestHashAndThumbnail_Deviation(t *testing.T) {
+	for name, img := range precomputeInputs(t) {
+		for _, kind := range rawKinds {
+			for _, thumbMax := range []int{64, 160, 256, 512} {
+				got, thumb, err := HashAndThumbnail(img, kind, 8, thumbMax)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b := thumb.Bounds()
+				if max(b.Dx(), b.Dy()) > thumbMax || b.Min != (image.Point{}) {
+					t.Errorf("%s thumbnail %d: bounds %v", name, thumbMax, b)
+				}
+				want, _ := Hash(img, kind, 8)
+				d, err := got.Distance(want)
+				if err != nil {
+					t.Fatal(err)
+				}
+				w, h := options{}.workingSize(img, kind, 8)
+				exact := b.Dx() < thumbnailFactor*w || b.Dy() < thumbnailFactor*h || b.Size() == img.Bounds().Size()
+				if got.Kind() != kind || d > 2 || exact && d != 0 {
+					t.Errorf("%s %s thumbnail %d: hash %s, Hash %s, %d bits apart", name, kind, thumbMax, got.ToString(), want.ToString(), d)
+				}
+			}
+		}
+	}
+}
+
+func TestHashAndThumbnail_Options(t *testing.T) {
+	img := getBenchImage()
+	// Preprocessing is applied to the image, not the thumbnail
+	opts := []Option{WithPreprocess(NewPreprocess(AutoCrop(8)))}
+	got, _, err := HashAndThumbnail(img, KindDifference, 8, 512, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := Hash(img, KindDifference, 8, opts...); got.ToString() != want.ToString() {
+		t.Errorf("with preprocessing: hash %s, Hash %s", got.ToString(), want.ToString())
+	}
+
+	// The grid of an aspect bucket is kept
+	pano := noiseImage(960, 120, 964)
+	got, _, err = HashAndThumbnail(pano, KindAverage, 8, 512, WithAspectBuckets())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := Hash(pano, KindAverage, 8, WithAspectBuckets()); got.rows != want.rows || got.cols != want.cols {
+		t.Errorf("aspect buckets: shape %dx%d, Hash %dx%d", got.rows, got.cols, want.rows, want.cols)
+	}
+
+	if _, _, err := HashAndThumbnail(img, KindAverage, 8, 0); err == nil {
+		t.Error("thumbnail size 0 expected error")
+	}
+	if _, _, err := HashAndThumbnail(img, "whash", 8, 128); err == nil {
+		t.Error("unknown kind expected error")
+	}
+	if _, _, err := HashAndThumbnail(img, KindAverage, MaxHashSize+1, 128); err == nil {
+		t.Error("oversized hash expected error")
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	for _, tt := range []struct {
+		w, h, maxSide int
+		want          image.Point
+	}{
+		{400, 100, 128, image.Pt(128, 32)},
+		{100, 400, 128, image.Pt(32, 128)},
+		{64, 48, 128, image.Pt(64, 48)},
+		{64, 48, 0, image.Point{}},
+	} {
+		img := noiseImage(tt.w, tt.h, 1)
+		if got := Thumbnail(img, tt.maxSide).Rect.Size(); got != tt.want {
+			t.Errorf("Thumbnail(%dx%d, %d) size %v, want %v", tt.w, tt.h, tt.maxSide, got, tt.want)
+		}
+	}
+}
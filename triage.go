@@ -0,0 +1,184 @@
+package imagehashgo
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Defaults of TriageOptions
+const (
+	DefaultTriageSampleEvery   = 10
+	DefaultTriageSizeTolerance = 0.01
+	DefaultTriageChecksumBytes = 64 << 10
+)
+
+// TriageOptions configures TriageScan. Zero fields use the defaults.
+type TriageOptions struct {
+	// SampleEvery selects every Nth file, in walk order, whatever its size,
+	// so that near-duplicates re-encoded to very different sizes still have
+	// a chance to be found. Negative disables sampling.
+	SampleEvery int
+	// SizeTolerance is the relative size difference, of the larger file,
+	// within which two files are duplicate candidates
+	SizeTolerance float64
+	// ChecksumBytes is how much of the start and of the end of equally
+	// sized files is compared to find exact copies
+	ChecksumBytes int64
+	// Match selects the files to triage; nil selects every regular file
+	Match func(path string) bool
+}
+
+// TriagePlan is the result of TriageScan
+type TriagePlan struct {
+	// Hash lists, sorted, the files that need a full perceptual hash: the
+	// sampled files and those with a size close to another file's, except
+	// exact copies after the first
+	Hash []string
+	// Exact groups the files of equal size whose first and last
+	// ChecksumBytes are equal, sorted. They are almost certainly copies,
+	// so only the first of each group is in Hash and its hash can be
+	// reused for the others.
+	Exact [][]string
+	// Files is the number of files the walk selected
+	Files int
+}
+
+// Selected reports whether path is in p.Hash
+func (p *TriagePlan) Selected(path string) bool {
+	_, found := slices.BinarySearch(p.Hash, path)
+	return found
+}
+
+// TriageScan is a quick first pass over a large tree: it reads only file
+// sizes, and the start and end of equally sized files, to plan which files
+// a full hashing pass needs to look at. Files without a size neighbour are
+// left out unless sampled, on the premise that most duplicates in an
+// archive are copies or light re-encodings.
+func TriageScan(ctx context.Context, root string, opts TriageOptions) (TriagePlan, error) {
+	if opts.SampleEvery == 0 {
+		opts.SampleEvery = DefaultTriageSampleEvery
+	}
+	if opts.SizeTolerance <= 0 {
+		opts.SizeTolerance = DefaultTriageSizeTolerance
+	}
+	if opts.ChecksumBytes <= 0 {
+		opts.ChecksumBytes = DefaultTriageChecksumBytes
+	}
+
+	type file struct {
+		path string
+		size int64
+	}
+	var files []file
+	selected := make(map[string]bool)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || (opts.Match != nil && !opts.Match(path)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if opts.SampleEvery > 0 && len(files)%opts.SampleEvery == 0 {
+			selected[path] = true
+		}
+		files = append(files, file{path, info.Size()})
+		return nil
+	})
+	if err != nil {
+		return TriagePlan{}, err
+	}
+
+	// In size order, a file has a neighbour within the tolerance exactly
+	// when one of its adjacent files is within it
+	slices.SortStableFunc(files, func(a, b file) int {
+		return cmp.Compare(a.size, b.size)
+	})
+	near := func(a, b file) bool {
+		return float64(b.size-a.size) <= opts.SizeTolerance*float64(b.size)
+	}
+	for i, f := range files {
+		if (i > 0 && near(files[i-1], f)) || (i+1 < len(files) && near(f, files[i+1])) {
+			selected[f.path] = true
+		}
+	}
+
+	var exact [][]string
+	for i := 0; i < len(files); {
+		j := i + 1
+		for j < len(files) && files[j].size == files[i].size {
+			j++
+		}
+		if j-i > 1 {
+			sums := make(map[[sha256.Size]byte][]string)
+			for _, f := range files[i:j] {
+				if err := ctx.Err(); err != nil {
+					return TriagePlan{}, err
+				}
+				sum, err := headTailSum(f.path, f.size, opts.ChecksumBytes)
+				if err != nil {
+					return TriagePlan{}, err
+				}
+				sums[sum] = append(sums[sum], f.path)
+			}
+			for _, group := range sums {
+				if len(group) > 1 {
+					slices.Sort(group)
+					exact = append(exact, group)
+				}
+			}
+		}
+		i = j
+	}
+	slices.SortFunc(exact, func(a, b []string) int {
+		return strings.Compare(a[0], b[0])
+	})
+	for _, group := range exact {
+		for _, path := range group[1:] {
+			delete(selected, path)
+		}
+	}
+
+	plan := TriagePlan{Exact: exact, Files: len(files)}
+	for path := range selected {
+		plan.Hash = append(plan.Hash, path)
+	}
+	slices.Sort(plan.Hash)
+	return plan, nil
+}
+
+// headTailSum returns the SHA-256 of the first and last n bytes of a file
+// of the given size, or of the whole file when it is not longer than 2n
+func headTailSum(path string, size, n int64) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if size <= 2*n {
+		_, err = io.Copy(h, f)
+	} else if _, err = io.CopyN(h, f, n); err == nil {
+		_, err = io.Copy(h, io.NewSectionReader(f, size-n, n))
+	}
+	if err != nil {
+		return sum, err
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
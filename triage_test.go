@@ -0,0 +1,162 @@
+package imagehashgo
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeTriageTree writes files of the given sizes under a temp dir, with
+// contents derived from their seeds, and returns the dir
+func writeTriageTree(t *testing.T, files map[string]struct {
+	size int
+	seed uint64
+}) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, f := range files {
+		data := make([]byte, f.size)
+		r := rand.New(rand.NewPCG(f.seed, 957))
+		for i := range data {
+			data[i] = byte(r.Uint32())
+		}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestTriageScan(t *testing.T) {
+	type file = struct {
+		size int
+		seed uint64
+	}
+	root := writeTriageTree(t, map[string]file{
+		// Exact copies, one in a subdirectory
+		"a.jpg":         {40000, 1},
+		"copies/a.jpg":  {40000, 1},
+		"copies/a2.jpg": {40000, 1},
+		"b.jpg":         {60000, 2},
+		"copies/b.jpg":  {60000, 2},
+		// Near-duplicates: re-encodings within 1% of each other
+		"near/c.jpg":     {100000, 3},
+		"near/c-q90.jpg": {100600, 4},
+		// Same size, different content: candidates but not exact copies
+		"d1.jpg": {8000, 5},
+		"d2.jpg": {8000, 6},
+		// Unique sizes
+		"u1.jpg":      {1000, 7},
+		"u2.jpg":      {3000, 8},
+		"u3.jpg":      {20000, 9},
+		"notes.txt":   {500, 10},
+		"near/u4.jpg": {250000, 11},
+	})
+	rel := func(paths ...string) []string {
+		for i, p := range paths {
+			paths[i] = filepath.Join(root, filepath.FromSlash(p))
+		}
+		return paths
+	}
+	opts := TriageOptions{
+		SampleEvery: -1,
+		Match:       func(path string) bool { return filepath.Ext(path) == ".jpg" },
+	}
+
+	plan, err := TriageScan(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Files != 13 {
+		t.Errorf("Files = %d, want 13", plan.Files)
+	}
+	wantHash := rel("a.jpg", "b.jpg", "d1.jpg", "d2.jpg", "near/c-q90.jpg", "near/c.jpg")
+	slices.Sort(wantHash)
+	if !slices.Equal(plan.Hash, wantHash) {
+		t.Errorf("Hash = %v\nwant %v", plan.Hash, wantHash)
+	}
+	wantExact := [][]string{rel("a.jpg", "copies/a.jpg", "copies/a2.jpg"), rel("b.jpg", "copies/b.jpg")}
+	if !slices.EqualFunc(plan.Exact, wantExact, slices.Equal) {
+		t.Errorf("Exact = %v\nwant %v", plan.Exact, wantExact)
+	}
+	for _, path := range wantHash {
+		if !plan.Selected(path) {
+			t.Errorf("Selected(%s) = false", path)
+		}
+	}
+	if plan.Selected(rel("u1.jpg")[0]) || plan.Selected(rel("copies/a.jpg")[0]) {
+		t.Error("Selected() accepts a pruned file")
+	}
+
+	// Sampling adds every Nth file in walk order, but never exact copies
+	opts.SampleEvery = 3
+	sampled, err := TriageScan(context.Background(), root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Walk order: a, b, copies/a, copies/a2, copies/b, d1, d2, near/c-q90,
+	// near/c, near/u4, u1, u2, u3
+	for _, path := range rel("near/u4.jpg", "u3.jpg") {
+		if !sampled.Selected(path) {
+			t.Errorf("sampled plan misses %s", path)
+		}
+	}
+	if sampled.Selected(rel("copies/a2.jpg")[0]) || len(sampled.Hash) != len(plan.Hash)+2 {
+		t.Errorf("sampled Hash = %v", sampled.Hash)
+	}
+}
+
+// TestTriageScan_HeadTail checks that only the start and end of a file
+// decide whether it is an exact copy
+func TestTriageScan_HeadTail(t *testing.T) {
+	root := writeTriageTree(t, map[string]struct {
+		size int
+		seed uint64
+	}{"x": {3000, 1}, "y": {3000, 1}, "z": {3000, 1}})
+	path := filepath.Join(root, "y")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[1500]++ // the middle is not read
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path = filepath.Join(root, "z")
+	data[2999]++ // the end is
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := TriageScan(context.Background(), root, TriageOptions{SampleEvery: -1, ChecksumBytes: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{filepath.Join(root, "x"), filepath.Join(root, "y")}}
+	if !slices.EqualFunc(plan.Exact, want, slices.Equal) {
+		t.Errorf("Exact = %v, want %v", plan.Exact, want)
+	}
+}
+
+func TestTriageScan_Canceled(t *testing.T) {
+	root := writeTriageTree(t, map[string]struct {
+		size int
+		seed uint64
+	}{"x": {10, 1}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := TriageScan(ctx, root, TriageOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("TriageScan() error = %v, want context.Canceled", err)
+	}
+	if _, err := TriageScan(context.Background(), filepath.Join(root, "missing"), TriageOptions{}); err == nil {
+		t.Error("TriageScan() of a missing root returned no error")
+	}
+}
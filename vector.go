@@ -0,0 +1,59 @@
+package imagehashgo
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// GrayVector returns the AverageHash input before thresholding: the
+// hashSize x hashSize resized grayscale cells, row-major, scaled to [0, 1].
+// Use it to re-rank candidates found by Hamming distance with L1Distance or
+// L2Distance, which keep the magnitudes the hash bits throw away.
+//
+// The vector is deterministic but is not a hash: it may change between
+// versions of this package, so do not store it alongside hashes.
+func GrayVector(img image.Image, hashSize int, opts ...Option) ([]float64, error) {
+	if hashSize < 2 {
+		return nil, fmt.Errorf("invalid hash size: %d", hashSize)
+	}
+	if img.Bounds().Empty() {
+		return nil, fmt.Errorf("empty image")
+	}
+
+	o := newOptions(opts)
+	resized := o.resize(o.grayscale(img), hashSize, hashSize)
+	v := make([]float64, 0, hashSize*hashSize)
+	for y := range hashSize {
+		for _, p := range resized.Pix[y*resized.Stride : y*resized.Stride+hashSize] {
+			v = append(v, float64(p)/255)
+		}
+	}
+	return v, nil
+}
+
+// L1Distance returns the Manhattan distance between two vectors of the
+// same length, e.g. from GrayVector
+func L1Distance(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("vectors must have the same length: %d vs %d", len(a), len(b))
+	}
+	var d float64
+	for i := range a {
+		d += math.Abs(a[i] - b[i])
+	}
+	return d, nil
+}
+
+// L2Distance returns the Euclidean distance between two vectors of the
+// same length, e.g. from GrayVector
+func L2Distance(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("vectors must have the same length: %d vs %d", len(a), len(b))
+	}
+	var d float64
+	for i := range a {
+		d += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(d), nil
+}
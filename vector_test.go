@@ -0,0 +1,91 @@
+package imagehashgo
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// flattened returns img with its contrast around mid-gray reduced by
+// percent
+func flattened(img *image.Gray, percent int) *image.Gray {
+	out := image.NewGray(img.Bounds())
+	for i, p := range img.Pix {
+		out.Pix[i] = uint8(128 + (int(p)-128)*(100-percent)/100)
+	}
+	return out
+}
+
+// Copies with increasingly reduced contrast all hash like the original,
+// but GrayVector's L1 distance orders them by strength
+func TestGrayVectorReRank(t *testing.T) {
+	want := uint64Hash(0x0123456789abcdef, 8, 8)
+	want.kind = KindAverage
+	base := SynthesizeFromHash(want, 64)
+	vBase, err := GrayVector(base, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := 0.0
+	for _, percent := range []int{5, 20, 40, 80} {
+		img := flattened(base, percent)
+		if d, _ := AverageHash(img, 8).Distance(want); d != 0 {
+			t.Fatalf("flattening %d%% changed the hash by %d bits, Hamming does not tie", percent, d)
+		}
+		v, err := GrayVector(img, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l1, err := L1Distance(vBase, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l1 <= prev {
+			t.Errorf("flattening %d%%: L1 %.4f not above the weaker one's %.4f", percent, l1, prev)
+		}
+		prev = l1
+	}
+}
+
+func TestGrayVector(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 40, 40))
+	for i := range img.Pix {
+		img.Pix[i] = 51
+	}
+	v, err := GrayVector(img, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 16 {
+		t.Fatalf("len = %d, want 16", len(v))
+	}
+	for _, x := range v {
+		if math.Abs(x-0.2) > 1e-9 {
+			t.Errorf("cell = %v, want 0.2", x)
+		}
+	}
+
+	if _, err := GrayVector(img, 1); err == nil {
+		t.Error("expected an error for hash size 1")
+	}
+	if _, err := GrayVector(image.NewGray(image.Rectangle{}), 8); err == nil {
+		t.Error("expected an error for an empty image")
+	}
+}
+
+func TestL1L2Distance(t *testing.T) {
+	a, b := []float64{0, 0, 1}, []float64{0.3, 0.4, 1}
+	if d, err := L1Distance(a, b); err != nil || math.Abs(d-0.7) > 1e-12 {
+		t.Errorf("L1Distance = %v, %v, want 0.7", d, err)
+	}
+	if d, err := L2Distance(a, b); err != nil || math.Abs(d-0.5) > 1e-12 {
+		t.Errorf("L2Distance = %v, %v, want 0.5", d, err)
+	}
+	if _, err := L1Distance(a, b[:2]); err == nil {
+		t.Error("L1Distance: expected an error for different lengths")
+	}
+	if _, err := L2Distance(a, b[:2]); err == nil {
+		t.Error("L2Distance: expected an error for different lengths")
+	}
+}
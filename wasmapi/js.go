@@ -0,0 +1,42 @@
+//go:build js && wasm
+
+package wasmapi
+
+import "syscall/js"
+
+// Register sets hashRGBA and distance on obj:
+//
+//	imagehash.hashRGBA(imageData.data, width, height, "phash", 8) // {hex} or {error}
+//	imagehash.distance(hexA, hexB)                                // {distance} or {error}
+//
+// The pixels may be a Uint8Array or a Uint8ClampedArray. Each function
+// returns an object holding either its result or an error message.
+func Register(obj js.Value) {
+	obj.Set("hashRGBA", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) != 5 {
+			return jsError("hashRGBA takes pixels, width, height, kind and hash size")
+		}
+		pix := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(pix, args[0])
+		hex, err := HashRGBA(pix, args[1].Int(), args[2].Int(), args[3].String(), args[4].Int())
+		if err != nil {
+			return jsError(err.Error())
+		}
+		return map[string]any{"hex": hex}
+	}))
+	obj.Set("distance", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if len(args) != 2 {
+			return jsError("distance takes two hex hashes")
+		}
+		d, err := Distance(args[0].String(), args[1].String())
+		if err != nil {
+			return jsError(err.Error())
+		}
+		return map[string]any{"distance": d}
+	}))
+}
+
+// jsError is the result object of a failed call
+func jsError(msg string) map[string]any {
+	return map[string]any{"error": msg}
+}
@@ -0,0 +1,61 @@
+// Package wasmapi exposes hashing over byte slices and strings only, for
+// WebAssembly builds where the host already holds decoded pixels, such as
+// the RGBA ImageData of a browser canvas, and decoding in Go would be slow.
+// The functions here are plain Go; Register, in a file built only for
+// GOOS=js GOARCH=wasm, makes them callable from JavaScript:
+//
+//	//go:build js && wasm
+//
+//	func main() {
+//		api := js.Global().Get("Object").New()
+//		wasmapi.Register(api)
+//		js.Global().Set("imagehash", api)
+//		select {}
+//	}
+package wasmapi
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+// HashRGBA hashes width x height pixels of unpremultiplied 8-bit RGBA, 4
+// bytes per pixel with no row padding, as in the data of a canvas
+// ImageData. kind is a HashKind name (ahash, phash, dhash, dhash_v), and
+// the result is the hash as ImageHash.ToString gives it. pix is only read.
+func HashRGBA(pix []byte, width, height int, kind string, hashSize int) (hex string, err error) {
+	if width < 1 || height < 1 {
+		return "", fmt.Errorf("image size %dx%d is empty or negative", width, height)
+	}
+	if width > math.MaxInt/4/height || len(pix) != 4*width*height {
+		return "", fmt.Errorf("%d bytes are not %dx%d RGBA pixels", len(pix), width, height)
+	}
+	k, err := imagehashgo.ParseHashKind(kind)
+	if err != nil {
+		return "", err
+	}
+	img := &image.NRGBA{Pix: pix, Stride: 4 * width, Rect: image.Rect(0, 0, width, height)}
+	h, err := imagehashgo.Hash(img, k, hashSize)
+	if err != nil {
+		return "", err
+	}
+	return h.ToString(), nil
+}
+
+// Distance returns the Hamming distance between two hashes given as hex
+// strings, as HashRGBA returns them. Hashes of different sizes are an
+// error.
+func Distance(hexA, hexB string) (int, error) {
+	a, err := imagehashgo.HexToHash(hexA)
+	if err != nil {
+		return 0, err
+	}
+	b, err := imagehashgo.HexToHash(hexB)
+	if err != nil {
+		return 0, err
+	}
+	return a.Distance(b)
+}
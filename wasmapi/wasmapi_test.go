@@ -0,0 +1,65 @@
+package wasmapi
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	imagehashgo "github.com/K0ng2/imagehash-go"
+)
+
+func TestHashRGBA_MatchesHash(t *testing.T) {
+	const w, h = 97, 61
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 3), uint8(y * 4), uint8(x ^ y), uint8(128 + x%128)})
+		}
+	}
+	for _, kind := range []string{"ahash", "phash", "dhash", "dhash_v"} {
+		got, err := HashRGBA(img.Pix, w, h, kind, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := imagehashgo.Hash(img, imagehashgo.HashKind(kind), 8)
+		if got != want.ToString() {
+			t.Errorf("%s: HashRGBA = %s, Hash = %s", kind, got, want.ToString())
+		}
+		if d, err := Distance(got, want.ToString()); err != nil || d != 0 {
+			t.Errorf("%s: Distance to itself = %d, %v", kind, d, err)
+		}
+	}
+}
+
+func TestHashRGBA_Errors(t *testing.T) {
+	pix := make([]byte, 4*10*8)
+	for name, args := range map[string][2]int{
+		"zero width":   {0, 8},
+		"negative":     {10, -8},
+		"short":        {10, 9},
+		"long":         {10, 7},
+		"overflowing":  {1 << 62, 8},
+		"huge product": {1 << 31, 1 << 31},
+	} {
+		if _, err := HashRGBA(pix, args[0], args[1], "ahash", 8); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := HashRGBA(pix, 10, 8, "whash", 8); err == nil {
+		t.Error("unknown kind expected error")
+	}
+	if _, err := HashRGBA(pix, 10, 8, "ahash", imagehashgo.MaxHashSize+1); err == nil {
+		t.Error("oversized hash expected error")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	if d, err := Distance("ffff0000ffff0000", "ffff0000ffff0001"); err != nil || d != 1 {
+		t.Errorf("Distance = %d, %v, want 1", d, err)
+	}
+	for _, pair := range [][2]string{{"ffff", "ffff0000ffff0000"}, {"xyz", "ffff"}, {"ffff", ""}} {
+		if _, err := Distance(pair[0], pair[1]); err == nil {
+			t.Errorf("Distance(%q, %q) expected error", pair[0], pair[1])
+		}
+	}
+}